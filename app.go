@@ -21,7 +21,13 @@ type App struct {
 
 func NewApp() *App { return &App{} }
 
-func (a *App) startup(ctx context.Context) { a.ctx = ctx }
+func (a *App) startup(ctx context.Context) {
+	a.ctx = ctx
+	// Resume turns that were still queued when the app last exited.
+	if a.agentService != nil {
+		_ = a.agentService.ResumeQueuedTurns(context.Background())
+	}
+}
 
 // Context exposes the Wails runtime context to dependent services.
 func (a *App) Context() context.Context { return a.ctx }
@@ -0,0 +1,96 @@
+package agents
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// AdaptersConfigFile is the file under the data directory listing extra agent
+// adapters registered next to the built-in "codex" one.
+const AdaptersConfigFile = "agents.json"
+
+// AdapterTypeProcess launches an executable speaking the JSONL process protocol.
+const AdapterTypeProcess = "process"
+
+// AdapterConfig describes one configured adapter. Command, Args and Env apply
+// to process adapters; BaseURL, APIKey and Model to OpenAI-compatible ones.
+type AdapterConfig struct {
+	ID      string            `json:"id"`
+	Type    string            `json:"type,omitempty"`
+	Command string            `json:"command,omitempty"`
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+	BaseURL string            `json:"baseUrl,omitempty"`
+	APIKey  string            `json:"apiKey,omitempty"`
+	Model   string            `json:"model,omitempty"`
+}
+
+type adaptersFile struct {
+	Adapters []AdapterConfig `json:"adapters"`
+}
+
+// LoadAdapterConfigs reads adapter definitions from path. A missing file
+// yields no adapters.
+func LoadAdapterConfigs(path string) ([]AdapterConfig, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read adapters config: %w", err)
+	}
+	var file adaptersFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("decode adapters config %s: %w", path, err)
+	}
+	seen := make(map[string]bool, len(file.Adapters))
+	for i := range file.Adapters {
+		cfg := &file.Adapters[i]
+		cfg.ID = strings.TrimSpace(cfg.ID)
+		cfg.Type = strings.TrimSpace(cfg.Type)
+		if cfg.ID == "" {
+			return nil, fmt.Errorf("adapter %d in %s has no id", i, path)
+		}
+		if seen[cfg.ID] {
+			return nil, fmt.Errorf("adapter id %q is defined twice in %s", cfg.ID, path)
+		}
+		seen[cfg.ID] = true
+	}
+	return file.Adapters, nil
+}
+
+// NewAdapterFromConfig builds the adapter described by cfg. Adapters that keep
+// local state store it under dataDir.
+func NewAdapterFromConfig(cfg AdapterConfig, dataDir string) (Adapter, error) {
+	switch cfg.Type {
+	case "", AdapterTypeProcess:
+		return NewProcessAdapter(cfg.Command, cfg.Args, envList(cfg.Env))
+	case AdapterTypeOpenAI:
+		return NewOpenAIAdapter(OpenAIAdapterOptions{
+			BaseURL:    os.ExpandEnv(cfg.BaseURL),
+			APIKey:     os.ExpandEnv(cfg.APIKey),
+			Model:      cfg.Model,
+			HistoryDir: filepath.Join(dataDir, "chat-history", cfg.ID),
+		})
+	default:
+		return nil, fmt.Errorf("adapter %q has unsupported type %q", cfg.ID, cfg.Type)
+	}
+}
+
+func envList(env map[string]string) []string {
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	list := make([]string, 0, len(keys))
+	for _, key := range keys {
+		list = append(list, key+"="+os.ExpandEnv(env[key]))
+	}
+	return list
+}
@@ -2,10 +2,10 @@ package agents
 
 import (
     "context"
+    "errors"
     "fmt"
-    "strings"
 
-    "codex-ui/internal/git/worktrees"
+    "codex-ui/internal/events"
     "codex-ui/internal/storage/discovery"
     "codex-ui/internal/watchers"
     "codex-ui/internal/logging"
@@ -20,29 +20,56 @@ type API struct {
     watch *watchers.Service
     ctxFn func() context.Context
     log   logging.Logger
+    hub   *events.Hub
 }
 
-func NewAPI(svc *Service, repo *discovery.Repository, watch *watchers.Service, ctxProvider func() context.Context, logger logging.Logger) *API {
+// APIOption configures optional API dependencies.
+type APIOption func(*API)
+
+// WithEventHub routes runtime events through hub so that subscribers other
+// than the webview (e.g. the local API server) receive them too.
+func WithEventHub(hub *events.Hub) APIOption { return func(a *API) { a.hub = hub } }
+
+func NewAPI(svc *Service, repo *discovery.Repository, watch *watchers.Service, ctxProvider func() context.Context, logger logging.Logger, opts ...APIOption) *API {
     if logger == nil { logger = logging.Nop() }
-    return &API{svc: svc, repo: repo, watch: watch, ctxFn: ctxProvider, log: logger}
+    api := &API{svc: svc, repo: repo, watch: watch, ctxFn: ctxProvider, log: logger}
+    for _, opt := range opts {
+        if opt != nil {
+            opt(api)
+        }
+    }
+    if svc != nil {
+        svc.SetQueuedStreamHandler(api.handleQueuedStream)
+    }
+    return api
 }
 
 // Send streams a prompt through the configured agent and emits runtime events.
+// Follow-ups sent while the thread is still running are queued instead.
 func (a *API) Send(req MessageRequest) (StreamHandle, error) {
 	if a.svc == nil {
 		return StreamHandle{}, fmt.Errorf("agent service not initialised")
 	}
-	if a.ctxFn == nil {
-		return StreamHandle{}, fmt.Errorf("application context not initialised")
-	}
-	ctx := a.ctxFn()
-	if ctx == nil {
+	if a.hub == nil && a.runtimeContext() == nil {
 		return StreamHandle{}, fmt.Errorf("application context not initialised")
 	}
 	stream, thread, err := a.svc.Send(context.Background(), req)
+	if errors.Is(err, ErrThreadBusy) {
+		queued, qerr := a.EnqueueTurn(req)
+		if qerr != nil {
+			return StreamHandle{}, qerr
+		}
+		return StreamHandle{ThreadID: queued.ThreadID, QueuedTurnID: queued.ID}, nil
+	}
 	if err != nil {
 		return StreamHandle{}, err
 	}
+	a.attachStream(stream, thread)
+	return StreamHandle{StreamID: stream.ID(), ThreadID: thread.ID, ThreadExternalID: thread.ExternalID}, nil
+}
+
+// attachStream forwards stream events to the runtime until the stream finishes.
+func (a *API) attachStream(stream *Stream, thread discovery.Thread) {
 	if a.watch != nil {
 		a.watch.Ensure(thread.ID, thread.WorktreePath)
 	}
@@ -54,37 +81,352 @@ func (a *API) Send(req MessageRequest) (StreamHandle, error) {
 			if event.Item != nil && len(event.Item.FileDiffs) > 0 {
 				go a.emitDiff(thread.ID)
 			}
-			wailsruntime.EventsEmit(ctx, topic, event)
+			a.emit(topic, event)
 		}
-		finalEvent := StreamEvent{Type: "stream.complete"}
-		if err := stream.Wait(); err != nil {
-			finalEvent.Type = "stream.error"
-			finalEvent.Error = &StreamError{Message: err.Error()}
+		_ = stream.Wait()
+		// The terminal event is buffered too, so resumed views see how the turn ended.
+		finalEvent, _ := stream.buffer.terminalEvent()
+		a.emit(topic, finalEvent)
+	}()
+}
+
+// FanOut starts the same prompt on several variants, each in its own thread
+// and worktree, and streams them concurrently.
+func (a *API) FanOut(req FanOutRequest) (FanOutHandle, error) {
+	if a.svc == nil {
+		return FanOutHandle{}, fmt.Errorf("agent service not initialised")
+	}
+	if a.hub == nil && a.runtimeContext() == nil {
+		return FanOutHandle{}, fmt.Errorf("application context not initialised")
+	}
+	groupID, started, err := a.svc.FanOut(context.Background(), req)
+	handle := FanOutHandle{GroupID: groupID}
+	for _, result := range started {
+		entry := FanOutStreamHandle{StreamHandle: StreamHandle{ThreadID: result.Thread.ID, ThreadExternalID: result.Thread.ExternalID}}
+		if result.Err != nil {
+			entry.Error = result.Err.Error()
 		} else {
-			if updated, err := a.svc.GetThread(context.Background(), thread.ID); err == nil {
-				finalEvent.Message = updated.Status
+			a.attachStream(result.Stream, result.Thread)
+			entry.StreamID = result.Stream.ID()
+		}
+		handle.Streams = append(handle.Streams, entry)
+	}
+	return handle, err
+}
+
+// CompareGroup returns diff stats, token usage and final messages of a fan-out group.
+func (a *API) CompareGroup(groupID string) (GroupComparisonDTO, error) {
+	return a.svc.CompareGroup(context.Background(), groupID)
+}
+
+// PromoteGroupWinner keeps one thread of a fan-out group and archives the rest.
+func (a *API) PromoteGroupWinner(groupID string, threadID int64) (GroupComparisonDTO, error) {
+	comparison, err := a.svc.PromoteGroupWinner(context.Background(), groupID, threadID)
+	if err != nil {
+		return GroupComparisonDTO{}, err
+	}
+	if a.watch != nil {
+		for _, member := range comparison.Members {
+			if member.Thread.ArchivedAt != nil {
+				a.watch.Remove(member.Thread.ID)
 			}
 		}
-		wailsruntime.EventsEmit(ctx, topic, finalEvent)
-	}()
-	return StreamHandle{StreamID: stream.ID(), ThreadID: thread.ID, ThreadExternalID: thread.ExternalID}, nil
+	}
+	return comparison, nil
+}
+
+// handleQueuedStream attaches streams started from a thread's turn queue.
+func (a *API) handleQueuedStream(stream *Stream, thread discovery.Thread) {
+	a.attachStream(stream, thread)
+	a.emit(QueueTopic(thread.ID), queueStartedEvent{ThreadID: thread.ID, StreamID: stream.ID()})
+	a.emitQueue(thread.ID)
+}
+
+type queueStartedEvent struct {
+	ThreadID int64  `json:"threadId"`
+	StreamID string `json:"streamId"`
+}
+
+// EnqueueTurn queues a follow-up for a thread; it starts once the running turn ends.
+func (a *API) EnqueueTurn(req MessageRequest) (QueuedTurnDTO, error) {
+	queued, err := a.svc.EnqueueTurn(context.Background(), req)
+	if err != nil {
+		return QueuedTurnDTO{}, err
+	}
+	a.emitQueue(queued.ThreadID)
+	return queued, nil
+}
+
+// ListQueuedTurns returns the pending turns of a thread in execution order.
+func (a *API) ListQueuedTurns(threadID int64) ([]QueuedTurnDTO, error) {
+	return a.svc.ListQueuedTurns(context.Background(), threadID)
+}
+
+// ReorderQueuedTurns sets the execution order of a thread's pending turns.
+func (a *API) ReorderQueuedTurns(threadID int64, queuedTurnIDs []int64) ([]QueuedTurnDTO, error) {
+	turns, err := a.svc.ReorderQueuedTurns(context.Background(), threadID, queuedTurnIDs)
+	if err != nil {
+		return nil, err
+	}
+	a.emitQueue(threadID)
+	return turns, nil
+}
+
+// CancelQueuedTurn removes a pending turn before it starts.
+func (a *API) CancelQueuedTurn(threadID, queuedTurnID int64) error {
+	if err := a.svc.CancelQueuedTurn(context.Background(), threadID, queuedTurnID); err != nil {
+		return err
+	}
+	a.emitQueue(threadID)
+	return nil
+}
+
+func (a *API) emitQueue(threadID int64) {
+	turns, err := a.svc.ListQueuedTurns(context.Background(), threadID)
+	if err != nil {
+		if a.log != nil { a.log.Warn("list queued turns failed", "threadID", threadID, "error", err) }
+		return
+	}
+	payload := struct {
+		ThreadID int64           `json:"threadId"`
+		Turns    []QueuedTurnDTO `json:"turns"`
+	}{ThreadID: threadID, Turns: turns}
+	a.emit(QueueTopic(threadID), payload)
+}
+
+func (a *API) emit(topic string, payload any) {
+	if a.hub != nil {
+		a.hub.Emit(topic, payload)
+		return
+	}
+	if ctx := a.runtimeContext(); ctx != nil {
+		wailsruntime.EventsEmit(ctx, topic, payload)
+	}
+}
+
+func (a *API) runtimeContext() context.Context {
+	if a.ctxFn == nil {
+		return nil
+	}
+	return a.ctxFn()
+}
+
+// ResumeStream returns the events of streamID after afterSeq so a reloaded or
+// late-joining view can catch up; live events keep arriving on the stream topic.
+func (a *API) ResumeStream(streamID string, afterSeq int64) (StreamReplayDTO, error) {
+	if a.svc == nil {
+		return StreamReplayDTO{}, fmt.Errorf("agent service not initialised")
+	}
+	return a.svc.ResumeStream(streamID, afterSeq)
+}
+
+// ListActiveStreams returns the running streams of a thread.
+func (a *API) ListActiveStreams(threadID int64) ([]ActiveStreamDTO, error) {
+	if a.svc == nil {
+		return nil, fmt.Errorf("agent service not initialised")
+	}
+	return a.svc.ListActiveStreams(threadID), nil
+}
+
+// ListModels returns the models of agentID (all agents when empty) with their
+// reasoning levels and sandbox modes.
+func (a *API) ListModels(agentID string) []ModelDTO {
+	if a.svc == nil {
+		return nil
+	}
+	return a.svc.ListModels(agentID)
+}
+
+// GetProjectModelDefaults returns the model, reasoning level and sandbox mode
+// new threads of a project use when the request leaves them empty.
+func (a *API) GetProjectModelDefaults(projectID int64) (ProjectModelDefaultsDTO, error) {
+	return a.svc.GetProjectModelDefaults(context.Background(), projectID)
+}
+
+// SetProjectModelDefaults stores a project's thread option defaults.
+func (a *API) SetProjectModelDefaults(defaults ProjectModelDefaultsDTO) (ProjectModelDefaultsDTO, error) {
+	return a.svc.SetProjectModelDefaults(context.Background(), defaults)
+}
+
+// GetUsageReport totals recorded token usage by thread, project, model,
+// agent, day or month over an optional date range.
+func (a *API) GetUsageReport(query UsageQueryDTO) (UsageReportDTO, error) {
+	return a.svc.UsageReport(context.Background(), query)
+}
+
+// ExportUsageCSV returns the usage report (or, without grouping, every turn) as CSV.
+func (a *API) ExportUsageCSV(query UsageQueryDTO) (string, error) {
+	return a.svc.ExportUsageCSV(context.Background(), query)
+}
+
+// SearchConversations returns ranked entry and thread title matches with
+// highlighted snippets. A zero projectID searches every project.
+func (a *API) SearchConversations(query string, projectID int64) ([]SearchHitDTO, error) {
+	return a.svc.SearchConversations(context.Background(), query, projectID)
+}
+
+// GetProjectBudget returns the token and time limits a project's threads default to.
+func (a *API) GetProjectBudget(projectID int64) (BudgetDTO, error) {
+	return a.svc.GetProjectBudget(context.Background(), projectID)
+}
+
+// SetProjectBudget stores a project's default limits. Zero fields are unlimited.
+func (a *API) SetProjectBudget(projectID int64, budget BudgetDTO) (BudgetDTO, error) {
+	return a.svc.SetProjectBudget(context.Background(), projectID, budget)
+}
+
+// GetThreadBudget returns a thread's limits and the tokens it has used.
+func (a *API) GetThreadBudget(threadID int64) (ThreadBudgetDTO, error) {
+	return a.svc.GetThreadBudget(context.Background(), threadID)
+}
+
+// SetThreadBudget stores a thread's own limits; zero fields use the project's.
+func (a *API) SetThreadBudget(threadID int64, budget BudgetDTO) (ThreadBudgetDTO, error) {
+	return a.svc.SetThreadBudget(context.Background(), threadID, budget)
+}
+
+// ListPromptTemplates returns the global, project and built-in prompt
+// templates available to a project. Pass 0 for global templates only.
+func (a *API) ListPromptTemplates(projectID int64) ([]PromptTemplateDTO, error) {
+	return a.svc.ListPromptTemplates(context.Background(), projectID)
+}
+
+// CreatePromptTemplate saves a template; a zero projectId makes it global.
+func (a *API) CreatePromptTemplate(req SavePromptTemplateRequest) (PromptTemplateDTO, error) {
+	return a.svc.CreatePromptTemplate(context.Background(), req)
+}
+
+// UpdatePromptTemplate replaces a saved template's name, description and body.
+func (a *API) UpdatePromptTemplate(id int64, req SavePromptTemplateRequest) (PromptTemplateDTO, error) {
+	return a.svc.UpdatePromptTemplate(context.Background(), id, req)
+}
+
+// DeletePromptTemplate removes a saved template.
+func (a *API) DeletePromptTemplate(id int64) error {
+	return a.svc.DeletePromptTemplate(context.Background(), id)
+}
+
+// ListCheckpoints returns the worktree snapshots taken before each turn of a thread.
+func (a *API) ListCheckpoints(threadID int64) ([]CheckpointDTO, error) {
+	return a.svc.ListCheckpoints(context.Background(), threadID)
+}
+
+// RestoreCheckpoint resets the thread's worktree to a checkpoint and marks the
+// later entries as rolled back.
+func (a *API) RestoreCheckpoint(threadID, checkpointID int64) (CheckpointDTO, error) {
+	cp, err := a.svc.RestoreCheckpoint(context.Background(), threadID, checkpointID)
+	if err != nil {
+		return CheckpointDTO{}, err
+	}
+	a.emitDiff(threadID)
+	return cp, nil
+}
+
+// ListThreadCommits returns the commits auto-commit mode made after a thread's turns.
+func (a *API) ListThreadCommits(threadID int64) ([]ThreadCommitDTO, error) {
+	return a.svc.ListThreadCommits(context.Background(), threadID)
+}
+
+// ListPolicyRules returns a project's command and path rules in evaluation order.
+func (a *API) ListPolicyRules(projectID int64) ([]PolicyRuleDTO, error) {
+	return a.svc.ListPolicyRules(context.Background(), projectID)
+}
+
+// CreatePolicyRule adds an allow, deny or ask rule to a project's policy.
+func (a *API) CreatePolicyRule(req SavePolicyRuleRequest) (PolicyRuleDTO, error) {
+	return a.svc.CreatePolicyRule(context.Background(), req)
+}
+
+// UpdatePolicyRule replaces a rule's position, action, target and pattern.
+func (a *API) UpdatePolicyRule(id int64, req SavePolicyRuleRequest) (PolicyRuleDTO, error) {
+	return a.svc.UpdatePolicyRule(context.Background(), id, req)
+}
+
+// DeletePolicyRule removes a rule from a project's policy.
+func (a *API) DeletePolicyRule(id int64) error {
+	return a.svc.DeletePolicyRule(context.Background(), id)
+}
+
+// ListPolicyDecisions returns the audited policy decisions, newest first.
+func (a *API) ListPolicyDecisions(query PolicyDecisionQuery) ([]PolicyDecisionDTO, error) {
+	return a.svc.ListPolicyDecisions(context.Background(), query)
+}
+
+// ApprovePolicyDecision approves a paused command or path for the thread.
+func (a *API) ApprovePolicyDecision(id int64) (PolicyDecisionDTO, error) {
+	return a.svc.ApprovePolicyDecision(context.Background(), id)
+}
+
+// ListAgents returns the agent IDs that can be passed as MessageRequest.AgentID.
+func (a *API) ListAgents() []string {
+	if a.svc == nil {
+		return nil
+	}
+	return a.svc.ListAgents()
 }
 
 func (a *API) Cancel(streamID string) (CancelResponse, error) {
 	return a.svc.Cancel(context.Background(), streamID)
 }
+// ListThreads returns a project's threads without archived ones.
 func (a *API) ListThreads(projectID int64) ([]ThreadDTO, error) {
-	return a.svc.ListThreads(context.Background(), projectID)
+	return a.svc.ListThreads(context.Background(), projectID, ThreadFilterDTO{})
+}
+
+// ListThreadsFiltered returns a project's threads, hiding archived ones unless filter asks for them.
+func (a *API) ListThreadsFiltered(projectID int64, filter ThreadFilterDTO) ([]ThreadDTO, error) {
+	return a.svc.ListThreads(context.Background(), projectID, filter)
 }
+
+// QueryThreads returns one page of threads matching query, across projects when projectId is 0.
+func (a *API) QueryThreads(query ThreadQueryDTO) (ThreadPageDTO, error) {
+	return a.svc.QueryThreads(context.Background(), query)
+}
+
+// ListRecentThreads returns one page of the most recently active threads of every project.
+func (a *API) ListRecentThreads(cursor string, limit int) (ThreadPageDTO, error) {
+	return a.svc.ListRecentThreads(context.Background(), cursor, limit)
+}
+
 func (a *API) GetThread(threadID int64) (ThreadDTO, error) {
 	return a.svc.GetThread(context.Background(), threadID)
 }
 func (a *API) LoadThreadConversation(threadID int64) ([]ConversationEntryDTO, error) {
 	return a.svc.LoadThreadConversation(context.Background(), threadID)
 }
+
+// LoadConversationPage returns one page of a thread's timeline, optionally as a summary.
+func (a *API) LoadConversationPage(query ConversationPageQuery) (ConversationPageDTO, error) {
+	return a.svc.LoadConversationPage(context.Background(), query)
+}
+
+// GetConversationEntry returns one entry in full, expanding a partial summary entry.
+func (a *API) GetConversationEntry(threadID int64, entryID string) (ConversationEntryDTO, error) {
+	id, err := parseEntryID(entryID)
+	if err != nil {
+		return ConversationEntryDTO{}, err
+	}
+	return a.svc.GetConversationEntry(context.Background(), threadID, id)
+}
+
 func (a *API) RenameThread(threadID int64, title string) (ThreadDTO, error) {
 	return a.svc.RenameThread(context.Background(), threadID, title)
 }
+// ForkThread copies a thread up to entryID into a new thread with its own worktree.
+func (a *API) ForkThread(threadID int64, entryID string) (ThreadDTO, error) {
+	id, err := parseEntryID(entryID)
+	if err != nil {
+		return ThreadDTO{}, err
+	}
+	fork, err := a.svc.ForkThread(context.Background(), threadID, id)
+	if err != nil {
+		return ThreadDTO{}, err
+	}
+	if a.watch != nil {
+		a.watch.Ensure(fork.ID, fork.WorktreePath)
+	}
+	return fork, nil
+}
 func (a *API) DeleteThread(threadID int64) error {
 	if a.watch != nil {
 		a.watch.Remove(threadID)
@@ -92,6 +434,30 @@ func (a *API) DeleteThread(threadID int64) error {
 	return a.svc.DeleteThread(context.Background(), threadID)
 }
 
+// ArchiveThread hides a thread from the default list and releases its worktree.
+func (a *API) ArchiveThread(threadID int64) (ThreadDTO, error) {
+	thread, err := a.svc.ArchiveThread(context.Background(), threadID)
+	if err == nil && a.watch != nil {
+		a.watch.Remove(threadID)
+	}
+	return thread, err
+}
+
+// UnarchiveThread returns an archived thread to the default list.
+func (a *API) UnarchiveThread(threadID int64) (ThreadDTO, error) {
+	return a.svc.UnarchiveThread(context.Background(), threadID)
+}
+
+// PinThread pins a thread to the top of its project's list, or unpins it.
+func (a *API) PinThread(threadID int64, pinned bool) (ThreadDTO, error) {
+	return a.svc.PinThread(context.Background(), threadID, pinned)
+}
+
+// SetThreadStatus marks an idle thread completed, awaiting-review or merged.
+func (a *API) SetThreadStatus(threadID int64, status string) (ThreadDTO, error) {
+	return a.svc.SetThreadStatus(context.Background(), threadID, status)
+}
+
 // ListThreadFileDiffs returns git diff stats and ensures watcher is attached.
 func (a *API) ListThreadFileDiffs(threadID int64) ([]FileDiffStatDTO, error) {
 	th, err := a.svc.GetThread(context.Background(), threadID)
@@ -110,62 +476,16 @@ func (a *API) CreatePullRequest(threadID int64) (string, error) {
 	if a.svc == nil {
 		return "", fmt.Errorf("agent service not initialised")
 	}
-	thread, err := a.svc.GetThread(context.Background(), threadID)
-	if err != nil {
-		return "", err
-	}
-	if strings.TrimSpace(thread.PRURL) != "" {
-		return thread.PRURL, nil
-	}
-	worktree := strings.TrimSpace(thread.WorktreePath)
-	if worktree == "" {
-		return "", fmt.Errorf("thread %d has no worktree", threadID)
-	}
-    diffs, err := a.svc.ListThreadDiffStats(context.Background(), threadID)
-    if err != nil {
-        return "", err
-    }
-    if len(diffs) == 0 {
-        return "", fmt.Errorf("no file changes detected")
-    }
-    // Resolve branch name with fallback and persist if missing
-    branch := strings.TrimSpace(thread.BranchName)
-    if branch == "" {
-        branch = worktrees.BranchName(thread.Title, thread.ID)
-        // best-effort persist before running the PR job
-        _ = a.repo.UpdateThreadBranchName(context.Background(), thread.ID, branch)
-    }
-    instruction := BuildCreatePRInstruction(branch)
-    stream, err := StartBackgroundPRStream(worktree, "danger-full-access", instruction)
+	prURL, err := a.svc.CreatePullRequest(context.Background(), threadID)
 	if err != nil {
 		return "", err
 	}
-	if stream.Close != nil {
-		defer stream.Close()
-	}
-	var prURL string
-	for evt := range stream.Events {
-		if url := ExtractPRURLFromEvent(evt); url != "" {
-			prURL = url
-		}
-	}
-	if stream.Done != nil {
-		if waitErr, ok := <-stream.Done; ok && waitErr != nil {
-			return "", waitErr
-		}
-	}
-	if strings.TrimSpace(prURL) == "" {
-		return "", fmt.Errorf("failed to detect PR URL from agent run")
-	}
-	if err := a.repo.UpdateThreadPRURL(context.Background(), thread.ID, prURL); err != nil {
-		return "", err
-	}
-	a.emitDiff(thread.ID)
+	a.emitDiff(threadID)
 	return prURL, nil
 }
 
 func (a *API) emitDiff(threadID int64) {
-	if a.svc == nil || a.ctxFn == nil {
+	if a.svc == nil || (a.hub == nil && a.ctxFn == nil) {
 		return
 	}
 	stats, err := a.svc.ListThreadDiffStats(context.Background(), threadID)
@@ -177,11 +497,7 @@ func (a *API) emitDiff(threadID int64) {
 		ThreadID int64             `json:"threadId"`
 		Files    []FileDiffStatDTO `json:"files"`
 	}{ThreadID: threadID, Files: stats}
-	ctx := a.ctxFn()
-	if ctx == nil {
-		return
-	}
-	wailsruntime.EventsEmit(ctx, FileChangeTopic(threadID), payload)
+	a.emit(FileChangeTopic(threadID), payload)
 }
 
 // EmitThreadDiffUpdate recomputes and emits file diff update for a thread.
@@ -0,0 +1,145 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"codex-ui/internal/storage/discovery"
+)
+
+const (
+	commitSubjectLimit = 72
+	commitBodyLimit    = 4000
+)
+
+// ThreadCommitDTO is a commit made after a turn of a thread in auto-commit
+// mode. EntryID is the user message that started the turn and AgentEntryID
+// the agent message that ended it.
+type ThreadCommitDTO struct {
+	ID           int64  `json:"id"`
+	ThreadID     int64  `json:"threadId"`
+	EntryID      string `json:"entryId"`
+	AgentEntryID string `json:"agentEntryId,omitempty"`
+	Commit       string `json:"commit"`
+	Subject      string `json:"subject"`
+	// RolledBack marks commits undone by a restored checkpoint.
+	RolledBack bool   `json:"rolledBack,omitempty"`
+	CreatedAt  string `json:"createdAt"`
+}
+
+// commitTurn commits the thread's worktree after a completed turn when the
+// project has auto-commit enabled, and links the commit to the turn's entries.
+func (s *Service) commitTurn(ctx context.Context, active *activeStream, thread discovery.Thread) {
+	if s.worktrees == nil || active.entryID == 0 || strings.TrimSpace(thread.WorktreePath) == "" {
+		return
+	}
+	settings, err := s.repo.GetProjectSettings(ctx, thread.ProjectID)
+	if err != nil || !settings.AutoCommit {
+		return
+	}
+	reply, agentEntryID, usage := active.state.turnOutcome()
+	message := turnCommitMessage(thread, active.entryID, active.prompt, reply, usage)
+	sha, err := s.worktrees.CommitAll(ctx, thread.WorktreePath, message)
+	if err != nil {
+		s.recordSystemError(ctx, thread.ID, "Auto-commit failed: "+err.Error())
+		return
+	}
+	if sha == "" {
+		return
+	}
+	subject, _, _ := strings.Cut(message, "\n")
+	if _, err := s.repo.RecordThreadCommit(ctx, discovery.ThreadCommit{
+		ThreadID:     thread.ID,
+		EntryID:      active.entryID,
+		AgentEntryID: agentEntryID,
+		Commit:       sha,
+		Subject:      subject,
+	}); err != nil {
+		return
+	}
+	s.recordSystemEntry(ctx, thread.ID, "info", fmt.Sprintf("Committed %s: %s", shortHash(sha), subject), map[string]any{"commit": sha})
+}
+
+// turnCommitMessage derives a commit message from the turn: the prompt's
+// first line as the subject, the final agent message as the body, and the
+// thread, entry, model and token usage as trailers.
+func turnCommitMessage(thread discovery.Thread, entryID int64, prompt, reply string, usage *UsageDTO) string {
+	subject := ""
+	for _, line := range strings.Split(prompt, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			subject = line
+			break
+		}
+	}
+	if subject == "" {
+		subject = fmt.Sprintf("Codex turn in thread %d", thread.ID)
+	}
+	subject = truncateRunes(subject, commitSubjectLimit)
+
+	var b strings.Builder
+	b.WriteString(subject)
+	if body := strings.TrimSpace(reply); body != "" {
+		b.WriteString("\n\n")
+		b.WriteString(truncateRunes(body, commitBodyLimit))
+	}
+	b.WriteString("\n\n")
+	fmt.Fprintf(&b, "Codex-Thread: %d\n", thread.ID)
+	fmt.Fprintf(&b, "Codex-Entry: %s\n", formatEntryID(entryID))
+	if model := strings.TrimSpace(thread.Model); model != "" {
+		fmt.Fprintf(&b, "Codex-Model: %s\n", model)
+	}
+	if usage != nil {
+		fmt.Fprintf(&b, "Codex-Input-Tokens: %d\n", usage.InputTokens)
+		fmt.Fprintf(&b, "Codex-Cached-Input-Tokens: %d\n", usage.CachedInputTokens)
+		fmt.Fprintf(&b, "Codex-Output-Tokens: %d\n", usage.OutputTokens)
+	}
+	return b.String()
+}
+
+func truncateRunes(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit-1]) + "…"
+}
+
+func shortHash(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+// ListThreadCommits returns the commits made after a thread's turns, oldest
+// first.
+func (s *Service) ListThreadCommits(ctx context.Context, threadID int64) ([]ThreadCommitDTO, error) {
+	if err := s.ensureRepo(); err != nil {
+		return nil, err
+	}
+	commits, err := s.repo.ListThreadCommits(ctx, threadID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ThreadCommitDTO, 0, len(commits))
+	for _, c := range commits {
+		dto := ThreadCommitDTO{
+			ID:        c.ID,
+			ThreadID:  c.ThreadID,
+			EntryID:   formatEntryID(c.EntryID),
+			Commit:    c.Commit,
+			Subject:   c.Subject,
+			CreatedAt: c.CreatedAt.UTC().Format(time.RFC3339),
+		}
+		if c.AgentEntryID != 0 {
+			dto.AgentEntryID = formatEntryID(c.AgentEntryID)
+		}
+		if entry, err := s.repo.GetConversationEntry(ctx, c.EntryID); err == nil {
+			dto.RolledBack = entry.RolledBackAt != nil
+		}
+		out = append(out, dto)
+	}
+	return out, nil
+}
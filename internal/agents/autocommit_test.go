@@ -0,0 +1,76 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"codex-ui/internal/storage/discovery"
+)
+
+func TestService_AutoCommitAfterEachTurn(t *testing.T) {
+	svc, repo, _ := newTestService(t, &notesAdapter{})
+	ctx := context.Background()
+	project := newGitProject(t, svc, repo)
+
+	// Off by default: changes stay uncommitted.
+	thread, err := sendAndWait(t, svc, MessageRequest{ProjectID: project.ID, Input: "draft", ThreadOptions: ThreadOptionsDTO{Model: "m"}})
+	if err != nil {
+		t.Fatalf("first turn: %v", err)
+	}
+	if commits, _ := svc.ListThreadCommits(ctx, thread.ID); len(commits) != 0 {
+		t.Fatalf("expected no commits without auto-commit, got %+v", commits)
+	}
+
+	if err := repo.SaveProjectSettings(ctx, discovery.ProjectSettings{ProjectID: project.ID, AutoCommit: true}); err != nil {
+		t.Fatalf("save settings: %v", err)
+	}
+	if _, err := sendAndWait(t, svc, MessageRequest{ThreadID: thread.ID, Input: "Add the second note\nwith details"}); err != nil {
+		t.Fatalf("second turn: %v", err)
+	}
+	commits, err := svc.ListThreadCommits(ctx, thread.ID)
+	if err != nil {
+		t.Fatalf("list commits: %v", err)
+	}
+	if len(commits) != 1 || commits[0].Subject != "Add the second note" || commits[0].AgentEntryID == "" {
+		t.Fatalf("unexpected commits %+v", commits)
+	}
+
+	stored, _ := repo.GetThread(ctx, thread.ID)
+	git := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = stored.WorktreePath
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	if head := git("rev-parse", "HEAD"); head != commits[0].Commit {
+		t.Fatalf("expected HEAD %s, got %s", commits[0].Commit, head)
+	}
+	if status := git("status", "--porcelain"); status != "" {
+		t.Fatalf("expected a clean worktree, got %q", status)
+	}
+	message := git("log", "-1", "--format=%B")
+	for _, want := range []string{
+		"Add the second note\n\nWrote with details to notes.txt.\n\n",
+		fmt.Sprintf("Codex-Thread: %d", thread.ID),
+		"Codex-Entry: " + commits[0].EntryID,
+		"Codex-Model: m",
+		"Codex-Input-Tokens: 10",
+		"Codex-Output-Tokens: 3",
+	} {
+		if !strings.Contains(message, want) {
+			t.Errorf("commit message missing %q:\n%s", want, message)
+		}
+	}
+	if got := git("log", "-1", "--format=%(trailers:key=Codex-Cached-Input-Tokens,valueonly)"); got != "2" {
+		t.Fatalf("expected usage in parseable trailers, got %q", got)
+	}
+	if msg := lastSystemMessage(t, repo, thread.ID); !strings.Contains(msg, "Committed "+commits[0].Commit[:7]) {
+		t.Fatalf("unexpected system entry %s", msg)
+	}
+}
@@ -13,8 +13,8 @@ import (
 
 // BootstrapService constructs the default agent service backed by the Codex adapter.
 // It ensures the worktrees root exists under the provided data directory and
-// starts the scheduled cleanup worker.
-func BootstrapService(dataDir string, repo *discovery.Repository) (*Service, error) {
+// starts the scheduled cleanup worker. opts are applied after the defaults.
+func BootstrapService(dataDir string, repo *discovery.Repository, opts ...ServiceOption) (*Service, error) {
 	adapter, err := NewCodexAdapter(CodexOptionsFromEnv())
 	if err != nil {
 		return nil, fmt.Errorf("initialise codex adapter: %w", err)
@@ -29,11 +29,41 @@ func BootstrapService(dataDir string, repo *discovery.Repository) (*Service, err
     // Use go-git for read ops (repo root / current ref), exec for write/worktree ops
     manager.SetGitClient(gitc.NewGoGitClient())
     gitClient := gitc.NewGoGitClient()
-    service := NewService("codex", repo, WithWorktreeManager(manager), WithGitClient(gitClient))
+    catalog, err := LoadModelCatalog(filepath.Join(dataDir, ModelsConfigFile))
+    if err != nil {
+        return nil, err
+    }
+    opts = append([]ServiceOption{WithWorktreeManager(manager), WithGitClient(gitClient), WithModelCatalog(catalog)}, opts...)
+    service := NewService("codex", repo, opts...)
 	if err := service.Register("codex", adapter); err != nil {
 		return nil, fmt.Errorf("register codex adapter: %w", err)
 	}
+	if err := registerConfiguredAdapters(service, dataDir); err != nil {
+		return nil, err
+	}
 
 	service.StartWorktreeCleanup(time.Hour)
 	return service, nil
 }
+
+// registerConfiguredAdapters registers the adapters listed in the data
+// directory's agents.json under their own IDs.
+func registerConfiguredAdapters(service *Service, dataDir string) error {
+	configs, err := LoadAdapterConfigs(filepath.Join(dataDir, AdaptersConfigFile))
+	if err != nil {
+		return err
+	}
+	for _, cfg := range configs {
+		if cfg.ID == "codex" {
+			return fmt.Errorf("adapter id %q is reserved", cfg.ID)
+		}
+		adapter, err := NewAdapterFromConfig(cfg, dataDir)
+		if err != nil {
+			return err
+		}
+		if err := service.Register(cfg.ID, adapter); err != nil {
+			return fmt.Errorf("register %s adapter: %w", cfg.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"codex-ui/internal/storage/discovery"
+)
+
+// ErrBudgetExceeded is returned when a turn is refused or stopped by a budget.
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// BudgetDTO limits agent turns. Zero fields are unlimited.
+type BudgetDTO struct {
+	MaxTurnTokens   int64 `json:"maxTurnTokens,omitempty"`
+	MaxTurnSeconds  int64 `json:"maxTurnSeconds,omitempty"`
+	MaxThreadTokens int64 `json:"maxThreadTokens,omitempty"`
+}
+
+// ThreadBudgetDTO reports a thread's own limits, the limits in effect after
+// falling back to the project's, and the tokens the thread has used.
+type ThreadBudgetDTO struct {
+	ThreadID    int64     `json:"threadId"`
+	Limits      BudgetDTO `json:"limits"`
+	Effective   BudgetDTO `json:"effective"`
+	SpentTokens int64     `json:"spentTokens"`
+}
+
+func (b BudgetDTO) validate() error {
+	if b.MaxTurnTokens < 0 || b.MaxTurnSeconds < 0 || b.MaxThreadTokens < 0 {
+		return errors.New("budget limits must not be negative")
+	}
+	return nil
+}
+
+func toBudgetDTO(b discovery.Budget) BudgetDTO {
+	return BudgetDTO{MaxTurnTokens: b.MaxTurnTokens, MaxTurnSeconds: b.MaxTurnSeconds, MaxThreadTokens: b.MaxThreadTokens}
+}
+
+func (b BudgetDTO) record() discovery.Budget {
+	return discovery.Budget{MaxTurnTokens: b.MaxTurnTokens, MaxTurnSeconds: b.MaxTurnSeconds, MaxThreadTokens: b.MaxThreadTokens}
+}
+
+// effectiveBudget fills the thread's unset limits from the project's.
+func effectiveBudget(thread, project discovery.Budget) discovery.Budget {
+	if thread.MaxTurnTokens == 0 {
+		thread.MaxTurnTokens = project.MaxTurnTokens
+	}
+	if thread.MaxTurnSeconds == 0 {
+		thread.MaxTurnSeconds = project.MaxTurnSeconds
+	}
+	if thread.MaxThreadTokens == 0 {
+		thread.MaxThreadTokens = project.MaxThreadTokens
+	}
+	return thread
+}
+
+// GetProjectBudget returns the default limits for a project's threads.
+func (s *Service) GetProjectBudget(ctx context.Context, projectID int64) (BudgetDTO, error) {
+	if err := s.ensureRepo(); err != nil {
+		return BudgetDTO{}, err
+	}
+	budget, err := s.repo.GetProjectBudget(ctx, projectID)
+	if err != nil {
+		return BudgetDTO{}, err
+	}
+	return toBudgetDTO(budget), nil
+}
+
+// SetProjectBudget replaces the default limits for a project's threads.
+func (s *Service) SetProjectBudget(ctx context.Context, projectID int64, budget BudgetDTO) (BudgetDTO, error) {
+	if err := s.ensureRepo(); err != nil {
+		return BudgetDTO{}, err
+	}
+	if err := budget.validate(); err != nil {
+		return BudgetDTO{}, err
+	}
+	if _, err := s.repo.GetProjectByID(ctx, projectID); err != nil {
+		return BudgetDTO{}, err
+	}
+	if err := s.repo.SaveProjectBudget(ctx, projectID, budget.record()); err != nil {
+		return BudgetDTO{}, err
+	}
+	return budget, nil
+}
+
+// GetThreadBudget returns a thread's limits and the tokens it has used.
+func (s *Service) GetThreadBudget(ctx context.Context, threadID int64) (ThreadBudgetDTO, error) {
+	if err := s.ensureRepo(); err != nil {
+		return ThreadBudgetDTO{}, err
+	}
+	thread, err := s.repo.GetThread(ctx, threadID)
+	if err != nil {
+		return ThreadBudgetDTO{}, err
+	}
+	own, err := s.repo.GetThreadBudget(ctx, threadID)
+	if err != nil {
+		return ThreadBudgetDTO{}, err
+	}
+	effective, spent, err := s.turnBudget(ctx, thread)
+	if err != nil {
+		return ThreadBudgetDTO{}, err
+	}
+	return ThreadBudgetDTO{
+		ThreadID:    threadID,
+		Limits:      toBudgetDTO(own),
+		Effective:   toBudgetDTO(effective),
+		SpentTokens: spent,
+	}, nil
+}
+
+// SetThreadBudget replaces a thread's own limits; zero fields fall back to the
+// project's.
+func (s *Service) SetThreadBudget(ctx context.Context, threadID int64, budget BudgetDTO) (ThreadBudgetDTO, error) {
+	if err := s.ensureRepo(); err != nil {
+		return ThreadBudgetDTO{}, err
+	}
+	if err := budget.validate(); err != nil {
+		return ThreadBudgetDTO{}, err
+	}
+	if _, err := s.repo.GetThread(ctx, threadID); err != nil {
+		return ThreadBudgetDTO{}, err
+	}
+	if err := s.repo.SaveThreadBudget(ctx, threadID, budget.record()); err != nil {
+		return ThreadBudgetDTO{}, err
+	}
+	return s.GetThreadBudget(ctx, threadID)
+}
+
+// turnBudget returns the limits in effect for the thread's next turn and the
+// tokens the thread has already used.
+func (s *Service) turnBudget(ctx context.Context, thread discovery.Thread) (discovery.Budget, int64, error) {
+	projectBudget, err := s.repo.GetProjectBudget(ctx, thread.ProjectID)
+	if err != nil {
+		return discovery.Budget{}, 0, err
+	}
+	threadBudget, err := s.repo.GetThreadBudget(ctx, thread.ID)
+	if err != nil {
+		return discovery.Budget{}, 0, err
+	}
+	spent, err := s.repo.SumThreadTokens(ctx, thread.ID)
+	if err != nil {
+		return discovery.Budget{}, 0, err
+	}
+	return effectiveBudget(threadBudget, projectBudget), spent, nil
+}
+
+// watchTurnDuration stops the turn once it runs past its time budget. The
+// returned function releases the timer.
+func (s *Service) watchTurnDuration(active *activeStream) func() {
+	if active.budget.MaxTurnSeconds <= 0 {
+		return func() {}
+	}
+	limit := time.Duration(active.budget.MaxTurnSeconds) * time.Second
+	timer := s.afterFunc(limit, func() {
+		s.stopForBudget(active, fmt.Sprintf("Turn stopped: it ran longer than the %s time budget.", limit))
+	})
+	return func() { timer.Stop() }
+}
+
+// checkTokenBudget stops the turn when its reported usage goes over the
+// per-turn or per-thread token budget. Any event may carry usage, which is the
+// running total for the turn; agents that report usage only on turn.completed
+// are checked once the turn has already finished.
+func (s *Service) checkTokenBudget(active *activeStream, usage *UsageDTO) {
+	if usage == nil {
+		return
+	}
+	used := int64(usage.InputTokens + usage.OutputTokens)
+	budget := active.budget
+	switch {
+	case budget.MaxTurnTokens > 0 && used > budget.MaxTurnTokens:
+		s.stopForBudget(active, fmt.Sprintf("Turn stopped: it used %d tokens, over the budget of %d tokens per turn.", used, budget.MaxTurnTokens))
+	case budget.MaxThreadTokens > 0 && active.spentTokens+used > budget.MaxThreadTokens:
+		s.stopForBudget(active, fmt.Sprintf("Turn stopped: the thread has used %d tokens, over its budget of %d tokens.", active.spentTokens+used, budget.MaxThreadTokens))
+	}
+}
+
+// stopForBudget cancels the turn through the regular cancel path; the stream
+// finalises with ThreadStatusBudgetExceeded and records message.
+func (s *Service) stopForBudget(active *activeStream, message string) {
+	s.stopTurn(active, discovery.ThreadStatusBudgetExceeded, "warning", message, nil)
+}
+
+// stopTurn cancels the turn through the regular cancel path; the stream
+// finalises with status and records message as a system entry.
+func (s *Service) stopTurn(active *activeStream, status discovery.ThreadStatus, tone, message string, meta map[string]any) {
+	if active.state != nil && !active.state.recordStop(status, tone, message, meta) {
+		return
+	}
+	if active.cancel != nil {
+		_ = active.cancel()
+	}
+}
@@ -0,0 +1,126 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"codex-ui/internal/storage/discovery"
+)
+
+func sendAndWait(t *testing.T, svc *Service, req MessageRequest) (discovery.Thread, error) {
+	t.Helper()
+	stream, thread, err := svc.Send(context.Background(), req)
+	if err != nil {
+		return discovery.Thread{}, err
+	}
+	for range stream.Events() {
+	}
+	return thread, stream.Wait()
+}
+
+func lastSystemMessage(t *testing.T, repo *discovery.Repository, threadID int64) string {
+	t.Helper()
+	entries, err := repo.ListConversationEntries(context.Background(), threadID)
+	if err != nil {
+		t.Fatalf("list entries: %v", err)
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Role == "system" {
+			return string(entries[i].Payload)
+		}
+	}
+	return ""
+}
+
+func TestService_TokenBudgetsStopTurns(t *testing.T) {
+	svc, repo, project := newTestService(t, modelEchoAdapter{})
+	ctx := context.Background()
+
+	// Each turn uses len(model) + 1 tokens.
+	if _, err := svc.SetProjectBudget(ctx, project.ID, BudgetDTO{MaxTurnTokens: 5}); err != nil {
+		t.Fatalf("set project budget: %v", err)
+	}
+	thread, err := sendAndWait(t, svc, MessageRequest{ProjectID: project.ID, Input: "hi", ThreadOptions: ThreadOptionsDTO{Model: "abcdef"}})
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected budget error, got %v", err)
+	}
+	stored, _ := repo.GetThread(ctx, thread.ID)
+	if stored.Status != discovery.ThreadStatusBudgetExceeded {
+		t.Fatalf("expected budget-exceeded status, got %q", stored.Status)
+	}
+	if msg := lastSystemMessage(t, repo, thread.ID); !strings.Contains(msg, `"tone":"warning"`) || !strings.Contains(msg, "budget of 5 tokens per turn") {
+		t.Fatalf("unexpected system entry %s", msg)
+	}
+
+	// A thread's own limits override the project's; the cumulative cap
+	// counts earlier turns from the ledger.
+	if _, err := svc.SetThreadBudget(ctx, thread.ID, BudgetDTO{MaxTurnTokens: 100, MaxThreadTokens: 12}); err != nil {
+		t.Fatalf("set thread budget: %v", err)
+	}
+	if _, err := sendAndWait(t, svc, MessageRequest{ThreadID: thread.ID, Input: "again"}); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected thread budget error, got %v", err)
+	}
+	if msg := lastSystemMessage(t, repo, thread.ID); !strings.Contains(msg, "the thread has used 14 tokens") {
+		t.Fatalf("unexpected system entry %s", msg)
+	}
+	budget, err := svc.GetThreadBudget(ctx, thread.ID)
+	if err != nil {
+		t.Fatalf("get thread budget: %v", err)
+	}
+	if budget.SpentTokens != 14 || budget.Effective.MaxTurnTokens != 100 || budget.Effective.MaxThreadTokens != 12 {
+		t.Fatalf("unexpected budget %+v", budget)
+	}
+	if _, _, err := svc.Send(ctx, MessageRequest{ThreadID: thread.ID, Input: "more"}); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected exhausted thread to refuse new turns, got %v", err)
+	}
+
+	if _, err := svc.SetProjectBudget(ctx, project.ID, BudgetDTO{MaxTurnSeconds: -1}); err == nil {
+		t.Fatal("expected negative limits to be rejected")
+	}
+}
+
+func TestService_TimeBudgetCancelsTurn(t *testing.T) {
+	adapter := &scriptedAdapter{release: make(chan struct{})}
+	svc, repo, project := newTestService(t, adapter)
+	// Run the budget's timer a hundred times faster.
+	WithAfterFunc(func(d time.Duration, f func()) *time.Timer { return time.AfterFunc(d/100, f) })(svc)
+	ctx := context.Background()
+	if _, err := svc.SetProjectBudget(ctx, project.ID, BudgetDTO{MaxTurnSeconds: 1}); err != nil {
+		t.Fatalf("set project budget: %v", err)
+	}
+
+	thread, err := sendAndWait(t, svc, MessageRequest{ProjectID: project.ID, Input: "hang"})
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected budget error, got %v", err)
+	}
+	stored, _ := repo.GetThread(ctx, thread.ID)
+	if stored.Status != discovery.ThreadStatusBudgetExceeded {
+		t.Fatalf("expected budget-exceeded status, got %q", stored.Status)
+	}
+	if msg := lastSystemMessage(t, repo, thread.ID); !strings.Contains(msg, "1s time budget") {
+		t.Fatalf("unexpected system entry %s", msg)
+	}
+}
+
+func TestService_TokenBudgetStopsRunningTurn(t *testing.T) {
+	// The turn reports its usage so far but never sends turn.completed.
+	adapter := &scriptedAdapter{events: []StreamEvent{
+		{Type: "item.started", Item: &AgentItemDTO{ID: "cmd_1", Type: "command_execution", Command: &CommandExecutionDTO{Command: "make", Status: "in_progress"}}, Usage: &UsageDTO{InputTokens: 40, OutputTokens: 5}},
+	}}
+	svc, repo, project := newTestService(t, adapter)
+	ctx := context.Background()
+	if _, err := svc.SetProjectBudget(ctx, project.ID, BudgetDTO{MaxTurnTokens: 20}); err != nil {
+		t.Fatalf("set project budget: %v", err)
+	}
+
+	thread, err := sendAndWait(t, svc, MessageRequest{ProjectID: project.ID, Input: "loop"})
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected budget error, got %v", err)
+	}
+	if msg := lastSystemMessage(t, repo, thread.ID); !strings.Contains(msg, "it used 45 tokens") {
+		t.Fatalf("unexpected system entry %s", msg)
+	}
+}
@@ -0,0 +1,168 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"codex-ui/internal/git/worktrees"
+	"codex-ui/internal/storage/discovery"
+)
+
+// CheckpointDTO is a snapshot of a thread's worktree taken before the turn
+// started by EntryID. Restoring it undoes that turn and every later one.
+type CheckpointDTO struct {
+	ID       int64  `json:"id"`
+	ThreadID int64  `json:"threadId"`
+	EntryID  string `json:"entryId"`
+	// Prompt is the start of the user message that began the turn.
+	Prompt     string `json:"prompt"`
+	Commit     string `json:"commit"`
+	CreatedAt  string `json:"createdAt"`
+	RestoredAt string `json:"restoredAt,omitempty"`
+}
+
+const checkpointPromptLimit = 80
+
+func toCheckpointDTO(cp discovery.Checkpoint, prompt string) CheckpointDTO {
+	dto := CheckpointDTO{
+		ID:        cp.ID,
+		ThreadID:  cp.ThreadID,
+		EntryID:   formatEntryID(cp.EntryID),
+		Prompt:    prompt,
+		Commit:    cp.Commit,
+		CreatedAt: cp.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if cp.RestoredAt != nil {
+		dto.RestoredAt = cp.RestoredAt.UTC().Format(time.RFC3339)
+	}
+	return dto
+}
+
+// checkpointTurn snapshots the thread's worktree before the turn started by
+// entryID. Failures are ignored: a missing checkpoint must not block a turn.
+func (s *Service) checkpointTurn(ctx context.Context, thread discovery.Thread, entryID int64) {
+	if s.worktrees == nil || strings.TrimSpace(thread.WorktreePath) == "" {
+		return
+	}
+	ref := worktrees.CheckpointRef(thread.ID, entryID)
+	snapshot, err := s.worktrees.Snapshot(ctx, thread.WorktreePath, ref, fmt.Sprintf("codex-ui checkpoint: thread %d before entry %d", thread.ID, entryID))
+	if err != nil {
+		return
+	}
+	_, _ = s.repo.CreateCheckpoint(ctx, discovery.Checkpoint{
+		ThreadID: thread.ID,
+		EntryID:  entryID,
+		Ref:      snapshot.Ref,
+		Commit:   snapshot.Commit,
+		Head:     snapshot.Head,
+	})
+}
+
+// ListCheckpoints returns a thread's checkpoints, oldest first.
+func (s *Service) ListCheckpoints(ctx context.Context, threadID int64) ([]CheckpointDTO, error) {
+	if err := s.ensureRepo(); err != nil {
+		return nil, err
+	}
+	checkpoints, err := s.repo.ListCheckpoints(ctx, threadID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]CheckpointDTO, 0, len(checkpoints))
+	for _, cp := range checkpoints {
+		out = append(out, toCheckpointDTO(cp, s.checkpointPrompt(ctx, cp.EntryID)))
+	}
+	return out, nil
+}
+
+// RestoreCheckpoint resets the thread's worktree to a checkpoint and marks
+// the entries from the checkpoint's turn onward as rolled back. Restoring a
+// later checkpoint afterwards brings those turns back.
+func (s *Service) RestoreCheckpoint(ctx context.Context, threadID, checkpointID int64) (CheckpointDTO, error) {
+	if err := s.ensureRepo(); err != nil {
+		return CheckpointDTO{}, err
+	}
+	if s.worktrees == nil {
+		return CheckpointDTO{}, errors.New("worktrees are not enabled")
+	}
+	cp, err := s.repo.GetCheckpoint(ctx, checkpointID)
+	if err != nil {
+		return CheckpointDTO{}, err
+	}
+	if cp.ThreadID != threadID {
+		return CheckpointDTO{}, fmt.Errorf("checkpoint %d does not belong to thread %d", checkpointID, threadID)
+	}
+	thread, err := s.repo.GetThread(ctx, threadID)
+	if err != nil {
+		return CheckpointDTO{}, err
+	}
+	if strings.TrimSpace(thread.WorktreePath) == "" {
+		return CheckpointDTO{}, fmt.Errorf("thread %d has no worktree", threadID)
+	}
+	if !s.claimThread(threadID) {
+		return CheckpointDTO{}, ErrThreadBusy
+	}
+	defer s.releaseThread(threadID)
+
+	snapshot := worktrees.Checkpoint{Ref: cp.Ref, Commit: cp.Commit, Head: cp.Head}
+	if err := s.worktrees.Restore(ctx, thread.WorktreePath, snapshot); err != nil {
+		return CheckpointDTO{}, err
+	}
+	if err := s.repo.RollBackEntries(ctx, cp.ID, threadID, cp.EntryID); err != nil {
+		return CheckpointDTO{}, err
+	}
+	prompt := s.checkpointPrompt(ctx, cp.EntryID)
+	s.recordSystemEntry(ctx, threadID, "info", fmt.Sprintf("Rolled back to the checkpoint before “%s”.", prompt), map[string]any{"restoredCheckpointId": cp.ID})
+	if restored, err := s.repo.GetCheckpoint(ctx, cp.ID); err == nil {
+		cp = restored
+	}
+	return toCheckpointDTO(cp, prompt), nil
+}
+
+// checkpointPrompt returns the start of the user message of entryID.
+func (s *Service) checkpointPrompt(ctx context.Context, entryID int64) string {
+	entry, err := s.repo.GetConversationEntry(ctx, entryID)
+	if err != nil {
+		return ""
+	}
+	dto, err := conversationEntryToDTO(entry)
+	if err != nil {
+		return ""
+	}
+	prompt := strings.Join(strings.Fields(dto.Text), " ")
+	if runes := []rune(prompt); len(runes) > checkpointPromptLimit {
+		prompt = string(runes[:checkpointPromptLimit-1]) + "…"
+	}
+	return prompt
+}
+
+// rollbackNotice tells the agent its workspace was rolled back when no turn
+// has run since the last restore; the agent session still remembers the
+// undone turns.
+func (s *Service) rollbackNotice(ctx context.Context, thread discovery.Thread) string {
+	entries, err := s.repo.ListConversationEntries(ctx, thread.ID)
+	if err != nil {
+		return ""
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if entry.RolledBackAt != nil {
+			continue
+		}
+		if entry.Role == "user" {
+			return ""
+		}
+		if entry.Role != "system" {
+			continue
+		}
+		dto, err := conversationEntryToDTO(entry)
+		if err != nil || dto.Meta["restoredCheckpointId"] == nil {
+			continue
+		}
+		return "The workspace was rolled back to how it was before an earlier turn. Changes made from that turn onward " +
+			"were undone; check the files again before relying on earlier results."
+	}
+	return ""
+}
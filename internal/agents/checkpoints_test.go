@@ -0,0 +1,155 @@
+package agents
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"codex-ui/internal/git/worktrees"
+	"codex-ui/internal/storage/discovery"
+)
+
+// notesAdapter appends the prompt's last line to notes.txt in the working
+// directory, standing in for an agent that edits files, and replies with the
+// line it wrote.
+type notesAdapter struct {
+	mu     sync.Mutex
+	inputs []string
+}
+
+func (a *notesAdapter) Stream(ctx context.Context, req MessageRequest) (*StreamResult, error) {
+	a.mu.Lock()
+	a.inputs = append(a.inputs, req.Input)
+	a.mu.Unlock()
+	lines := strings.Split(req.Input, "\n")
+	line := lines[len(lines)-1]
+	path := filepath.Join(req.ThreadOptions.WorkingDirectory, "notes.txt")
+	existing, _ := os.ReadFile(path)
+	if err := os.WriteFile(path, append(existing, []byte(line+"\n")...), 0o644); err != nil {
+		return nil, err
+	}
+	events := make(chan StreamEvent)
+	done := make(chan error, 1)
+	go func() {
+		defer close(events)
+		defer close(done)
+		events <- StreamEvent{Type: "item.completed", Item: &AgentItemDTO{ID: "m1", Type: entryTypeAgentMessage, Text: "Wrote " + line + " to notes.txt."}}
+		events <- StreamEvent{Type: "turn.completed", Usage: &UsageDTO{InputTokens: 10, CachedInputTokens: 2, OutputTokens: 3}}
+		done <- nil
+	}()
+	return &StreamResult{Events: events, Done: done}, nil
+}
+
+// newGitProject registers a project backed by a fresh git repository and
+// enables worktrees on svc.
+func newGitProject(t *testing.T, svc *Service, repo *discovery.Repository) discovery.Project {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available in PATH")
+	}
+	repoDir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"config", "user.email", "you@example.com"},
+		{"config", "user.name", "Your Name"},
+		{"commit", "-q", "--allow-empty", "-m", "init"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repoDir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	project, err := repo.UpsertProject(context.Background(), discovery.UpsertProjectParams{Path: repoDir})
+	if err != nil {
+		t.Fatalf("upsert project: %v", err)
+	}
+	svc.worktrees = worktrees.NewManager(t.TempDir(), "")
+	return project
+}
+
+func TestService_CheckpointsRestoreEarlierTurns(t *testing.T) {
+	adapter := &notesAdapter{}
+	svc, repo, _ := newTestService(t, adapter)
+	ctx := context.Background()
+	project := newGitProject(t, svc, repo)
+
+	thread, err := sendAndWait(t, svc, MessageRequest{ProjectID: project.ID, Input: "first"})
+	if err != nil {
+		t.Fatalf("first turn: %v", err)
+	}
+	for _, input := range []string{"second", "third"} {
+		if _, err := sendAndWait(t, svc, MessageRequest{ThreadID: thread.ID, Input: input}); err != nil {
+			t.Fatalf("%s turn: %v", input, err)
+		}
+	}
+	stored, _ := repo.GetThread(ctx, thread.ID)
+	notes := filepath.Join(stored.WorktreePath, "notes.txt")
+	read := func() string {
+		data, err := os.ReadFile(notes)
+		if err != nil {
+			return "<missing>"
+		}
+		return string(data)
+	}
+	if got := read(); got != "first\nsecond\nthird\n" {
+		t.Fatalf("unexpected notes %q", got)
+	}
+
+	checkpoints, err := svc.ListCheckpoints(ctx, thread.ID)
+	if err != nil {
+		t.Fatalf("list checkpoints: %v", err)
+	}
+	if len(checkpoints) != 3 || checkpoints[1].Prompt != "second" {
+		t.Fatalf("expected one checkpoint per turn, got %+v", checkpoints)
+	}
+
+	restored, err := svc.RestoreCheckpoint(ctx, thread.ID, checkpoints[1].ID)
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if restored.RestoredAt == "" {
+		t.Fatalf("expected restore time, got %+v", restored)
+	}
+	if got := read(); got != "first\n" {
+		t.Fatalf("expected the second and third turns to be undone, got %q", got)
+	}
+	entries, _ := svc.LoadThreadConversation(ctx, thread.ID)
+	var rolledBack []string
+	for _, entry := range entries {
+		if entry.RolledBack && entry.Role == "user" {
+			rolledBack = append(rolledBack, entry.Text)
+		}
+	}
+	if strings.Join(rolledBack, ",") != "second,third" {
+		t.Fatalf("unexpected rolled back entries %q", rolledBack)
+	}
+	if last := entries[len(entries)-1]; last.Role != "system" || !strings.Contains(last.Message, "before “second”") || last.RolledBack {
+		t.Fatalf("unexpected restore entry %+v", last)
+	}
+
+	if _, err := sendAndWait(t, svc, MessageRequest{ThreadID: thread.ID, Input: "fourth"}); err != nil {
+		t.Fatalf("fourth turn: %v", err)
+	}
+	if got := adapter.inputs[len(adapter.inputs)-1]; !strings.Contains(got, "workspace was rolled back") {
+		t.Fatalf("expected the agent to be told about the rollback, got %q", got)
+	}
+	if got := read(); got != "first\nfourth\n" {
+		t.Fatalf("unexpected notes after rollback %q", got)
+	}
+
+	// Restoring a later checkpoint brings its turns back.
+	if _, err := svc.RestoreCheckpoint(ctx, thread.ID, checkpoints[2].ID); err != nil {
+		t.Fatalf("restore later checkpoint: %v", err)
+	}
+	if got := read(); got != "first\nsecond\n" {
+		t.Fatalf("unexpected notes after redo %q", got)
+	}
+	if _, err := svc.RestoreCheckpoint(ctx, thread.ID+1, checkpoints[0].ID); err == nil {
+		t.Fatal("expected checkpoints of other threads to be rejected")
+	}
+}
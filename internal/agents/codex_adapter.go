@@ -12,9 +12,19 @@ import (
 	"github.com/activadee/godex"
 )
 
+// reasoningEffortConfigKey is the Codex CLI config key for the reasoning level.
+const reasoningEffortConfigKey = "model_reasoning_effort"
+
 // CodexAdapter streams turns through the Codex CLI.
 type CodexAdapter struct {
 	client codexClient
+
+	// The reasoning level is a CLI config override, which godex fixes per
+	// client, so one client is kept per requested level.
+	options   godex.CodexOptions
+	newClient func(godex.CodexOptions) (codexClient, error)
+	mu        sync.Mutex
+	byLevel   map[string]codexClient
 }
 
 // CodexOptionsFromEnv builds Codex options using environment overrides.
@@ -35,7 +45,35 @@ func NewCodexAdapter(options godex.CodexOptions) (*CodexAdapter, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &CodexAdapter{client: client}, nil
+	return &CodexAdapter{client: client, options: options, newClient: newDefaultCodexClient}, nil
+}
+
+// clientFor returns the client that runs turns at the given reasoning level.
+func (a *CodexAdapter) clientFor(level string) (codexClient, error) {
+	level = strings.TrimSpace(level)
+	if level == "" || a.newClient == nil {
+		return a.client, nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if client, ok := a.byLevel[level]; ok {
+		return client, nil
+	}
+	opts := a.options
+	opts.ConfigOverrides = make(map[string]any, len(a.options.ConfigOverrides)+1)
+	for k, v := range a.options.ConfigOverrides {
+		opts.ConfigOverrides[k] = v
+	}
+	opts.ConfigOverrides[reasoningEffortConfigKey] = level
+	client, err := a.newClient(opts)
+	if err != nil {
+		return nil, fmt.Errorf("initialise codex client for reasoning level %s: %w", level, err)
+	}
+	if a.byLevel == nil {
+		a.byLevel = make(map[string]codexClient)
+	}
+	a.byLevel[level] = client
+	return client, nil
 }
 
 // Stream implements Adapter.
@@ -51,10 +89,11 @@ func (a *CodexAdapter) Stream(ctx context.Context, req MessageRequest) (*StreamR
 		SkipGitRepoCheck: req.ThreadOptions.SkipGitRepoCheck,
 	}
 
-	thread, err := a.selectThread(req.ThreadExternalID, threadOpts)
+	client, err := a.clientFor(req.ThreadOptions.ReasoningLevel)
 	if err != nil {
 		return nil, err
 	}
+	thread := selectThread(client, req.ThreadExternalID, threadOpts)
 
 	turnOpts, err := buildTurnOptions(req.TurnOptions)
 	if err != nil {
@@ -133,11 +172,11 @@ func (a *CodexAdapter) Stream(ctx context.Context, req MessageRequest) (*StreamR
 	}, nil
 }
 
-func (a *CodexAdapter) selectThread(threadID string, options godex.ThreadOptions) (threadRunner, error) {
+func selectThread(client codexClient, threadID string, options godex.ThreadOptions) threadRunner {
 	if strings.TrimSpace(threadID) == "" {
-		return a.client.StartThread(options), nil
+		return client.StartThread(options)
 	}
-	return a.client.ResumeThread(threadID, options), nil
+	return client.ResumeThread(threadID, options)
 }
 
 func buildTurnOptions(turn *TurnOptionsDTO) (*godex.TurnOptions, error) {
@@ -3,6 +3,8 @@ package agents
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"codex-ui/internal/storage/discovery"
@@ -43,6 +45,13 @@ func marshalSystemMessagePayload(tone, message string, meta map[string]any) (jso
 	return json.Marshal(payload)
 }
 
+// UsageEntry returns the system entry a finished turn records for its token
+// usage.
+func UsageEntry(usage UsageDTO) ConversationEntryDTO {
+	message, meta := buildUsageSystemMessage(&usage)
+	return ConversationEntryDTO{Role: "system", Tone: "info", Message: message, Meta: meta}
+}
+
 func buildUsageSystemMessage(usage *UsageDTO) (string, map[string]any) {
 	if usage == nil {
 		return "", nil
@@ -56,16 +65,44 @@ func buildUsageSystemMessage(usage *UsageDTO) (string, map[string]any) {
 	return message, meta
 }
 
+const entryIDPrefix = "entry-"
+
+// itemKey keys the entry of an agent item: item IDs are only unique within a
+// turn, so the key is scoped by the user entry that started it.
+func itemKey(turnEntryID int64, itemID string) string {
+	return fmt.Sprintf("%d:%s", turnEntryID, itemID)
+}
+
+func formatEntryID(id int64) string {
+	return fmt.Sprintf("%s%d", entryIDPrefix, id)
+}
+
+// parseEntryID accepts both the "entry-<id>" form used by ConversationEntryDTO
+// and a bare numeric identifier.
+func parseEntryID(value string) (int64, error) {
+	trimmed := strings.TrimPrefix(strings.TrimSpace(value), entryIDPrefix)
+	id, err := strconv.ParseInt(trimmed, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid entry id %q", value)
+	}
+	return id, nil
+}
+
 func conversationEntryToDTO(entry discovery.ConversationEntry) (ConversationEntryDTO, error) {
 	dto := ConversationEntryDTO{
-		ID:        fmt.Sprintf("entry-%d", entry.ID),
-		Role:      entry.Role,
-		CreatedAt: entry.CreatedAt.Format(time.RFC3339),
+		ID:         formatEntryID(entry.ID),
+		Role:       entry.Role,
+		CreatedAt:  entry.CreatedAt.Format(time.RFC3339),
+		RolledBack: entry.RolledBackAt != nil,
 	}
 	if !entry.UpdatedAt.IsZero() {
 		updated := entry.UpdatedAt.Format(time.RFC3339)
 		dto.UpdatedAt = &updated
 	}
+	if entry.CompletedAt != nil {
+		completed := entry.CompletedAt.Format(time.RFC3339)
+		dto.CompletedAt = &completed
+	}
 
 	switch entry.Role {
 	case "user":
@@ -0,0 +1,56 @@
+package agents
+
+import (
+	"context"
+	"testing"
+)
+
+func TestService_StoresOneEntryPerItem(t *testing.T) {
+	command := func(status, output string) *AgentItemDTO {
+		return &AgentItemDTO{ID: "item_0", Type: "command_execution", Command: &CommandExecutionDTO{Command: "make", Status: status, AggregatedOutput: output}}
+	}
+	adapter := &scriptedAdapter{events: []StreamEvent{
+		{Type: "item.started", Item: command("in_progress", "")},
+		{Type: "item.updated", Item: command("in_progress", "a")},
+		{Type: "item.completed", Item: &AgentItemDTO{ID: "item_1", Type: entryTypeAgentMessage, Text: "done"}},
+		{Type: "item.completed", Item: command("completed", "ab")},
+		{Type: "turn.completed", Usage: &UsageDTO{}},
+	}}
+	svc, _, project := newTestService(t, adapter)
+	ctx := context.Background()
+
+	thread, err := sendAndWait(t, svc, MessageRequest{ProjectID: project.ID, Input: "first", ThreadOptions: ThreadOptionsDTO{Model: "m"}})
+	if err != nil {
+		t.Fatalf("send first: %v", err)
+	}
+	// Codex numbers items per turn, so the next turn reuses the same IDs.
+	if _, err := sendAndWait(t, svc, MessageRequest{ThreadID: thread.ID, Input: "second"}); err != nil {
+		t.Fatalf("send second: %v", err)
+	}
+
+	entries, err := svc.LoadThreadConversation(ctx, thread.ID)
+	if err != nil {
+		t.Fatalf("load conversation: %v", err)
+	}
+	var commands, replies int
+	for _, entry := range entries {
+		if entry.Item == nil {
+			continue
+		}
+		switch entry.Item.Type {
+		case "command_execution":
+			commands++
+			if entry.Item.Command.Status != "completed" || entry.Item.Command.AggregatedOutput != "ab" {
+				t.Fatalf("expected the command's final state, got %+v", entry.Item.Command)
+			}
+			if entry.CompletedAt == nil {
+				t.Fatalf("expected the command to be marked completed, got %+v", entry)
+			}
+		case entryTypeAgentMessage:
+			replies++
+		}
+	}
+	if commands != 2 || replies != 2 {
+		t.Fatalf("expected one command and one reply per turn, got %d commands and %d replies in %+v", commands, replies, entries)
+	}
+}
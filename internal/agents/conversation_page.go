@@ -0,0 +1,135 @@
+package agents
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"codex-ui/internal/storage/discovery"
+)
+
+const (
+	defaultConversationPageSize = 100
+	maxConversationPageSize     = 500
+	// summaryFieldBytes is the size above which summary entries leave out
+	// command output, reasoning and structured output.
+	summaryFieldBytes = 1024
+)
+
+// ConversationPageQuery selects up to Limit entries of a thread before or
+// after a cursor entry ID. With neither set it selects the latest entries.
+// Summary leaves out large fields, marking those entries Partial.
+type ConversationPageQuery struct {
+	ThreadID int64  `json:"threadId"`
+	Before   string `json:"before,omitempty"`
+	After    string `json:"after,omitempty"`
+	Limit    int    `json:"limit,omitempty"`
+	Summary  bool   `json:"summary,omitempty"`
+}
+
+// ConversationPageDTO is a page of a thread's timeline in chronological
+// order. The first and last entry IDs are the cursors for the neighbouring
+// pages.
+type ConversationPageDTO struct {
+	Entries       []ConversationEntryDTO `json:"entries"`
+	HasMoreBefore bool                   `json:"hasMoreBefore"`
+	HasMoreAfter  bool                   `json:"hasMoreAfter"`
+}
+
+// LoadConversationPage returns one page of a thread's timeline.
+func (s *Service) LoadConversationPage(ctx context.Context, query ConversationPageQuery) (ConversationPageDTO, error) {
+	if err := s.ensureRepo(); err != nil {
+		return ConversationPageDTO{}, err
+	}
+	if query.Before != "" && query.After != "" {
+		return ConversationPageDTO{}, errors.New("set either before or after, not both")
+	}
+	page := discovery.ConversationEntryPage{ThreadID: query.ThreadID, Limit: query.Limit}
+	switch {
+	case page.Limit <= 0:
+		page.Limit = defaultConversationPageSize
+	case page.Limit > maxConversationPageSize:
+		page.Limit = maxConversationPageSize
+	}
+	var err error
+	if query.Before != "" {
+		if page.BeforeID, err = parseEntryID(query.Before); err != nil {
+			return ConversationPageDTO{}, err
+		}
+	}
+	if query.After != "" {
+		if page.AfterID, err = parseEntryID(query.After); err != nil {
+			return ConversationPageDTO{}, err
+		}
+	}
+	if _, err := s.repo.GetThread(ctx, query.ThreadID); err != nil {
+		return ConversationPageDTO{}, err
+	}
+	entries, more, err := s.repo.ListConversationEntriesPage(ctx, page)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ConversationPageDTO{}, fmt.Errorf("cursor entry is not part of thread %d", query.ThreadID)
+		}
+		return ConversationPageDTO{}, err
+	}
+	out := ConversationPageDTO{Entries: make([]ConversationEntryDTO, 0, len(entries))}
+	if page.AfterID != 0 {
+		out.HasMoreBefore, out.HasMoreAfter = true, more
+	} else {
+		out.HasMoreBefore, out.HasMoreAfter = more, page.BeforeID != 0
+	}
+	for _, entry := range entries {
+		dto, err := conversationEntryToDTO(entry)
+		if err != nil {
+			return ConversationPageDTO{}, err
+		}
+		if query.Summary {
+			summarizeEntry(&dto)
+		}
+		out.Entries = append(out.Entries, dto)
+	}
+	return out, nil
+}
+
+// GetConversationEntry returns one entry of a thread in full, for expanding
+// a partial summary entry.
+func (s *Service) GetConversationEntry(ctx context.Context, threadID, entryID int64) (ConversationEntryDTO, error) {
+	if err := s.ensureRepo(); err != nil {
+		return ConversationEntryDTO{}, err
+	}
+	entry, err := s.repo.GetConversationEntry(ctx, entryID)
+	if err != nil {
+		return ConversationEntryDTO{}, err
+	}
+	if entry.ThreadID != threadID {
+		return ConversationEntryDTO{}, fmt.Errorf("entry %d is not part of thread %d", entryID, threadID)
+	}
+	return conversationEntryToDTO(entry)
+}
+
+// summarizeEntry drops the large fields of an agent item.
+func summarizeEntry(dto *ConversationEntryDTO) {
+	item := dto.Item
+	if item == nil {
+		return
+	}
+	trimmed := *item
+	if len(trimmed.Reasoning) > summaryFieldBytes {
+		trimmed.Reasoning = ""
+		dto.Partial = true
+	}
+	if trimmed.Command != nil && len(trimmed.Command.AggregatedOutput) > summaryFieldBytes {
+		command := *trimmed.Command
+		command.AggregatedOutput = ""
+		trimmed.Command = &command
+		dto.Partial = true
+	}
+	if trimmed.StructuredOutput != nil && len(trimmed.StructuredOutput.Output) > summaryFieldBytes {
+		output := *trimmed.StructuredOutput
+		output.Output = nil
+		trimmed.StructuredOutput = &output
+		dto.Partial = true
+	}
+	dto.Item = &trimmed
+}
@@ -0,0 +1,110 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"codex-ui/internal/storage/discovery"
+)
+
+func TestService_LoadConversationPageWalksBothWays(t *testing.T) {
+	svc, repo, project := newTestService(t, &scriptedAdapter{})
+	ctx := context.Background()
+	thread, err := repo.CreateThread(ctx, discovery.CreateThreadParams{ProjectID: project.ID, Title: "paging", Model: "m"})
+	if err != nil {
+		t.Fatalf("create thread: %v", err)
+	}
+	other, _ := repo.CreateThread(ctx, discovery.CreateThreadParams{ProjectID: project.ID, Title: "other", Model: "m"})
+	otherEntry, _ := repo.CreateConversationEntry(ctx, discovery.CreateConversationEntryParams{ThreadID: other.ID, Role: "user", EntryType: entryTypeUserMessage})
+
+	// Entries 1-3 share a timestamp, so pages must break ties on the id.
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	var ids []string
+	for i := 0; i < 5; i++ {
+		item := &AgentItemDTO{Type: entryTypeAgentMessage, Text: "reply"}
+		if i == 2 {
+			item = &AgentItemDTO{Type: "command_execution", Command: &CommandExecutionDTO{Command: "make", AggregatedOutput: strings.Repeat("x", summaryFieldBytes+1), Status: "completed"}}
+		}
+		payload, _ := json.Marshal(item)
+		at := base.Add(time.Duration(max(i-2, 0)) * time.Minute)
+		entry, err := repo.CreateConversationEntry(ctx, discovery.CreateConversationEntryParams{ThreadID: thread.ID, Role: "agent", EntryType: item.Type, Payload: payload, CreatedAt: at})
+		if err != nil {
+			t.Fatalf("create entry: %v", err)
+		}
+		ids = append(ids, formatEntryID(entry.ID))
+	}
+	pageIDs := func(page ConversationPageDTO) []string {
+		var out []string
+		for _, entry := range page.Entries {
+			out = append(out, entry.ID)
+		}
+		return out
+	}
+
+	// Walk back from the latest page.
+	var backward []string
+	query := ConversationPageQuery{ThreadID: thread.ID, Limit: 2}
+	for {
+		page, err := svc.LoadConversationPage(ctx, query)
+		if err != nil {
+			t.Fatalf("load page: %v", err)
+		}
+		if query.Before == "" && page.HasMoreAfter {
+			t.Fatal("the latest page cannot have entries after it")
+		}
+		backward = append(pageIDs(page), backward...)
+		if !page.HasMoreBefore {
+			break
+		}
+		query.Before = page.Entries[0].ID
+	}
+	if strings.Join(backward, ",") != strings.Join(ids, ",") {
+		t.Fatalf("walking back: got %v, want %v", backward, ids)
+	}
+
+	page, err := svc.LoadConversationPage(ctx, ConversationPageQuery{ThreadID: thread.ID, After: ids[1], Limit: 2})
+	if err != nil {
+		t.Fatalf("load page after: %v", err)
+	}
+	if got := pageIDs(page); strings.Join(got, ",") != ids[2]+","+ids[3] || !page.HasMoreBefore || !page.HasMoreAfter {
+		t.Fatalf("unexpected page after %s: %v %+v", ids[1], got, page)
+	}
+	if page.Entries[0].Partial || page.Entries[0].Item.Command.AggregatedOutput == "" {
+		t.Fatalf("expected full entries outside summary mode, got %+v", page.Entries[0])
+	}
+
+	summary, err := svc.LoadConversationPage(ctx, ConversationPageQuery{ThreadID: thread.ID, After: ids[1], Limit: 2, Summary: true})
+	if err != nil {
+		t.Fatalf("load summary: %v", err)
+	}
+	command := summary.Entries[0]
+	if !command.Partial || command.Item.Command.AggregatedOutput != "" || command.Item.Command.Command != "make" {
+		t.Fatalf("expected the command output to be left out, got %+v", command.Item.Command)
+	}
+	if summary.Entries[1].Partial || summary.Entries[1].Item.Text != "reply" {
+		t.Fatalf("expected small entries to stay whole, got %+v", summary.Entries[1])
+	}
+	expanded, err := svc.GetConversationEntry(ctx, thread.ID, mustParseEntryID(t, command.ID))
+	if err != nil || len(expanded.Item.Command.AggregatedOutput) != summaryFieldBytes+1 {
+		t.Fatalf("expected the expanded entry in full, got %+v (err %v)", expanded.Item, err)
+	}
+
+	if _, err := svc.LoadConversationPage(ctx, ConversationPageQuery{ThreadID: thread.ID, Before: formatEntryID(otherEntry.ID)}); err == nil {
+		t.Fatal("expected a cursor from another thread to be rejected")
+	}
+	if _, err := svc.GetConversationEntry(ctx, thread.ID, otherEntry.ID); err == nil {
+		t.Fatal("expected entries of other threads to be rejected")
+	}
+}
+
+func mustParseEntryID(t *testing.T, value string) int64 {
+	t.Helper()
+	id, err := parseEntryID(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
@@ -0,0 +1,196 @@
+package agents
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"codex-ui/internal/git/worktrees"
+	"codex-ui/internal/storage/discovery"
+)
+
+// forkContextEntryLimit caps how many copied transcript entries are replayed to
+// the agent when a forked thread starts its first turn.
+const forkContextEntryLimit = 40
+
+// ForkThread creates a new thread that copies the source transcript up to and
+// including entryID and gets its own worktree branched from the source thread's
+// branch, even after the source's worktree was released. It fails when that
+// branch no longer exists. Uncommitted changes in the source worktree are not
+// carried over.
+func (s *Service) ForkThread(ctx context.Context, threadID, entryID int64) (ThreadDTO, error) {
+	if err := s.ensureRepo(); err != nil {
+		return ThreadDTO{}, err
+	}
+	source, err := s.repo.GetThread(ctx, threadID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ThreadDTO{}, fmt.Errorf("thread %d not found", threadID)
+		}
+		return ThreadDTO{}, err
+	}
+	entry, err := s.repo.GetConversationEntry(ctx, entryID)
+	if err != nil || entry.ThreadID != source.ID {
+		return ThreadDTO{}, fmt.Errorf("entry %d not found in thread %d", entryID, threadID)
+	}
+	if entry.RolledBackAt != nil {
+		return ThreadDTO{}, fmt.Errorf("entry %d was rolled back by a restored checkpoint", entryID)
+	}
+
+	title := forkTitle(source.Title)
+	fork, err := s.repo.CreateThread(ctx, discovery.CreateThreadParams{
+		ProjectID:         source.ProjectID,
+		Title:             title,
+		Model:             source.Model,
+		SandboxMode:       source.SandboxMode,
+		ReasoningLevel:    source.ReasoningLevel,
+		ParentThreadID:    source.ID,
+		ForkedFromEntryID: entry.ID,
+		AgentID:           source.AgentID,
+	})
+	if err != nil {
+		return ThreadDTO{}, err
+	}
+	// Drop the half-made fork, and its worktree, if a later step fails.
+	var wtPath string
+	forked := false
+	defer func() {
+		if forked {
+			return
+		}
+		if wtPath != "" {
+			_ = s.worktrees.RemoveForThread(context.Background(), wtPath)
+		}
+		_ = s.repo.DeleteThread(context.Background(), fork.ID)
+	}()
+
+	branch := worktrees.BranchName(title, fork.ID)
+	if err := s.repo.UpdateThreadBranchName(ctx, fork.ID, branch); err != nil {
+		return ThreadDTO{}, err
+	}
+	if _, err := s.repo.CopyConversationEntries(ctx, source.ID, fork.ID, entry.ID); err != nil {
+		return ThreadDTO{}, err
+	}
+	if err := s.repo.UpdateThreadStatus(ctx, fork.ID, discovery.ThreadStatusCompleted, &entry.CreatedAt); err != nil {
+		return ThreadDTO{}, err
+	}
+
+	if s.worktrees != nil {
+		project, err := s.repo.GetProjectByID(ctx, source.ProjectID)
+		if err != nil {
+			return ThreadDTO{}, err
+		}
+		baseRef, err := s.forkBaseRef(ctx, project.Path, source)
+		if err != nil {
+			return ThreadDTO{}, err
+		}
+		wtPath, _, _, err = s.worktrees.EnsureForThreadFrom(ctx, project.Path, fork.ID, title, branch, baseRef)
+		if err != nil {
+			return ThreadDTO{}, err
+		}
+		if err := s.repo.UpdateThreadWorktreePath(ctx, fork.ID, wtPath); err != nil {
+			return ThreadDTO{}, err
+		}
+	}
+
+	dto, err := s.GetThread(ctx, fork.ID)
+	if err != nil {
+		return ThreadDTO{}, err
+	}
+	forked = true
+	return dto, nil
+}
+
+// forkBaseRef returns the ref a fork of source branches from: the source's
+// branch, which outlives its worktree. Only an imported thread may lack its
+// branch, since the branch is created by its first turn; its fork starts from
+// the project's current ref like the thread itself would.
+func (s *Service) forkBaseRef(ctx context.Context, projectPath string, source discovery.Thread) (string, error) {
+	branch := strings.TrimSpace(source.BranchName)
+	if branch == "" {
+		return "", nil
+	}
+	exists, err := s.worktrees.BranchExists(ctx, projectPath, branch)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case exists:
+		return branch, nil
+	case source.ImportedAt != nil && strings.TrimSpace(source.WorktreePath) == "":
+		return "", nil
+	default:
+		return "", fmt.Errorf("branch %s of thread %d no longer exists", branch, source.ID)
+	}
+}
+
+func forkTitle(title string) string {
+	trimmed := strings.TrimSpace(title)
+	if trimmed == "" {
+		return "Fork"
+	}
+	return trimmed + " (fork)"
+}
+
+// buildForkContext condenses the transcript copied into a fork, or imported
+// from an archive, so the agent, which starts a fresh session, knows what
+// happened before.
+func (s *Service) buildForkContext(ctx context.Context, thread discovery.Thread) string {
+	if strings.TrimSpace(thread.ExternalID) != "" {
+		return ""
+	}
+	origin := "forked from"
+	switch {
+	case thread.ParentThreadID != 0:
+	case thread.ImportedAt != nil:
+		origin = "imported from"
+	default:
+		return ""
+	}
+	entries, err := s.repo.ListConversationEntries(ctx, thread.ID)
+	if err != nil || len(entries) == 0 {
+		return ""
+	}
+	var lines []string
+	for _, entry := range entries {
+		dto, err := conversationEntryToDTO(entry)
+		if err != nil {
+			continue
+		}
+		switch {
+		case dto.Role == "user" && strings.TrimSpace(dto.Text) != "":
+			lines = append(lines, "User: "+strings.TrimSpace(dto.Text))
+		case dto.Item != nil && dto.Item.Type == entryTypeAgentMessage && strings.TrimSpace(dto.Item.Text) != "":
+			lines = append(lines, "Assistant: "+strings.TrimSpace(dto.Item.Text))
+		case dto.Item != nil && dto.Item.Command != nil:
+			lines = append(lines, "Command run: "+dto.Item.Command.Command)
+		}
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	if len(lines) > forkContextEntryLimit {
+		lines = lines[len(lines)-forkContextEntryLimit:]
+	}
+	return "This thread was " + origin + " an earlier conversation. Conversation so far:\n\n" +
+		strings.Join(lines, "\n\n") +
+		"\n\nContinue from here."
+}
+
+// prependInstructions places text ahead of the user's input for the adapter
+// without changing what is persisted as the user's message.
+func prependInstructions(req *MessageRequest, text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	if len(req.Segments) == 0 {
+		req.Input = text + "\n\n" + req.Input
+		return
+	}
+	segments := make([]InputSegmentDTO, 0, len(req.Segments)+1)
+	segments = append(segments, InputSegmentDTO{Type: "text", Text: text})
+	req.Segments = append(segments, req.Segments...)
+}
@@ -0,0 +1,196 @@
+package agents
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"codex-ui/internal/git/worktrees"
+	"codex-ui/internal/storage/discovery"
+)
+
+func TestService_ForkThreadCopiesEntriesUpToPoint(t *testing.T) {
+	svc, repo, project := newTestService(t, &scriptedAdapter{})
+	ctx := context.Background()
+
+	source, err := repo.CreateThread(ctx, discovery.CreateThreadParams{ProjectID: project.ID, Title: "Fix login", Model: "m"})
+	if err != nil {
+		t.Fatalf("create thread: %v", err)
+	}
+	var ids []int64
+	for _, text := range []string{"one", "two", "three"} {
+		payload, _ := marshalUserEntryPayload(text, nil)
+		entry, err := repo.CreateConversationEntry(ctx, discovery.CreateConversationEntryParams{ThreadID: source.ID, Role: "user", EntryType: entryTypeUserMessage, Payload: payload})
+		if err != nil {
+			t.Fatalf("create entry: %v", err)
+		}
+		ids = append(ids, entry.ID)
+	}
+
+	fork, err := svc.ForkThread(ctx, source.ID, ids[1])
+	if err != nil {
+		t.Fatalf("fork thread: %v", err)
+	}
+	if fork.ParentThreadID != source.ID || fork.ForkedFromEntryID != formatEntryID(ids[1]) {
+		t.Fatalf("unexpected lineage: parent=%d entry=%s", fork.ParentThreadID, fork.ForkedFromEntryID)
+	}
+	if fork.Title != "Fix login (fork)" {
+		t.Fatalf("unexpected fork title %q", fork.Title)
+	}
+	entries, err := svc.LoadThreadConversation(ctx, fork.ID)
+	if err != nil {
+		t.Fatalf("load fork conversation: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Text != "one" || entries[1].Text != "two" {
+		t.Fatalf("unexpected fork entries: %+v", entries)
+	}
+
+	record, err := repo.GetThread(ctx, fork.ID)
+	if err != nil {
+		t.Fatalf("get fork: %v", err)
+	}
+	preamble := svc.buildForkContext(ctx, record)
+	if !strings.Contains(preamble, "User: one") || strings.Contains(preamble, "three") {
+		t.Fatalf("unexpected fork context %q", preamble)
+	}
+
+	if _, err := svc.ForkThread(ctx, fork.ID, ids[2]); err == nil {
+		t.Fatalf("expected error forking from an entry of another thread")
+	}
+}
+
+func TestService_ForkThreadLeavesOutRolledBackTurns(t *testing.T) {
+	svc, repo, project := newTestService(t, &scriptedAdapter{})
+	ctx := context.Background()
+
+	source, err := repo.CreateThread(ctx, discovery.CreateThreadParams{ProjectID: project.ID, Title: "Fix login", Model: "m"})
+	if err != nil {
+		t.Fatalf("create thread: %v", err)
+	}
+	addEntry := func(text string) int64 {
+		t.Helper()
+		payload, _ := marshalUserEntryPayload(text, nil)
+		entry, err := repo.CreateConversationEntry(ctx, discovery.CreateConversationEntryParams{ThreadID: source.ID, Role: "user", EntryType: entryTypeUserMessage, Payload: payload})
+		if err != nil {
+			t.Fatalf("create entry: %v", err)
+		}
+		return entry.ID
+	}
+	addEntry("one")
+	undone := addEntry("two")
+	// Restoring the checkpoint before "two" undoes its turn.
+	if err := repo.RollBackEntries(ctx, 0, source.ID, undone); err != nil {
+		t.Fatalf("roll back: %v", err)
+	}
+	latest := addEntry("three")
+
+	fork, err := svc.ForkThread(ctx, source.ID, latest)
+	if err != nil {
+		t.Fatalf("fork thread: %v", err)
+	}
+	entries, err := svc.LoadThreadConversation(ctx, fork.ID)
+	if err != nil {
+		t.Fatalf("load fork conversation: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Text != "one" || entries[1].Text != "three" {
+		t.Fatalf("expected the undone turn to be left out, got %+v", entries)
+	}
+	record, err := repo.GetThread(ctx, fork.ID)
+	if err != nil {
+		t.Fatalf("get fork: %v", err)
+	}
+	if preamble := svc.buildForkContext(ctx, record); strings.Contains(preamble, "two") {
+		t.Fatalf("expected the fork context to leave out the undone turn, got %q", preamble)
+	}
+	if _, err := svc.ForkThread(ctx, source.ID, undone); err == nil {
+		t.Fatal("expected forking from a rolled-back entry to fail")
+	}
+}
+
+func TestParseEntryID(t *testing.T) {
+	if id, err := parseEntryID("entry-42"); err != nil || id != 42 {
+		t.Fatalf("entry-42 -> %d, %v", id, err)
+	}
+	if id, err := parseEntryID("7"); err != nil || id != 7 {
+		t.Fatalf("7 -> %d, %v", id, err)
+	}
+	if _, err := parseEntryID("entry-x"); err == nil {
+		t.Fatalf("expected error for invalid id")
+	}
+}
+
+func TestService_ForkThreadDropsForkOnFailure(t *testing.T) {
+	svc, repo, project := newTestService(t, &scriptedAdapter{})
+	ctx := context.Background()
+	// The project path is not a git repository, so the fork's worktree fails.
+	svc.worktrees = worktrees.NewManager(t.TempDir(), "")
+
+	source, err := repo.CreateThread(ctx, discovery.CreateThreadParams{ProjectID: project.ID, Title: "Fix login", Model: "m"})
+	if err != nil {
+		t.Fatalf("create thread: %v", err)
+	}
+	payload, _ := marshalUserEntryPayload("one", nil)
+	entry, err := repo.CreateConversationEntry(ctx, discovery.CreateConversationEntryParams{ThreadID: source.ID, Role: "user", EntryType: entryTypeUserMessage, Payload: payload})
+	if err != nil {
+		t.Fatalf("create entry: %v", err)
+	}
+
+	if _, err := svc.ForkThread(ctx, source.ID, entry.ID); err == nil {
+		t.Fatal("expected the fork to fail without a git repository")
+	}
+	threads, err := repo.ListThreads(ctx, discovery.ThreadFilter{ProjectID: project.ID, IncludeArchived: true})
+	if err != nil {
+		t.Fatalf("list threads: %v", err)
+	}
+	if len(threads) != 1 || threads[0].ID != source.ID {
+		t.Fatalf("expected the failed fork to be removed, got %+v", threads)
+	}
+}
+
+func TestService_ForkThreadBranchesFromReleasedSourceBranch(t *testing.T) {
+	svc, repo, _ := newTestService(t, &scriptedAdapter{})
+	ctx := context.Background()
+	project := newGitProject(t, svc, repo)
+	git := func(dir string, args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	// The source's work lives only on its branch; its worktree was released.
+	git(project.Path, "checkout", "-q", "-b", "feature")
+	git(project.Path, "commit", "-q", "--allow-empty", "-m", "source work")
+	git(project.Path, "checkout", "-q", "-")
+
+	fork := func(branch string) (ThreadDTO, error) {
+		source, err := repo.CreateThread(ctx, discovery.CreateThreadParams{ProjectID: project.ID, Title: "Source", Model: "m"})
+		if err != nil {
+			t.Fatalf("create thread: %v", err)
+		}
+		if err := repo.UpdateThreadBranchName(ctx, source.ID, branch); err != nil {
+			t.Fatalf("branch: %v", err)
+		}
+		payload, _ := marshalUserEntryPayload("one", nil)
+		entry, err := repo.CreateConversationEntry(ctx, discovery.CreateConversationEntryParams{ThreadID: source.ID, Role: "user", EntryType: entryTypeUserMessage, Payload: payload})
+		if err != nil {
+			t.Fatalf("create entry: %v", err)
+		}
+		return svc.ForkThread(ctx, source.ID, entry.ID)
+	}
+
+	forked, err := fork("feature")
+	if err != nil {
+		t.Fatalf("fork thread: %v", err)
+	}
+	if got := git(forked.WorktreePath, "log", "-1", "--format=%s"); got != "source work" {
+		t.Fatalf("expected the fork to start from the source branch, got %q", got)
+	}
+	if _, err := fork("deleted"); err == nil || !strings.Contains(err.Error(), "no longer exists") {
+		t.Fatalf("expected a missing source branch to fail the fork, got %v", err)
+	}
+}
@@ -0,0 +1,240 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"codex-ui/internal/git/worktrees"
+	"codex-ui/internal/storage/discovery"
+
+	"github.com/google/uuid"
+)
+
+// FanOutStream pairs a started stream with its thread. Err is set instead of
+// Stream when the variant could not be started.
+type FanOutStream struct {
+	Stream *Stream
+	Thread discovery.Thread
+	Err    error
+}
+
+// FanOut starts the same prompt on every variant in parallel. Each variant
+// gets its own thread and worktree; all threads share a new group ID.
+func (s *Service) FanOut(ctx context.Context, req FanOutRequest) (string, []FanOutStream, error) {
+	if err := s.ensureRepo(); err != nil {
+		return "", nil, err
+	}
+	if req.ProjectID == 0 {
+		return "", nil, errors.New("projectId is required")
+	}
+	if len(req.Variants) < 2 {
+		return "", nil, errors.New("fan-out needs at least two variants")
+	}
+	prompt := MessageRequest{ProjectID: req.ProjectID, Input: req.Input, TemplateID: req.TemplateID, TemplateName: req.TemplateName, TemplateValues: req.TemplateValues}
+	if err := s.applyTemplate(ctx, &prompt); err != nil {
+		return "", nil, err
+	}
+	req.Input = prompt.Input
+	if strings.TrimSpace(req.Input) == "" && len(req.Segments) == 0 {
+		return "", nil, errors.New("input text or segments are required")
+	}
+	for _, variant := range req.Variants {
+		agentID := strings.TrimSpace(variant.AgentID)
+		if agentID == "" {
+			agentID = s.defaultAgent
+		}
+		if _, err := s.loadAdapter(agentID); err != nil {
+			return "", nil, err
+		}
+		probe := MessageRequest{ProjectID: req.ProjectID, ThreadOptions: variant.ThreadOptions}
+		if _, err := s.resolveThreadOptions(ctx, agentID, &probe); err != nil {
+			return "", nil, err
+		}
+	}
+
+	groupID := uuid.NewString()
+	baseTitle := deriveTitle(req.Input, req.Segments)
+	results := make([]FanOutStream, 0, len(req.Variants))
+	for _, variant := range req.Variants {
+		agentID := strings.TrimSpace(variant.AgentID)
+		if agentID == "" {
+			agentID = s.defaultAgent
+		}
+		title := baseTitle
+		if label := variantLabel(variant); label != "" {
+			title = fmt.Sprintf("%s [%s]", baseTitle, label)
+		}
+		thread, err := s.repo.CreateThread(ctx, discovery.CreateThreadParams{
+			ProjectID:      req.ProjectID,
+			Title:          title,
+			Model:          variant.ThreadOptions.Model,
+			SandboxMode:    variant.ThreadOptions.SandboxMode,
+			ReasoningLevel: variant.ThreadOptions.ReasoningLevel,
+			GroupID:        groupID,
+			AgentID:        agentID,
+		})
+		if err != nil {
+			return groupID, results, err
+		}
+		_ = s.repo.UpdateThreadBranchName(ctx, thread.ID, worktrees.BranchName(title, thread.ID))
+
+		stream, started, err := s.Send(ctx, MessageRequest{
+			AgentID:       agentID,
+			ThreadID:      thread.ID,
+			Input:         req.Input,
+			Segments:      req.Segments,
+			ThreadOptions: variant.ThreadOptions,
+			TurnOptions:   req.TurnOptions,
+		})
+		if err != nil {
+			// Keep the sibling visible in the group with the reason it did not run.
+			s.recordSystemError(ctx, thread.ID, err.Error())
+			_ = s.repo.UpdateThreadStatus(ctx, thread.ID, discovery.ThreadStatusFailed, nil)
+			if refreshed, getErr := s.repo.GetThread(ctx, thread.ID); getErr == nil {
+				thread = refreshed
+			}
+			results = append(results, FanOutStream{Thread: thread, Err: err})
+			continue
+		}
+		results = append(results, FanOutStream{Stream: stream, Thread: started})
+	}
+	return groupID, results, nil
+}
+
+func variantLabel(variant FanOutVariantDTO) string {
+	var parts []string
+	if agent := strings.TrimSpace(variant.AgentID); agent != "" {
+		parts = append(parts, agent)
+	}
+	if model := strings.TrimSpace(variant.ThreadOptions.Model); model != "" {
+		parts = append(parts, model)
+	}
+	if level := strings.TrimSpace(variant.ThreadOptions.ReasoningLevel); level != "" {
+		parts = append(parts, level)
+	}
+	return strings.Join(parts, " · ")
+}
+
+func (s *Service) recordSystemError(ctx context.Context, threadID int64, message string) {
+	s.recordSystemEntry(ctx, threadID, "error", message, nil)
+}
+
+func (s *Service) recordSystemEntry(ctx context.Context, threadID int64, tone, message string, meta map[string]any) {
+	payload, err := marshalSystemMessagePayload(tone, message, meta)
+	if err != nil {
+		return
+	}
+	_, _ = s.repo.CreateConversationEntry(ctx, discovery.CreateConversationEntryParams{
+		ThreadID:  threadID,
+		Role:      "system",
+		EntryType: entryTypeSystemMessage,
+		Payload:   payload,
+	})
+}
+
+// CompareGroup summarises the threads of a fan-out group side by side.
+func (s *Service) CompareGroup(ctx context.Context, groupID string) (GroupComparisonDTO, error) {
+	if err := s.ensureRepo(); err != nil {
+		return GroupComparisonDTO{}, err
+	}
+	threads, err := s.repo.ListThreadsByGroup(ctx, groupID)
+	if err != nil {
+		return GroupComparisonDTO{}, err
+	}
+	if len(threads) == 0 {
+		return GroupComparisonDTO{}, fmt.Errorf("group %s not found", groupID)
+	}
+	comparison := GroupComparisonDTO{GroupID: groupID, Members: make([]GroupMemberDTO, 0, len(threads))}
+	for _, thread := range threads {
+		member := GroupMemberDTO{Thread: toThreadDTO(thread)}
+		if stats, err := s.ListThreadDiffStats(ctx, thread.ID); err == nil {
+			member.DiffStats = stats
+			for _, st := range stats {
+				member.DiffSummary.Added += st.Added
+				member.DiffSummary.Removed += st.Removed
+			}
+		}
+		entries, err := s.repo.ListConversationEntries(ctx, thread.ID)
+		if err != nil {
+			return GroupComparisonDTO{}, err
+		}
+		for _, entry := range entries {
+			dto, err := conversationEntryToDTO(entry)
+			if err != nil {
+				continue
+			}
+			if dto.Item != nil && dto.Item.Type == entryTypeAgentMessage && strings.TrimSpace(dto.Item.Text) != "" {
+				member.FinalMessage = dto.Item.Text
+			}
+		}
+		// Usage comes from the ledger so it matches the usage report.
+		totals, err := s.repo.SumTurnUsage(ctx, discovery.UsageFilter{ThreadID: thread.ID}, nil)
+		if err != nil {
+			return GroupComparisonDTO{}, err
+		}
+		for _, total := range totals {
+			member.Usage.InputTokens += int(total.InputTokens)
+			member.Usage.CachedInputTokens += int(total.CachedInputTokens)
+			member.Usage.OutputTokens += int(total.OutputTokens)
+		}
+		comparison.Members = append(comparison.Members, member)
+	}
+	return comparison, nil
+}
+
+// PromoteGroupWinner keeps winnerThreadID and archives the other threads of
+// its group, removing their worktrees. Branches are kept so discarded attempts
+// stay recoverable.
+func (s *Service) PromoteGroupWinner(ctx context.Context, groupID string, winnerThreadID int64) (GroupComparisonDTO, error) {
+	if err := s.ensureRepo(); err != nil {
+		return GroupComparisonDTO{}, err
+	}
+	threads, err := s.repo.ListThreadsByGroup(ctx, groupID)
+	if err != nil {
+		return GroupComparisonDTO{}, err
+	}
+	found := false
+	for _, thread := range threads {
+		if thread.ID == winnerThreadID {
+			found = true
+		}
+	}
+	if !found {
+		return GroupComparisonDTO{}, fmt.Errorf("thread %d is not part of group %s", winnerThreadID, groupID)
+	}
+	for _, thread := range threads {
+		if thread.ID == winnerThreadID || thread.ArchivedAt != nil {
+			continue
+		}
+		if s.isThreadActive(thread.ID) {
+			return GroupComparisonDTO{}, fmt.Errorf("thread %d is still running", thread.ID)
+		}
+	}
+	for _, thread := range threads {
+		if thread.ID == winnerThreadID || thread.ArchivedAt != nil {
+			continue
+		}
+		if err := s.archiveThread(ctx, thread); err != nil {
+			return GroupComparisonDTO{}, err
+		}
+	}
+	if err := s.repo.SetThreadArchived(ctx, winnerThreadID, false); err != nil {
+		return GroupComparisonDTO{}, err
+	}
+	return s.CompareGroup(ctx, groupID)
+}
+
+// archiveThread hides a thread from the default list and releases its
+// worktree. The branch and transcript are retained.
+func (s *Service) archiveThread(ctx context.Context, thread discovery.Thread) error {
+	_ = s.repo.DeleteQueuedTurnsForThread(ctx, thread.ID)
+	if s.worktrees != nil && strings.TrimSpace(thread.WorktreePath) != "" {
+		_ = s.worktrees.RemoveForThread(ctx, thread.WorktreePath)
+		if err := s.repo.UpdateThreadWorktreePath(ctx, thread.ID, ""); err != nil {
+			return err
+		}
+	}
+	return s.repo.SetThreadArchived(ctx, thread.ID, true)
+}
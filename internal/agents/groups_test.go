@@ -0,0 +1,93 @@
+package agents
+
+import (
+	"context"
+	"testing"
+)
+
+// modelEchoAdapter answers with the requested model name and fixed usage.
+type modelEchoAdapter struct{}
+
+func (modelEchoAdapter) Stream(ctx context.Context, req MessageRequest) (*StreamResult, error) {
+	events := make(chan StreamEvent, 2)
+	done := make(chan error, 1)
+	events <- StreamEvent{Type: "item.completed", Item: &AgentItemDTO{ID: "m", Type: entryTypeAgentMessage, Text: "answer from " + req.ThreadOptions.Model}}
+	events <- StreamEvent{Type: "turn.completed", Usage: &UsageDTO{InputTokens: len(req.ThreadOptions.Model), OutputTokens: 1}}
+	close(events)
+	done <- nil
+	close(done)
+	return &StreamResult{Events: events, Done: done}, nil
+}
+
+func TestService_FanOutCompareAndPromote(t *testing.T) {
+	svc, _, project := newTestService(t, modelEchoAdapter{})
+	ctx := context.Background()
+
+	groupID, started, err := svc.FanOut(ctx, FanOutRequest{
+		ProjectID: project.ID,
+		Input:     "refactor the parser",
+		Variants: []FanOutVariantDTO{
+			{ThreadOptions: ThreadOptionsDTO{Model: "small"}},
+			{ThreadOptions: ThreadOptionsDTO{Model: "larger", ReasoningLevel: "high"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("fan out: %v", err)
+	}
+	if groupID == "" || len(started) != 2 {
+		t.Fatalf("unexpected fan-out result %q %d", groupID, len(started))
+	}
+	for _, result := range started {
+		if result.Err != nil {
+			t.Fatalf("variant failed: %v", result.Err)
+		}
+		if result.Thread.GroupID != groupID {
+			t.Fatalf("thread %d not tagged with group", result.Thread.ID)
+		}
+		for range result.Stream.Events() {
+		}
+		if err := result.Stream.Wait(); err != nil {
+			t.Fatalf("wait: %v", err)
+		}
+	}
+
+	// Only the ledger counts; usage notes in the transcript are not re-read.
+	svc.recordSystemEntry(ctx, started[0].Thread.ID, "info", "Token usage", map[string]any{"inputTokens": 100})
+
+	comparison, err := svc.CompareGroup(ctx, groupID)
+	if err != nil {
+		t.Fatalf("compare: %v", err)
+	}
+	if len(comparison.Members) != 2 {
+		t.Fatalf("expected 2 members, got %d", len(comparison.Members))
+	}
+	first, second := comparison.Members[0], comparison.Members[1]
+	if first.FinalMessage != "answer from small" || second.FinalMessage != "answer from larger" {
+		t.Fatalf("unexpected final messages %q / %q", first.FinalMessage, second.FinalMessage)
+	}
+	if first.Usage.InputTokens != 5 || second.Usage.InputTokens != 6 {
+		t.Fatalf("unexpected usage %+v / %+v", first.Usage, second.Usage)
+	}
+	if second.Thread.Title != "refactor the parser [larger · high]" {
+		t.Fatalf("unexpected variant title %q", second.Thread.Title)
+	}
+
+	promoted, err := svc.PromoteGroupWinner(ctx, groupID, second.Thread.ID)
+	if err != nil {
+		t.Fatalf("promote: %v", err)
+	}
+	if promoted.Members[0].Thread.ArchivedAt == nil || promoted.Members[1].Thread.ArchivedAt != nil {
+		t.Fatalf("expected only the loser to be archived: %+v", promoted.Members)
+	}
+	threads, err := svc.ListThreads(ctx, project.ID, ThreadFilterDTO{})
+	if err != nil {
+		t.Fatalf("list threads: %v", err)
+	}
+	if len(threads) != 1 || threads[0].ID != second.Thread.ID {
+		t.Fatalf("expected only the winner to be listed, got %+v", threads)
+	}
+
+	if _, err := svc.PromoteGroupWinner(ctx, groupID, 999); err == nil {
+		t.Fatal("expected error for thread outside the group")
+	}
+}
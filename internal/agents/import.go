@@ -0,0 +1,198 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"codex-ui/internal/git/worktrees"
+	"codex-ui/internal/storage/discovery"
+)
+
+// ErrThreadReadOnly is returned by Send for threads imported as read-only.
+var ErrThreadReadOnly = errors.New("thread is read-only")
+
+// ImportThreadRequest recreates a thread from an exported transcript.
+type ImportThreadRequest struct {
+	ProjectID int64 `json:"projectId"`
+	// Thread supplies the title, agent and thread options; IDs, paths and
+	// lineage are not carried over.
+	Thread  ThreadDTO              `json:"thread"`
+	Entries []ConversationEntryDTO `json:"entries"`
+	// ReadOnly threads can be read and forked but refuse new turns. Otherwise
+	// the first new turn replays the imported transcript to the agent.
+	ReadOnly bool `json:"readOnly,omitempty"`
+	// ExternalID binds the thread to an existing local agent session, which
+	// new turns resume instead of replaying the transcript.
+	ExternalID       string `json:"externalId,omitempty"`
+	ConversationPath string `json:"conversationPath,omitempty"`
+	// Usage lists the token usage of the imported turns for the usage ledger.
+	// The transcript should carry matching entries from UsageEntry.
+	Usage []ImportedUsageDTO `json:"usage,omitempty"`
+}
+
+// ImportedUsageDTO is the token usage of one imported turn.
+type ImportedUsageDTO struct {
+	Usage UsageDTO `json:"usage"`
+	// CompletedAt is an RFC 3339 time; the import time is used when empty.
+	CompletedAt string `json:"completedAt,omitempty"`
+}
+
+// ImportThread stores an exported transcript as a new thread of the project.
+func (s *Service) ImportThread(ctx context.Context, req ImportThreadRequest) (ThreadDTO, error) {
+	if err := s.ensureRepo(); err != nil {
+		return ThreadDTO{}, err
+	}
+	if req.ProjectID == 0 {
+		return ThreadDTO{}, errors.New("projectId is required")
+	}
+	if _, err := s.repo.GetProjectByID(ctx, req.ProjectID); err != nil {
+		return ThreadDTO{}, err
+	}
+	params := make([]discovery.CreateConversationEntryParams, 0, len(req.Entries))
+	for i, entry := range req.Entries {
+		p, err := conversationEntryFromDTO(entry)
+		if err != nil {
+			return ThreadDTO{}, fmt.Errorf("entry %d: %w", i, err)
+		}
+		params = append(params, p)
+	}
+	completedAts := make([]time.Time, len(req.Usage))
+	for i, u := range req.Usage {
+		if u.CompletedAt == "" {
+			continue
+		}
+		completedAt, err := time.Parse(time.RFC3339, u.CompletedAt)
+		if err != nil {
+			return ThreadDTO{}, fmt.Errorf("usage %d: invalid completedAt: %w", i, err)
+		}
+		completedAts[i] = completedAt
+	}
+
+	title := strings.TrimSpace(req.Thread.Title)
+	if title == "" {
+		title = "Imported thread"
+		for _, entry := range req.Entries {
+			if entry.Role == "user" {
+				title = deriveTitle(entry.Text, entry.Segments)
+				break
+			}
+		}
+	}
+	importedAt := time.Now().UTC()
+	thread, err := s.repo.CreateThread(ctx, discovery.CreateThreadParams{
+		ProjectID:      req.ProjectID,
+		Title:          title,
+		Model:          req.Thread.Model,
+		SandboxMode:    req.Thread.SandboxMode,
+		ReasoningLevel: req.Thread.ReasoningLevel,
+		ReadOnly:       req.ReadOnly,
+		ImportedAt:     &importedAt,
+		AgentID:        strings.TrimSpace(req.Thread.AgentID),
+	})
+	if err != nil {
+		return ThreadDTO{}, err
+	}
+	// Drop the half-imported thread if a later step fails.
+	imported := false
+	defer func() {
+		if !imported {
+			_ = s.repo.DeleteThread(context.Background(), thread.ID)
+		}
+	}()
+
+	var last *time.Time
+	for _, p := range params {
+		p.ThreadID = thread.ID
+		entry, err := s.repo.CreateConversationEntry(ctx, p)
+		if err != nil {
+			return ThreadDTO{}, err
+		}
+		if last == nil || entry.CreatedAt.After(*last) {
+			createdAt := entry.CreatedAt
+			last = &createdAt
+		}
+	}
+	if externalID := strings.TrimSpace(req.ExternalID); externalID != "" {
+		if err := s.repo.UpdateThreadExternalID(ctx, thread.ID, externalID); err != nil {
+			return ThreadDTO{}, err
+		}
+	}
+	if err := s.repo.UpdateThreadConversationPath(ctx, thread.ID, strings.TrimSpace(req.ConversationPath)); err != nil {
+		return ThreadDTO{}, err
+	}
+	if err := s.repo.UpdateThreadBranchName(ctx, thread.ID, worktrees.BranchName(title, thread.ID)); err != nil {
+		return ThreadDTO{}, err
+	}
+	if err := s.repo.UpdateThreadStatus(ctx, thread.ID, discovery.ThreadStatusCompleted, last); err != nil {
+		return ThreadDTO{}, err
+	}
+	dto, err := s.GetThread(ctx, thread.ID)
+	if err != nil {
+		return ThreadDTO{}, err
+	}
+	// The ledger outlives deleted threads, so it is written last and in one
+	// transaction: a failed import must not leave rows a retry would repeat.
+	usages := make([]discovery.TurnUsage, 0, len(req.Usage))
+	for i, u := range req.Usage {
+		usages = append(usages, discovery.TurnUsage{
+			ThreadID:          thread.ID,
+			ProjectID:         thread.ProjectID,
+			AgentID:           thread.AgentID,
+			Model:             thread.Model,
+			ReasoningLevel:    thread.ReasoningLevel,
+			Status:            string(discovery.ThreadStatusCompleted),
+			InputTokens:       int64(u.Usage.InputTokens),
+			CachedInputTokens: int64(u.Usage.CachedInputTokens),
+			OutputTokens:      int64(u.Usage.OutputTokens),
+			CompletedAt:       completedAts[i],
+		})
+	}
+	if err := s.repo.RecordTurnUsages(ctx, usages); err != nil {
+		return ThreadDTO{}, err
+	}
+	imported = true
+	return dto, nil
+}
+
+// conversationEntryFromDTO is the inverse of conversationEntryToDTO; the entry
+// ID is not preserved.
+func conversationEntryFromDTO(dto ConversationEntryDTO) (discovery.CreateConversationEntryParams, error) {
+	params := discovery.CreateConversationEntryParams{Role: dto.Role}
+	var err error
+	switch dto.Role {
+	case "user":
+		params.EntryType = entryTypeUserMessage
+		params.Payload, err = marshalUserEntryPayload(dto.Text, dto.Segments)
+	case "agent":
+		if dto.Item == nil || strings.TrimSpace(dto.Item.Type) == "" {
+			return params, errors.New("agent entry without item type")
+		}
+		item := *dto.Item
+		if strings.HasPrefix(item.ID, entryIDPrefix) {
+			// Filled in from the source entry's ID by conversationEntryToDTO.
+			item.ID = ""
+		}
+		params.EntryType = item.Type
+		params.Payload, err = marshalAgentItemPayload(&item)
+	case "system":
+		params.EntryType = entryTypeSystemMessage
+		params.Payload, err = marshalSystemMessagePayload(dto.Tone, dto.Message, dto.Meta)
+	default:
+		return params, fmt.Errorf("unknown role %q", dto.Role)
+	}
+	if err != nil {
+		return params, err
+	}
+	if createdAt, perr := time.Parse(time.RFC3339, dto.CreatedAt); perr == nil {
+		params.CreatedAt = createdAt.UTC()
+	}
+	if dto.UpdatedAt != nil {
+		if updatedAt, perr := time.Parse(time.RFC3339, *dto.UpdatedAt); perr == nil {
+			params.UpdatedAt = updatedAt.UTC()
+		}
+	}
+	return params, nil
+}
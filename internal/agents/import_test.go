@@ -0,0 +1,88 @@
+package agents
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+
+	"codex-ui/internal/storage/discovery"
+)
+
+func TestService_ImportThreadDropsThreadOnFailure(t *testing.T) {
+	svc, repo, project := newTestService(t, &scriptedAdapter{})
+	ctx := context.Background()
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name()))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	// Fail the last step, after the thread and its entries exist.
+	if _, err := db.Exec(`CREATE TRIGGER reject_status BEFORE UPDATE OF status ON threads BEGIN SELECT RAISE(ABORT, 'status unavailable'); END`); err != nil {
+		t.Fatalf("create trigger: %v", err)
+	}
+
+	_, err = svc.ImportThread(ctx, ImportThreadRequest{
+		ProjectID: project.ID,
+		Thread:    ThreadDTO{Title: "Imported"},
+		Entries:   []ConversationEntryDTO{{Role: "user", Text: "hello", CreatedAt: "2025-03-01T12:00:00Z"}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "status unavailable") {
+		t.Fatalf("expected the status update to fail, got %v", err)
+	}
+	threads, err := repo.ListThreadsByProject(ctx, project.ID)
+	if err != nil {
+		t.Fatalf("list threads: %v", err)
+	}
+	if len(threads) != 0 {
+		t.Fatalf("expected the half-imported thread to be removed, got %+v", threads)
+	}
+}
+
+func TestService_ImportThreadRecordsNoUsageOnFailure(t *testing.T) {
+	svc, repo, project := newTestService(t, &scriptedAdapter{})
+	ctx := context.Background()
+	req := ImportThreadRequest{
+		ProjectID: project.ID,
+		Thread:    ThreadDTO{Title: "Imported"},
+		Entries:   []ConversationEntryDTO{{Role: "user", Text: "hello", CreatedAt: "2025-03-01T12:00:00Z"}},
+		Usage: []ImportedUsageDTO{
+			{Usage: UsageDTO{InputTokens: 10, OutputTokens: 5}, CompletedAt: "2025-03-01T12:01:00Z"},
+			{Usage: UsageDTO{InputTokens: 20, OutputTokens: 7}, CompletedAt: "yesterday"},
+		},
+	}
+	if _, err := svc.ImportThread(ctx, req); err == nil || !strings.Contains(err.Error(), "usage 1") {
+		t.Fatalf("expected the invalid completion time to be rejected, got %v", err)
+	}
+
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name()))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	// Fail the second ledger row, after the first is written.
+	if _, err := db.Exec(`CREATE TRIGGER reject_usage BEFORE INSERT ON turn_usage WHEN NEW.input_tokens = 20 BEGIN SELECT RAISE(ABORT, 'ledger unavailable'); END`); err != nil {
+		t.Fatalf("create trigger: %v", err)
+	}
+	req.Usage[1].CompletedAt = ""
+	if _, err := svc.ImportThread(ctx, req); err == nil || !strings.Contains(err.Error(), "ledger unavailable") {
+		t.Fatalf("expected the ledger write to fail, got %v", err)
+	}
+	threads, err := repo.ListThreadsByProject(ctx, project.ID)
+	if err != nil {
+		t.Fatalf("list threads: %v", err)
+	}
+	if len(threads) != 0 {
+		t.Fatalf("expected the half-imported thread to be removed, got %+v", threads)
+	}
+	totals, err := repo.SumTurnUsage(ctx, discovery.UsageFilter{ProjectID: project.ID}, nil)
+	if err != nil {
+		t.Fatalf("sum usage: %v", err)
+	}
+	for _, total := range totals {
+		if total.Turns != 0 {
+			t.Fatalf("expected no ledger rows from the failed import, got %+v", totals)
+		}
+	}
+}
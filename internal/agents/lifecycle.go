@@ -0,0 +1,108 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"codex-ui/internal/storage/discovery"
+)
+
+// ErrThreadArchived is returned by Send and EnqueueTurn for archived threads,
+// which have released their worktree; unarchive the thread first.
+var ErrThreadArchived = errors.New("thread is archived")
+
+// ThreadFilterDTO narrows ListThreads. Archived threads are hidden unless
+// IncludeArchived or ArchivedOnly is set; an empty Statuses matches every
+// status.
+type ThreadFilterDTO struct {
+	IncludeArchived bool     `json:"includeArchived,omitempty"`
+	ArchivedOnly    bool     `json:"archivedOnly,omitempty"`
+	PinnedOnly      bool     `json:"pinnedOnly,omitempty"`
+	Statuses        []string `json:"statuses,omitempty"`
+}
+
+// reviewStatuses are the statuses a user may set by hand; the others follow
+// the thread's turns.
+var reviewStatuses = map[discovery.ThreadStatus]bool{
+	discovery.ThreadStatusCompleted:      true,
+	discovery.ThreadStatusAwaitingReview: true,
+	discovery.ThreadStatusMerged:         true,
+}
+
+func (f ThreadFilterDTO) toFilter(projectID int64) discovery.ThreadFilter {
+	filter := discovery.ThreadFilter{
+		ProjectID:       projectID,
+		IncludeArchived: f.IncludeArchived,
+		ArchivedOnly:    f.ArchivedOnly,
+		PinnedOnly:      f.PinnedOnly,
+	}
+	for _, status := range f.Statuses {
+		filter.Statuses = append(filter.Statuses, discovery.ThreadStatus(status))
+	}
+	return filter
+}
+
+// ArchiveThread hides a thread from the default list, drops its queued turns
+// and releases its worktree. The branch and transcript are kept, and the
+// worktree is recreated if the thread is unarchived and sent to again.
+func (s *Service) ArchiveThread(ctx context.Context, threadID int64) (ThreadDTO, error) {
+	if err := s.ensureRepo(); err != nil {
+		return ThreadDTO{}, err
+	}
+	thread, err := s.repo.GetThread(ctx, threadID)
+	if err != nil {
+		return ThreadDTO{}, err
+	}
+	if s.isThreadActive(threadID) {
+		return ThreadDTO{}, fmt.Errorf("thread %d is still running", threadID)
+	}
+	if err := s.archiveThread(ctx, thread); err != nil {
+		return ThreadDTO{}, err
+	}
+	return s.GetThread(ctx, threadID)
+}
+
+// UnarchiveThread returns an archived thread to the default list.
+func (s *Service) UnarchiveThread(ctx context.Context, threadID int64) (ThreadDTO, error) {
+	if err := s.ensureRepo(); err != nil {
+		return ThreadDTO{}, err
+	}
+	if err := s.repo.SetThreadArchived(ctx, threadID, false); err != nil {
+		return ThreadDTO{}, err
+	}
+	return s.GetThread(ctx, threadID)
+}
+
+// PinThread pins a thread to the top of its project's list, or unpins it.
+func (s *Service) PinThread(ctx context.Context, threadID int64, pinned bool) (ThreadDTO, error) {
+	if err := s.ensureRepo(); err != nil {
+		return ThreadDTO{}, err
+	}
+	if err := s.repo.SetThreadPinned(ctx, threadID, pinned); err != nil {
+		return ThreadDTO{}, err
+	}
+	return s.GetThread(ctx, threadID)
+}
+
+// SetThreadStatus moves an idle thread through review by hand: completed,
+// awaiting-review or merged.
+func (s *Service) SetThreadStatus(ctx context.Context, threadID int64, status string) (ThreadDTO, error) {
+	if err := s.ensureRepo(); err != nil {
+		return ThreadDTO{}, err
+	}
+	next := discovery.ThreadStatus(status)
+	if !reviewStatuses[next] {
+		return ThreadDTO{}, fmt.Errorf("status %q cannot be set by hand", status)
+	}
+	if _, err := s.repo.GetThread(ctx, threadID); err != nil {
+		return ThreadDTO{}, err
+	}
+	if s.isThreadActive(threadID) {
+		return ThreadDTO{}, fmt.Errorf("thread %d is still running", threadID)
+	}
+	if err := s.repo.UpdateThreadStatus(ctx, threadID, next, nil); err != nil {
+		return ThreadDTO{}, err
+	}
+	return s.GetThread(ctx, threadID)
+}
@@ -0,0 +1,121 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"testing"
+
+	"codex-ui/internal/storage/discovery"
+)
+
+func TestService_ArchivePinAndReviewStatuses(t *testing.T) {
+	svc, repo, _ := newTestService(t, &notesAdapter{})
+	ctx := context.Background()
+	project := newGitProject(t, svc, repo)
+
+	var threads []discovery.Thread
+	for _, input := range []string{"first", "second", "third"} {
+		thread, err := sendAndWait(t, svc, MessageRequest{ProjectID: project.ID, Input: input})
+		if err != nil {
+			t.Fatalf("%s turn: %v", input, err)
+		}
+		if thread.Status != discovery.ThreadStatusActive {
+			t.Fatalf("expected a running turn to mark the thread active, got %q", thread.Status)
+		}
+		threads = append(threads, thread)
+	}
+	first, second, third := threads[0].ID, threads[1].ID, threads[2].ID
+	ids := func(filter ThreadFilterDTO) []int64 {
+		t.Helper()
+		list, err := svc.ListThreads(ctx, project.ID, filter)
+		if err != nil {
+			t.Fatalf("list threads: %v", err)
+		}
+		var out []int64
+		for _, thread := range list {
+			out = append(out, thread.ID)
+		}
+		return out
+	}
+	same := func(got []int64, want ...int64) bool {
+		if len(got) != len(want) {
+			return false
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	pinned, err := svc.PinThread(ctx, first, true)
+	if err != nil || pinned.PinnedAt == nil {
+		t.Fatalf("pin: %+v (err %v)", pinned, err)
+	}
+	if got := ids(ThreadFilterDTO{}); !same(got, first, third, second) {
+		t.Fatalf("expected the pinned thread first, got %v", got)
+	}
+	if got := ids(ThreadFilterDTO{PinnedOnly: true}); !same(got, first) {
+		t.Fatalf("unexpected pinned threads %v", got)
+	}
+
+	stored, _ := repo.GetThread(ctx, second)
+	// Work committed on the thread's branch must survive the archive.
+	for _, args := range [][]string{{"add", "-A"}, {"commit", "-q", "-m", "kept"}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = stored.WorktreePath
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	archived, err := svc.ArchiveThread(ctx, second)
+	if err != nil || archived.ArchivedAt == nil || archived.WorktreePath != "" {
+		t.Fatalf("archive: %+v (err %v)", archived, err)
+	}
+	if _, err := os.Stat(stored.WorktreePath); !os.IsNotExist(err) {
+		t.Fatalf("expected the archived worktree to be removed, got %v", err)
+	}
+	if got := ids(ThreadFilterDTO{}); !same(got, first, third) {
+		t.Fatalf("expected archived threads to be hidden, got %v", got)
+	}
+	if got := ids(ThreadFilterDTO{ArchivedOnly: true}); !same(got, second) {
+		t.Fatalf("unexpected archived threads %v", got)
+	}
+	if got := ids(ThreadFilterDTO{IncludeArchived: true}); len(got) != 3 {
+		t.Fatalf("expected every thread, got %v", got)
+	}
+
+	if _, _, err := svc.Send(ctx, MessageRequest{ThreadID: second, Input: "archived"}); !errors.Is(err, ErrThreadArchived) {
+		t.Fatalf("expected ErrThreadArchived from Send, got %v", err)
+	}
+	if _, err := svc.EnqueueTurn(ctx, MessageRequest{ThreadID: second, Input: "archived"}); !errors.Is(err, ErrThreadArchived) {
+		t.Fatalf("expected ErrThreadArchived from EnqueueTurn, got %v", err)
+	}
+
+	if _, err := svc.SetThreadStatus(ctx, third, "merged"); err != nil {
+		t.Fatalf("set status: %v", err)
+	}
+	if _, err := svc.SetThreadStatus(ctx, third, "active"); err == nil {
+		t.Fatal("expected turn-driven statuses to be rejected")
+	}
+	if got := ids(ThreadFilterDTO{Statuses: []string{"merged", "awaiting-review"}}); !same(got, third) {
+		t.Fatalf("unexpected merged threads %v", got)
+	}
+
+	if _, err := svc.UnarchiveThread(ctx, second); err != nil {
+		t.Fatalf("unarchive: %v", err)
+	}
+	if _, err := sendAndWait(t, svc, MessageRequest{ThreadID: second, Input: "again"}); err != nil {
+		t.Fatalf("turn after unarchive: %v", err)
+	}
+	restored, _ := repo.GetThread(ctx, second)
+	if data, err := os.ReadFile(restored.WorktreePath + "/notes.txt"); err != nil || string(data) != "second\nagain\n" {
+		t.Fatalf("expected a fresh worktree on the kept branch, got %q (err %v)", data, err)
+	}
+	if restored.Status != discovery.ThreadStatusCompleted || restored.ArchivedAt != nil {
+		t.Fatalf("unexpected thread after unarchive %+v", restored)
+	}
+}
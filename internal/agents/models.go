@@ -0,0 +1,351 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// ModelsConfigFile is the file under the data directory that adds models to
+// the built-in catalog or overrides built-in entries with the same agent and ID.
+const ModelsConfigFile = "models.json"
+
+// Sandbox modes understood by the Codex CLI.
+var codexSandboxModes = []string{"read-only", "workspace-write", "danger-full-access"}
+
+// ModelDTO describes a model an agent can run and the options it accepts.
+// Reasoning levels are ordered from cheapest to most thorough.
+type ModelDTO struct {
+	AgentID               string   `json:"agentId"`
+	ID                    string   `json:"id"`
+	Label                 string   `json:"label,omitempty"`
+	ReasoningLevels       []string `json:"reasoningLevels,omitempty"`
+	DefaultReasoningLevel string   `json:"defaultReasoningLevel,omitempty"`
+	SandboxModes          []string `json:"sandboxModes,omitempty"`
+	// Default marks the model used when a request names none.
+	Default bool `json:"default,omitempty"`
+}
+
+// ProjectModelDefaultsDTO holds the thread options a project uses when a
+// request leaves them empty.
+type ProjectModelDefaultsDTO struct {
+	ProjectID      int64  `json:"projectId"`
+	Model          string `json:"model,omitempty"`
+	ReasoningLevel string `json:"reasoningLevel,omitempty"`
+	SandboxMode    string `json:"sandboxMode,omitempty"`
+}
+
+var builtinModels = []ModelDTO{
+	{AgentID: "codex", ID: "gpt-5.1-codex", Label: "GPT-5.1 Codex", ReasoningLevels: []string{"minimal", "low", "medium", "high"}, DefaultReasoningLevel: "medium", SandboxModes: codexSandboxModes, Default: true},
+	{AgentID: "codex", ID: "gpt-5.1-codex-mini", Label: "GPT-5.1 Codex Mini", ReasoningLevels: []string{"medium", "high"}, DefaultReasoningLevel: "medium", SandboxModes: codexSandboxModes},
+	{AgentID: "codex", ID: "gpt-5.1", Label: "GPT-5.1", ReasoningLevels: []string{"minimal", "low", "medium", "high"}, DefaultReasoningLevel: "medium", SandboxModes: codexSandboxModes},
+	{AgentID: "codex", ID: "gpt-5", Label: "GPT-5", ReasoningLevels: []string{"minimal", "low", "medium", "high"}, DefaultReasoningLevel: "medium", SandboxModes: codexSandboxModes},
+}
+
+// ModelCatalog lists the models of each agent. Agents without entries are
+// not validated, so adapters that pick their own models keep working.
+type ModelCatalog struct {
+	models []ModelDTO
+}
+
+// NewModelCatalog builds a catalog from the given entries.
+func NewModelCatalog(models []ModelDTO) *ModelCatalog {
+	return &ModelCatalog{models: append([]ModelDTO(nil), models...)}
+}
+
+// DefaultModelCatalog returns the built-in catalog.
+func DefaultModelCatalog() *ModelCatalog { return NewModelCatalog(builtinModels) }
+
+type modelsFile struct {
+	Models []ModelDTO `json:"models"`
+}
+
+// LoadModelCatalog merges the entries of path over the built-in catalog. A
+// missing file yields the built-in catalog.
+func LoadModelCatalog(path string) (*ModelCatalog, error) {
+	catalog := DefaultModelCatalog()
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return catalog, nil
+		}
+		return nil, fmt.Errorf("read models config: %w", err)
+	}
+	var file modelsFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("decode models config %s: %w", path, err)
+	}
+	for i, model := range file.Models {
+		model.AgentID = strings.TrimSpace(model.AgentID)
+		model.ID = strings.TrimSpace(model.ID)
+		if model.AgentID == "" || model.ID == "" {
+			return nil, fmt.Errorf("model %d in %s needs agentId and id", i, path)
+		}
+		if model.DefaultReasoningLevel != "" && !slices.Contains(model.ReasoningLevels, model.DefaultReasoningLevel) {
+			return nil, fmt.Errorf("model %s/%s: default reasoning level %q is not in reasoningLevels", model.AgentID, model.ID, model.DefaultReasoningLevel)
+		}
+		catalog.put(model)
+	}
+	return catalog, nil
+}
+
+func (c *ModelCatalog) put(model ModelDTO) {
+	if model.Default {
+		for i := range c.models {
+			if c.models[i].AgentID == model.AgentID {
+				c.models[i].Default = false
+			}
+		}
+	}
+	for i := range c.models {
+		if c.models[i].AgentID == model.AgentID && c.models[i].ID == model.ID {
+			c.models[i] = model
+			return
+		}
+	}
+	c.models = append(c.models, model)
+}
+
+// List returns the models of agentID, or of every agent when agentID is empty.
+func (c *ModelCatalog) List(agentID string) []ModelDTO {
+	list := make([]ModelDTO, 0, len(c.models))
+	for _, model := range c.models {
+		if agentID == "" || model.AgentID == agentID {
+			list = append(list, model)
+		}
+	}
+	return list
+}
+
+// Lookup returns the entry for model of agentID.
+func (c *ModelCatalog) Lookup(agentID, model string) (ModelDTO, bool) {
+	for _, entry := range c.models {
+		if entry.AgentID == agentID && entry.ID == model {
+			return entry, true
+		}
+	}
+	return ModelDTO{}, false
+}
+
+// DefaultModel returns the model used for agentID when none is requested:
+// the entry marked default, else the first one.
+func (c *ModelCatalog) DefaultModel(agentID string) (ModelDTO, bool) {
+	models := c.List(agentID)
+	for _, model := range models {
+		if model.Default {
+			return model, true
+		}
+	}
+	if len(models) > 0 {
+		return models[0], true
+	}
+	return ModelDTO{}, false
+}
+
+// Validate checks opts against the catalog entries of agentID. Empty
+// reasoning and sandbox values are accepted.
+func (c *ModelCatalog) Validate(agentID string, opts ThreadOptionsDTO) error {
+	if issues := c.check(agentID, opts); len(issues) > 0 {
+		return issues[0].err
+	}
+	return nil
+}
+
+// optionIssue is a thread option the catalog does not accept.
+type optionIssue struct {
+	// option is model, reasoningLevel or sandboxMode.
+	option string
+	err    error
+}
+
+// check lists the options of opts the catalog entries of agentID reject. An
+// unknown model is the only issue reported, since the other options depend
+// on it.
+func (c *ModelCatalog) check(agentID string, opts ThreadOptionsDTO) []optionIssue {
+	if len(c.List(agentID)) == 0 {
+		return nil
+	}
+	model, ok := c.Lookup(agentID, opts.Model)
+	if !ok {
+		return []optionIssue{{"model", fmt.Errorf("model %q is not available for agent %s", opts.Model, agentID)}}
+	}
+	var issues []optionIssue
+	if level := opts.ReasoningLevel; level != "" && !slices.Contains(model.ReasoningLevels, level) {
+		issues = append(issues, optionIssue{"reasoningLevel", fmt.Errorf("model %s does not support reasoning level %q (supported: %s)", model.ID, level, strings.Join(model.ReasoningLevels, ", "))})
+	}
+	if mode := opts.SandboxMode; mode != "" && len(model.SandboxModes) > 0 && !slices.Contains(model.SandboxModes, mode) {
+		issues = append(issues, optionIssue{"sandboxMode", fmt.Errorf("model %s does not support sandbox mode %q", model.ID, mode)})
+	}
+	return issues
+}
+
+// ListModels returns the catalog entries of agentID (all agents when empty).
+func (s *Service) ListModels(agentID string) []ModelDTO {
+	return s.catalog.List(strings.TrimSpace(agentID))
+}
+
+// resolveThreadOptions fills empty thread options from the thread, then the
+// project defaults when they suit agentID, then the catalog. Options the request sets must be in the
+// catalog. Values stored on the thread or project that the catalog does not
+// list, such as the model of a thread imported from an older session, are
+// kept and returned as warnings so those threads keep working.
+func (s *Service) resolveThreadOptions(ctx context.Context, agentID string, req *MessageRequest) ([]string, error) {
+	opts := &req.ThreadOptions
+	opts.Model = strings.TrimSpace(opts.Model)
+	opts.ReasoningLevel = strings.TrimSpace(opts.ReasoningLevel)
+	opts.SandboxMode = strings.TrimSpace(opts.SandboxMode)
+	requested := map[string]bool{
+		"model":          opts.Model != "",
+		"reasoningLevel": opts.ReasoningLevel != "",
+		"sandboxMode":    opts.SandboxMode != "",
+	}
+
+	projectID := req.ProjectID
+	if req.ThreadID != 0 && s.repo != nil {
+		thread, err := s.repo.GetThread(ctx, req.ThreadID)
+		if err != nil {
+			return nil, err
+		}
+		projectID = thread.ProjectID
+		if opts.Model == "" {
+			opts.Model = thread.Model
+		}
+		if opts.ReasoningLevel == "" {
+			opts.ReasoningLevel = thread.ReasoningLevel
+		}
+		if opts.SandboxMode == "" {
+			opts.SandboxMode = thread.SandboxMode
+		}
+	}
+	if projectID != 0 && s.repo != nil {
+		settings, err := s.repo.GetProjectSettings(ctx, projectID)
+		if err != nil {
+			return nil, err
+		}
+		if s.projectDefaultsApply(agentID, settings.DefaultModel) {
+			if opts.Model == "" {
+				opts.Model = settings.DefaultModel
+			}
+			if opts.SandboxMode == "" {
+				opts.SandboxMode = settings.DefaultSandboxMode
+			}
+			if opts.ReasoningLevel == "" && opts.Model == settings.DefaultModel {
+				opts.ReasoningLevel = settings.DefaultReasoningLevel
+			}
+		}
+	}
+	if opts.Model == "" {
+		if model, ok := s.catalog.DefaultModel(agentID); ok {
+			opts.Model = model.ID
+		}
+	}
+	if opts.ReasoningLevel == "" {
+		if model, ok := s.catalog.Lookup(agentID, opts.Model); ok {
+			opts.ReasoningLevel = model.DefaultReasoningLevel
+		}
+	}
+	var warnings []string
+	for _, issue := range s.catalog.check(agentID, *opts) {
+		if requested[issue.option] {
+			return nil, issue.err
+		}
+		warnings = append(warnings, issue.err.Error())
+	}
+	return warnings, nil
+}
+
+// projectDefaultsApply reports whether a project's thread option defaults,
+// which are validated against the default agent, suit agentID. Other agents
+// only take them when the catalog lists the default model for them, so an
+// adapter's own configured model is not overridden.
+func (s *Service) projectDefaultsApply(agentID, model string) bool {
+	if agentID == s.defaultAgent {
+		return true
+	}
+	_, ok := s.catalog.Lookup(agentID, model)
+	return ok
+}
+
+// GetProjectModelDefaults returns the thread option defaults of a project.
+func (s *Service) GetProjectModelDefaults(ctx context.Context, projectID int64) (ProjectModelDefaultsDTO, error) {
+	if err := s.ensureRepo(); err != nil {
+		return ProjectModelDefaultsDTO{}, err
+	}
+	settings, err := s.repo.GetProjectSettings(ctx, projectID)
+	if err != nil {
+		return ProjectModelDefaultsDTO{}, err
+	}
+	return ProjectModelDefaultsDTO{
+		ProjectID:      projectID,
+		Model:          settings.DefaultModel,
+		ReasoningLevel: settings.DefaultReasoningLevel,
+		SandboxMode:    settings.DefaultSandboxMode,
+	}, nil
+}
+
+// SetProjectModelDefaults stores the thread option defaults of a project after
+// validating them against the default agent's catalog entries.
+func (s *Service) SetProjectModelDefaults(ctx context.Context, defaults ProjectModelDefaultsDTO) (ProjectModelDefaultsDTO, error) {
+	if err := s.ensureRepo(); err != nil {
+		return ProjectModelDefaultsDTO{}, err
+	}
+	if defaults.ProjectID == 0 {
+		return ProjectModelDefaultsDTO{}, errors.New("projectId is required")
+	}
+	if _, err := s.repo.GetProjectByID(ctx, defaults.ProjectID); err != nil {
+		return ProjectModelDefaultsDTO{}, err
+	}
+	defaults.Model = strings.TrimSpace(defaults.Model)
+	defaults.ReasoningLevel = strings.TrimSpace(defaults.ReasoningLevel)
+	defaults.SandboxMode = strings.TrimSpace(defaults.SandboxMode)
+	if err := s.validateThreadDefaults(defaults.Model, defaults.ReasoningLevel, defaults.SandboxMode); err != nil {
+		return ProjectModelDefaultsDTO{}, err
+	}
+	settings, err := s.repo.GetProjectSettings(ctx, defaults.ProjectID)
+	if err != nil {
+		return ProjectModelDefaultsDTO{}, err
+	}
+	settings.DefaultModel = defaults.Model
+	settings.DefaultReasoningLevel = defaults.ReasoningLevel
+	settings.DefaultSandboxMode = defaults.SandboxMode
+	if err := s.repo.SaveProjectSettings(ctx, settings); err != nil {
+		return ProjectModelDefaultsDTO{}, err
+	}
+	return defaults, nil
+}
+
+// StoreProjectModelDefaults is SetProjectModelDefaults for callers that hold
+// the defaults as separate values, such as the project settings service.
+func (s *Service) StoreProjectModelDefaults(ctx context.Context, projectID int64, model, reasoningLevel, sandboxMode string) error {
+	_, err := s.SetProjectModelDefaults(ctx, ProjectModelDefaultsDTO{ProjectID: projectID, Model: model, ReasoningLevel: reasoningLevel, SandboxMode: sandboxMode})
+	return err
+}
+
+// validateThreadDefaults checks project thread defaults against the default
+// agent's catalog entries. Empty values are allowed.
+func (s *Service) validateThreadDefaults(model, reasoningLevel, sandboxMode string) error {
+	if reasoningLevel != "" && model == "" {
+		return errors.New("a default reasoning level needs a default model")
+	}
+	if model != "" {
+		opts := ThreadOptionsDTO{Model: model, ReasoningLevel: reasoningLevel, SandboxMode: sandboxMode}
+		return s.catalog.Validate(s.defaultAgent, opts)
+	}
+	if sandboxMode != "" && !slices.Contains(codexSandboxModes, sandboxMode) {
+		return fmt.Errorf("unknown sandbox mode %q", sandboxMode)
+	}
+	return nil
+}
+
+// lowestReasoningLevel returns the cheapest reasoning level of model, or ""
+// when the catalog does not know it.
+func (s *Service) lowestReasoningLevel(agentID, model string) string {
+	if entry, ok := s.catalog.Lookup(agentID, model); ok && len(entry.ReasoningLevels) > 0 {
+		return entry.ReasoningLevels[0]
+	}
+	return ""
+}
@@ -0,0 +1,300 @@
+package agents
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"codex-ui/internal/storage/discovery"
+
+	"github.com/activadee/godex"
+)
+
+func TestLoadModelCatalogMergesOverBuiltins(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ModelsConfigFile)
+	if err := os.WriteFile(path, []byte(`{"models":[
+		{"agentId":"codex","id":"gpt-5.1-codex-mini","reasoningLevels":["low","medium","high"],"default":true},
+		{"agentId":"local","id":"qwen","reasoningLevels":[]}
+	]}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	catalog, err := LoadModelCatalog(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	mini, ok := catalog.Lookup("codex", "gpt-5.1-codex-mini")
+	if !ok || strings.Join(mini.ReasoningLevels, ",") != "low,medium,high" {
+		t.Fatalf("expected override of built-in entry, got %+v", mini)
+	}
+	if def, _ := catalog.DefaultModel("codex"); def.ID != "gpt-5.1-codex-mini" {
+		t.Fatalf("expected overridden default model, got %q", def.ID)
+	}
+	if _, ok := catalog.Lookup("codex", "gpt-5.1"); !ok {
+		t.Fatal("expected built-in entries to be kept")
+	}
+	if len(catalog.List("local")) != 1 {
+		t.Fatalf("expected added agent entry, got %+v", catalog.List("local"))
+	}
+
+	if missing, err := LoadModelCatalog(filepath.Join(t.TempDir(), "none.json")); err != nil || len(missing.List("codex")) != len(builtinModels) {
+		t.Fatalf("expected built-in catalog for missing file, got %v", err)
+	}
+}
+
+func TestModelCatalogValidate(t *testing.T) {
+	catalog := DefaultModelCatalog()
+	cases := []struct {
+		agent string
+		opts  ThreadOptionsDTO
+		ok    bool
+	}{
+		{"codex", ThreadOptionsDTO{Model: "gpt-5.1-codex", ReasoningLevel: "minimal", SandboxMode: "read-only"}, true},
+		{"codex", ThreadOptionsDTO{Model: "gpt-5.1-codex-mini", ReasoningLevel: "minimal"}, false},
+		{"codex", ThreadOptionsDTO{Model: "gpt-5.1", SandboxMode: "everything"}, false},
+		{"codex", ThreadOptionsDTO{Model: "unknown"}, false},
+		{"other", ThreadOptionsDTO{Model: "anything", ReasoningLevel: "max"}, true},
+	}
+	for _, tc := range cases {
+		if err := catalog.Validate(tc.agent, tc.opts); (err == nil) != tc.ok {
+			t.Errorf("%s %+v: err = %v", tc.agent, tc.opts, err)
+		}
+	}
+}
+
+// optionsAdapter records the thread options of each turn.
+type optionsAdapter struct {
+	seen []ThreadOptionsDTO
+}
+
+func (a *optionsAdapter) Stream(ctx context.Context, req MessageRequest) (*StreamResult, error) {
+	a.seen = append(a.seen, req.ThreadOptions)
+	events := make(chan StreamEvent)
+	done := make(chan error, 1)
+	close(events)
+	done <- nil
+	close(done)
+	return &StreamResult{Events: events, Done: done}, nil
+}
+
+func TestService_SendAppliesProjectDefaultsAndValidates(t *testing.T) {
+	adapter := &optionsAdapter{}
+	svc, _, project := newTestService(t, adapter)
+	svc.catalog = NewModelCatalog([]ModelDTO{
+		{AgentID: "fake", ID: "small", ReasoningLevels: []string{"low", "high"}, DefaultReasoningLevel: "low", Default: true},
+		{AgentID: "fake", ID: "large", ReasoningLevels: []string{"medium", "high"}, DefaultReasoningLevel: "medium"},
+	})
+	ctx := context.Background()
+
+	if _, err := svc.SetProjectModelDefaults(ctx, ProjectModelDefaultsDTO{ProjectID: project.ID, Model: "small", ReasoningLevel: "medium"}); err == nil {
+		t.Fatal("expected unsupported default reasoning level to be rejected")
+	}
+	if _, err := svc.SetProjectModelDefaults(ctx, ProjectModelDefaultsDTO{ProjectID: project.ID, Model: "large", ReasoningLevel: "high", SandboxMode: "read-only"}); err != nil {
+		t.Fatalf("set defaults: %v", err)
+	}
+
+	send := func(req MessageRequest) error {
+		stream, _, err := svc.Send(ctx, req)
+		if err != nil {
+			return err
+		}
+		for range stream.Events() {
+		}
+		return stream.Wait()
+	}
+	if err := send(MessageRequest{ProjectID: project.ID, Input: "defaults"}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if err := send(MessageRequest{ProjectID: project.ID, Input: "explicit model", ThreadOptions: ThreadOptionsDTO{Model: "small"}}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if got := adapter.seen[0]; got.Model != "large" || got.ReasoningLevel != "high" || got.SandboxMode != "read-only" {
+		t.Fatalf("expected project defaults, got %+v", got)
+	}
+	// The project's reasoning default belongs to its default model only.
+	if got := adapter.seen[1]; got.Model != "small" || got.ReasoningLevel != "low" {
+		t.Fatalf("expected catalog default level for small, got %+v", got)
+	}
+
+	err := send(MessageRequest{ProjectID: project.ID, Input: "bad", ThreadOptions: ThreadOptionsDTO{Model: "small", ReasoningLevel: "medium"}})
+	if err == nil || !strings.Contains(err.Error(), "reasoning level") {
+		t.Fatalf("expected reasoning level validation error, got %v", err)
+	}
+	if len(adapter.seen) != 2 {
+		t.Fatalf("invalid request must not reach the adapter, saw %d turns", len(adapter.seen))
+	}
+}
+
+func TestService_ProjectDefaultsSkipOtherAgents(t *testing.T) {
+	svc, _, project := newTestService(t, &optionsAdapter{})
+	other := &optionsAdapter{}
+	if err := svc.Register("other", other); err != nil {
+		t.Fatalf("register adapter: %v", err)
+	}
+	svc.catalog = NewModelCatalog([]ModelDTO{
+		{AgentID: "fake", ID: "large", ReasoningLevels: []string{"medium", "high"}, DefaultReasoningLevel: "medium", Default: true},
+	})
+	ctx := context.Background()
+	if _, err := svc.SetProjectModelDefaults(ctx, ProjectModelDefaultsDTO{ProjectID: project.ID, Model: "large", ReasoningLevel: "high", SandboxMode: "read-only"}); err != nil {
+		t.Fatalf("set defaults: %v", err)
+	}
+
+	if _, err := sendAndWait(t, svc, MessageRequest{AgentID: "other", ProjectID: project.ID, Input: "first"}); err != nil {
+		t.Fatalf("send with other agent: %v", err)
+	}
+	// The catalog lists nothing for other, so it keeps its own configured model.
+	if got := other.seen[0]; got != (ThreadOptionsDTO{}) {
+		t.Fatalf("expected the default agent's project defaults to be skipped, got %+v", got)
+	}
+
+	svc.catalog = NewModelCatalog([]ModelDTO{
+		{AgentID: "fake", ID: "large", ReasoningLevels: []string{"medium", "high"}, DefaultReasoningLevel: "medium", Default: true},
+		{AgentID: "other", ID: "large", ReasoningLevels: []string{"high"}, DefaultReasoningLevel: "high", Default: true},
+	})
+	if _, err := sendAndWait(t, svc, MessageRequest{AgentID: "other", ProjectID: project.ID, Input: "second"}); err != nil {
+		t.Fatalf("send with other agent: %v", err)
+	}
+	if got := other.seen[1]; got.Model != "large" || got.ReasoningLevel != "high" || got.SandboxMode != "read-only" {
+		t.Fatalf("expected project defaults once the catalog lists the model for other, got %+v", got)
+	}
+}
+
+func TestService_SendKeepsStoredOffCatalogOptions(t *testing.T) {
+	adapter := &optionsAdapter{}
+	svc, repo, project := newTestService(t, adapter)
+	other := &optionsAdapter{}
+	if err := svc.Register("other", other); err != nil {
+		t.Fatalf("register adapter: %v", err)
+	}
+	svc.catalog = NewModelCatalog([]ModelDTO{{AgentID: "fake", ID: "small", Default: true}})
+	ctx := context.Background()
+
+	// Threads imported from older sessions can carry models the catalog dropped.
+	thread, err := repo.CreateThread(ctx, discovery.CreateThreadParams{ProjectID: project.ID, Title: "old", Model: "retired"})
+	if err != nil {
+		t.Fatalf("create thread: %v", err)
+	}
+	if _, err := sendAndWait(t, svc, MessageRequest{ThreadID: thread.ID, Input: "follow-up"}); err != nil {
+		t.Fatalf("send to thread with a stored off-catalog model: %v", err)
+	}
+	if got := adapter.seen[0]; got.Model != "retired" {
+		t.Fatalf("expected the stored model, got %+v", got)
+	}
+	if msg := lastSystemMessage(t, repo, thread.ID); !strings.Contains(msg, "retired") || !strings.Contains(msg, "warning") {
+		t.Fatalf("expected a warning about the stored model, got %s", msg)
+	}
+	if _, err := sendAndWait(t, svc, MessageRequest{ThreadID: thread.ID, Input: "explicit", ThreadOptions: ThreadOptionsDTO{Model: "retired"}}); err == nil {
+		t.Fatal("expected an explicitly requested off-catalog model to be rejected")
+	}
+
+	started, err := sendAndWait(t, svc, MessageRequest{AgentID: "other", ProjectID: project.ID, Input: "first"})
+	if err != nil {
+		t.Fatalf("send with other agent: %v", err)
+	}
+	if _, err := sendAndWait(t, svc, MessageRequest{ThreadID: started.ID, Input: "second"}); err != nil {
+		t.Fatalf("send follow-up: %v", err)
+	}
+	if len(other.seen) != 2 || len(adapter.seen) != 1 {
+		t.Fatalf("expected the follow-up to stay on the thread's agent, other saw %d and default saw %d", len(other.seen), len(adapter.seen))
+	}
+	if stored, _ := repo.GetThread(ctx, started.ID); stored.AgentID != "other" {
+		t.Fatalf("expected the thread to record its agent, got %q", stored.AgentID)
+	}
+}
+
+func TestService_StandingInstructionsPrefixFirstTurn(t *testing.T) {
+	adapter := &scriptedAdapter{}
+	svc, repo, project := newTestService(t, adapter)
+	ctx := context.Background()
+	if err := repo.SaveProjectSettings(ctx, discovery.ProjectSettings{ProjectID: project.ID, Instructions: "Log with slog."}); err != nil {
+		t.Fatalf("save settings: %v", err)
+	}
+	if _, err := svc.SetProjectModelDefaults(ctx, ProjectModelDefaultsDTO{ProjectID: project.ID, SandboxMode: "read-only"}); err != nil {
+		t.Fatalf("set defaults: %v", err)
+	}
+	if settings, _ := repo.GetProjectSettings(ctx, project.ID); settings.Instructions != "Log with slog." || settings.DefaultSandboxMode != "read-only" {
+		t.Fatalf("expected model defaults to keep the instructions, got %+v", settings)
+	}
+
+	thread, err := sendAndWait(t, svc, MessageRequest{ProjectID: project.ID, Input: "Add request logging", ThreadOptions: ThreadOptionsDTO{Model: "m"}})
+	if err != nil {
+		t.Fatalf("first turn: %v", err)
+	}
+	if _, err := sendAndWait(t, svc, MessageRequest{ThreadID: thread.ID, Input: "Now the tests"}); err != nil {
+		t.Fatalf("follow-up: %v", err)
+	}
+	inputs := adapter.seenInputs()
+	if len(inputs) != 2 || inputs[0] != "Standing instructions for this project:\n\nLog with slog.\n\nAdd request logging" || inputs[1] != "Now the tests" {
+		t.Fatalf("expected instructions on the first turn only, got %q", inputs)
+	}
+	if thread.SandboxMode != "read-only" {
+		t.Fatalf("expected the project sandbox default, got %q", thread.SandboxMode)
+	}
+	entries, _ := svc.LoadThreadConversation(ctx, thread.ID)
+	if entries[0].Text != "Add request logging" {
+		t.Fatalf("instructions must not be stored as the user's message, got %q", entries[0].Text)
+	}
+
+	records, _ := repo.ListConversationEntries(ctx, thread.ID)
+	fork, err := svc.ForkThread(ctx, thread.ID, records[len(records)-1].ID)
+	if err != nil {
+		t.Fatalf("fork: %v", err)
+	}
+	if _, err := sendAndWait(t, svc, MessageRequest{ThreadID: fork.ID, Input: "Try zap instead"}); err != nil {
+		t.Fatalf("fork turn: %v", err)
+	}
+	if got := adapter.seenInputs()[2]; !strings.HasPrefix(got, "Standing instructions for this project:") || !strings.Contains(got, "forked from") {
+		t.Fatalf("expected a fork's fresh session to get the instructions, got %q", got)
+	}
+
+	project, err = repo.GetProjectByID(ctx, project.ID)
+	if err != nil || project.Settings.Instructions != "Log with slog." {
+		t.Fatalf("expected the project to carry its settings, got %+v, %v", project.Settings, err)
+	}
+}
+
+type recordingClientFactory struct {
+	options []godex.CodexOptions
+	client  *fakeCodexClient
+}
+
+func (f *recordingClientFactory) new(opts godex.CodexOptions) (codexClient, error) {
+	f.options = append(f.options, opts)
+	return f.client, nil
+}
+
+func TestCodexAdapter_Stream_ForwardsReasoningLevel(t *testing.T) {
+	events := make(chan godex.ThreadEvent)
+	close(events)
+	levelClient := &fakeCodexClient{startThread: &fakeThreadRunner{stream: &fakeStreamResult{events: events}}}
+	factory := &recordingClientFactory{client: levelClient}
+	base := &fakeCodexClient{}
+	adapter := &CodexAdapter{
+		client:    base,
+		options:   godex.CodexOptions{ConfigOverrides: map[string]any{"profile": "work"}},
+		newClient: factory.new,
+	}
+
+	for i := 0; i < 2; i++ {
+		result, err := adapter.Stream(context.Background(), MessageRequest{Input: "hi", ThreadOptions: ThreadOptionsDTO{Model: "gpt-5.1-codex", ReasoningLevel: "high"}})
+		if err != nil {
+			t.Fatalf("stream: %v", err)
+		}
+		for range result.Events {
+		}
+	}
+	if len(factory.options) != 1 {
+		t.Fatalf("expected one client per level, got %d", len(factory.options))
+	}
+	overrides := factory.options[0].ConfigOverrides
+	if overrides[reasoningEffortConfigKey] != "high" || overrides["profile"] != "work" {
+		t.Fatalf("unexpected overrides %v", overrides)
+	}
+	if _, ok := adapter.options.ConfigOverrides[reasoningEffortConfigKey]; ok {
+		t.Fatal("base options must not be modified")
+	}
+	if !levelClient.startThread.calledRunStreamed {
+		t.Fatal("expected the level client to run the turn")
+	}
+}
@@ -0,0 +1,562 @@
+package agents
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AdapterTypeOpenAI talks to an OpenAI-compatible /v1/chat/completions endpoint.
+const AdapterTypeOpenAI = "openai"
+
+const (
+	openAIShellTool          = "shell"
+	openAIThreadPrefix       = "chat-"
+	openAIDefaultToolRounds  = 25
+	openAIDefaultCmdTimeout  = 5 * time.Minute
+	openAIToolOutputLimit    = 32 << 10
+	openAISystemPromptFormat = "You are a coding agent working in the repository at %s. " +
+		"Use the shell tool to inspect files, run commands and edit code. " +
+		"Commands run with that directory as the working directory. " +
+		"When the task is done, reply with a short summary of what you changed."
+)
+
+// openAIUpdateInterval spaces the item.updated events of streamed text, since
+// each one rewrites the item's conversation entry.
+const openAIUpdateInterval = 100 * time.Millisecond
+
+// OpenAIAdapterOptions configures an OpenAIAdapter.
+type OpenAIAdapterOptions struct {
+	// BaseURL is the API root, e.g. http://localhost:8000/v1.
+	BaseURL string
+	APIKey  string
+	// Model is used when a turn does not specify one.
+	Model string
+	// HistoryDir stores per-thread chat history so threads can be resumed.
+	HistoryDir string
+	// MaxToolRounds bounds request/tool-call round trips per turn.
+	MaxToolRounds  int
+	CommandTimeout time.Duration
+	HTTPClient     *http.Client
+}
+
+// OpenAIAdapter streams turns from an OpenAI-compatible chat completions
+// endpoint and executes the model's shell tool calls in the thread worktree.
+type OpenAIAdapter struct {
+	opts OpenAIAdapterOptions
+	// updateInterval is the least time between two updates of an item.
+	updateInterval time.Duration
+	// locks serialises turns per thread so history files are not interleaved.
+	locks sync.Map
+}
+
+// NewOpenAIAdapter constructs an adapter for the given endpoint.
+func NewOpenAIAdapter(opts OpenAIAdapterOptions) (*OpenAIAdapter, error) {
+	opts.BaseURL = strings.TrimRight(strings.TrimSpace(opts.BaseURL), "/")
+	if opts.BaseURL == "" {
+		return nil, errors.New("openai adapter baseUrl is required")
+	}
+	if strings.TrimSpace(opts.HistoryDir) == "" {
+		return nil, errors.New("openai adapter history directory is required")
+	}
+	if err := os.MkdirAll(opts.HistoryDir, 0o700); err != nil {
+		return nil, fmt.Errorf("ensure openai history dir: %w", err)
+	}
+	if opts.MaxToolRounds <= 0 {
+		opts.MaxToolRounds = openAIDefaultToolRounds
+	}
+	if opts.CommandTimeout <= 0 {
+		opts.CommandTimeout = openAIDefaultCmdTimeout
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	return &OpenAIAdapter{opts: opts, updateInterval: openAIUpdateInterval}, nil
+}
+
+type chatMessage struct {
+	Role       string         `json:"role"`
+	Content    any            `json:"content,omitempty"`
+	ToolCalls  []chatToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
+}
+
+type chatToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type chatContentPart struct {
+	Type     string        `json:"type"`
+	Text     string        `json:"text,omitempty"`
+	ImageURL *chatImageURL `json:"image_url,omitempty"`
+}
+
+type chatImageURL struct {
+	URL string `json:"url"`
+}
+
+type chatChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content          string `json:"content"`
+			ReasoningContent string `json:"reasoning_content"`
+			Reasoning        string `json:"reasoning"`
+			ToolCalls        []struct {
+				Index    int    `json:"index"`
+				ID       string `json:"id"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens        int `json:"prompt_tokens"`
+		CompletionTokens    int `json:"completion_tokens"`
+		PromptTokensDetails *struct {
+			CachedTokens int `json:"cached_tokens"`
+		} `json:"prompt_tokens_details"`
+	} `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+var openAIShellToolSpec = map[string]any{
+	"type": "function",
+	"function": map[string]any{
+		"name":        openAIShellTool,
+		"description": "Run a shell command in the repository and return its combined stdout and stderr.",
+		"parameters": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"command": map[string]any{"type": "string", "description": "The shell command to run"},
+			},
+			"required": []string{"command"},
+		},
+	},
+}
+
+// Stream implements Adapter.
+func (a *OpenAIAdapter) Stream(ctx context.Context, req MessageRequest) (*StreamResult, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	model := strings.TrimSpace(req.ThreadOptions.Model)
+	if model == "" {
+		model = a.opts.Model
+	}
+	if model == "" {
+		return nil, errors.New("threadOptions.model is required")
+	}
+	userMessage, err := buildChatUserMessage(req)
+	if err != nil {
+		return nil, err
+	}
+
+	threadID := strings.TrimSpace(req.ThreadExternalID)
+	isNew := threadID == ""
+	if isNew {
+		threadID = openAIThreadPrefix + uuid.NewString()
+	}
+	history, err := a.loadHistory(threadID)
+	if err != nil {
+		return nil, err
+	}
+	if len(history) == 0 {
+		history = append(history, chatMessage{Role: "system", Content: fmt.Sprintf(openAISystemPromptFormat, req.ThreadOptions.WorkingDirectory)})
+	}
+	history = append(history, userMessage)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	events := make(chan StreamEvent)
+	done := make(chan error, 1)
+
+	turn := &openAITurn{
+		adapter:  a,
+		ctx:      runCtx,
+		events:   events,
+		model:    model,
+		threadID: threadID,
+		workDir:  req.ThreadOptions.WorkingDirectory,
+		readOnly: req.ThreadOptions.SandboxMode == "read-only",
+		history:  history,
+	}
+
+	go func() {
+		defer close(events)
+		defer close(done)
+		defer cancel()
+
+		lock := a.threadLock(threadID)
+		lock.Lock()
+		defer lock.Unlock()
+
+		if isNew {
+			turn.emit(StreamEvent{Type: "thread.started", ThreadID: threadID})
+		}
+		turn.emit(StreamEvent{Type: "turn.started"})
+		err := turn.run()
+		if saveErr := a.saveHistory(threadID, turn.history); saveErr != nil && err == nil {
+			err = saveErr
+		}
+		switch {
+		case ctx.Err() != nil:
+			done <- ctx.Err()
+		case runCtx.Err() != nil:
+			done <- context.Canceled
+		case err != nil:
+			turn.emit(StreamEvent{Type: "turn.failed", Error: &StreamError{Message: err.Error()}})
+			done <- err
+		default:
+			turn.emit(StreamEvent{Type: "turn.completed", Usage: &turn.usage})
+			done <- nil
+		}
+	}()
+
+	var closeOnce sync.Once
+	closeFn := func() error {
+		closeOnce.Do(cancel)
+		return nil
+	}
+	return &StreamResult{Events: events, Done: done, Close: closeFn}, nil
+}
+
+func (a *OpenAIAdapter) threadLock(threadID string) *sync.Mutex {
+	lock, _ := a.locks.LoadOrStore(threadID, &sync.Mutex{})
+	return lock.(*sync.Mutex)
+}
+
+func (a *OpenAIAdapter) historyPath(threadID string) string {
+	return filepath.Join(a.opts.HistoryDir, filepath.Base(threadID)+".json")
+}
+
+func (a *OpenAIAdapter) loadHistory(threadID string) ([]chatMessage, error) {
+	raw, err := os.ReadFile(a.historyPath(threadID))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read chat history: %w", err)
+	}
+	var history []chatMessage
+	if err := json.Unmarshal(raw, &history); err != nil {
+		return nil, fmt.Errorf("decode chat history: %w", err)
+	}
+	return history, nil
+}
+
+func (a *OpenAIAdapter) saveHistory(threadID string, history []chatMessage) error {
+	raw, err := json.Marshal(history)
+	if err != nil {
+		return fmt.Errorf("encode chat history: %w", err)
+	}
+	if err := os.WriteFile(a.historyPath(threadID), raw, 0o600); err != nil {
+		return fmt.Errorf("write chat history: %w", err)
+	}
+	return nil
+}
+
+func buildChatUserMessage(req MessageRequest) (chatMessage, error) {
+	if len(req.Segments) == 0 {
+		return chatMessage{Role: "user", Content: req.Input}, nil
+	}
+	parts := make([]chatContentPart, 0, len(req.Segments))
+	for idx, seg := range req.Segments {
+		switch seg.Type {
+		case "text":
+			parts = append(parts, chatContentPart{Type: "text", Text: seg.Text})
+		case "image":
+			url, err := imageDataURL(seg.ImagePath)
+			if err != nil {
+				return chatMessage{}, fmt.Errorf("segment %d: %w", idx, err)
+			}
+			parts = append(parts, chatContentPart{Type: "image_url", ImageURL: &chatImageURL{URL: url}})
+		default:
+			return chatMessage{}, fmt.Errorf("segment %d has unsupported type %q", idx, seg.Type)
+		}
+	}
+	return chatMessage{Role: "user", Content: parts}, nil
+}
+
+func imageDataURL(path string) (string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read image: %w", err)
+	}
+	mimeType := mime.TypeByExtension(strings.ToLower(filepath.Ext(path)))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(raw)
+	}
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(raw), nil
+}
+
+// openAITurn holds the state of one streamed turn.
+type openAITurn struct {
+	adapter  *OpenAIAdapter
+	ctx      context.Context
+	events   chan<- StreamEvent
+	model    string
+	threadID string
+	workDir  string
+	readOnly bool
+	history  []chatMessage
+	usage    UsageDTO
+	items    int
+}
+
+func (t *openAITurn) emit(evt StreamEvent) {
+	select {
+	case t.events <- evt:
+	case <-t.ctx.Done():
+	}
+}
+
+// emitUpdate sends the latest state of a streaming item unless the previous
+// update, at *last, was too recent.
+func (t *openAITurn) emitUpdate(last *time.Time, item *AgentItemDTO) {
+	now := time.Now()
+	if !last.IsZero() && now.Sub(*last) < t.adapter.updateInterval {
+		return
+	}
+	*last = now
+	t.emit(StreamEvent{Type: "item.updated", Item: item})
+}
+
+func (t *openAITurn) nextItemID(kind string) string {
+	t.items++
+	return fmt.Sprintf("%s_%d", kind, t.items)
+}
+
+// run performs completion rounds until the model answers without tool calls.
+func (t *openAITurn) run() error {
+	for round := 0; round < t.adapter.opts.MaxToolRounds; round++ {
+		reply, err := t.complete()
+		if err != nil {
+			return err
+		}
+		t.history = append(t.history, reply)
+		if len(reply.ToolCalls) == 0 {
+			return nil
+		}
+		for _, call := range reply.ToolCalls {
+			output := t.runToolCall(call)
+			t.history = append(t.history, chatMessage{Role: "tool", ToolCallID: call.ID, Content: output})
+		}
+	}
+	return fmt.Errorf("stopped after %d tool rounds", t.adapter.opts.MaxToolRounds)
+}
+
+// complete sends the history and streams one assistant reply.
+func (t *openAITurn) complete() (chatMessage, error) {
+	body, err := json.Marshal(map[string]any{
+		"model":          t.model,
+		"messages":       t.history,
+		"tools":          []any{openAIShellToolSpec},
+		"stream":         true,
+		"stream_options": map[string]any{"include_usage": true},
+	})
+	if err != nil {
+		return chatMessage{}, fmt.Errorf("encode chat request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(t.ctx, http.MethodPost, t.adapter.opts.BaseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return chatMessage{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+	if key := strings.TrimSpace(t.adapter.opts.APIKey); key != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+key)
+	}
+	resp, err := t.adapter.opts.HTTPClient.Do(httpReq)
+	if err != nil {
+		return chatMessage{}, fmt.Errorf("chat completions request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return chatMessage{}, fmt.Errorf("chat completions returned %s: %s", resp.Status, strings.TrimSpace(string(raw)))
+	}
+
+	var (
+		text, reasoning strings.Builder
+		messageID       string
+		reasoningID     string
+		calls           []chatToolCall
+		// Times of the last item.updated event per item.
+		messageAt, reasoningAt time.Time
+	)
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+		var chunk chatChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return chatMessage{}, fmt.Errorf("decode chat chunk: %w", err)
+		}
+		if chunk.Error != nil {
+			return chatMessage{}, errors.New(chunk.Error.Message)
+		}
+		if chunk.Usage != nil {
+			t.usage.InputTokens += chunk.Usage.PromptTokens
+			t.usage.OutputTokens += chunk.Usage.CompletionTokens
+			if chunk.Usage.PromptTokensDetails != nil {
+				t.usage.CachedInputTokens += chunk.Usage.PromptTokensDetails.CachedTokens
+			}
+		}
+		for _, choice := range chunk.Choices {
+			delta := choice.Delta
+			if r := delta.ReasoningContent + delta.Reasoning; r != "" {
+				if reasoningID == "" {
+					reasoningID = t.nextItemID("reasoning")
+					t.emit(StreamEvent{Type: "item.started", Item: &AgentItemDTO{ID: reasoningID, Type: "reasoning"}})
+				}
+				reasoning.WriteString(r)
+				t.emitUpdate(&reasoningAt, &AgentItemDTO{ID: reasoningID, Type: "reasoning", Reasoning: reasoning.String()})
+			}
+			if delta.Content != "" {
+				if messageID == "" {
+					messageID = t.nextItemID("msg")
+					t.emit(StreamEvent{Type: "item.started", Item: &AgentItemDTO{ID: messageID, Type: entryTypeAgentMessage}})
+				}
+				text.WriteString(delta.Content)
+				t.emitUpdate(&messageAt, &AgentItemDTO{ID: messageID, Type: entryTypeAgentMessage, Text: text.String()})
+			}
+			for _, tc := range delta.ToolCalls {
+				for len(calls) <= tc.Index {
+					calls = append(calls, chatToolCall{Type: "function"})
+				}
+				call := &calls[tc.Index]
+				if tc.ID != "" {
+					call.ID = tc.ID
+				}
+				call.Function.Name += tc.Function.Name
+				call.Function.Arguments += tc.Function.Arguments
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return chatMessage{}, fmt.Errorf("read chat stream: %w", err)
+	}
+
+	if reasoningID != "" {
+		t.emit(StreamEvent{Type: "item.completed", Item: &AgentItemDTO{ID: reasoningID, Type: "reasoning", Reasoning: reasoning.String()}})
+	}
+	if messageID != "" {
+		t.emit(StreamEvent{Type: "item.completed", Item: &AgentItemDTO{ID: messageID, Type: entryTypeAgentMessage, Text: text.String()}})
+	}
+	reply := chatMessage{Role: "assistant", ToolCalls: calls}
+	if text.Len() > 0 {
+		reply.Content = text.String()
+	}
+	for i := range reply.ToolCalls {
+		if reply.ToolCalls[i].ID == "" {
+			reply.ToolCalls[i].ID = fmt.Sprintf("call_%d", i)
+		}
+	}
+	return reply, nil
+}
+
+// runToolCall executes a shell tool call and returns the tool message content.
+func (t *openAITurn) runToolCall(call chatToolCall) string {
+	if call.Function.Name != openAIShellTool {
+		return fmt.Sprintf("unknown tool %q", call.Function.Name)
+	}
+	var args struct {
+		Command string `json:"command"`
+	}
+	if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil || strings.TrimSpace(args.Command) == "" {
+		return "invalid arguments: expected {\"command\": \"...\"}"
+	}
+	itemID := t.nextItemID("cmd")
+	item := &AgentItemDTO{ID: itemID, Type: "command_execution", Command: &CommandExecutionDTO{Command: args.Command, Status: "in_progress"}}
+	// Report the usage so far, so token budgets can stop the turn between rounds.
+	usage := t.usage
+	t.emit(StreamEvent{Type: "item.started", Item: item, Usage: &usage})
+
+	if strings.TrimSpace(t.workDir) == "" {
+		declined := &AgentItemDTO{ID: itemID, Type: "command_execution", Command: &CommandExecutionDTO{Command: args.Command, Status: "declined"}}
+		t.emit(StreamEvent{Type: "item.completed", Item: declined})
+		return "command execution is disabled because no working directory was set for this turn"
+	}
+	if t.readOnly {
+		declined := &AgentItemDTO{ID: itemID, Type: "command_execution", Command: &CommandExecutionDTO{Command: args.Command, Status: "declined"}}
+		t.emit(StreamEvent{Type: "item.completed", Item: declined})
+		return "command execution is disabled in the read-only sandbox"
+	}
+
+	output, exitCode := t.execShell(args.Command)
+	status := "completed"
+	if exitCode != 0 {
+		status = "failed"
+	}
+	completed := &AgentItemDTO{ID: itemID, Type: "command_execution", Command: &CommandExecutionDTO{
+		Command:          args.Command,
+		AggregatedOutput: output,
+		ExitCode:         &exitCode,
+		Status:           status,
+	}}
+	t.emit(StreamEvent{Type: "item.completed", Item: completed})
+	return fmt.Sprintf("exit code %d\n%s", exitCode, output)
+}
+
+func (t *openAITurn) execShell(command string) (string, int) {
+	ctx, cancel := context.WithTimeout(t.ctx, t.adapter.opts.CommandTimeout)
+	defer cancel()
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Dir = t.workDir
+	out := &tailBuffer{limit: openAIToolOutputLimit}
+	cmd.Stdout = out
+	cmd.Stderr = out
+	err := cmd.Run()
+	exitCode := 0
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			exitCode = exitErr.ExitCode()
+		} else {
+			exitCode = -1
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			return out.String() + "\n(command timed out)", exitCode
+		}
+		if !errors.As(err, &exitErr) {
+			return out.String() + err.Error(), exitCode
+		}
+	}
+	return out.String(), exitCode
+}
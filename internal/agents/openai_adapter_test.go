@@ -0,0 +1,222 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// chatStub replays one scripted SSE response per request and records requests.
+type chatStub struct {
+	mu        sync.Mutex
+	responses [][]string
+	requests  []map[string]any
+}
+
+func (s *chatStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]any
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	s.mu.Lock()
+	s.requests = append(s.requests, body)
+	idx := len(s.requests) - 1
+	s.mu.Unlock()
+	if r.URL.Path != "/v1/chat/completions" || idx >= len(s.responses) {
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	for _, chunk := range s.responses[idx] {
+		fmt.Fprintf(w, "data: %s\n\n", chunk)
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+func collectEvents(t *testing.T, result *StreamResult) ([]StreamEvent, error) {
+	t.Helper()
+	var events []StreamEvent
+	for evt := range result.Events {
+		events = append(events, evt)
+	}
+	return events, <-result.Done
+}
+
+func TestOpenAIAdapterStreamsAndRunsShellTool(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell tool test uses sh")
+	}
+	stub := &chatStub{responses: [][]string{
+		{
+			`{"choices":[{"delta":{"reasoning_content":"Need to "}}]}`,
+			`{"choices":[{"delta":{"reasoning_content":"write a file."}}]}`,
+			`{"choices":[{"delta":{"content":"Writing it."}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_a","function":{"name":"shell","arguments":"{\"command\":"}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"echo hi > out.txt && cat out.txt\"}"}}]}}]}`,
+			`{"choices":[],"usage":{"prompt_tokens":10,"completion_tokens":4,"prompt_tokens_details":{"cached_tokens":2}}}`,
+		},
+		{
+			`{"choices":[{"delta":{"content":"Done: "}}]}`,
+			`{"choices":[{"delta":{"content":"created out.txt"}}]}`,
+			`{"choices":[],"usage":{"prompt_tokens":20,"completion_tokens":5}}`,
+		},
+		{
+			`{"choices":[{"delta":{"content":"Still here."}}]}`,
+		},
+	}}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	adapter, err := NewOpenAIAdapter(OpenAIAdapterOptions{BaseURL: server.URL + "/v1/", Model: "local-model", HistoryDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("new adapter: %v", err)
+	}
+	adapter.updateInterval = 0
+	workDir := t.TempDir()
+	result, err := adapter.Stream(context.Background(), MessageRequest{Input: "create out.txt", ThreadOptions: ThreadOptionsDTO{WorkingDirectory: workDir}})
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	events, err := collectEvents(t, result)
+	if err != nil {
+		t.Fatalf("turn failed: %v", err)
+	}
+
+	var threadID, reasoning, command string
+	var messages, updates []string
+	var usage *UsageDTO
+	for _, evt := range events {
+		switch {
+		case evt.Type == "thread.started":
+			threadID = evt.ThreadID
+		case evt.Type == "turn.completed":
+			usage = evt.Usage
+		case evt.Type == "item.completed" && evt.Item.Type == "reasoning":
+			reasoning = evt.Item.Reasoning
+		case evt.Type == "item.completed" && evt.Item.Type == entryTypeAgentMessage:
+			messages = append(messages, evt.Item.Text)
+		case evt.Type == "item.updated" && evt.Item.Type == entryTypeAgentMessage:
+			updates = append(updates, evt.Item.Text)
+		case evt.Type == "item.completed" && evt.Item.Command != nil:
+			command = evt.Item.Command.AggregatedOutput
+			if evt.Item.Command.ExitCode == nil || *evt.Item.Command.ExitCode != 0 || evt.Item.Command.Status != "completed" {
+				t.Fatalf("unexpected command item %+v", evt.Item.Command)
+			}
+		}
+	}
+	if !strings.HasPrefix(threadID, openAIThreadPrefix) {
+		t.Fatalf("expected generated thread id, got %q", threadID)
+	}
+	if reasoning != "Need to write a file." {
+		t.Fatalf("unexpected reasoning %q", reasoning)
+	}
+	if strings.Join(messages, "|") != "Writing it.|Done: created out.txt" {
+		t.Fatalf("unexpected messages %q", messages)
+	}
+	if strings.Join(updates, "|") != "Writing it.|Done: |Done: created out.txt" {
+		t.Fatalf("expected the message text to stream as updates, got %q", updates)
+	}
+	if strings.TrimSpace(command) != "hi" {
+		t.Fatalf("unexpected command output %q", command)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, "out.txt")); err != nil {
+		t.Fatalf("command did not run in working directory: %v", err)
+	}
+	if usage == nil || usage.InputTokens != 30 || usage.OutputTokens != 9 || usage.CachedInputTokens != 2 {
+		t.Fatalf("unexpected usage %+v", usage)
+	}
+
+	// The second request carries the tool result for the model.
+	second := stub.requests[1]["messages"].([]any)
+	last := second[len(second)-1].(map[string]any)
+	if last["role"] != "tool" || last["tool_call_id"] != "call_a" || !strings.Contains(last["content"].(string), "hi") {
+		t.Fatalf("unexpected tool message %v", last)
+	}
+	if stub.requests[0]["model"] != "local-model" {
+		t.Fatalf("expected default model, got %v", stub.requests[0]["model"])
+	}
+
+	// Resuming replays the persisted history.
+	result, err = adapter.Stream(context.Background(), MessageRequest{ThreadExternalID: threadID, Input: "still there?", ThreadOptions: ThreadOptionsDTO{WorkingDirectory: workDir}})
+	if err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	events, err = collectEvents(t, result)
+	if err != nil {
+		t.Fatalf("resumed turn failed: %v", err)
+	}
+	for _, evt := range events {
+		if evt.Type == "thread.started" {
+			t.Fatal("resumed turn must not start a new thread")
+		}
+	}
+	third := stub.requests[2]["messages"].([]any)
+	// system, user, assistant+tool call, tool, assistant, user
+	if len(third) != 6 {
+		t.Fatalf("expected 6 history messages, got %d", len(third))
+	}
+}
+
+func TestOpenAIAdapterSurfacesHTTPErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "model not loaded", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	adapter, err := NewOpenAIAdapter(OpenAIAdapterOptions{BaseURL: server.URL, Model: "m", HistoryDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("new adapter: %v", err)
+	}
+	result, err := adapter.Stream(context.Background(), MessageRequest{Input: "hi"})
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	events, err := collectEvents(t, result)
+	if err == nil || !strings.Contains(err.Error(), "model not loaded") {
+		t.Fatalf("expected http error, got %v", err)
+	}
+	if last := events[len(events)-1]; last.Type != "turn.failed" || last.Error == nil {
+		t.Fatalf("expected turn.failed event, got %+v", last)
+	}
+}
+
+func TestOpenAIAdapterRefusesShellWithoutWorkingDirectory(t *testing.T) {
+	stub := &chatStub{responses: [][]string{
+		{`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_a","function":{"name":"shell","arguments":"{\"command\":\"touch out.txt\"}"}}]}}]}`},
+		{`{"choices":[{"delta":{"content":"Cannot run commands."}}]}`},
+	}}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	adapter, err := NewOpenAIAdapter(OpenAIAdapterOptions{BaseURL: server.URL + "/v1", Model: "m", HistoryDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("new adapter: %v", err)
+	}
+	result, err := adapter.Stream(context.Background(), MessageRequest{Input: "touch a file"})
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	events, err := collectEvents(t, result)
+	if err != nil {
+		t.Fatalf("turn failed: %v", err)
+	}
+	var status string
+	for _, evt := range events {
+		if evt.Type == "item.completed" && evt.Item.Command != nil {
+			status = evt.Item.Command.Status
+		}
+	}
+	if status != "declined" {
+		t.Fatalf("expected the command to be declined, got %q", status)
+	}
+	second := stub.requests[1]["messages"].([]any)
+	if tool := second[len(second)-1].(map[string]any); !strings.Contains(tool["content"].(string), "no working directory") {
+		t.Fatalf("expected the model to be told why, got %v", tool)
+	}
+}
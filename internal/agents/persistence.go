@@ -2,6 +2,7 @@ package agents
 
 import (
     "context"
+    "fmt"
     "io/fs"
     "os"
     "path/filepath"
@@ -9,21 +10,34 @@ import (
     "sync"
     "time"
 
+    "codex-ui/internal/jsonschema"
     "codex-ui/internal/storage/discovery"
 )
 
 type streamPersistence struct {
-	repo   *discovery.Repository
-	thread discovery.Thread
+	repo      *discovery.Repository
+	thread    discovery.Thread
+	agentID   string
+	startedAt time.Time
+	// outputSchema validates the final agent message when set.
+	outputSchema *jsonschema.Schema
+	// turnEntryID is the user entry that started the turn; with the item ID
+	// it keys the entry each agent item is stored in.
+	turnEntryID int64
 
 	mu                      sync.Mutex
 	externalID              string
 	finalStatus             discovery.ThreadStatus
 	lastActivity            *time.Time
 	finalAgentText          string
+	agentMessageEntryID     int64
 	reasoningSlices         []string
 	usage                   *UsageDTO
 	finalError              string
+	stopStatus              discovery.ThreadStatus
+	stopTone                string
+	stopMessage             string
+	stopMeta                map[string]any
 	agentMessagePersisted   bool
 	agentReasoningPersisted bool
 	finalised               bool
@@ -31,8 +45,9 @@ type streamPersistence struct {
 
 func newStreamPersistence(repo *discovery.Repository, thread discovery.Thread) *streamPersistence {
 	return &streamPersistence{
-		repo:   repo,
-		thread: thread,
+		repo:      repo,
+		thread:    thread,
+		startedAt: time.Now().UTC(),
 	}
 }
 
@@ -59,7 +74,10 @@ func (s *streamPersistence) recordThreadExternal(ctx context.Context, externalID
 	return nil
 }
 
-func (s *streamPersistence) storeAgentItem(ctx context.Context, item *AgentItemDTO) *time.Time {
+// storeAgentItem persists the latest state of an agent item. Items with an
+// ID are stored in one entry per turn that each event updates; completed
+// marks the item as finished.
+func (s *streamPersistence) storeAgentItem(ctx context.Context, item *AgentItemDTO, completed bool) *time.Time {
 	if item == nil {
 		return nil
 	}
@@ -68,14 +86,25 @@ func (s *streamPersistence) storeAgentItem(ctx context.Context, item *AgentItemD
 		return nil
 	}
 	now := time.Now().UTC()
-	if _, err := s.repo.CreateConversationEntry(ctx, discovery.CreateConversationEntryParams{
+	params := discovery.CreateConversationEntryParams{
 		ThreadID:  s.thread.ID,
 		Role:      "agent",
 		EntryType: item.Type,
 		Payload:   payload,
 		CreatedAt: now,
 		UpdatedAt: now,
-	}); err != nil {
+	}
+	if completed {
+		params.CompletedAt = &now
+	}
+	var entry discovery.ConversationEntry
+	if strings.TrimSpace(item.ID) != "" {
+		params.ItemKey = itemKey(s.turnEntryID, item.ID)
+		entry, err = s.repo.UpsertConversationEntry(ctx, params)
+	} else {
+		entry, err = s.repo.CreateConversationEntry(ctx, params)
+	}
+	if err != nil {
 		return nil
 	}
 	s.mu.Lock()
@@ -85,6 +114,7 @@ func (s *streamPersistence) storeAgentItem(ctx context.Context, item *AgentItemD
 		s.agentMessagePersisted = true
 		if strings.TrimSpace(item.Text) != "" {
 			s.finalAgentText = item.Text
+			s.agentMessageEntryID = entry.ID
 		}
 	case entryTypeAgentReasoning, "reasoning":
 		s.agentReasoningPersisted = true
@@ -128,6 +158,38 @@ func (s *streamPersistence) recordError(message string) {
 	s.mu.Unlock()
 }
 
+// recordStop marks the turn as stopped by the service rather than the user:
+// it finalises with status and a system entry with tone, message and meta. It
+// reports false when the turn already finished or was stopped before.
+func (s *streamPersistence) recordStop(status discovery.ThreadStatus, tone, message string, meta map[string]any) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.finalised || s.stopMessage != "" {
+		return false
+	}
+	s.stopStatus = status
+	s.stopTone = tone
+	s.stopMessage = message
+	s.stopMeta = meta
+	s.finalStatus = status
+	return true
+}
+
+// stopReason returns the status and message recorded by recordStop, if any.
+func (s *streamPersistence) stopReason() (discovery.ThreadStatus, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stopStatus, s.stopMessage
+}
+
+// turnOutcome returns the turn's final agent message, the entry storing it
+// and the reported usage.
+func (s *streamPersistence) turnOutcome() (string, int64, *UsageDTO) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.finalAgentText, s.agentMessageEntryID, s.usage
+}
+
 func (s *streamPersistence) finalize(ctx context.Context, status discovery.ThreadStatus) (discovery.Thread, error) {
 	s.mu.Lock()
 	if s.finalised {
@@ -148,6 +210,7 @@ func (s *streamPersistence) finalize(ctx context.Context, status discovery.Threa
 	reasoning := append([]string(nil), s.reasoningSlices...)
 	usage := s.usage
 	finalError := s.finalError
+	stopTone, stopMessage, stopMeta := s.stopTone, s.stopMessage, s.stopMeta
 	agentMessagePersisted := s.agentMessagePersisted
 	agentReasoningPersisted := s.agentReasoningPersisted
 	s.mu.Unlock()
@@ -156,7 +219,7 @@ func (s *streamPersistence) finalize(ctx context.Context, status discovery.Threa
 	var latest time.Time
 
 	if strings.TrimSpace(finalText) != "" && !agentMessagePersisted {
-		if created := s.storeAgentItem(ctx, &AgentItemDTO{Type: entryTypeAgentMessage, Text: finalText}); created != nil {
+		if created := s.storeAgentItem(ctx, &AgentItemDTO{Type: entryTypeAgentMessage, Text: finalText}, true); created != nil {
 			if !hasLatest || created.After(latest) {
 				hasLatest = true
 				latest = *created
@@ -164,9 +227,27 @@ func (s *streamPersistence) finalize(ctx context.Context, status discovery.Threa
 		}
 	}
 
+	if s.outputSchema != nil && status == discovery.ThreadStatusCompleted {
+		out := checkStructuredOutput(s.outputSchema, finalText)
+		if created := s.storeAgentItem(ctx, &AgentItemDTO{ID: structuredOutputItemID, Type: entryTypeStructuredOutput, StructuredOutput: out}, true); created != nil {
+			if !hasLatest || created.After(latest) {
+				hasLatest = true
+				latest = *created
+			}
+		}
+		if !out.Valid {
+			if created := s.createSystemEntry(ctx, "error", structuredOutputMessage(out), map[string]any{"violations": out.Violations}); created != nil {
+				if !hasLatest || created.After(latest) {
+					hasLatest = true
+					latest = *created
+				}
+			}
+		}
+	}
+
 	if len(reasoning) > 0 && !agentReasoningPersisted {
 		item := &AgentItemDTO{Type: "reasoning", Reasoning: strings.Join(reasoning, "\n")}
-		if created := s.storeAgentItem(ctx, item); created != nil {
+		if created := s.storeAgentItem(ctx, item, true); created != nil {
 			if !hasLatest || created.After(latest) {
 				hasLatest = true
 				latest = *created
@@ -175,6 +256,26 @@ func (s *streamPersistence) finalize(ctx context.Context, status discovery.Threa
 	}
 
 	if usage != nil {
+		if _, err := s.repo.RecordTurnUsage(ctx, discovery.TurnUsage{
+			ThreadID:          thread.ID,
+			ProjectID:         thread.ProjectID,
+			AgentID:           s.agentID,
+			Model:             thread.Model,
+			ReasoningLevel:    thread.ReasoningLevel,
+			Status:            string(status),
+			InputTokens:       int64(usage.InputTokens),
+			CachedInputTokens: int64(usage.CachedInputTokens),
+			OutputTokens:      int64(usage.OutputTokens),
+			StartedAt:         s.startedAt,
+		}); err != nil {
+			// Budgets and usage reports read the ledger, so say the turn is missing from it.
+			if created := s.createSystemEntry(ctx, "error", fmt.Sprintf("Token usage for this turn was not recorded: %v", err), nil); created != nil {
+				if !hasLatest || created.After(latest) {
+					hasLatest = true
+					latest = *created
+				}
+			}
+		}
 		message, meta := buildUsageSystemMessage(usage)
 		if strings.TrimSpace(message) != "" {
 			if created := s.createSystemEntry(ctx, "info", message, meta); created != nil {
@@ -195,6 +296,15 @@ func (s *streamPersistence) finalize(ctx context.Context, status discovery.Threa
 		}
 	}
 
+	if stopMessage != "" {
+		if created := s.createSystemEntry(ctx, stopTone, stopMessage, stopMeta); created != nil {
+			if !hasLatest || created.After(latest) {
+				hasLatest = true
+				latest = *created
+			}
+		}
+	}
+
 	var lastMessageAt *time.Time
 	if hasLatest {
 		lastMessageAt = &latest
@@ -0,0 +1,626 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"codex-ui/internal/storage/discovery"
+)
+
+// Policy actions and targets.
+const (
+	PolicyActionAllow = "allow"
+	PolicyActionDeny  = "deny"
+	PolicyActionAsk   = "ask"
+
+	PolicyTargetCommand = "command"
+	PolicyTargetPath    = "path"
+)
+
+var (
+	// ErrPolicyDenied is returned when a turn is stopped by a deny rule.
+	ErrPolicyDenied = errors.New("denied by policy")
+	// ErrApprovalRequired is returned when a turn is paused by an ask rule.
+	ErrApprovalRequired = errors.New("approval required")
+)
+
+// PolicyRuleDTO allows, denies or asks about agent commands or changed file
+// paths matching Pattern. Command patterns are globs where * matches anything,
+// matched against the command and each command of its script; path patterns
+// are relative to the worktree, * stays within a directory and ** crosses
+// directories, and a pattern without a slash matches the file name in any
+// directory.
+type PolicyRuleDTO struct {
+	ID          int64  `json:"id"`
+	ProjectID   int64  `json:"projectId"`
+	Position    int64  `json:"position"`
+	Action      string `json:"action"`
+	Target      string `json:"target"`
+	Pattern     string `json:"pattern"`
+	Description string `json:"description,omitempty"`
+	CreatedAt   string `json:"createdAt"`
+	UpdatedAt   string `json:"updatedAt"`
+}
+
+// SavePolicyRuleRequest holds the editable fields of a policy rule. ProjectID
+// is ignored on update.
+type SavePolicyRuleRequest struct {
+	ProjectID   int64  `json:"projectId"`
+	Position    int64  `json:"position"`
+	Action      string `json:"action"`
+	Target      string `json:"target"`
+	Pattern     string `json:"pattern"`
+	Description string `json:"description,omitempty"`
+}
+
+// PolicyDecisionDTO is one audited evaluation of a command or path. Decisions
+// without a RuleID matched no rule and were allowed.
+type PolicyDecisionDTO struct {
+	ID         int64  `json:"id"`
+	ThreadID   int64  `json:"threadId"`
+	ProjectID  int64  `json:"projectId"`
+	RuleID     int64  `json:"ruleId,omitempty"`
+	Pattern    string `json:"pattern,omitempty"`
+	Action     string `json:"action"`
+	Target     string `json:"target"`
+	Subject    string `json:"subject"`
+	ItemID     string `json:"itemId,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+	ApprovedAt string `json:"approvedAt,omitempty"`
+	CreatedAt  string `json:"createdAt"`
+}
+
+// PolicyDecisionQuery filters the decision log. Zero fields match all.
+type PolicyDecisionQuery struct {
+	ProjectID int64 `json:"projectId,omitempty"`
+	ThreadID  int64 `json:"threadId,omitempty"`
+	Limit     int   `json:"limit,omitempty"`
+}
+
+func toPolicyRuleDTO(rule discovery.PolicyRule) PolicyRuleDTO {
+	return PolicyRuleDTO{
+		ID:          rule.ID,
+		ProjectID:   rule.ProjectID,
+		Position:    rule.Position,
+		Action:      rule.Action,
+		Target:      rule.Target,
+		Pattern:     rule.Pattern,
+		Description: rule.Description,
+		CreatedAt:   rule.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedAt:   rule.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+func toPolicyDecisionDTO(decision discovery.PolicyDecision) PolicyDecisionDTO {
+	dto := PolicyDecisionDTO{
+		ID:        decision.ID,
+		ThreadID:  decision.ThreadID,
+		ProjectID: decision.ProjectID,
+		RuleID:    decision.RuleID,
+		Pattern:   decision.Pattern,
+		Action:    decision.Action,
+		Target:    decision.Target,
+		Subject:   decision.Subject,
+		ItemID:    decision.ItemID,
+		Reason:    decision.Reason,
+		CreatedAt: decision.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if decision.ApprovedAt != nil {
+		dto.ApprovedAt = decision.ApprovedAt.UTC().Format(time.RFC3339)
+	}
+	return dto
+}
+
+func policyRuleParams(req SavePolicyRuleRequest) (discovery.SavePolicyRuleParams, error) {
+	params := discovery.SavePolicyRuleParams{
+		ProjectID:   req.ProjectID,
+		Position:    req.Position,
+		Action:      strings.ToLower(strings.TrimSpace(req.Action)),
+		Target:      strings.ToLower(strings.TrimSpace(req.Target)),
+		Pattern:     strings.TrimSpace(req.Pattern),
+		Description: strings.TrimSpace(req.Description),
+	}
+	switch params.Action {
+	case PolicyActionAllow, PolicyActionDeny, PolicyActionAsk:
+	default:
+		return params, fmt.Errorf("policy action must be allow, deny or ask, got %q", req.Action)
+	}
+	switch params.Target {
+	case PolicyTargetCommand, PolicyTargetPath:
+	default:
+		return params, fmt.Errorf("policy target must be command or path, got %q", req.Target)
+	}
+	if params.Pattern == "" {
+		return params, errors.New("policy pattern is required")
+	}
+	if _, err := compilePolicyPattern(params.Target, params.Pattern); err != nil {
+		return params, fmt.Errorf("policy pattern %q: %w", params.Pattern, err)
+	}
+	return params, nil
+}
+
+// compilePolicyPattern turns a glob into an anchored regular expression.
+func compilePolicyPattern(target, pattern string) (*regexp.Regexp, error) {
+	paths := target == PolicyTargetPath
+	if paths {
+		pattern = strings.TrimPrefix(filepath.ToSlash(pattern), "./")
+		if !strings.Contains(strings.TrimSuffix(pattern, "/"), "/") {
+			pattern = "**/" + pattern
+		}
+	}
+	var b strings.Builder
+	b.WriteString(`(?s)^`)
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; {
+		case r == '*' && paths && i+1 < len(runes) && runes[i+1] == '*':
+			i++
+			if i+1 < len(runes) && runes[i+1] == '/' {
+				i++
+				b.WriteString(`(?:.*/)?`)
+			} else {
+				b.WriteString(`.*`)
+			}
+		case r == '*' && paths:
+			b.WriteString(`[^/]*`)
+		case r == '*':
+			b.WriteString(`.*`)
+		case r == '?' && paths:
+			b.WriteString(`[^/]`)
+		case r == '?':
+			b.WriteString(`.`)
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	if paths && strings.HasSuffix(pattern, "/") {
+		// A directory pattern covers everything beneath it.
+		b.WriteString(`.*`)
+	}
+	b.WriteString(`$`)
+	return regexp.Compile(b.String())
+}
+
+// shellScript matches commands the agent wraps in a shell, such as
+// bash -lc 'git push'.
+var shellScript = regexp.MustCompile(`^(?:\S*/)?(?:ba|z|da)?sh\s+-l?c\s+(.+)$`)
+
+// bareShell matches a shell that reads its script from stdin, as in
+// echo 'git push' | sh.
+var bareShell = regexp.MustCompile(`^(?:\S*/)?(?:ba|z|da)?sh(?:\s+-\S+)*$`)
+
+// commandPrefix matches what may run ahead of the actual command: variable
+// assignments and wrappers such as env or sudo together with their options.
+var commandPrefix = regexp.MustCompile(`^(?:[A-Za-z_][A-Za-z0-9_]*=(?:'[^']*'|"(?:[^"\\]|\\.)*"|[^\s'"])*|(?:\S*/)?(?:env|sudo|exec|command|nohup|time)(?:\s+-\S+)*)\s+`)
+
+// commandSubstitution matches $(...) and `...` whose output becomes part of
+// the command.
+var commandSubstitution = regexp.MustCompile("\\$\\(([^()]*)\\)|`([^`]*)`")
+
+// maxShellNesting bounds how deep commandCandidates unwraps nested shells.
+const maxShellNesting = 8
+
+// commandCandidates returns the strings a command pattern is matched against:
+// the command itself and every simple command it runs. Scripts are split on
+// ;, &, |, && and || and newlines, shell wrappers such as bash -lc '...' and
+// echo '...' | sh and command substitutions are unwrapped, and leading
+// variable assignments and env, sudo and similar wrappers are dropped.
+func commandCandidates(command string) []string {
+	candidates := []string{command}
+	seen := map[string]bool{command: true}
+	var walk func(script string, depth int)
+	walk = func(script string, depth int) {
+		segments := splitShellScript(script)
+		for i, segment := range segments {
+			for {
+				loc := commandPrefix.FindStringIndex(segment)
+				if loc == nil {
+					break
+				}
+				segment = segment[loc[1]:]
+			}
+			if !seen[segment] {
+				seen[segment] = true
+				candidates = append(candidates, segment)
+			}
+			if depth >= maxShellNesting {
+				continue
+			}
+			for _, m := range commandSubstitution.FindAllStringSubmatch(segment, -1) {
+				walk(m[1]+m[2], depth+1)
+			}
+			if m := shellScript.FindStringSubmatch(segment); m != nil {
+				walk(strings.Join(shellWords(m[1]), " "), depth+1)
+			} else if i > 0 && bareShell.MatchString(segment) {
+				if words := shellWords(segments[i-1]); len(words) > 0 && (words[0] == "echo" || words[0] == "printf") {
+					args := words[1:]
+					for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+						args = args[1:]
+					}
+					walk(strings.Join(args, " "), depth+1)
+				}
+			}
+		}
+	}
+	walk(command, 0)
+	return candidates
+}
+
+// splitShellScript splits script into its simple commands at unquoted
+// control operators and newlines. Grouping parentheses and braces around a
+// command are dropped.
+func splitShellScript(script string) []string {
+	var (
+		segments []string
+		current  strings.Builder
+		quote    rune
+		escaped  bool
+	)
+	flush := func() {
+		segment := strings.TrimSpace(strings.TrimLeft(current.String(), "({! \t"))
+		// Drop closing parentheses and braces that have no opening one left.
+		for strings.HasSuffix(segment, ")") && strings.Count(segment, ")") > strings.Count(segment, "(") ||
+			strings.HasSuffix(segment, "}") && strings.Count(segment, "}") > strings.Count(segment, "{") {
+			segment = strings.TrimSpace(segment[:len(segment)-1])
+		}
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+		current.Reset()
+	}
+	runes := []rune(script)
+	for i, r := range runes {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '&' && (i > 0 && (runes[i-1] == '>' || runes[i-1] == '<') || i+1 < len(runes) && runes[i+1] == '>'):
+			// Part of a redirection such as 2>&1 or &>log.
+		case r == ';' || r == '&' || r == '|' || r == '\n':
+			flush()
+			continue
+		}
+		current.WriteRune(r)
+	}
+	flush()
+	return segments
+}
+
+// shellWords splits s into words the way a shell would, removing quotes.
+func shellWords(s string) []string {
+	var (
+		words   []string
+		current strings.Builder
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range s {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, current.String())
+	}
+	return words
+}
+
+type compiledPolicyRule struct {
+	discovery.PolicyRule
+	re *regexp.Regexp
+}
+
+// policyChecker evaluates a turn's commands and file changes against the
+// project's rules and records each decision once per item.
+type policyChecker struct {
+	repo      *discovery.Repository
+	threadID  int64
+	projectID int64
+	rules     []compiledPolicyRule
+
+	mu   sync.Mutex
+	root string
+	seen map[string]bool
+}
+
+// newPolicyChecker loads the project's rules. It returns nil when there are
+// none, so projects without a policy record no decisions.
+func (s *Service) newPolicyChecker(ctx context.Context, threadID, projectID int64) (*policyChecker, error) {
+	rules, err := s.repo.ListPolicyRules(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	checker := &policyChecker{repo: s.repo, threadID: threadID, projectID: projectID, seen: make(map[string]bool)}
+	for _, rule := range rules {
+		re, err := compilePolicyPattern(rule.Target, rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("policy rule %d: %w", rule.ID, err)
+		}
+		checker.rules = append(checker.rules, compiledPolicyRule{PolicyRule: rule, re: re})
+	}
+	return checker, nil
+}
+
+// setRoot sets the directory file-change paths are made relative to.
+func (c *policyChecker) setRoot(root string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.root = strings.TrimSpace(root)
+	c.mu.Unlock()
+}
+
+// check evaluates the item's command and changed paths that were not seen
+// before and returns the first decision that blocks the turn.
+func (c *policyChecker) check(ctx context.Context, item *AgentItemDTO) *discovery.PolicyDecision {
+	if c == nil || item == nil {
+		return nil
+	}
+	if item.Command != nil {
+		if command := strings.TrimSpace(item.Command.Command); command != "" {
+			if decision := c.evaluate(ctx, PolicyTargetCommand, command, item.ID); decision != nil {
+				return decision
+			}
+		}
+	}
+	for _, change := range item.FileDiffs {
+		if path := c.relativePath(change.Path); path != "" {
+			if decision := c.evaluate(ctx, PolicyTargetPath, path, item.ID); decision != nil {
+				return decision
+			}
+		}
+	}
+	return nil
+}
+
+func (c *policyChecker) relativePath(path string) string {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return ""
+	}
+	c.mu.Lock()
+	root := c.root
+	c.mu.Unlock()
+	if root != "" && filepath.IsAbs(path) {
+		if rel, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(rel, "..") {
+			path = rel
+		}
+	}
+	return strings.TrimPrefix(filepath.ToSlash(filepath.Clean(path)), "./")
+}
+
+// evaluate records the decision for subject and returns it when it is a deny
+// or an unapproved ask.
+func (c *policyChecker) evaluate(ctx context.Context, target, subject, itemID string) *discovery.PolicyDecision {
+	key := target + "\x00" + subject + "\x00" + itemID
+	c.mu.Lock()
+	seen := c.seen[key]
+	c.seen[key] = true
+	c.mu.Unlock()
+	if seen {
+		return nil
+	}
+
+	decision := discovery.PolicyDecision{
+		ThreadID:  c.threadID,
+		ProjectID: c.projectID,
+		Action:    PolicyActionAllow,
+		Target:    target,
+		Subject:   subject,
+		ItemID:    itemID,
+		Reason:    "no rule matched",
+	}
+	if rule, ok := c.match(target, subject); ok {
+		decision.RuleID = rule.ID
+		decision.Pattern = rule.Pattern
+		decision.Action = rule.Action
+		decision.Reason = fmt.Sprintf("matched rule %d", rule.ID)
+		if rule.Description != "" {
+			decision.Reason += ": " + rule.Description
+		}
+		if rule.Action == PolicyActionAsk {
+			if approved, err := c.repo.PolicySubjectApproved(ctx, c.threadID, target, subject); err == nil && approved {
+				decision.Action = PolicyActionAllow
+				decision.Reason = fmt.Sprintf("approved earlier under rule %d", rule.ID)
+			}
+		}
+	}
+	if recorded, err := c.repo.RecordPolicyDecision(ctx, decision); err == nil {
+		decision = recorded
+	}
+	if decision.Action == PolicyActionAllow {
+		return nil
+	}
+	return &decision
+}
+
+// policyActionRank orders actions from least to most restrictive.
+var policyActionRank = map[string]int{PolicyActionAllow: 0, PolicyActionAsk: 1, PolicyActionDeny: 2}
+
+// match returns the rule that decides subject. Each command candidate is
+// decided by its first matching rule, and the most restrictive of those
+// decisions applies, so allowing one part of a script never lets another
+// part past a deny rule.
+func (c *policyChecker) match(target, subject string) (discovery.PolicyRule, bool) {
+	candidates := []string{subject}
+	if target == PolicyTargetCommand {
+		candidates = commandCandidates(subject)
+	}
+	var (
+		best  discovery.PolicyRule
+		found bool
+	)
+	for _, candidate := range candidates {
+		for _, rule := range c.rules {
+			if rule.Target != target || !rule.re.MatchString(candidate) {
+				continue
+			}
+			if !found || policyActionRank[rule.Action] > policyActionRank[best.Action] {
+				best, found = rule.PolicyRule, true
+			}
+			break
+		}
+	}
+	return best, found
+}
+
+// policyStop describes how a blocking decision ends the turn.
+func policyStop(decision discovery.PolicyDecision) (discovery.ThreadStatus, string, string) {
+	if decision.Action == PolicyActionAsk {
+		return discovery.ThreadStatusAwaitingApproval, "warning", fmt.Sprintf(
+			"Turn paused: policy rule %d (`%s`) asks before running the %s `%s`. Approve decision %d and send again to continue.",
+			decision.RuleID, decision.Pattern, decision.Target, decision.Subject, decision.ID)
+	}
+	return discovery.ThreadStatusPolicyDenied, "error", fmt.Sprintf(
+		"Turn stopped: policy rule %d (`%s`) denies the %s `%s`.",
+		decision.RuleID, decision.Pattern, decision.Target, decision.Subject)
+}
+
+// enforcePolicy stops the turn when the item runs a command or changes a path
+// the project's policy denies or asks about.
+func (s *Service) enforcePolicy(ctx context.Context, active *activeStream, item *AgentItemDTO) {
+	decision := active.policy.check(ctx, item)
+	if decision == nil {
+		return
+	}
+	status, tone, message := policyStop(*decision)
+	s.stopTurn(active, status, tone, message, map[string]any{"policyDecisionId": decision.ID, "policyRuleId": decision.RuleID})
+}
+
+// ListPolicyRules returns a project's rules in evaluation order.
+func (s *Service) ListPolicyRules(ctx context.Context, projectID int64) ([]PolicyRuleDTO, error) {
+	if err := s.ensureRepo(); err != nil {
+		return nil, err
+	}
+	rules, err := s.repo.ListPolicyRules(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]PolicyRuleDTO, 0, len(rules))
+	for _, rule := range rules {
+		out = append(out, toPolicyRuleDTO(rule))
+	}
+	return out, nil
+}
+
+// CreatePolicyRule adds a rule to a project's policy.
+func (s *Service) CreatePolicyRule(ctx context.Context, req SavePolicyRuleRequest) (PolicyRuleDTO, error) {
+	if err := s.ensureRepo(); err != nil {
+		return PolicyRuleDTO{}, err
+	}
+	params, err := policyRuleParams(req)
+	if err != nil {
+		return PolicyRuleDTO{}, err
+	}
+	if _, err := s.repo.GetProjectByID(ctx, params.ProjectID); err != nil {
+		return PolicyRuleDTO{}, err
+	}
+	rule, err := s.repo.CreatePolicyRule(ctx, params)
+	if err != nil {
+		return PolicyRuleDTO{}, err
+	}
+	return toPolicyRuleDTO(rule), nil
+}
+
+// UpdatePolicyRule replaces a rule's position, action, target and pattern.
+func (s *Service) UpdatePolicyRule(ctx context.Context, id int64, req SavePolicyRuleRequest) (PolicyRuleDTO, error) {
+	if err := s.ensureRepo(); err != nil {
+		return PolicyRuleDTO{}, err
+	}
+	params, err := policyRuleParams(req)
+	if err != nil {
+		return PolicyRuleDTO{}, err
+	}
+	rule, err := s.repo.UpdatePolicyRule(ctx, id, params)
+	if err != nil {
+		return PolicyRuleDTO{}, err
+	}
+	return toPolicyRuleDTO(rule), nil
+}
+
+// DeletePolicyRule removes a rule. Decisions recorded under it are kept.
+func (s *Service) DeletePolicyRule(ctx context.Context, id int64) error {
+	if err := s.ensureRepo(); err != nil {
+		return err
+	}
+	return s.repo.DeletePolicyRule(ctx, id)
+}
+
+// ListPolicyDecisions returns the decision log, newest first.
+func (s *Service) ListPolicyDecisions(ctx context.Context, query PolicyDecisionQuery) ([]PolicyDecisionDTO, error) {
+	if err := s.ensureRepo(); err != nil {
+		return nil, err
+	}
+	decisions, err := s.repo.ListPolicyDecisions(ctx, discovery.PolicyDecisionQuery{
+		ProjectID: query.ProjectID,
+		ThreadID:  query.ThreadID,
+		Limit:     query.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]PolicyDecisionDTO, 0, len(decisions))
+	for _, decision := range decisions {
+		out = append(out, toPolicyDecisionDTO(decision))
+	}
+	return out, nil
+}
+
+// ApprovePolicyDecision approves an ask decision so later turns of the same
+// thread may run the command or change the path.
+func (s *Service) ApprovePolicyDecision(ctx context.Context, id int64) (PolicyDecisionDTO, error) {
+	if err := s.ensureRepo(); err != nil {
+		return PolicyDecisionDTO{}, err
+	}
+	decision, err := s.repo.GetPolicyDecision(ctx, id)
+	if err != nil {
+		return PolicyDecisionDTO{}, err
+	}
+	if decision.Action != PolicyActionAsk {
+		return PolicyDecisionDTO{}, fmt.Errorf("policy decision %d did not ask for approval", id)
+	}
+	approved, err := s.repo.ApprovePolicyDecision(ctx, id)
+	if err != nil {
+		return PolicyDecisionDTO{}, err
+	}
+	return toPolicyDecisionDTO(approved), nil
+}
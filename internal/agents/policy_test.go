@@ -0,0 +1,172 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"codex-ui/internal/storage/discovery"
+)
+
+func TestCompilePolicyPattern(t *testing.T) {
+	cases := []struct {
+		target, pattern, subject string
+		want                     bool
+	}{
+		{PolicyTargetCommand, "git push*", "git push --force origin main", true},
+		{PolicyTargetCommand, "git push*", "git status", false},
+		{PolicyTargetCommand, "rm -rf *", "rm -rf /tmp/build", true},
+		{PolicyTargetPath, "*.env", "config/prod.env", true},
+		{PolicyTargetPath, "secrets/**", "secrets/keys/id_rsa", true},
+		{PolicyTargetPath, "secrets/*", "secrets/keys/id_rsa", false},
+		{PolicyTargetPath, "src/**/*.go", "src/main.go", true},
+		{PolicyTargetPath, "migrations/", "migrations/0001.sql", true},
+		{PolicyTargetPath, "./go.mod", "go.mod", true},
+		{PolicyTargetPath, "go.?od", "vendor/go.mod", true},
+	}
+	for _, tc := range cases {
+		re, err := compilePolicyPattern(tc.target, tc.pattern)
+		if err != nil {
+			t.Fatalf("compile %q: %v", tc.pattern, err)
+		}
+		if got := re.MatchString(tc.subject); got != tc.want {
+			t.Errorf("%s %q against %q: got %v, want %v", tc.target, tc.pattern, tc.subject, got, tc.want)
+		}
+	}
+	if got := commandCandidates(`/bin/bash -lc 'git push origin'`); len(got) != 2 || got[1] != "git push origin" {
+		t.Fatalf("unexpected shell candidates %q", got)
+	}
+}
+
+func TestPolicyMatchesEveryCommandInAScript(t *testing.T) {
+	checker := &policyChecker{}
+	for i, r := range []discovery.PolicyRule{
+		{Action: PolicyActionAllow, Target: PolicyTargetCommand, Pattern: "cd *"},
+		{Action: PolicyActionDeny, Target: PolicyTargetCommand, Pattern: "rm -rf *"},
+		{Action: PolicyActionDeny, Target: PolicyTargetCommand, Pattern: "git push*"},
+		{Action: PolicyActionAllow, Target: PolicyTargetCommand, Pattern: "*"},
+	} {
+		r.ID = int64(i + 1)
+		re, err := compilePolicyPattern(r.Target, r.Pattern)
+		if err != nil {
+			t.Fatalf("compile %q: %v", r.Pattern, err)
+		}
+		checker.rules = append(checker.rules, compiledPolicyRule{PolicyRule: r, re: re})
+	}
+	for _, command := range []string{
+		"cd x && rm -rf /",
+		"true; git push --force",
+		"make || git push",
+		"ls | rm -rf /tmp",
+		"sleep 1 & rm -rf /",
+		"go test\nrm -rf /",
+		"env FOO=1 rm -rf /",
+		`FOO="a b" BAR=2 git push`,
+		"sudo -E rm -rf /",
+		`bash -lc "cd repo && git push origin main"`,
+		`sh -c 'true; sh -c "rm -rf /"'`,
+		`echo 'rm -rf /' | sh`,
+		`printf "git push" | bash -s`,
+		"(cd x; rm -rf /)",
+		"echo $(rm -rf /)",
+		"echo `git push`",
+	} {
+		rule, ok := checker.match(PolicyTargetCommand, command)
+		if !ok || rule.Action != PolicyActionDeny {
+			t.Errorf("expected %q to be denied, got %+v", command, rule)
+		}
+	}
+	for _, command := range []string{"cd x && ls", `echo "rm -rf /; git push"`, "go test ./... 2>&1 | tee log"} {
+		if rule, _ := checker.match(PolicyTargetCommand, command); rule.Action != PolicyActionAllow {
+			t.Errorf("expected %q to be allowed, got %+v", command, rule)
+		}
+	}
+}
+
+func TestService_PolicyDenyStopsTurn(t *testing.T) {
+	adapter := &scriptedAdapter{events: []StreamEvent{
+		{Type: "item.completed", Item: &AgentItemDTO{ID: "c1", Type: "command_execution", Command: &CommandExecutionDTO{Command: "go test ./...", Status: "completed"}}},
+		{Type: "item.started", Item: &AgentItemDTO{ID: "c2", Type: "command_execution", Command: &CommandExecutionDTO{Command: "bash -lc 'git push --force'", Status: "in_progress"}}},
+		{Type: "item.updated", Item: &AgentItemDTO{ID: "c2", Type: "command_execution", Command: &CommandExecutionDTO{Command: "bash -lc 'git push --force'", Status: "in_progress"}}},
+	}}
+	svc, repo, project := newTestService(t, adapter)
+	ctx := context.Background()
+
+	rule, err := svc.CreatePolicyRule(ctx, SavePolicyRuleRequest{ProjectID: project.ID, Action: "Deny", Target: "command", Pattern: "git push*", Description: "no pushes from agents"})
+	if err != nil {
+		t.Fatalf("create rule: %v", err)
+	}
+	if _, err := svc.CreatePolicyRule(ctx, SavePolicyRuleRequest{ProjectID: project.ID, Action: "block", Target: "command", Pattern: "x"}); err == nil {
+		t.Fatal("expected unknown actions to be rejected")
+	}
+
+	thread, err := sendAndWait(t, svc, MessageRequest{ProjectID: project.ID, Input: "ship it"})
+	if !errors.Is(err, ErrPolicyDenied) {
+		t.Fatalf("expected policy error, got %v", err)
+	}
+	stored, _ := repo.GetThread(ctx, thread.ID)
+	if stored.Status != discovery.ThreadStatusPolicyDenied {
+		t.Fatalf("expected policy-denied status, got %q", stored.Status)
+	}
+	if msg := lastSystemMessage(t, repo, thread.ID); !strings.Contains(msg, `"tone":"error"`) || !strings.Contains(msg, "denies the command `bash -lc 'git push --force'`") {
+		t.Fatalf("unexpected system entry %s", msg)
+	}
+
+	decisions, err := svc.ListPolicyDecisions(ctx, PolicyDecisionQuery{ThreadID: thread.ID})
+	if err != nil {
+		t.Fatalf("list decisions: %v", err)
+	}
+	if len(decisions) != 2 {
+		t.Fatalf("expected one decision per command, got %+v", decisions)
+	}
+	if d := decisions[0]; d.Action != PolicyActionDeny || d.RuleID != rule.ID || d.ItemID != "c2" || !strings.Contains(d.Reason, "no pushes from agents") {
+		t.Fatalf("unexpected deny decision %+v", d)
+	}
+	if d := decisions[1]; d.Action != PolicyActionAllow || d.RuleID != 0 || d.Subject != "go test ./..." {
+		t.Fatalf("unexpected allow decision %+v", d)
+	}
+	if _, err := svc.ApprovePolicyDecision(ctx, decisions[0].ID); err == nil {
+		t.Fatal("expected deny decisions to be final")
+	}
+}
+
+func TestService_PolicyAskPausesUntilApproved(t *testing.T) {
+	adapter := &scriptedAdapter{events: []StreamEvent{
+		{Type: "item.completed", Item: &AgentItemDTO{ID: "f1", Type: "file_change", FileDiffs: []FileChangeDTO{{Path: "README.md", Kind: "update"}, {Path: "secrets/token.txt", Kind: "add"}}}},
+		{Type: "turn.completed", Usage: &UsageDTO{}},
+	}}
+	svc, repo, project := newTestService(t, adapter)
+	ctx := context.Background()
+	if _, err := svc.CreatePolicyRule(ctx, SavePolicyRuleRequest{ProjectID: project.ID, Action: "ask", Target: "path", Pattern: "secrets/**"}); err != nil {
+		t.Fatalf("create rule: %v", err)
+	}
+
+	thread, err := sendAndWait(t, svc, MessageRequest{ProjectID: project.ID, Input: "rotate the token"})
+	if !errors.Is(err, ErrApprovalRequired) {
+		t.Fatalf("expected approval error, got %v", err)
+	}
+	stored, _ := repo.GetThread(ctx, thread.ID)
+	if stored.Status != discovery.ThreadStatusAwaitingApproval {
+		t.Fatalf("expected awaiting-approval status, got %q", stored.Status)
+	}
+	decisions, _ := svc.ListPolicyDecisions(ctx, PolicyDecisionQuery{ThreadID: thread.ID, Limit: 1})
+	if len(decisions) != 1 || decisions[0].Action != PolicyActionAsk || decisions[0].Subject != "secrets/token.txt" {
+		t.Fatalf("unexpected decisions %+v", decisions)
+	}
+	if msg := lastSystemMessage(t, repo, thread.ID); !strings.Contains(msg, "Approve decision") || !strings.Contains(msg, `"policyDecisionId"`) {
+		t.Fatalf("unexpected system entry %s", msg)
+	}
+
+	approved, err := svc.ApprovePolicyDecision(ctx, decisions[0].ID)
+	if err != nil || approved.ApprovedAt == "" {
+		t.Fatalf("approve decision: %+v %v", approved, err)
+	}
+	if _, err := sendAndWait(t, svc, MessageRequest{ThreadID: thread.ID, Input: "continue"}); err != nil {
+		t.Fatalf("expected the approved path to pass, got %v", err)
+	}
+	latest, _ := svc.ListPolicyDecisions(ctx, PolicyDecisionQuery{ThreadID: thread.ID, Limit: 1})
+	if len(latest) != 1 || latest[0].Action != PolicyActionAllow || !strings.Contains(latest[0].Reason, "approved earlier") {
+		t.Fatalf("unexpected decision after approval %+v", latest)
+	}
+}
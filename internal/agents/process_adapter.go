@@ -0,0 +1,183 @@
+package agents
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// ProcessProtocolVersion is sent with every turn request so executables can
+// reject protocol revisions they do not understand.
+const ProcessProtocolVersion = 1
+
+// processStderrLimit bounds how much stderr is kept to explain failed runs.
+const processStderrLimit = 8 << 10
+
+// ProcessAdapter runs one executable per turn and exchanges JSON lines with
+// it: a single ProcessTurnRequest on stdin, StreamEvent objects on stdout.
+// See docs/agent-process-protocol.md.
+type ProcessAdapter struct {
+	command string
+	args    []string
+	env     []string
+}
+
+// ProcessTurnRequest is the first and only line written to the process stdin.
+type ProcessTurnRequest struct {
+	ProtocolVersion int               `json:"protocolVersion"`
+	ThreadID        string            `json:"threadId,omitempty"`
+	Input           string            `json:"input,omitempty"`
+	Segments        []InputSegmentDTO `json:"segments,omitempty"`
+	Options         ThreadOptionsDTO  `json:"options"`
+	OutputSchema    json.RawMessage   `json:"outputSchema,omitempty"`
+}
+
+// NewProcessAdapter constructs an adapter launching command with args. env
+// entries ("KEY=value") are appended to the current environment.
+func NewProcessAdapter(command string, args []string, env []string) (*ProcessAdapter, error) {
+	if strings.TrimSpace(command) == "" {
+		return nil, errors.New("process adapter command is required")
+	}
+	return &ProcessAdapter{command: command, args: args, env: env}, nil
+}
+
+// Stream implements Adapter.
+func (a *ProcessAdapter) Stream(ctx context.Context, req MessageRequest) (*StreamResult, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	payload := ProcessTurnRequest{
+		ProtocolVersion: ProcessProtocolVersion,
+		ThreadID:        req.ThreadExternalID,
+		Input:           req.Input,
+		Segments:        req.Segments,
+		Options:         req.ThreadOptions,
+	}
+	if req.TurnOptions != nil {
+		payload.OutputSchema = req.TurnOptions.OutputSchema
+	}
+	line, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("encode turn request: %w", err)
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	cmd := exec.CommandContext(runCtx, a.command, a.args...)
+	cmd.Dir = req.ThreadOptions.WorkingDirectory
+	cmd.Env = append(os.Environ(), a.env...)
+	cmd.Stdin = bytes.NewReader(append(line, '\n'))
+	stderr := &tailBuffer{limit: processStderrLimit}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("open process stdout: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return nil, fmt.Errorf("start %s: %w", a.command, err)
+	}
+
+	events := make(chan StreamEvent)
+	done := make(chan error, 1)
+
+	var closeOnce sync.Once
+	closeFn := func() error {
+		closeOnce.Do(cancel)
+		return nil
+	}
+
+	go func() {
+		defer close(events)
+		defer close(done)
+		defer cancel()
+
+		readErr := readProcessEvents(runCtx, stdout, events)
+		if readErr != nil {
+			// Malformed output aborts the turn.
+			cancel()
+		}
+		// Drain so the process is never blocked writing after we stopped reading.
+		_, _ = io.Copy(io.Discard, stdout)
+		waitErr := cmd.Wait()
+		switch {
+		case ctx.Err() != nil:
+			done <- ctx.Err()
+		case readErr != nil:
+			done <- readErr
+		case runCtx.Err() != nil:
+			// Closed by the caller.
+			done <- context.Canceled
+		case waitErr != nil:
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				done <- fmt.Errorf("%s: %w: %s", a.command, waitErr, msg)
+			} else {
+				done <- fmt.Errorf("%s: %w", a.command, waitErr)
+			}
+		default:
+			done <- nil
+		}
+	}()
+
+	return &StreamResult{Events: events, Done: done, Close: closeFn}, nil
+}
+
+// readProcessEvents decodes stdout lines into events. Lines that are not JSON
+// objects are ignored so that executables can print diagnostics.
+func readProcessEvents(ctx context.Context, r io.Reader, events chan<- StreamEvent) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+		var evt StreamEvent
+		if err := json.Unmarshal(line, &evt); err != nil {
+			return fmt.Errorf("decode process event: %w", err)
+		}
+		if strings.TrimSpace(evt.Type) == "" {
+			continue
+		}
+		select {
+		case events <- evt:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("read process output: %w", err)
+	}
+	return nil
+}
+
+// tailBuffer keeps the last limit bytes written to it.
+type tailBuffer struct {
+	mu    sync.Mutex
+	limit int
+	buf   []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if over := len(b.buf) - b.limit; over > 0 {
+		b.buf = b.buf[over:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
@@ -0,0 +1,162 @@
+package agents
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestProcessAdapterHelper is not a real test: it is the executable launched by
+// the process adapter tests when PROCESS_ADAPTER_HELPER is set.
+func TestProcessAdapterHelper(t *testing.T) {
+	mode := os.Getenv("PROCESS_ADAPTER_HELPER")
+	if mode == "" {
+		return
+	}
+	defer os.Exit(0)
+	line, _ := bufio.NewReader(os.Stdin).ReadBytes('\n')
+	var req ProcessTurnRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		fmt.Fprintln(os.Stderr, "bad request:", err)
+		os.Exit(3)
+	}
+	switch mode {
+	case "fail":
+		fmt.Fprintln(os.Stderr, "model unavailable")
+		os.Exit(2)
+	case "echo":
+		threadID := req.ThreadID
+		if threadID == "" {
+			threadID = "proc-thread-1"
+		}
+		exit := 0
+		out := json.NewEncoder(os.Stdout)
+		fmt.Println("starting up") // non-JSON diagnostics are ignored
+		_ = out.Encode(StreamEvent{Type: "thread.started", ThreadID: threadID})
+		_ = out.Encode(StreamEvent{Type: "turn.started"})
+		_ = out.Encode(StreamEvent{Type: "item.completed", Item: &AgentItemDTO{
+			ID: "cmd-1", Type: "command_execution",
+			Command: &CommandExecutionDTO{Command: "ls", AggregatedOutput: "ok", ExitCode: &exit, Status: "completed"},
+		}})
+		_ = out.Encode(StreamEvent{Type: "item.completed", Item: &AgentItemDTO{
+			ID: "msg-1", Type: "agent_message", Text: "echo: " + req.Input + " @" + filepath.Base(req.Options.WorkingDirectory),
+		}})
+		_ = out.Encode(StreamEvent{Type: "turn.completed", Usage: &UsageDTO{InputTokens: 3, OutputTokens: 2}})
+	}
+}
+
+func newHelperAdapter(t *testing.T, mode string) *ProcessAdapter {
+	t.Helper()
+	adapter, err := NewProcessAdapter(os.Args[0], []string{"-test.run=^TestProcessAdapterHelper$"}, []string{"PROCESS_ADAPTER_HELPER=" + mode})
+	if err != nil {
+		t.Fatalf("new process adapter: %v", err)
+	}
+	return adapter
+}
+
+func TestProcessAdapterStreamsThroughService(t *testing.T) {
+	svc, repo, project := newTestService(t, newHelperAdapter(t, "echo"))
+	ctx := context.Background()
+
+	stream, thread, err := svc.Send(ctx, MessageRequest{
+		ProjectID:     project.ID,
+		Input:         "hello",
+		ThreadOptions: ThreadOptionsDTO{Model: "local", WorkingDirectory: t.TempDir()},
+	})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	var types []string
+	for evt := range stream.Events() {
+		types = append(types, evt.Type)
+	}
+	if err := stream.Wait(); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	if got := strings.Join(types, ","); got != "thread.started,turn.started,item.completed,item.completed,turn.completed" {
+		t.Fatalf("unexpected events %s", got)
+	}
+
+	stored, err := repo.GetThread(ctx, thread.ID)
+	if err != nil {
+		t.Fatalf("get thread: %v", err)
+	}
+	if stored.ExternalID != "proc-thread-1" {
+		t.Fatalf("expected external id to be recorded, got %q", stored.ExternalID)
+	}
+	entries, err := svc.LoadThreadConversation(ctx, thread.ID)
+	if err != nil {
+		t.Fatalf("load conversation: %v", err)
+	}
+	var message string
+	var sawCommand bool
+	for _, entry := range entries {
+		if entry.Item == nil {
+			continue
+		}
+		if entry.Item.Command != nil && entry.Item.Command.Command == "ls" {
+			sawCommand = true
+		}
+		if entry.Item.Type == entryTypeAgentMessage {
+			message = entry.Item.Text
+		}
+	}
+	if !sawCommand || !strings.HasPrefix(message, "echo: hello @") {
+		t.Fatalf("unexpected transcript: command=%v message=%q", sawCommand, message)
+	}
+}
+
+func TestProcessAdapterReportsExitFailure(t *testing.T) {
+	adapter := newHelperAdapter(t, "fail")
+	result, err := adapter.Stream(context.Background(), MessageRequest{Input: "hi", ThreadOptions: ThreadOptionsDTO{WorkingDirectory: t.TempDir()}})
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	for range result.Events {
+	}
+	err = <-result.Done
+	if err == nil || !strings.Contains(err.Error(), "model unavailable") {
+		t.Fatalf("expected stderr in error, got %v", err)
+	}
+}
+
+func TestLoadAdapterConfigs(t *testing.T) {
+	dir := t.TempDir()
+	if configs, err := LoadAdapterConfigs(filepath.Join(dir, AdaptersConfigFile)); err != nil || configs != nil {
+		t.Fatalf("missing file: configs=%v err=%v", configs, err)
+	}
+
+	path := filepath.Join(dir, AdaptersConfigFile)
+	raw := `{"adapters":[{"id":"local","command":"my-agent","args":["--jsonl"],"env":{"B":"2","A":"1"}}]}`
+	if err := os.WriteFile(path, []byte(raw), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	configs, err := LoadAdapterConfigs(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(configs) != 1 || configs[0].ID != "local" || configs[0].Command != "my-agent" {
+		t.Fatalf("unexpected configs %+v", configs)
+	}
+	adapter, err := NewAdapterFromConfig(configs[0], dir)
+	if err != nil {
+		t.Fatalf("build adapter: %v", err)
+	}
+	proc, ok := adapter.(*ProcessAdapter)
+	if !ok || strings.Join(proc.env, ",") != "A=1,B=2" {
+		t.Fatalf("unexpected adapter %#v", adapter)
+	}
+
+	dup := `{"adapters":[{"id":"x","command":"a"},{"id":"x","command":"b"}]}`
+	if err := os.WriteFile(path, []byte(dup), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadAdapterConfigs(path); err == nil {
+		t.Fatal("expected duplicate ids to be rejected")
+	}
+}
@@ -5,6 +5,9 @@ import (
     "fmt"
     "strings"
     "time"
+
+    "codex-ui/internal/git/worktrees"
+    "codex-ui/internal/storage/discovery"
 )
 
 type prStream struct {
@@ -13,36 +16,127 @@ type prStream struct {
     Close  func() error
 }
 
-// BuildCreatePRInstruction composes the instruction sent to the agent to create a PR.
+// BuildCreatePRInstruction composes the instruction sent to the agent to create
+// a PR from the built-in template.
 func BuildCreatePRInstruction(branchName string) string {
-    return fmt.Sprintf(`You are operating in a git worktree branch for this thread.
-Task:
-1) Review all staged and unstaged changes.
-2) Group logically and create conventional commits (feat|fix|chore|refactor|docs|test) with meaningful scope and messages.
-3) Push the branch '%s' to origin and ensure upstream is set.
-4) Create or update a GitHub pull request from this branch against the default base branch.
-   - Use a conventional title.
-   - Write a clear, structured description that summarizes the changes.
-
-Constraints:
-- Prefer the GitHub CLI (gh). If a PR already exists for the branch, update it.
-- Do not print secrets or token values.
-
-Output:
-- After completion print exactly one line with: PR_URL: https://github.com/<owner>/<repo>/pull/<number>
-- Do not include any other lines after the PR_URL line.`, branchName)
+    tmpl, _ := builtinTemplate(CreatePRTemplateName)
+    instruction, _ := ExpandTemplate(tmpl.Body, map[string]string{"branch": branchName, "title": ""})
+    return instruction
 }
 
 // StartBackgroundPRStream starts a background agent run to create a PR.
-func StartBackgroundPRStream(worktree, sandboxMode, instruction string) (*prStream, error) {
+// opts.Model and opts.WorkingDirectory are required.
+func StartBackgroundPRStream(opts ThreadOptionsDTO, instruction string) (*prStream, error) {
     adapter, err := NewCodexAdapter(CodexOptionsFromEnv())
     if err != nil { return nil, fmt.Errorf("initialise codex adapter: %w", err) }
-    sandbox := strings.TrimSpace(sandboxMode)
-    if sandbox == "" { sandbox = "workspace-write" }
-    req := MessageRequest{ ThreadOptions: ThreadOptionsDTO{ Model: "gpt-5.1-codex", SandboxMode: sandbox, ReasoningLevel: "minimal", WorkingDirectory: worktree, SkipGitRepoCheck: false }, Input: instruction }
+    if strings.TrimSpace(opts.SandboxMode) == "" { opts.SandboxMode = "workspace-write" }
+    opts.SkipGitRepoCheck = false
+    req := MessageRequest{ ThreadOptions: opts, Input: instruction }
     ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
     res, err := adapter.Stream(ctx, req)
     if err != nil { cancel(); return nil, err }
     wrappedClose := func() error { cancel(); if res.Close!=nil { return res.Close() }; return nil }
     return &prStream{Events: res.Events, Done: res.Done, Close: wrappedClose}, nil
 }
+
+// CreatePullRequest runs a background agent turn in the thread worktree that
+// commits pending changes, pushes the branch and opens a GitHub PR. Returns the
+// PR URL. If a PR URL is already stored for the thread it is returned unchanged.
+func (s *Service) CreatePullRequest(ctx context.Context, threadID int64) (string, error) {
+	thread, err := s.GetThread(ctx, threadID)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(thread.PRURL) != "" {
+		return thread.PRURL, nil
+	}
+	worktree := strings.TrimSpace(thread.WorktreePath)
+	if worktree == "" {
+		return "", fmt.Errorf("thread %d has no worktree", threadID)
+	}
+	diffs, err := s.ListThreadDiffStats(ctx, threadID)
+	if err != nil {
+		return "", err
+	}
+	if len(diffs) == 0 {
+		return "", fmt.Errorf("no file changes detected")
+	}
+	// Resolve branch name with fallback and persist if missing
+	branch := strings.TrimSpace(thread.BranchName)
+	if branch == "" {
+		branch = worktrees.BranchName(thread.Title, thread.ID)
+		// best-effort persist before running the PR job
+		_ = s.repo.UpdateThreadBranchName(ctx, thread.ID, branch)
+	}
+	tmpl, err := s.ResolvePromptTemplate(ctx, thread.ProjectID, CreatePRTemplateName)
+	if err != nil {
+		return "", err
+	}
+	instruction, err := ExpandTemplate(tmpl.Body, map[string]string{"branch": branch, "title": thread.Title})
+	if err != nil {
+		return "", fmt.Errorf("template %q: %w", tmpl.Name, err)
+	}
+	// The PR job runs with full access, so the project's policy is the only
+	// guard on what it executes.
+	policy, err := s.newPolicyChecker(ctx, thread.ID, thread.ProjectID)
+	if err != nil {
+		return "", err
+	}
+	policy.setRoot(worktree)
+	stream, err := StartBackgroundPRStream(s.prThreadOptions(ctx, thread, worktree), instruction)
+	if err != nil {
+		return "", err
+	}
+	if stream.Close != nil {
+		defer stream.Close()
+	}
+	var prURL string
+	for evt := range stream.Events {
+		if decision := policy.check(ctx, evt.Item); decision != nil {
+			status, _, message := policyStop(*decision)
+			return "", fmt.Errorf("%w: %s", stopError(status), message)
+		}
+		if url := ExtractPRURLFromEvent(evt); url != "" {
+			prURL = url
+		}
+	}
+	if stream.Done != nil {
+		if waitErr, ok := <-stream.Done; ok && waitErr != nil {
+			return "", waitErr
+		}
+	}
+	if strings.TrimSpace(prURL) == "" {
+		return "", fmt.Errorf("failed to detect PR URL from agent run")
+	}
+	if err := s.repo.UpdateThreadPRURL(ctx, thread.ID, prURL); err != nil {
+		return "", err
+	}
+	_ = s.repo.UpdateThreadStatus(ctx, thread.ID, discovery.ThreadStatusAwaitingReview, nil)
+	return prURL, nil
+}
+
+// prThreadOptions runs the PR job on the thread's Codex model (falling back to
+// the project and catalog defaults) at its cheapest reasoning level.
+func (s *Service) prThreadOptions(ctx context.Context, thread ThreadDTO, worktree string) ThreadOptionsDTO {
+	const agentID = "codex"
+	model := strings.TrimSpace(thread.Model)
+	if _, ok := s.catalog.Lookup(agentID, model); !ok {
+		model = ""
+		if settings, err := s.repo.GetProjectSettings(ctx, thread.ProjectID); err == nil {
+			if _, ok := s.catalog.Lookup(agentID, settings.DefaultModel); ok {
+				model = settings.DefaultModel
+			}
+		}
+	}
+	if model == "" {
+		if entry, ok := s.catalog.DefaultModel(agentID); ok {
+			model = entry.ID
+		}
+	}
+	return ThreadOptionsDTO{
+		Model:            model,
+		SandboxMode:      "danger-full-access",
+		ReasoningLevel:   s.lowestReasoningLevel(agentID, model),
+		WorkingDirectory: worktree,
+	}
+}
@@ -0,0 +1,331 @@
+package agents
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"codex-ui/internal/storage/discovery"
+)
+
+// SetQueuedStreamHandler registers a callback that receives streams started
+// in the background (queued turns, SendDetached), so callers can forward
+// their events.
+func (s *Service) SetQueuedStreamHandler(fn func(*Stream, discovery.Thread)) {
+	s.activeMu.Lock()
+	s.onQueuedStream = fn
+	s.activeMu.Unlock()
+}
+
+// threadLockTTL bounds how long a thread stays locked after the process
+// running its turn dies; running turns renew the lock well before then.
+const threadLockTTL = 2 * time.Minute
+
+// claimThread reserves a thread for starting a new turn. It fails when the
+// thread already has an active stream, another turn is being started, or
+// another process holds the thread's turn lock.
+func (s *Service) claimThread(threadID int64) bool {
+	s.activeMu.Lock()
+	if s.claimed[threadID] || s.hasActiveStreamLocked(threadID) {
+		s.activeMu.Unlock()
+		return false
+	}
+	s.claimed[threadID] = true
+	s.activeMu.Unlock()
+	if s.repo == nil {
+		return true
+	}
+	if ok, err := s.repo.AcquireThreadLock(context.Background(), threadID, s.lockOwner, threadLockTTL); err != nil || !ok {
+		s.activeMu.Lock()
+		delete(s.claimed, threadID)
+		s.activeMu.Unlock()
+		return false
+	}
+	return true
+}
+
+// releaseThread ends a claim. A turn that started keeps the thread's lock
+// until its stream finishes.
+func (s *Service) releaseThread(threadID int64) {
+	s.activeMu.Lock()
+	delete(s.claimed, threadID)
+	running := s.hasActiveStreamLocked(threadID)
+	s.activeMu.Unlock()
+	if !running {
+		s.releaseThreadLock(threadID)
+	}
+}
+
+func (s *Service) hasActiveStreamLocked(threadID int64) bool {
+	for _, a := range s.active {
+		if a.threadID == threadID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Service) releaseThreadLock(threadID int64) {
+	if s.repo != nil {
+		_ = s.repo.ReleaseThreadLock(context.Background(), threadID, s.lockOwner)
+	}
+}
+
+// renewThreadLock keeps the thread's lock alive while a turn runs. The
+// returned function stops renewing.
+func (s *Service) renewThreadLock(threadID int64) func() {
+	if s.repo == nil {
+		return func() {}
+	}
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(threadLockTTL / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				_ = s.repo.RenewThreadLock(context.Background(), threadID, s.lockOwner, threadLockTTL)
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return func() { close(stop) }
+}
+
+// cancelPollInterval is how often a running turn checks whether another
+// process asked to stop it.
+const cancelPollInterval = time.Second
+
+// watchCancelRequests stops a running turn when another process asks to cancel
+// its thread through the thread's lock. The returned function stops watching.
+func (s *Service) watchCancelRequests(streamID string, threadID int64) func() {
+	if s.repo == nil {
+		return func() {}
+	}
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(cancelPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if requested, err := s.repo.ThreadCancelRequested(context.Background(), threadID, s.lockOwner); err == nil && requested {
+					_, _ = s.Cancel(context.Background(), streamID)
+					return
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return func() { close(stop) }
+}
+
+// EnqueueTurn appends a follow-up to the thread's turn queue. The turn starts
+// as soon as the thread has no running turn, in a process that dispatches
+// queued turns.
+func (s *Service) EnqueueTurn(ctx context.Context, req MessageRequest) (QueuedTurnDTO, error) {
+	if err := s.ensureRepo(); err != nil {
+		return QueuedTurnDTO{}, err
+	}
+	if req.ThreadID == 0 {
+		return QueuedTurnDTO{}, errors.New("threadId is required to queue a turn")
+	}
+	if err := s.applyTemplate(ctx, &req); err != nil {
+		return QueuedTurnDTO{}, err
+	}
+	if strings.TrimSpace(req.Input) == "" && len(req.Segments) == 0 {
+		return QueuedTurnDTO{}, errors.New("input text or segments are required")
+	}
+	thread, err := s.repo.GetThread(ctx, req.ThreadID)
+	if err != nil {
+		return QueuedTurnDTO{}, err
+	}
+	if thread.ArchivedAt != nil {
+		return QueuedTurnDTO{}, fmt.Errorf("%w: thread %d", ErrThreadArchived, thread.ID)
+	}
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return QueuedTurnDTO{}, fmt.Errorf("encode queued turn: %w", err)
+	}
+	record, err := s.repo.EnqueueTurn(ctx, req.ThreadID, payload)
+	if err != nil {
+		return QueuedTurnDTO{}, err
+	}
+	if !s.isThreadActive(req.ThreadID) {
+		_ = s.repo.UpdateThreadStatus(ctx, req.ThreadID, discovery.ThreadStatusQueued, nil)
+	}
+	go s.dispatchQueuedTurn(req.ThreadID)
+	return toQueuedTurnDTO(record)
+}
+
+// ListQueuedTurns returns the pending turns of a thread in execution order.
+func (s *Service) ListQueuedTurns(ctx context.Context, threadID int64) ([]QueuedTurnDTO, error) {
+	if err := s.ensureRepo(); err != nil {
+		return nil, err
+	}
+	records, err := s.repo.ListQueuedTurns(ctx, threadID)
+	if err != nil {
+		return nil, err
+	}
+	dtos := make([]QueuedTurnDTO, 0, len(records))
+	for _, record := range records {
+		dto, err := toQueuedTurnDTO(record)
+		if err != nil {
+			return nil, err
+		}
+		dtos = append(dtos, dto)
+	}
+	return dtos, nil
+}
+
+// ReorderQueuedTurns sets the execution order of a thread's pending turns.
+func (s *Service) ReorderQueuedTurns(ctx context.Context, threadID int64, ids []int64) ([]QueuedTurnDTO, error) {
+	if err := s.ensureRepo(); err != nil {
+		return nil, err
+	}
+	if err := s.repo.ReorderQueuedTurns(ctx, threadID, ids); err != nil {
+		return nil, err
+	}
+	return s.ListQueuedTurns(ctx, threadID)
+}
+
+// CancelQueuedTurn removes a pending turn of a thread before it starts. It
+// returns an error wrapping sql.ErrNoRows when the turn is not queued on that
+// thread.
+func (s *Service) CancelQueuedTurn(ctx context.Context, threadID, queuedTurnID int64) error {
+	if err := s.ensureRepo(); err != nil {
+		return err
+	}
+	if err := s.repo.DeleteQueuedTurn(ctx, threadID, queuedTurnID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("queued turn %d not found in thread %d: %w", queuedTurnID, threadID, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// ResumeQueuedTurns starts the head of every persisted queue. It is intended to
+// run once at startup so that turns queued before a restart are not lost.
+func (s *Service) ResumeQueuedTurns(ctx context.Context) error {
+	if err := s.ensureRepo(); err != nil {
+		return err
+	}
+	threadIDs, err := s.repo.ListThreadsWithQueuedTurns(ctx)
+	if err != nil {
+		return err
+	}
+	for _, id := range threadIDs {
+		go s.dispatchQueuedTurn(id)
+	}
+	return nil
+}
+
+// dispatchQueuedTurn starts the head of a thread's queue when the thread is
+// idle. The turn stays queued under a claim until it has started, so a turn
+// that cannot start keeps its ID and place. Failures are recorded on the
+// thread as system entries; the turn is retried on the next dispatch.
+func (s *Service) dispatchQueuedTurn(threadID int64) {
+	if s.repo == nil || s.queueDispatchOff || s.isThreadActive(threadID) {
+		return
+	}
+	ctx := context.Background()
+	for {
+		next, err := s.repo.NextQueuedTurn(ctx, threadID)
+		if err != nil {
+			return
+		}
+		if s.isThreadActive(threadID) {
+			return
+		}
+		if err := s.repo.ClaimQueuedTurn(ctx, threadID, next.ID, threadLockTTL); err != nil {
+			// Another dispatcher is starting this turn.
+			return
+		}
+		var req MessageRequest
+		if err := json.Unmarshal(next.Request, &req); err != nil {
+			// A turn that cannot be decoded never starts; drop it.
+			_ = s.repo.DeleteQueuedTurn(ctx, threadID, next.ID)
+			s.recordQueueFailure(ctx, threadID, fmt.Errorf("decode queued turn: %w", err))
+			continue
+		}
+		req.ThreadID = threadID
+		stream, thread, err := s.send(ctx, req, true)
+		if err != nil {
+			_ = s.repo.ReleaseQueuedTurnClaim(ctx, threadID, next.ID)
+			if !errors.Is(err, ErrThreadBusy) {
+				// The running turn dispatches it again when the thread is busy.
+				s.recordQueueFailure(ctx, threadID, err)
+			}
+			return
+		}
+		_ = s.repo.DeleteQueuedTurn(ctx, threadID, next.ID)
+		s.handOffStream(stream, thread)
+		return
+	}
+}
+
+// SendDetached starts a turn nobody is reading (e.g. a scheduled run). The
+// stream's events go to the handler registered with SetQueuedStreamHandler,
+// or are drained when none is set; callers may still Wait on the returned
+// stream to learn how the turn ended.
+func (s *Service) SendDetached(ctx context.Context, req MessageRequest) (*Stream, discovery.Thread, error) {
+	stream, thread, err := s.Send(ctx, req)
+	if err != nil {
+		return nil, discovery.Thread{}, err
+	}
+	s.handOffStream(stream, thread)
+	return stream, thread, nil
+}
+
+func (s *Service) handOffStream(stream *Stream, thread discovery.Thread) {
+	s.activeMu.Lock()
+	handler := s.onQueuedStream
+	s.activeMu.Unlock()
+	if handler != nil {
+		handler(stream, thread)
+	} else {
+		go drainStream(stream)
+	}
+}
+
+func (s *Service) recordQueueFailure(ctx context.Context, threadID int64, err error) {
+	thread, getErr := s.repo.GetThread(ctx, threadID)
+	if getErr != nil {
+		return
+	}
+	state := newStreamPersistence(s.repo, thread)
+	state.createSystemEntry(ctx, "error", fmt.Sprintf("Queued turn failed to start: %v", err), nil)
+	if thread.Status == discovery.ThreadStatusQueued {
+		_ = s.repo.UpdateThreadStatus(ctx, threadID, discovery.ThreadStatusFailed, nil)
+	}
+}
+
+// drainStream consumes a stream nobody is listening to so it can complete.
+func drainStream(stream *Stream) {
+	defer stream.Close()
+	for range stream.Events() {
+	}
+	_ = stream.Wait()
+}
+
+func toQueuedTurnDTO(record discovery.QueuedTurn) (QueuedTurnDTO, error) {
+	var req MessageRequest
+	if err := json.Unmarshal(record.Request, &req); err != nil {
+		return QueuedTurnDTO{}, fmt.Errorf("decode queued turn %d: %w", record.ID, err)
+	}
+	return QueuedTurnDTO{
+		ID:        record.ID,
+		ThreadID:  record.ThreadID,
+		Position:  record.Position,
+		Input:     deriveUserMessageText(req),
+		Segments:  req.Segments,
+		CreatedAt: record.CreatedAt.Format(time.RFC3339),
+	}, nil
+}
@@ -0,0 +1,234 @@
+package agents
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"codex-ui/internal/storage/discovery"
+	"codex-ui/internal/storage/migrate"
+
+	_ "modernc.org/sqlite"
+)
+
+func newTestService(t *testing.T, adapter Adapter) (*Service, *discovery.Repository, discovery.Project) {
+	t.Helper()
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", strings.ReplaceAll(t.Name(), "/", "_"))
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatalf("open in-memory database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	if err := migrate.Up(db); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	repo := discovery.NewRepository(db)
+	project, err := repo.UpsertProject(context.Background(), discovery.UpsertProjectParams{Path: "/tmp/" + t.Name()})
+	if err != nil {
+		t.Fatalf("upsert project: %v", err)
+	}
+	svc := NewService("fake", repo)
+	if err := svc.Register("fake", adapter); err != nil {
+		t.Fatalf("register adapter: %v", err)
+	}
+	return svc, repo, project
+}
+
+// scriptedAdapter replays a fixed set of events per turn and blocks until released.
+type scriptedAdapter struct {
+	mu      sync.Mutex
+	inputs  []string
+	release chan struct{}
+	events  []StreamEvent
+}
+
+func (a *scriptedAdapter) Stream(ctx context.Context, req MessageRequest) (*StreamResult, error) {
+	a.mu.Lock()
+	a.inputs = append(a.inputs, req.Input)
+	release := a.release
+	a.mu.Unlock()
+	events := make(chan StreamEvent)
+	done := make(chan error, 1)
+	go func() {
+		defer close(events)
+		defer close(done)
+		if release != nil {
+			select {
+			case <-release:
+			case <-ctx.Done():
+				done <- ctx.Err()
+				return
+			}
+		}
+		for _, evt := range a.events {
+			select {
+			case events <- evt:
+			case <-ctx.Done():
+				done <- ctx.Err()
+				return
+			}
+		}
+		done <- nil
+	}()
+	return &StreamResult{Events: events, Done: done}, nil
+}
+
+func (a *scriptedAdapter) seenInputs() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]string(nil), a.inputs...)
+}
+
+func TestService_QueueRunsFollowUpsInOrder(t *testing.T) {
+	adapter := &scriptedAdapter{release: make(chan struct{}), events: []StreamEvent{{Type: "turn.completed", Usage: &UsageDTO{}}}}
+	svc, _, project := newTestService(t, adapter)
+	ctx := context.Background()
+
+	started := make(chan *Stream, 4)
+	svc.SetQueuedStreamHandler(func(stream *Stream, _ discovery.Thread) { started <- stream })
+
+	first, thread, err := svc.Send(ctx, MessageRequest{ProjectID: project.ID, Input: "first", ThreadOptions: ThreadOptionsDTO{Model: "m"}})
+	if err != nil {
+		t.Fatalf("send first: %v", err)
+	}
+	if _, _, err := svc.Send(ctx, MessageRequest{ThreadID: thread.ID, Input: "busy"}); !errors.Is(err, ErrThreadBusy) {
+		t.Fatalf("expected ErrThreadBusy, got %v", err)
+	}
+	second, err := svc.EnqueueTurn(ctx, MessageRequest{ThreadID: thread.ID, Input: "second"})
+	if err != nil {
+		t.Fatalf("enqueue second: %v", err)
+	}
+	third, err := svc.EnqueueTurn(ctx, MessageRequest{ThreadID: thread.ID, Input: "third"})
+	if err != nil {
+		t.Fatalf("enqueue third: %v", err)
+	}
+	if _, err := svc.ReorderQueuedTurns(ctx, thread.ID, []int64{third.ID, second.ID}); err != nil {
+		t.Fatalf("reorder: %v", err)
+	}
+
+	close(adapter.release)
+	go drainStream(first)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case stream := <-started:
+			drainStream(stream)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for queued turn %d", i+1)
+		}
+	}
+
+	got := adapter.seenInputs()
+	want := []string{"first", "third", "second"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected turn order: got %v want %v", got, want)
+	}
+	if remaining, err := svc.ListQueuedTurns(ctx, thread.ID); err != nil || len(remaining) != 0 {
+		t.Fatalf("expected empty queue, got %v (err %v)", remaining, err)
+	}
+}
+
+func TestService_ThreadLockSpansServices(t *testing.T) {
+	adapter := &scriptedAdapter{release: make(chan struct{}), events: []StreamEvent{{Type: "turn.completed", Usage: &UsageDTO{}}}}
+	svc, repo, project := newTestService(t, adapter)
+	// other stands in for a second process (the CLI) sharing the catalog.
+	other := NewService("fake", repo)
+	if err := other.Register("fake", adapter); err != nil {
+		t.Fatalf("register adapter: %v", err)
+	}
+	ctx := context.Background()
+
+	stream, thread, err := svc.Send(ctx, MessageRequest{ProjectID: project.ID, Input: "first", ThreadOptions: ThreadOptionsDTO{Model: "m"}})
+	if err != nil {
+		t.Fatalf("send first: %v", err)
+	}
+	if _, _, err := svc.Send(ctx, MessageRequest{ThreadID: thread.ID, Input: "again"}); !errors.Is(err, ErrThreadBusy) {
+		t.Fatalf("expected ErrThreadBusy from the same service, got %v", err)
+	}
+	if _, _, err := other.Send(ctx, MessageRequest{ThreadID: thread.ID, Input: "elsewhere"}); !errors.Is(err, ErrThreadBusy) {
+		t.Fatalf("expected ErrThreadBusy from another service, got %v", err)
+	}
+
+	close(adapter.release)
+	drainStream(stream)
+	if _, err := sendAndWait(t, other, MessageRequest{ThreadID: thread.ID, Input: "after"}); err != nil {
+		t.Fatalf("send after the first turn finished: %v", err)
+	}
+}
+
+func TestService_QueuedTurnThatFailsToStartStaysQueued(t *testing.T) {
+	adapter := &scriptedAdapter{release: make(chan struct{}), events: []StreamEvent{{Type: "turn.completed", Usage: &UsageDTO{}}}}
+	svc, repo, project := newTestService(t, adapter)
+	ctx := context.Background()
+
+	first, thread, err := svc.Send(ctx, MessageRequest{ProjectID: project.ID, Input: "first", ThreadOptions: ThreadOptionsDTO{Model: "m"}})
+	if err != nil {
+		t.Fatalf("send first: %v", err)
+	}
+	queued, err := svc.EnqueueTurn(ctx, MessageRequest{ThreadID: thread.ID, Input: "second", AgentID: "missing"})
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	close(adapter.release)
+	drainStream(first)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(lastSystemMessage(t, repo, thread.ID), "Queued turn failed to start") {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for the queued turn to fail")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	remaining, err := svc.ListQueuedTurns(ctx, thread.ID)
+	if err != nil {
+		t.Fatalf("list queued turns: %v", err)
+	}
+	if len(remaining) != 1 || remaining[0].ID != queued.ID || remaining[0].Position != queued.Position {
+		t.Fatalf("expected queued turn %d to stay in place, got %+v", queued.ID, remaining)
+	}
+}
+
+func TestService_CancelThreadStopsTurnInAnotherService(t *testing.T) {
+	adapter := &scriptedAdapter{release: make(chan struct{}), events: []StreamEvent{{Type: "turn.completed", Usage: &UsageDTO{}}}}
+	svc, repo, project := newTestService(t, adapter)
+	// other stands in for the CLI; it leaves queued turns to svc.
+	other := NewService("fake", repo, WithoutQueueDispatch())
+	if err := other.Register("fake", adapter); err != nil {
+		t.Fatalf("register adapter: %v", err)
+	}
+	ctx := context.Background()
+
+	stream, thread, err := svc.Send(ctx, MessageRequest{ProjectID: project.ID, Input: "first", ThreadOptions: ThreadOptionsDTO{Model: "m"}})
+	if err != nil {
+		t.Fatalf("send first: %v", err)
+	}
+	if err := other.CancelThread(ctx, thread.ID); err != nil {
+		t.Fatalf("cancel from another service: %v", err)
+	}
+	finished := make(chan struct{})
+	go func() {
+		drainStream(stream)
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the turn to stop")
+	}
+	stored, err := repo.GetThread(ctx, thread.ID)
+	if err != nil {
+		t.Fatalf("get thread: %v", err)
+	}
+	if stored.Status != discovery.ThreadStatusStopped {
+		t.Fatalf("expected stopped thread, got %s", stored.Status)
+	}
+	if err := other.CancelThread(ctx, thread.ID); err == nil {
+		t.Fatal("expected cancel to fail once no turn runs")
+	}
+}
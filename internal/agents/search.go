@@ -0,0 +1,55 @@
+package agents
+
+import (
+	"context"
+	"time"
+)
+
+// searchResultLimit caps the hits returned by SearchConversations.
+const searchResultLimit = 50
+
+// SearchHitDTO is a ranked conversation search match. EntryID uses the
+// ConversationEntryDTO ID form and is empty when the thread title matched.
+// Snippet is HTML-escaped text with matched terms wrapped in <mark></mark>.
+type SearchHitDTO struct {
+	ThreadID    int64   `json:"threadId"`
+	ProjectID   int64   `json:"projectId"`
+	ThreadTitle string  `json:"threadTitle"`
+	EntryID     string  `json:"entryId,omitempty"`
+	Role        string  `json:"role,omitempty"`
+	EntryType   string  `json:"entryType,omitempty"`
+	Snippet     string  `json:"snippet"`
+	Rank        float64 `json:"rank"`
+	CreatedAt   string  `json:"createdAt"`
+}
+
+// SearchConversations finds entries (messages, reasoning, command output) and
+// thread titles containing every word of query, best matches first. A zero
+// projectID searches all projects.
+func (s *Service) SearchConversations(ctx context.Context, query string, projectID int64) ([]SearchHitDTO, error) {
+	if err := s.ensureRepo(); err != nil {
+		return nil, err
+	}
+	hits, err := s.repo.SearchConversations(ctx, query, projectID, searchResultLimit)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]SearchHitDTO, 0, len(hits))
+	for _, hit := range hits {
+		dto := SearchHitDTO{
+			ThreadID:    hit.ThreadID,
+			ProjectID:   hit.ProjectID,
+			ThreadTitle: hit.ThreadTitle,
+			Role:        hit.Role,
+			EntryType:   hit.EntryType,
+			Snippet:     hit.Snippet,
+			Rank:        hit.Rank,
+			CreatedAt:   hit.CreatedAt.Format(time.RFC3339),
+		}
+		if hit.EntryID != 0 {
+			dto.EntryID = formatEntryID(hit.EntryID)
+		}
+		result = append(result, dto)
+	}
+	return result, nil
+}
@@ -5,6 +5,7 @@ import (
     "database/sql"
     "errors"
     "fmt"
+    "sort"
     "strings"
     "sync"
 
@@ -37,11 +38,27 @@ type Service struct {
 
 	activeMu sync.Mutex
 	active   map[string]*activeStream
+	// buffers keeps replayable events of running and recently finished streams.
+	buffers map[string]*streamBuffer
+	// claimed marks threads whose next turn is being started but not yet registered as active.
+	claimed map[int64]bool
+	// lockOwner identifies this service in the thread turn locks it shares
+	// with other processes using the same catalog.
+	lockOwner string
+
+	// onQueuedStream receives streams started from a thread's turn queue.
+	onQueuedStream func(*Stream, discovery.Thread)
+	// queueDispatchOff leaves queued turns to other processes; see
+	// WithoutQueueDispatch.
+	queueDispatchOff bool
 
     worktrees *worktrees.Manager
     git       gitc.Client
+    catalog   *ModelCatalog
 	// cleanup controls
 	cleanupStop chan struct{}
+	// afterFunc starts the timer of a turn's time budget.
+	afterFunc func(time.Duration, func()) *time.Timer
 }
 
 // NewService constructs an empty service.
@@ -52,18 +69,39 @@ func WithWorktreeManager(m *worktrees.Manager) ServiceOption {
 }
 func WithGitClient(gc gitc.Client) ServiceOption { return func(s *Service) { s.git = gc } }
 
+// WithModelCatalog replaces the built-in model catalog used to validate thread options.
+func WithModelCatalog(c *ModelCatalog) ServiceOption { return func(s *Service) { s.catalog = c } }
+
+// WithAfterFunc replaces time.AfterFunc for the timers that enforce turn time
+// budgets.
+func WithAfterFunc(fn func(time.Duration, func()) *time.Timer) ServiceOption {
+	return func(s *Service) { s.afterFunc = fn }
+}
+
+// WithoutQueueDispatch keeps the service from starting queued turns. Short-lived
+// processes such as the CLI use it so a queued turn is never started by a
+// process that exits before the turn ends.
+func WithoutQueueDispatch() ServiceOption { return func(s *Service) { s.queueDispatchOff = true } }
+
 func NewService(defaultAgent string, repo *discovery.Repository, opts ...ServiceOption) *Service {
     s := &Service{
         adapters:     make(map[string]Adapter),
         defaultAgent: defaultAgent,
         repo:         repo,
         active:       make(map[string]*activeStream),
+        buffers:      make(map[string]*streamBuffer),
+        claimed:      make(map[int64]bool),
+        lockOwner:    uuid.NewString(),
+        afterFunc:    time.AfterFunc,
     }
 	for _, opt := range opts {
 		if opt != nil {
 			opt(s)
 		}
 	}
+	if s.catalog == nil {
+		s.catalog = DefaultModelCatalog()
+	}
 	return s
 }
 
@@ -82,10 +120,23 @@ func (s *Service) Register(agentID string, adapter Adapter) error {
 	return nil
 }
 
+// ListAgents returns the registered agent identifiers, sorted.
+func (s *Service) ListAgents() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := make([]string, 0, len(s.adapters))
+	for id := range s.adapters {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
 // StreamInitialTopicPrefix defines the event prefix used for runtime emissions.
 const StreamInitialTopicPrefix = "agent:stream:"
 const fileChangeTopicPrefix = "agent:file-change:"
 const terminalTopicPrefix = "agent:terminal:"
+const queueTopicPrefix = "agent:queue:"
 
 // StreamTopic returns the runtime event topic for a given stream ID.
 func StreamTopic(streamID string) string {
@@ -102,12 +153,18 @@ func TerminalTopic(threadID int64) string {
 	return fmt.Sprintf("%s%d", terminalTopicPrefix, threadID)
 }
 
+// QueueTopic returns the runtime event topic for turn queue updates.
+func QueueTopic(threadID int64) string {
+	return fmt.Sprintf("%s%d", queueTopicPrefix, threadID)
+}
+
 // Stream represents a running agent interaction.
 type Stream struct {
 	id      string
 	events  <-chan StreamEvent
 	done    <-chan error
 	closeFn func() error
+	buffer  *streamBuffer
 
 	closeOnce sync.Once
 	waitOnce  sync.Once
@@ -119,6 +176,17 @@ type activeStream struct {
 	threadID int64
 	cancel   func() error
 	state    *streamPersistence
+	buffer   *streamBuffer
+	// budget holds the turn's limits; spentTokens is the thread's usage
+	// before the turn started.
+	budget      discovery.Budget
+	spentTokens int64
+	// policy checks the turn's commands and file changes; nil when the
+	// project has no rules.
+	policy *policyChecker
+	// entryID and prompt identify the user entry that started the turn.
+	entryID int64
+	prompt  string
 }
 
 // ID returns the stream identifier.
@@ -164,20 +232,39 @@ func (s *Stream) Wait() error {
 	return s.waitErr
 }
 
+// ErrThreadBusy is returned by Send when the thread already has a running or pending turn.
+var ErrThreadBusy = errors.New("thread already has a running turn")
+
 // Send starts streaming a message through the selected agent adapter.
+// It returns ErrThreadBusy when the thread is still processing an earlier turn;
+// callers should queue the request with EnqueueTurn instead.
 func (s *Service) Send(ctx context.Context, req MessageRequest) (*Stream, discovery.Thread, error) {
+	return s.send(ctx, req, false)
+}
+
+func (s *Service) send(ctx context.Context, req MessageRequest, fromQueue bool) (*Stream, discovery.Thread, error) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
 
 	agentID := strings.TrimSpace(req.AgentID)
+	if agentID == "" && req.ThreadID != 0 && s.repo != nil {
+		// Follow-ups stay with the agent that ran the thread so far.
+		if thread, err := s.repo.GetThread(ctx, req.ThreadID); err == nil {
+			agentID = thread.AgentID
+		}
+	}
 	if agentID == "" {
 		agentID = s.defaultAgent
 	}
 	if agentID == "" {
 		return nil, discovery.Thread{}, errors.New("agent id is required")
 	}
+	req.AgentID = agentID
 
+	if err := s.applyTemplate(ctx, &req); err != nil {
+		return nil, discovery.Thread{}, err
+	}
 	if strings.TrimSpace(req.Input) == "" && len(req.Segments) == 0 {
 		return nil, discovery.Thread{}, errors.New("input text or segments are required")
 	}
@@ -187,10 +274,56 @@ func (s *Service) Send(ctx context.Context, req MessageRequest) (*Stream, discov
 		return nil, discovery.Thread{}, err
 	}
 
+	if req.ThreadID != 0 {
+		if !s.claimThread(req.ThreadID) {
+			return nil, discovery.Thread{}, ErrThreadBusy
+		}
+		defer s.releaseThread(req.ThreadID)
+		if !fromQueue && s.repo != nil {
+			if pending, err := s.repo.CountQueuedTurns(ctx, req.ThreadID); err == nil && pending > 0 {
+				return nil, discovery.Thread{}, ErrThreadBusy
+			}
+		}
+	}
+
+	optionWarnings, err := s.resolveThreadOptions(ctx, agentID, &req)
+	if err != nil {
+		return nil, discovery.Thread{}, err
+	}
+
+	outputSchema, err := compileOutputSchema(req.TurnOptions)
+	if err != nil {
+		return nil, discovery.Thread{}, err
+	}
+
+	newThread := req.ThreadID == 0
 	thread, err := s.prepareThread(ctx, &req)
 	if err != nil {
 		return nil, discovery.Thread{}, err
 	}
+	if newThread {
+		// Lock the new thread too, so other processes wait for its first turn.
+		if !s.claimThread(thread.ID) {
+			return nil, discovery.Thread{}, ErrThreadBusy
+		}
+		defer s.releaseThread(thread.ID)
+	}
+	for _, warning := range optionWarnings {
+		s.recordSystemEntry(ctx, thread.ID, "warning", warning, nil)
+	}
+
+	budget, spentTokens, err := s.turnBudget(ctx, thread)
+	if err != nil {
+		return nil, discovery.Thread{}, err
+	}
+	if budget.MaxThreadTokens > 0 && spentTokens >= budget.MaxThreadTokens {
+		return nil, discovery.Thread{}, fmt.Errorf("%w: thread %d has used %d of %d tokens", ErrBudgetExceeded, thread.ID, spentTokens, budget.MaxThreadTokens)
+	}
+
+	policy, err := s.newPolicyChecker(ctx, thread.ID, thread.ProjectID)
+	if err != nil {
+		return nil, discovery.Thread{}, err
+	}
 
 	// Ensure worktree + working directory override
 	if s.worktrees != nil {
@@ -201,7 +334,15 @@ func (s *Service) Send(ctx context.Context, req MessageRequest) (*Stream, discov
 		// Build descriptive naming for worktree dir + branch
 		nameHint := thread.Title
 		branchName := thread.BranchName
-		wtPath, workingDir, _, werr := s.worktrees.EnsureForThread(ctx, project.Path, thread.ID, nameHint, branchName)
+		baseRef := strings.TrimSpace(req.BaseBranch)
+		if baseRef == "" && strings.TrimSpace(branchName) != "" {
+			// A worktree released by archiving comes back on the thread's own
+			// branch rather than resetting it to the project's current ref.
+			if exists, berr := s.worktrees.BranchExists(ctx, project.Path, branchName); berr == nil && exists {
+				baseRef = branchName
+			}
+		}
+		wtPath, workingDir, _, werr := s.worktrees.EnsureForThreadFrom(ctx, project.Path, thread.ID, nameHint, branchName, baseRef)
 		if werr != nil {
 			return nil, discovery.Thread{}, werr
 		}
@@ -211,8 +352,13 @@ func (s *Service) Send(ctx context.Context, req MessageRequest) (*Stream, discov
 		req.ThreadOptions.SkipGitRepoCheck = false
 	}
 
+	forkContext := s.buildForkContext(ctx, thread)
+	instructions := s.standingInstructions(ctx, thread, forkContext != "")
+	rollback := s.rollbackNotice(ctx, thread)
+
 	userContent := deriveUserMessageText(req)
 	hasSegments := len(req.Segments) > 0
+	var turnEntryID int64
 	if trimmed := strings.TrimSpace(userContent); trimmed != "" || hasSegments {
 		payload, err := marshalUserEntryPayload(userContent, req.Segments)
 		if err != nil {
@@ -232,26 +378,42 @@ func (s *Service) Send(ctx context.Context, req MessageRequest) (*Stream, discov
 		}
 		createdAt := entry.CreatedAt
 		thread.LastMessageAt = &createdAt
+		s.checkpointTurn(ctx, thread, entry.ID)
+		turnEntryID = entry.ID
 	}
 
+	prependInstructions(&req, rollback)
+	prependInstructions(&req, forkContext)
+	prependInstructions(&req, instructions)
+
 	streamCtx, cancel := context.WithCancel(ctx)
 	result, err := adapter.Stream(streamCtx, req)
 	if err != nil {
 		cancel()
 		return nil, discovery.Thread{}, err
 	}
+	if err := s.repo.UpdateThreadStatus(ctx, thread.ID, discovery.ThreadStatusActive, nil); err == nil {
+		thread.Status = discovery.ThreadStatusActive
+	}
+
+	policy.setRoot(req.ThreadOptions.WorkingDirectory)
 
 	state := newStreamPersistence(s.repo, thread)
+	state.agentID = agentID
+	state.turnEntryID = turnEntryID
+	state.outputSchema = outputSchema
 
 	events := make(chan StreamEvent)
 	done := make(chan error, 1)
 
 	streamID := uuid.NewString()
+	buffer := newStreamBuffer(streamID, thread.ID, streamBufferSize)
 
 	stream := &Stream{
 		id:     streamID,
 		events: events,
 		done:   done,
+		buffer: buffer,
 		closeFn: func() error {
 			cancel()
 			if result.Close != nil {
@@ -266,10 +428,17 @@ func (s *Service) Send(ctx context.Context, req MessageRequest) (*Stream, discov
 		threadID: thread.ID,
 		cancel:   stream.Close,
 		state:    state,
+		buffer:   buffer,
+		budget:      budget,
+		spentTokens: spentTokens,
+		policy:      policy,
+		entryID:     turnEntryID,
+		prompt:      userContent,
 	}
 
 	s.activeMu.Lock()
 	s.active[streamID] = active
+	s.buffers[streamID] = buffer
 	s.activeMu.Unlock()
 
 	go s.forwardStream(streamCtx, streamID, active, result, events, done)
@@ -288,14 +457,32 @@ func (s *Service) loadAdapter(agentID string) (Adapter, error) {
 }
 
 func (s *Service) forwardStream(ctx context.Context, streamID string, active *activeStream, result *StreamResult, events chan<- StreamEvent, done chan<- error) {
+	var finalStatus discovery.ThreadStatus
+	defer func() {
+		// A stopped turn pauses the queue until the user sends or enqueues again.
+		if !pausesQueue(finalStatus) {
+			go s.dispatchQueuedTurn(active.threadID)
+		}
+	}()
+	defer s.releaseThreadLock(active.threadID)
 	defer close(events)
 	defer close(done)
 	defer s.unregisterActive(streamID)
+	defer s.renewThreadLock(active.threadID)()
+	defer s.watchCancelRequests(streamID, active.threadID)()
 
 	var streamErr error
 
+	releaseTimer := s.watchTurnDuration(active)
+	defer releaseTimer()
+
 	for event := range result.Events {
 		s.processEvent(ctx, active.state, event)
+		s.checkTokenBudget(active, event.Usage)
+		if event.Item != nil {
+			s.enforcePolicy(ctx, active, event.Item)
+		}
+		event = active.buffer.append(event)
 		select {
 		case events <- event:
 		case <-ctx.Done():
@@ -322,14 +509,55 @@ finalize:
 		}
 	}
 	if active.state != nil {
-		if _, err := active.state.finalize(context.Background(), status); err != nil && streamErr == nil {
+		if stopStatus, message := active.state.stopReason(); message != "" {
+			status = stopStatus
+			streamErr = fmt.Errorf("%w: %s", stopError(stopStatus), message)
+		}
+		thread, err := active.state.finalize(context.Background(), status)
+		if err != nil && streamErr == nil {
 			streamErr = err
 		}
+		status = thread.Status
+		if status == discovery.ThreadStatusCompleted && streamErr == nil {
+			s.commitTurn(context.Background(), active, thread)
+		}
 	}
+	finalStatus = status
+
+	terminal := StreamEvent{Type: "stream.complete", Message: string(status)}
+	if streamErr != nil {
+		terminal = StreamEvent{Type: "stream.error", Error: &StreamError{Message: streamErr.Error()}}
+	}
+	active.buffer.finish(terminal)
+	s.retireStreamBuffer(streamID)
 
 	done <- streamErr
 }
 
+// stopError returns the sentinel error reported for a turn the service
+// stopped with status.
+func stopError(status discovery.ThreadStatus) error {
+	switch status {
+	case discovery.ThreadStatusPolicyDenied:
+		return ErrPolicyDenied
+	case discovery.ThreadStatusAwaitingApproval:
+		return ErrApprovalRequired
+	default:
+		return ErrBudgetExceeded
+	}
+}
+
+// pausesQueue reports whether a turn that ended with status leaves the
+// thread's queued turns waiting for the user.
+func pausesQueue(status discovery.ThreadStatus) bool {
+	switch status {
+	case discovery.ThreadStatusStopped, discovery.ThreadStatusBudgetExceeded,
+		discovery.ThreadStatusPolicyDenied, discovery.ThreadStatusAwaitingApproval:
+		return true
+	}
+	return false
+}
+
 func (s *Service) processEvent(ctx context.Context, state *streamPersistence, event StreamEvent) {
 	if state == nil {
 		return
@@ -339,7 +567,7 @@ func (s *Service) processEvent(ctx context.Context, state *streamPersistence, ev
 		_ = state.recordThreadExternal(ctx, event.ThreadID)
 	case "item.started", "item.updated", "item.completed":
 		if event.Item != nil {
-			state.storeAgentItem(ctx, event.Item)
+			state.storeAgentItem(ctx, event.Item, event.Type == "item.completed")
 		}
 	case "turn.completed":
 		state.recordStatus(discovery.ThreadStatusCompleted)
@@ -403,12 +631,41 @@ func (s *Service) Cancel(ctx context.Context, streamID string) (CancelResponse,
 	return CancelResponse{ThreadID: active.threadID, Status: string(discovery.ThreadStatusStopped)}, nil
 }
 
-// ListThreads returns threads for a project.
-func (s *Service) ListThreads(ctx context.Context, projectID int64) ([]ThreadDTO, error) {
+// CancelThread stops the running turn of a thread. A turn run by another
+// process sharing the catalog is asked to stop through the thread's turn lock
+// and stops within cancelPollInterval.
+func (s *Service) CancelThread(ctx context.Context, threadID int64) error {
+	s.activeMu.Lock()
+	streamID := ""
+	for id, a := range s.active {
+		if a.threadID == threadID {
+			streamID = id
+		}
+	}
+	s.activeMu.Unlock()
+	if streamID != "" {
+		_, err := s.Cancel(ctx, streamID)
+		return err
+	}
+	if err := s.ensureRepo(); err != nil {
+		return err
+	}
+	requested, err := s.repo.RequestThreadCancel(ctx, threadID)
+	if err != nil {
+		return err
+	}
+	if !requested {
+		return fmt.Errorf("thread %d has no running turn", threadID)
+	}
+	return nil
+}
+
+// ListThreads returns a project's threads matching filter, pinned first.
+func (s *Service) ListThreads(ctx context.Context, projectID int64, filter ThreadFilterDTO) ([]ThreadDTO, error) {
 	if err := s.ensureRepo(); err != nil {
 		return nil, err
 	}
-	records, err := s.repo.ListThreadsByProject(ctx, projectID)
+	records, err := s.repo.ListThreads(ctx, filter.toFilter(projectID))
 	if err != nil {
 		return nil, err
 	}
@@ -544,8 +801,13 @@ func (s *Service) DeleteThread(ctx context.Context, id int64) error {
 		}
 		return getErr
 	}
+	_ = s.repo.DeleteQueuedTurnsForThread(ctx, id)
+	_ = s.repo.DeleteThreadBudget(ctx, id)
+	_ = s.repo.DeleteCheckpoints(ctx, id)
+	_ = s.repo.DeleteThreadCommits(ctx, id)
 	// Best-effort remove worktree (branch retained by design)
 	if s.worktrees != nil && strings.TrimSpace(thread.WorktreePath) != "" {
+		_ = s.worktrees.RemoveCheckpoints(ctx, thread.WorktreePath, id)
 		_ = s.worktrees.RemoveForThread(ctx, thread.WorktreePath)
 	}
 	if err := s.repo.DeleteThread(ctx, id); err != nil {
@@ -22,6 +22,22 @@ func (s *Service) ensureRepo() error {
 	return nil
 }
 
+// standingInstructions returns the project's standing instructions when the
+// turn opens the thread's agent session: the first turn of a new thread, or of
+// a fork or import that replays its transcript.
+func (s *Service) standingInstructions(ctx context.Context, thread discovery.Thread, replaying bool) string {
+	settings, err := s.repo.GetProjectSettings(ctx, thread.ProjectID)
+	if err != nil || strings.TrimSpace(settings.Instructions) == "" {
+		return ""
+	}
+	if !replaying {
+		if started, err := s.repo.HasUserEntries(ctx, thread.ID); err != nil || started {
+			return ""
+		}
+	}
+	return "Standing instructions for this project:\n\n" + strings.TrimSpace(settings.Instructions)
+}
+
 func (s *Service) prepareThread(ctx context.Context, req *MessageRequest) (discovery.Thread, error) {
 	if err := s.ensureRepo(); err != nil {
 		return discovery.Thread{}, err
@@ -32,6 +48,12 @@ func (s *Service) prepareThread(ctx context.Context, req *MessageRequest) (disco
 		if err != nil {
 			return discovery.Thread{}, err
 		}
+		if thread.ReadOnly {
+			return discovery.Thread{}, fmt.Errorf("%w: thread %d", ErrThreadReadOnly, thread.ID)
+		}
+		if thread.ArchivedAt != nil {
+			return discovery.Thread{}, fmt.Errorf("%w: thread %d", ErrThreadArchived, thread.ID)
+		}
 		// Persist latest thread options if they changed (keep per-thread preferences in sync)
 		resolvedModel := strings.TrimSpace(req.ThreadOptions.Model)
 		if resolvedModel == "" {
@@ -45,6 +67,12 @@ func (s *Service) prepareThread(ctx context.Context, req *MessageRequest) (disco
 		if resolvedReasoning == "" {
 			resolvedReasoning = thread.ReasoningLevel
 		}
+		if req.AgentID != "" && req.AgentID != thread.AgentID {
+			if err := s.repo.UpdateThreadAgent(ctx, thread.ID, req.AgentID); err != nil {
+				return discovery.Thread{}, err
+			}
+			thread.AgentID = req.AgentID
+		}
 		if resolvedModel != thread.Model || resolvedSandbox != thread.SandboxMode || resolvedReasoning != thread.ReasoningLevel {
 			if err := s.repo.UpdateThreadOptions(
 				ctx,
@@ -79,6 +107,7 @@ func (s *Service) prepareThread(ctx context.Context, req *MessageRequest) (disco
 		Model:          req.ThreadOptions.Model,
 		SandboxMode:    req.ThreadOptions.SandboxMode,
 		ReasoningLevel: req.ThreadOptions.ReasoningLevel,
+		AgentID:        req.AgentID,
 	}
 	thread, err := s.repo.CreateThread(ctx, params)
 	if err != nil {
@@ -167,6 +196,26 @@ func toThreadDTO(record discovery.Thread) ThreadDTO {
 		formatted := record.LastMessageAt.Format(time.RFC3339)
 		dto.LastMessageAt = &formatted
 	}
+	dto.ParentThreadID = record.ParentThreadID
+	if record.ForkedFromEntryID != 0 {
+		dto.ForkedFromEntryID = formatEntryID(record.ForkedFromEntryID)
+	}
+	dto.GroupID = record.GroupID
+	dto.ScheduledJobID = record.ScheduledJobID
+	if record.ArchivedAt != nil {
+		formatted := record.ArchivedAt.Format(time.RFC3339)
+		dto.ArchivedAt = &formatted
+	}
+	if record.PinnedAt != nil {
+		formatted := record.PinnedAt.Format(time.RFC3339)
+		dto.PinnedAt = &formatted
+	}
+	dto.ReadOnly = record.ReadOnly
+	if record.ImportedAt != nil {
+		formatted := record.ImportedAt.Format(time.RFC3339)
+		dto.ImportedAt = &formatted
+	}
+	dto.AgentID = record.AgentID
 	return dto
 }
 
@@ -0,0 +1,159 @@
+package agents
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// streamBufferSize bounds how many recent events a stream keeps for replay.
+	streamBufferSize = 1024
+	// streamBufferRetention keeps a finished stream's buffer around so a view
+	// that reloads right after the turn ends still sees the terminal event.
+	streamBufferRetention = 2 * time.Minute
+)
+
+// streamBuffer is a bounded ring of a stream's events numbered from 1.
+type streamBuffer struct {
+	mu        sync.Mutex
+	streamID  string
+	threadID  int64
+	startedAt time.Time
+	ring      []StreamEvent
+	head      int // index of the oldest event
+	count     int
+	lastSeq   int64
+	finished  bool
+	terminal  StreamEvent
+}
+
+func newStreamBuffer(streamID string, threadID int64, size int) *streamBuffer {
+	if size <= 0 {
+		size = streamBufferSize
+	}
+	return &streamBuffer{
+		streamID:  streamID,
+		threadID:  threadID,
+		startedAt: time.Now().UTC(),
+		ring:      make([]StreamEvent, size),
+	}
+}
+
+// append numbers evt and stores it, evicting the oldest event when full.
+func (b *streamBuffer) append(evt StreamEvent) StreamEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lastSeq++
+	evt.Seq = b.lastSeq
+	if b.count < len(b.ring) {
+		b.ring[(b.head+b.count)%len(b.ring)] = evt
+		b.count++
+	} else {
+		b.ring[b.head] = evt
+		b.head = (b.head + 1) % len(b.ring)
+	}
+	return evt
+}
+
+// finish stores the terminal stream.complete / stream.error event.
+func (b *streamBuffer) finish(evt StreamEvent) StreamEvent {
+	evt = b.append(evt)
+	b.mu.Lock()
+	b.finished = true
+	b.terminal = evt
+	b.mu.Unlock()
+	return evt
+}
+
+// terminalEvent returns the terminal event once the stream has finished.
+func (b *streamBuffer) terminalEvent() (StreamEvent, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.terminal, b.finished
+}
+
+// since returns the buffered events with a sequence number above afterSeq.
+func (b *streamBuffer) since(afterSeq int64) StreamReplayDTO {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	replay := StreamReplayDTO{
+		StreamID: b.streamID,
+		ThreadID: b.threadID,
+		Finished: b.finished,
+		LastSeq:  b.lastSeq,
+		Events:   []StreamEvent{},
+	}
+	if b.count == 0 {
+		return replay
+	}
+	oldest := b.ring[b.head].Seq
+	if afterSeq < oldest-1 {
+		replay.Truncated = true
+	}
+	for i := 0; i < b.count; i++ {
+		evt := b.ring[(b.head+i)%len(b.ring)]
+		if evt.Seq > afterSeq {
+			replay.Events = append(replay.Events, evt)
+		}
+	}
+	return replay
+}
+
+func (b *streamBuffer) info() ActiveStreamDTO {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return ActiveStreamDTO{
+		StreamID:  b.streamID,
+		ThreadID:  b.threadID,
+		StartedAt: b.startedAt.Format(time.RFC3339),
+		LastSeq:   b.lastSeq,
+	}
+}
+
+// retireStreamBuffer drops a finished stream's buffer after the retention period.
+func (s *Service) retireStreamBuffer(streamID string) {
+	time.AfterFunc(streamBufferRetention, func() {
+		s.activeMu.Lock()
+		delete(s.buffers, streamID)
+		s.activeMu.Unlock()
+	})
+}
+
+// ResumeStream returns the events of a running or recently finished stream
+// after afterSeq. Subscribe to the stream topic first, then drop live events
+// whose seq is not above the replay's LastSeq. Truncated means the buffer no
+// longer holds every missed event and the conversation should be reloaded.
+func (s *Service) ResumeStream(streamID string, afterSeq int64) (StreamReplayDTO, error) {
+	s.activeMu.Lock()
+	buffer, ok := s.buffers[streamID]
+	s.activeMu.Unlock()
+	if !ok {
+		return StreamReplayDTO{}, fmt.Errorf("stream %s not found", streamID)
+	}
+	return buffer.since(afterSeq), nil
+}
+
+// ListActiveStreams returns the running streams of a thread, oldest first.
+// A threadID of zero lists every running stream.
+func (s *Service) ListActiveStreams(threadID int64) []ActiveStreamDTO {
+	s.activeMu.Lock()
+	list := make([]ActiveStreamDTO, 0, len(s.active))
+	for streamID, active := range s.active {
+		if threadID != 0 && active.threadID != threadID {
+			continue
+		}
+		if buffer, ok := s.buffers[streamID]; ok {
+			list = append(list, buffer.info())
+		}
+	}
+	s.activeMu.Unlock()
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].StartedAt != list[j].StartedAt {
+			return list[i].StartedAt < list[j].StartedAt
+		}
+		return list[i].StreamID < list[j].StreamID
+	})
+	return list
+}
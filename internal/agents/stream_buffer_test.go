@@ -0,0 +1,74 @@
+package agents
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStreamBufferEvictsOldestAndReportsTruncation(t *testing.T) {
+	buffer := newStreamBuffer("s", 1, 3)
+	for i := 0; i < 5; i++ {
+		buffer.append(StreamEvent{Type: "item.updated"})
+	}
+	replay := buffer.since(3)
+	if replay.Truncated || len(replay.Events) != 2 || replay.Events[0].Seq != 4 || replay.LastSeq != 5 {
+		t.Fatalf("unexpected replay after 3: %+v", replay)
+	}
+	replay = buffer.since(0)
+	if !replay.Truncated || len(replay.Events) != 3 || replay.Events[0].Seq != 3 {
+		t.Fatalf("expected truncated replay from seq 3, got %+v", replay)
+	}
+	if replay = buffer.since(5); len(replay.Events) != 0 || replay.Finished {
+		t.Fatalf("expected empty, unfinished replay, got %+v", replay)
+	}
+}
+
+func TestService_ResumeStreamReplaysMissedEvents(t *testing.T) {
+	adapter := &scriptedAdapter{release: make(chan struct{}), events: []StreamEvent{
+		{Type: "item.completed", Item: &AgentItemDTO{ID: "a", Type: entryTypeAgentMessage, Text: "hello"}},
+		{Type: "turn.completed", Usage: &UsageDTO{}},
+	}}
+	svc, _, project := newTestService(t, adapter)
+	ctx := context.Background()
+
+	stream, thread, err := svc.Send(ctx, MessageRequest{ProjectID: project.ID, Input: "hi", ThreadOptions: ThreadOptionsDTO{Model: "m"}})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	active := svc.ListActiveStreams(thread.ID)
+	if len(active) != 1 || active[0].StreamID != stream.ID() {
+		t.Fatalf("expected the running stream to be listed, got %+v", active)
+	}
+	if other := svc.ListActiveStreams(thread.ID + 1); len(other) != 0 {
+		t.Fatalf("expected no streams for another thread, got %+v", other)
+	}
+
+	close(adapter.release)
+	var live []StreamEvent
+	for evt := range stream.Events() {
+		live = append(live, evt)
+	}
+	if err := stream.Wait(); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	if len(live) != 2 || live[0].Seq != 1 || live[1].Seq != 2 {
+		t.Fatalf("expected numbered live events, got %+v", live)
+	}
+
+	replay, err := svc.ResumeStream(stream.ID(), 1)
+	if err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	if !replay.Finished || replay.LastSeq != 3 || len(replay.Events) != 2 {
+		t.Fatalf("unexpected replay %+v", replay)
+	}
+	if replay.Events[0].Type != "turn.completed" || replay.Events[1].Type != "stream.complete" || replay.Events[1].Message != "completed" {
+		t.Fatalf("expected turn and terminal events, got %+v", replay.Events)
+	}
+	if len(svc.ListActiveStreams(0)) != 0 {
+		t.Fatal("finished stream should not be listed as active")
+	}
+	if _, err := svc.ResumeStream("missing", 0); err == nil {
+		t.Fatal("expected error for unknown stream")
+	}
+}
@@ -0,0 +1,82 @@
+package agents
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"codex-ui/internal/jsonschema"
+)
+
+const entryTypeStructuredOutput = "structured_output"
+
+// structuredOutputItemID is the item ID of a turn's structured output entry.
+// Agent item IDs are scoped to their turn, so one fixed ID is enough.
+const structuredOutputItemID = "structured_output"
+
+// StructuredOutputDTO is the final agent message of a turn run with an output
+// schema, parsed as JSON and checked against the schema. Output is empty when
+// the message was not JSON.
+type StructuredOutputDTO struct {
+	Output     json.RawMessage `json:"output,omitempty"`
+	Valid      bool            `json:"valid"`
+	Violations []string        `json:"violations,omitempty"`
+}
+
+// compileOutputSchema compiles the turn's output schema, or returns nil when
+// the turn has none.
+func compileOutputSchema(opts *TurnOptionsDTO) (*jsonschema.Schema, error) {
+	if opts == nil || len(opts.OutputSchema) == 0 || strings.TrimSpace(string(opts.OutputSchema)) == "null" {
+		return nil, nil
+	}
+	schema, err := jsonschema.Compile(opts.OutputSchema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output schema: %w", err)
+	}
+	return schema, nil
+}
+
+// checkStructuredOutput parses an agent message as JSON, tolerating a
+// surrounding Markdown code fence, and validates it against schema.
+func checkStructuredOutput(schema *jsonschema.Schema, text string) *StructuredOutputDTO {
+	raw := unfenceJSON(text)
+	if raw == "" {
+		return &StructuredOutputDTO{Violations: []string{"the agent returned no output"}}
+	}
+	if !json.Valid([]byte(raw)) {
+		return &StructuredOutputDTO{Violations: []string{"the output is not valid JSON"}}
+	}
+	out := &StructuredOutputDTO{Output: json.RawMessage(raw), Valid: true}
+	if err := schema.ValidateJSON([]byte(raw)); err != nil {
+		out.Valid = false
+		var invalid *jsonschema.ValidationError
+		if !errors.As(err, &invalid) {
+			out.Violations = []string{err.Error()}
+			return out
+		}
+		for _, v := range invalid.Violations {
+			out.Violations = append(out.Violations, v.String())
+		}
+	}
+	return out
+}
+
+func unfenceJSON(text string) string {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "```") {
+		return text
+	}
+	body := strings.TrimPrefix(text, "```")
+	if newline := strings.IndexByte(body, '\n'); newline >= 0 {
+		body = body[newline+1:]
+	} else {
+		return text
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(body), "```"))
+}
+
+// structuredOutputMessage summarises schema violations for a system error entry.
+func structuredOutputMessage(out *StructuredOutputDTO) string {
+	return "Structured output does not match the output schema: " + strings.Join(out.Violations, "; ")
+}
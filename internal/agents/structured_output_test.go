@@ -0,0 +1,87 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const planSchema = `{"type":"object","required":["steps"],"additionalProperties":false,"properties":{"steps":{"type":"array","minItems":1,"items":{"type":"string"}}}}`
+
+func replyAdapter(text string) *scriptedAdapter {
+	return &scriptedAdapter{events: []StreamEvent{
+		{Type: "item.completed", Item: &AgentItemDTO{ID: "m1", Type: entryTypeAgentMessage, Text: text}},
+		{Type: "turn.completed"},
+	}}
+}
+
+func TestService_StructuredOutputIsValidatedAndStored(t *testing.T) {
+	adapter := replyAdapter("```json\n{\"steps\": [\"add column\", \"backfill\"]}\n```")
+	svc, _, project := newTestService(t, adapter)
+	ctx := context.Background()
+	turn := &TurnOptionsDTO{OutputSchema: json.RawMessage(planSchema)}
+
+	thread, err := sendAndWait(t, svc, MessageRequest{ProjectID: project.ID, Input: "Plan the migration", ThreadOptions: ThreadOptionsDTO{Model: "m"}, TurnOptions: turn})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	entries, _ := svc.LoadThreadConversation(ctx, thread.ID)
+	var out *StructuredOutputDTO
+	for _, entry := range entries {
+		if entry.Item != nil && entry.Item.Type == entryTypeStructuredOutput {
+			out = entry.Item.StructuredOutput
+			if entry.Item.ID != structuredOutputItemID {
+				t.Fatalf("expected the structured output item ID, got %q", entry.Item.ID)
+			}
+		}
+		if entry.Role == "system" && entry.Tone == "error" {
+			t.Fatalf("unexpected error entry %q", entry.Message)
+		}
+	}
+	if out == nil || !out.Valid || string(out.Output) != `{"steps":["add column","backfill"]}` {
+		t.Fatalf("expected a valid structured output entry, got %+v", out)
+	}
+
+	adapter.events[0].Item.Text = `{"steps": [], "risk": "high"}`
+	if _, err := sendAndWait(t, svc, MessageRequest{ThreadID: thread.ID, Input: "Again", TurnOptions: turn}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	entries, _ = svc.LoadThreadConversation(ctx, thread.ID)
+	last := entries[len(entries)-1]
+	if last.Role != "system" || last.Tone != "error" || !strings.Contains(last.Message, "/risk: property is not allowed") || !strings.Contains(last.Message, "/steps: must have at least 1 items") {
+		t.Fatalf("expected schema violations as a system error, got %+v", last)
+	}
+	if out := entries[len(entries)-2].Item.StructuredOutput; out == nil || out.Valid || len(out.Violations) != 2 {
+		t.Fatalf("expected an invalid structured output entry, got %+v", entries[len(entries)-2])
+	}
+
+	adapter.events[0].Item.Text = "Here is the plan: add a column."
+	if _, err := sendAndWait(t, svc, MessageRequest{ThreadID: thread.ID, Input: "Once more", TurnOptions: turn}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	entries, _ = svc.LoadThreadConversation(ctx, thread.ID)
+	if last := entries[len(entries)-1]; !strings.Contains(last.Message, "not valid JSON") {
+		t.Fatalf("expected a parse error, got %+v", last)
+	}
+
+	if _, err := sendAndWait(t, svc, MessageRequest{ThreadID: thread.ID, Input: "No schema"}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	entries, _ = svc.LoadThreadConversation(ctx, thread.ID)
+	if last := entries[len(entries)-1]; last.Item == nil || last.Item.Type != entryTypeAgentMessage {
+		t.Fatalf("turns without a schema must not be validated, got %+v", last)
+	}
+}
+
+func TestService_SendRejectsInvalidOutputSchema(t *testing.T) {
+	adapter := replyAdapter("{}")
+	svc, _, project := newTestService(t, adapter)
+	_, _, err := svc.Send(context.Background(), MessageRequest{ProjectID: project.ID, Input: "x", ThreadOptions: ThreadOptionsDTO{Model: "m"}, TurnOptions: &TurnOptionsDTO{OutputSchema: json.RawMessage(`{"pattern":"("}`)}})
+	if err == nil || !strings.Contains(err.Error(), "invalid output schema") {
+		t.Fatalf("expected schema error, got %v", err)
+	}
+	if len(adapter.seenInputs()) != 0 {
+		t.Fatal("an invalid schema must not reach the adapter")
+	}
+}
@@ -0,0 +1,281 @@
+package agents
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"codex-ui/internal/storage/discovery"
+)
+
+// ErrMissingTemplateValues is returned when a template is expanded without a
+// value for each of its variables.
+var ErrMissingTemplateValues = errors.New("missing template values")
+
+// CreatePRTemplateName names the built-in template that instructs the agent to
+// commit, push and open a pull request. Saving a template with this name
+// overrides it globally or for one project.
+const CreatePRTemplateName = "create-pull-request"
+
+// PromptTemplateDTO is a reusable prompt. Global templates have no ProjectID;
+// built-in templates have no ID and are replaced by a saved template with the
+// same name.
+type PromptTemplateDTO struct {
+	ID          int64    `json:"id,omitempty"`
+	ProjectID   int64    `json:"projectId,omitempty"`
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Body        string   `json:"body"`
+	Variables   []string `json:"variables"`
+	BuiltIn     bool     `json:"builtIn,omitempty"`
+	CreatedAt   string   `json:"createdAt,omitempty"`
+	UpdatedAt   string   `json:"updatedAt,omitempty"`
+}
+
+// SavePromptTemplateRequest holds the editable fields of a template. ProjectID
+// zero saves a global template; it is ignored on update.
+type SavePromptTemplateRequest struct {
+	ProjectID   int64  `json:"projectId,omitempty"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Body        string `json:"body"`
+}
+
+const createPRTemplateBody = `You are operating in a git worktree branch for this thread.
+Task:
+1) Review all staged and unstaged changes.
+2) Group logically and create conventional commits (feat|fix|chore|refactor|docs|test) with meaningful scope and messages.
+3) Push the branch '{{branch}}' to origin and ensure upstream is set.
+4) Create or update a GitHub pull request from this branch against the default base branch.
+   - Use a conventional title.
+   - Write a clear, structured description that summarizes the changes.
+
+Constraints:
+- Prefer the GitHub CLI (gh). If a PR already exists for the branch, update it.
+- Do not print secrets or token values.
+
+Output:
+- After completion print exactly one line with: PR_URL: https://github.com/<owner>/<repo>/pull/<number>
+- Do not include any other lines after the PR_URL line.`
+
+var builtinTemplates = []PromptTemplateDTO{
+	{
+		Name:        CreatePRTemplateName,
+		Description: "Commit, push and open a pull request. Variables: branch, title.",
+		Body:        createPRTemplateBody,
+		BuiltIn:     true,
+	},
+}
+
+var templateVariable = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_.-]*)\s*\}\}`)
+
+// TemplateVariables lists the distinct {{variable}} names in body in order of
+// first use.
+func TemplateVariables(body string) []string {
+	names := []string{}
+	seen := make(map[string]bool)
+	for _, m := range templateVariable.FindAllStringSubmatch(body, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// ExpandTemplate replaces every {{variable}} in body with its value. Values
+// for unknown variables are ignored.
+func ExpandTemplate(body string, values map[string]string) (string, error) {
+	var missing []string
+	for _, name := range TemplateVariables(body) {
+		if _, ok := values[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("%w: %s", ErrMissingTemplateValues, strings.Join(missing, ", "))
+	}
+	return templateVariable.ReplaceAllStringFunc(body, func(match string) string {
+		return values[templateVariable.FindStringSubmatch(match)[1]]
+	}), nil
+}
+
+func toPromptTemplateDTO(tmpl discovery.PromptTemplate) PromptTemplateDTO {
+	return PromptTemplateDTO{
+		ID:          tmpl.ID,
+		ProjectID:   tmpl.ProjectID,
+		Name:        tmpl.Name,
+		Description: tmpl.Description,
+		Body:        tmpl.Body,
+		Variables:   TemplateVariables(tmpl.Body),
+		CreatedAt:   tmpl.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedAt:   tmpl.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+func builtinTemplate(name string) (PromptTemplateDTO, bool) {
+	for _, tmpl := range builtinTemplates {
+		if tmpl.Name == name {
+			tmpl.Variables = TemplateVariables(tmpl.Body)
+			return tmpl, true
+		}
+	}
+	return PromptTemplateDTO{}, false
+}
+
+// ListPromptTemplates returns the templates available to a project: its own,
+// the global ones and the built-ins that neither overrides. Pass 0 for global
+// templates only.
+func (s *Service) ListPromptTemplates(ctx context.Context, projectID int64) ([]PromptTemplateDTO, error) {
+	if err := s.ensureRepo(); err != nil {
+		return nil, err
+	}
+	records, err := s.repo.ListPromptTemplates(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	templates := make([]PromptTemplateDTO, 0, len(records)+len(builtinTemplates))
+	saved := make(map[string]bool)
+	for _, record := range records {
+		templates = append(templates, toPromptTemplateDTO(record))
+		saved[record.Name] = true
+	}
+	for _, tmpl := range builtinTemplates {
+		if !saved[tmpl.Name] {
+			builtin, _ := builtinTemplate(tmpl.Name)
+			templates = append(templates, builtin)
+		}
+	}
+	return templates, nil
+}
+
+// ResolvePromptTemplate returns the template named name for a project: the
+// project's own, else the global one, else the built-in.
+func (s *Service) ResolvePromptTemplate(ctx context.Context, projectID int64, name string) (PromptTemplateDTO, error) {
+	name = strings.TrimSpace(name)
+	if s.repo != nil {
+		record, err := s.repo.FindPromptTemplate(ctx, projectID, name)
+		if err == nil {
+			return toPromptTemplateDTO(record), nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return PromptTemplateDTO{}, err
+		}
+	}
+	if tmpl, ok := builtinTemplate(name); ok {
+		return tmpl, nil
+	}
+	return PromptTemplateDTO{}, fmt.Errorf("prompt template %q not found", name)
+}
+
+// CreatePromptTemplate saves a new global or project template.
+func (s *Service) CreatePromptTemplate(ctx context.Context, req SavePromptTemplateRequest) (PromptTemplateDTO, error) {
+	if err := s.ensureRepo(); err != nil {
+		return PromptTemplateDTO{}, err
+	}
+	params, err := promptTemplateParams(req)
+	if err != nil {
+		return PromptTemplateDTO{}, err
+	}
+	if params.ProjectID != 0 {
+		if _, err := s.repo.GetProjectByID(ctx, params.ProjectID); err != nil {
+			return PromptTemplateDTO{}, err
+		}
+	}
+	record, err := s.repo.CreatePromptTemplate(ctx, params)
+	if err != nil {
+		return PromptTemplateDTO{}, err
+	}
+	return toPromptTemplateDTO(record), nil
+}
+
+// UpdatePromptTemplate replaces a saved template's name, description and body.
+func (s *Service) UpdatePromptTemplate(ctx context.Context, id int64, req SavePromptTemplateRequest) (PromptTemplateDTO, error) {
+	if err := s.ensureRepo(); err != nil {
+		return PromptTemplateDTO{}, err
+	}
+	params, err := promptTemplateParams(req)
+	if err != nil {
+		return PromptTemplateDTO{}, err
+	}
+	record, err := s.repo.UpdatePromptTemplate(ctx, id, params)
+	if err != nil {
+		return PromptTemplateDTO{}, err
+	}
+	return toPromptTemplateDTO(record), nil
+}
+
+// DeletePromptTemplate removes a saved template. Deleting an override brings
+// back the template it replaced.
+func (s *Service) DeletePromptTemplate(ctx context.Context, id int64) error {
+	if err := s.ensureRepo(); err != nil {
+		return err
+	}
+	return s.repo.DeletePromptTemplate(ctx, id)
+}
+
+func promptTemplateParams(req SavePromptTemplateRequest) (discovery.SavePromptTemplateParams, error) {
+	params := discovery.SavePromptTemplateParams{
+		ProjectID:   req.ProjectID,
+		Name:        strings.TrimSpace(req.Name),
+		Description: strings.TrimSpace(req.Description),
+		Body:        req.Body,
+	}
+	if params.Name == "" {
+		return params, errors.New("template name is required")
+	}
+	if strings.TrimSpace(params.Body) == "" {
+		return params, errors.New("template body is required")
+	}
+	return params, nil
+}
+
+// applyTemplate replaces a request's template reference with the expanded
+// template text, followed by any input the request carries.
+func (s *Service) applyTemplate(ctx context.Context, req *MessageRequest) error {
+	name := strings.TrimSpace(req.TemplateName)
+	if req.TemplateID == 0 && name == "" {
+		return nil
+	}
+	projectID := req.ProjectID
+	if req.ThreadID != 0 && s.repo != nil {
+		thread, err := s.repo.GetThread(ctx, req.ThreadID)
+		if err != nil {
+			return err
+		}
+		projectID = thread.ProjectID
+	}
+	var tmpl PromptTemplateDTO
+	if req.TemplateID != 0 {
+		if err := s.ensureRepo(); err != nil {
+			return err
+		}
+		record, err := s.repo.GetPromptTemplate(ctx, req.TemplateID)
+		if err != nil {
+			return err
+		}
+		if record.ProjectID != 0 && record.ProjectID != projectID {
+			return fmt.Errorf("prompt template %d belongs to another project", record.ID)
+		}
+		tmpl = toPromptTemplateDTO(record)
+	} else {
+		var err error
+		if tmpl, err = s.ResolvePromptTemplate(ctx, projectID, name); err != nil {
+			return err
+		}
+	}
+	text, err := ExpandTemplate(tmpl.Body, req.TemplateValues)
+	if err != nil {
+		return fmt.Errorf("template %q: %w", tmpl.Name, err)
+	}
+	if extra := strings.TrimSpace(req.Input); extra != "" {
+		text = strings.TrimRight(text, "\n") + "\n\n" + extra
+	}
+	req.Input = text
+	req.TemplateID, req.TemplateName, req.TemplateValues = 0, "", nil
+	return nil
+}
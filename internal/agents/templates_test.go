@@ -0,0 +1,115 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"codex-ui/internal/storage/discovery"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	body := "Write tests for {{ area }} first, then fix {{area}} in {{file}}."
+	if got := TemplateVariables(body); len(got) != 2 || got[0] != "area" || got[1] != "file" {
+		t.Fatalf("unexpected variables %v", got)
+	}
+	text, err := ExpandTemplate(body, map[string]string{"area": "auth", "file": "login.go", "unused": "x"})
+	if err != nil || text != "Write tests for auth first, then fix auth in login.go." {
+		t.Fatalf("unexpected expansion %q, %v", text, err)
+	}
+	if _, err := ExpandTemplate(body, map[string]string{"area": "auth"}); !errors.Is(err, ErrMissingTemplateValues) || !strings.Contains(err.Error(), "file") {
+		t.Fatalf("expected missing value error, got %v", err)
+	}
+}
+
+func TestService_SendExpandsTemplates(t *testing.T) {
+	adapter := &scriptedAdapter{}
+	svc, repo, project := newTestService(t, adapter)
+	ctx := context.Background()
+
+	global, err := svc.CreatePromptTemplate(ctx, SavePromptTemplateRequest{Name: "tests-first", Body: "Write failing tests for {{area}} first."})
+	if err != nil {
+		t.Fatalf("create global template: %v", err)
+	}
+	if _, err := svc.CreatePromptTemplate(ctx, SavePromptTemplateRequest{ProjectID: project.ID, Name: "tests-first", Body: "Use table tests for {{area}}."}); err != nil {
+		t.Fatalf("create project template: %v", err)
+	}
+
+	thread, err := sendAndWait(t, svc, MessageRequest{ProjectID: project.ID, TemplateName: "tests-first", TemplateValues: map[string]string{"area": "the parser"}, Input: "Keep it short.", ThreadOptions: ThreadOptionsDTO{Model: "m"}})
+	if err != nil {
+		t.Fatalf("send by name: %v", err)
+	}
+	if _, err := sendAndWait(t, svc, MessageRequest{ThreadID: thread.ID, TemplateID: global.ID, TemplateValues: map[string]string{"area": "the lexer"}}); err != nil {
+		t.Fatalf("send by id: %v", err)
+	}
+	inputs := adapter.seenInputs()
+	if len(inputs) != 2 || inputs[0] != "Use table tests for the parser.\n\nKeep it short." || inputs[1] != "Write failing tests for the lexer first." {
+		t.Fatalf("unexpected adapter inputs %q", inputs)
+	}
+	entries, _ := svc.LoadThreadConversation(ctx, thread.ID)
+	if len(entries) == 0 || entries[0].Text != inputs[0] {
+		t.Fatalf("expected the expanded prompt in the timeline, got %+v", entries)
+	}
+
+	if _, _, err := svc.Send(ctx, MessageRequest{ThreadID: thread.ID, TemplateID: global.ID}); !errors.Is(err, ErrMissingTemplateValues) {
+		t.Fatalf("expected missing values error, got %v", err)
+	}
+	if len(adapter.seenInputs()) != 2 {
+		t.Fatal("a failed expansion must not reach the adapter")
+	}
+
+	other, err := repo.UpsertProject(ctx, discovery.UpsertProjectParams{Path: "/tmp/other-templates"})
+	if err != nil {
+		t.Fatalf("upsert project: %v", err)
+	}
+	var scopedID int64
+	listed, _ := svc.ListPromptTemplates(ctx, project.ID)
+	for _, tmpl := range listed {
+		if tmpl.ProjectID == project.ID {
+			scopedID = tmpl.ID
+		}
+	}
+	if _, _, err := svc.Send(ctx, MessageRequest{ProjectID: other.ID, TemplateID: scopedID, TemplateValues: map[string]string{"area": "x"}, ThreadOptions: ThreadOptionsDTO{Model: "m"}}); err == nil {
+		t.Fatal("expected a project template to be rejected in another project")
+	}
+}
+
+func TestService_PullRequestTemplateCanBeOverridden(t *testing.T) {
+	svc, _, project := newTestService(t, &scriptedAdapter{})
+	ctx := context.Background()
+
+	if !strings.Contains(BuildCreatePRInstruction("codex/fix-1"), "Push the branch 'codex/fix-1' to origin") {
+		t.Fatal("expected the built-in instruction to name the branch")
+	}
+	listed, err := svc.ListPromptTemplates(ctx, project.ID)
+	if err != nil {
+		t.Fatalf("list templates: %v", err)
+	}
+	if len(listed) != 1 || listed[0].Name != CreatePRTemplateName || !listed[0].BuiltIn || listed[0].Variables[0] != "branch" {
+		t.Fatalf("expected only the built-in template, got %+v", listed)
+	}
+
+	override, err := svc.CreatePromptTemplate(ctx, SavePromptTemplateRequest{ProjectID: project.ID, Name: CreatePRTemplateName, Body: "Open a draft PR for {{branch}} titled {{title}}."})
+	if err != nil {
+		t.Fatalf("override template: %v", err)
+	}
+	resolved, err := svc.ResolvePromptTemplate(ctx, project.ID, CreatePRTemplateName)
+	if err != nil || resolved.ID != override.ID {
+		t.Fatalf("expected the project override, got %+v, %v", resolved, err)
+	}
+	listed, _ = svc.ListPromptTemplates(ctx, project.ID)
+	if len(listed) != 1 || listed[0].BuiltIn {
+		t.Fatalf("expected the override to hide the built-in, got %+v", listed)
+	}
+	if resolved, _ := svc.ResolvePromptTemplate(ctx, 0, CreatePRTemplateName); !resolved.BuiltIn {
+		t.Fatalf("expected other projects to keep the built-in, got %+v", resolved)
+	}
+
+	if err := svc.DeletePromptTemplate(ctx, override.ID); err != nil {
+		t.Fatalf("delete template: %v", err)
+	}
+	if resolved, _ := svc.ResolvePromptTemplate(ctx, project.ID, CreatePRTemplateName); !resolved.BuiltIn {
+		t.Fatalf("expected the built-in back after deleting the override, got %+v", resolved)
+	}
+}
@@ -0,0 +1,162 @@
+package agents
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"codex-ui/internal/storage/discovery"
+)
+
+const (
+	defaultThreadPageSize = 50
+	maxThreadPageSize     = 200
+)
+
+// ThreadQueryDTO selects one page of threads. ProjectID 0 queries every
+// project. From and To take the same formats as usage queries and bound the
+// last activity time. Sort is recent (default), created or title; Order is
+// asc or desc, defaulting to desc except for title. Cursor is the NextCursor
+// of the previous page and must be used with the same sort and order.
+type ThreadQueryDTO struct {
+	ThreadFilterDTO
+	ProjectID int64  `json:"projectId,omitempty"`
+	Model     string `json:"model,omitempty"`
+	Branch    string `json:"branch,omitempty"`
+	// Title matches threads whose title contains it, ignoring case.
+	Title  string `json:"title,omitempty"`
+	HasPR  *bool  `json:"hasPr,omitempty"`
+	From   string `json:"from,omitempty"`
+	To     string `json:"to,omitempty"`
+	Sort   string `json:"sort,omitempty"`
+	Order  string `json:"order,omitempty"`
+	Cursor string `json:"cursor,omitempty"`
+	Limit  int    `json:"limit,omitempty"`
+	// IncludeDiffStat computes each listed worktree's diff summary, which
+	// runs git once per thread.
+	IncludeDiffStat bool `json:"includeDiffStat,omitempty"`
+}
+
+// ThreadPageDTO is one page of QueryThreads. NextCursor is empty on the last
+// page.
+type ThreadPageDTO struct {
+	Threads    []ThreadDTO `json:"threads"`
+	NextCursor string      `json:"nextCursor,omitempty"`
+}
+
+// threadPageCursor is the decoded form of a page cursor. It records the sort
+// it was made for so it cannot be replayed against another one.
+type threadPageCursor struct {
+	Sort  string `json:"s"`
+	Order string `json:"o"`
+	discovery.ThreadCursor
+}
+
+func (q ThreadQueryDTO) query() (discovery.ThreadQuery, error) {
+	query := discovery.ThreadQuery{
+		ThreadFilter:  q.ThreadFilterDTO.toFilter(q.ProjectID),
+		Model:         strings.TrimSpace(q.Model),
+		Branch:        strings.TrimSpace(q.Branch),
+		TitleContains: strings.TrimSpace(q.Title),
+		HasPR:         q.HasPR,
+		Sort:          discovery.ThreadSort(strings.TrimSpace(q.Sort)),
+		Limit:         q.Limit,
+	}
+	if query.Sort == "" {
+		query.Sort = discovery.ThreadSortRecent
+	}
+	order := strings.ToLower(strings.TrimSpace(q.Order))
+	switch order {
+	case "":
+		if query.Sort == discovery.ThreadSortTitle {
+			order = "asc"
+		} else {
+			order = "desc"
+		}
+	case "asc", "desc":
+	default:
+		return query, fmt.Errorf("invalid order %q (use asc or desc)", q.Order)
+	}
+	query.Ascending = order == "asc"
+	switch {
+	case query.Limit <= 0:
+		query.Limit = defaultThreadPageSize
+	case query.Limit > maxThreadPageSize:
+		query.Limit = maxThreadPageSize
+	}
+	var err error
+	if query.From, err = parseTimeBound(q.From); err != nil {
+		return query, fmt.Errorf("from: %w", err)
+	}
+	if query.To, err = parseTimeBound(q.To); err != nil {
+		return query, fmt.Errorf("to: %w", err)
+	}
+	if cursor := strings.TrimSpace(q.Cursor); cursor != "" {
+		decoded, err := decodeThreadCursor(cursor)
+		if err != nil {
+			return query, err
+		}
+		if decoded.Sort != string(query.Sort) || decoded.Order != order {
+			return query, errors.New("cursor belongs to a different sort order")
+		}
+		query.After = &decoded.ThreadCursor
+	}
+	return query, nil
+}
+
+func encodeThreadCursor(cursor threadPageCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeThreadCursor(value string) (threadPageCursor, error) {
+	var cursor threadPageCursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err == nil {
+		err = json.Unmarshal(data, &cursor)
+	}
+	if err != nil || cursor.ID == 0 {
+		return threadPageCursor{}, fmt.Errorf("invalid cursor %q", value)
+	}
+	return cursor, nil
+}
+
+// QueryThreads returns one page of the threads matching query.
+func (s *Service) QueryThreads(ctx context.Context, query ThreadQueryDTO) (ThreadPageDTO, error) {
+	if err := s.ensureRepo(); err != nil {
+		return ThreadPageDTO{}, err
+	}
+	q, err := query.query()
+	if err != nil {
+		return ThreadPageDTO{}, err
+	}
+	records, next, err := s.repo.QueryThreads(ctx, q)
+	if err != nil {
+		return ThreadPageDTO{}, err
+	}
+	page := ThreadPageDTO{Threads: make([]ThreadDTO, 0, len(records))}
+	for _, record := range records {
+		dto := toThreadDTO(record)
+		if query.IncludeDiffStat {
+			dto.DiffSummary = s.computeDiffSummary(ctx, record.WorktreePath)
+		}
+		page.Threads = append(page.Threads, dto)
+	}
+	if next != nil {
+		order := "desc"
+		if q.Ascending {
+			order = "asc"
+		}
+		page.NextCursor = encodeThreadCursor(threadPageCursor{Sort: string(q.Sort), Order: order, ThreadCursor: *next})
+	}
+	return page, nil
+}
+
+// ListRecentThreads returns one page of non-archived threads across every
+// project, most recently active first.
+func (s *Service) ListRecentThreads(ctx context.Context, cursor string, limit int) (ThreadPageDTO, error) {
+	return s.QueryThreads(ctx, ThreadQueryDTO{Cursor: cursor, Limit: limit})
+}
@@ -0,0 +1,54 @@
+package agents
+
+import (
+	"context"
+	"testing"
+
+	"codex-ui/internal/storage/discovery"
+)
+
+func TestService_QueryThreadsFollowsCursors(t *testing.T) {
+	svc, repo, project := newTestService(t, &scriptedAdapter{})
+	ctx := context.Background()
+	other, err := repo.UpsertProject(ctx, discovery.UpsertProjectParams{Path: "/tmp/" + t.Name() + "-other"})
+	if err != nil {
+		t.Fatalf("upsert project: %v", err)
+	}
+	for _, projectID := range []int64{project.ID, other.ID, project.ID} {
+		if _, err := repo.CreateThread(ctx, discovery.CreateThreadParams{ProjectID: projectID, Title: "task", Model: "m"}); err != nil {
+			t.Fatalf("create thread: %v", err)
+		}
+	}
+
+	var seen []int64
+	page, err := svc.ListRecentThreads(ctx, "", 2)
+	for err == nil {
+		for _, thread := range page.Threads {
+			seen = append(seen, thread.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		page, err = svc.ListRecentThreads(ctx, page.NextCursor, 2)
+	}
+	if err != nil {
+		t.Fatalf("list recent threads: %v", err)
+	}
+	if len(seen) != 3 || seen[0] != 3 || seen[2] != 1 {
+		t.Fatalf("expected every project's threads, newest first, got %v", seen)
+	}
+
+	first, err := svc.QueryThreads(ctx, ThreadQueryDTO{ProjectID: project.ID, Limit: 1})
+	if err != nil || len(first.Threads) != 1 || first.NextCursor == "" {
+		t.Fatalf("unexpected first page %+v (err %v)", first, err)
+	}
+	if _, err := svc.QueryThreads(ctx, ThreadQueryDTO{ProjectID: project.ID, Sort: "title", Cursor: first.NextCursor}); err == nil {
+		t.Fatal("expected a cursor from another sort to be rejected")
+	}
+	if _, err := svc.QueryThreads(ctx, ThreadQueryDTO{Cursor: "not-a-cursor"}); err == nil {
+		t.Fatal("expected a malformed cursor to be rejected")
+	}
+	if _, err := svc.QueryThreads(ctx, ThreadQueryDTO{From: "yesterday"}); err == nil {
+		t.Fatal("expected an invalid date to be rejected")
+	}
+}
@@ -15,6 +15,14 @@ type MessageRequest struct {
 	Segments         []InputSegmentDTO `json:"segments,omitempty"`
 	ThreadOptions    ThreadOptionsDTO  `json:"threadOptions"`
 	TurnOptions      *TurnOptionsDTO   `json:"turnOptions,omitempty"`
+	// BaseBranch is the ref a new thread worktree branches from. Empty uses
+	// the project's current ref; it is ignored once the worktree exists.
+	BaseBranch string `json:"baseBranch,omitempty"`
+	// TemplateID or TemplateName selects a prompt template that is expanded
+	// with TemplateValues and sent ahead of Input.
+	TemplateID     int64             `json:"templateId,omitempty"`
+	TemplateName   string            `json:"templateName,omitempty"`
+	TemplateValues map[string]string `json:"templateValues,omitempty"`
 }
 
 // InputSegmentDTO represents a piece of user input. Either Text or ImagePath must be set.
@@ -40,6 +48,8 @@ type TurnOptionsDTO struct {
 
 // StreamEvent represents a single event emitted during a streamed turn.
 type StreamEvent struct {
+	// Seq numbers the events of one stream from 1; see Service.ResumeStream.
+	Seq      int64         `json:"seq,omitempty"`
 	Type     string        `json:"type"`
 	ThreadID string        `json:"threadId,omitempty"`
 	Item     *AgentItemDTO `json:"item,omitempty"`
@@ -60,6 +70,8 @@ type AgentItemDTO struct {
 	WebSearch *WebSearchDTO        `json:"webSearch,omitempty"`
 	TodoList  *TodoListDTO         `json:"todoList,omitempty"`
 	Error     *ErrorItemDTO        `json:"error,omitempty"`
+	// StructuredOutput is set on structured_output items.
+	StructuredOutput *StructuredOutputDTO `json:"structuredOutput,omitempty"`
 }
 
 // CommandExecutionDTO captures command execution progress.
@@ -132,10 +144,41 @@ type StreamError struct {
 }
 
 // StreamHandle represents the initial response after starting a stream.
+// When the thread is busy the turn is queued instead and QueuedTurnID is set.
 type StreamHandle struct {
 	StreamID         string `json:"streamId"`
 	ThreadID         int64  `json:"threadId"`
 	ThreadExternalID string `json:"threadExternalId,omitempty"`
+	QueuedTurnID     int64  `json:"queuedTurnId,omitempty"`
+}
+
+// StreamReplayDTO carries the buffered events of a stream after a sequence number.
+type StreamReplayDTO struct {
+	StreamID string        `json:"streamId"`
+	ThreadID int64         `json:"threadId"`
+	Events   []StreamEvent `json:"events"`
+	LastSeq  int64         `json:"lastSeq"`
+	Finished bool          `json:"finished"`
+	// Truncated reports that some missed events were evicted from the buffer.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// ActiveStreamDTO describes a running stream a view can resume.
+type ActiveStreamDTO struct {
+	StreamID  string `json:"streamId"`
+	ThreadID  int64  `json:"threadId"`
+	StartedAt string `json:"startedAt"`
+	LastSeq   int64  `json:"lastSeq"`
+}
+
+// QueuedTurnDTO describes a follow-up waiting for the running turn of its thread.
+type QueuedTurnDTO struct {
+	ID        int64             `json:"id"`
+	ThreadID  int64             `json:"threadId"`
+	Position  int               `json:"position"`
+	Input     string            `json:"input"`
+	Segments  []InputSegmentDTO `json:"segments,omitempty"`
+	CreatedAt string            `json:"createdAt"`
 }
 
 // ConversationEntryDTO mirrors a single transcript entry used by the frontend timeline.
@@ -150,6 +193,14 @@ type ConversationEntryDTO struct {
 	Tone      string            `json:"tone,omitempty"`
 	Message   string            `json:"message,omitempty"`
 	Meta      map[string]any    `json:"meta,omitempty"`
+	// RolledBack marks entries whose turn was undone by a restored checkpoint.
+	RolledBack bool `json:"rolledBack,omitempty"`
+	// Partial marks summary entries whose large fields were left out; load
+	// the entry on its own to expand it.
+	Partial bool `json:"partial,omitempty"`
+	// CompletedAt is set on agent items once they have finished; CreatedAt
+	// is when they started.
+	CompletedAt *string `json:"completedAt,omitempty"`
 }
 
 // ThreadDTO mirrors persisted thread data for the frontend.
@@ -171,6 +222,67 @@ type ThreadDTO struct {
 	Branch         string          `json:"branch,omitempty"`
 	PullRequest    *int            `json:"pullRequestNumber,omitempty"`
 	DiffSummary    *DiffSummaryDTO `json:"diffStat,omitempty"`
+	// ParentThreadID and ForkedFromEntryID describe fork lineage.
+	ParentThreadID    int64  `json:"parentThreadId,omitempty"`
+	ForkedFromEntryID string `json:"forkedFromEntryId,omitempty"`
+	// GroupID links sibling threads of a fan-out send.
+	GroupID    string  `json:"groupId,omitempty"`
+	ArchivedAt *string `json:"archivedAt,omitempty"`
+	PinnedAt   *string `json:"pinnedAt,omitempty"`
+	// ScheduledJobID is set on threads created by a scheduled job.
+	ScheduledJobID int64 `json:"scheduledJobId,omitempty"`
+	// ReadOnly threads were imported for reading and refuse new turns.
+	ReadOnly   bool    `json:"readOnly,omitempty"`
+	ImportedAt *string `json:"importedAt,omitempty"`
+	// AgentID is the agent follow-up turns run on.
+	AgentID string `json:"agentId,omitempty"`
+}
+
+// FanOutRequest sends one prompt to several agent/model variants at once.
+type FanOutRequest struct {
+	ProjectID   int64              `json:"projectId"`
+	Input       string             `json:"input,omitempty"`
+	Segments    []InputSegmentDTO  `json:"segments,omitempty"`
+	Variants    []FanOutVariantDTO `json:"variants"`
+	TurnOptions *TurnOptionsDTO    `json:"turnOptions,omitempty"`
+	// TemplateID, TemplateName and TemplateValues work as in MessageRequest.
+	TemplateID     int64             `json:"templateId,omitempty"`
+	TemplateName   string            `json:"templateName,omitempty"`
+	TemplateValues map[string]string `json:"templateValues,omitempty"`
+}
+
+// FanOutVariantDTO configures one sibling thread of a fan-out.
+type FanOutVariantDTO struct {
+	AgentID       string           `json:"agentId,omitempty"`
+	ThreadOptions ThreadOptionsDTO `json:"threadOptions"`
+}
+
+// FanOutHandle reports the group and the per-variant streams of a fan-out.
+// Variants that failed to start carry an Error and no StreamID.
+type FanOutHandle struct {
+	GroupID string               `json:"groupId"`
+	Streams []FanOutStreamHandle `json:"streams"`
+}
+
+// FanOutStreamHandle is the StreamHandle of one fan-out variant.
+type FanOutStreamHandle struct {
+	StreamHandle
+	Error string `json:"error,omitempty"`
+}
+
+// GroupComparisonDTO lines up the threads of a fan-out group.
+type GroupComparisonDTO struct {
+	GroupID string           `json:"groupId"`
+	Members []GroupMemberDTO `json:"members"`
+}
+
+// GroupMemberDTO summarises one thread's outcome within a group.
+type GroupMemberDTO struct {
+	Thread       ThreadDTO         `json:"thread"`
+	DiffStats    []FileDiffStatDTO `json:"diffStats"`
+	DiffSummary  DiffSummaryDTO    `json:"diffSummary"`
+	Usage        UsageDTO          `json:"usage"`
+	FinalMessage string            `json:"finalMessage,omitempty"`
 }
 
 // CancelResponse reports the updated status after stopping a stream.
@@ -0,0 +1,217 @@
+package agents
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"codex-ui/internal/storage/discovery"
+)
+
+// UsageQueryDTO selects and groups ledger rows. From and To accept RFC 3339
+// timestamps or YYYY-MM-DD / YYYY-MM dates (UTC); From is inclusive and To
+// exclusive. GroupBy takes thread, project, model, agent, day and month.
+type UsageQueryDTO struct {
+	ThreadID  int64    `json:"threadId,omitempty"`
+	ProjectID int64    `json:"projectId,omitempty"`
+	Model     string   `json:"model,omitempty"`
+	From      string   `json:"from,omitempty"`
+	To        string   `json:"to,omitempty"`
+	GroupBy   []string `json:"groupBy,omitempty"`
+}
+
+// UsageRowDTO is one group of a usage report. Keys and Labels follow the
+// query's GroupBy order; labels name projects and threads.
+type UsageRowDTO struct {
+	Keys              []string `json:"keys,omitempty"`
+	Labels            []string `json:"labels,omitempty"`
+	Turns             int64    `json:"turns"`
+	InputTokens       int64    `json:"inputTokens"`
+	CachedInputTokens int64    `json:"cachedInputTokens"`
+	OutputTokens      int64    `json:"outputTokens"`
+}
+
+// UsageReportDTO aggregates token usage for a query.
+type UsageReportDTO struct {
+	GroupBy []string      `json:"groupBy,omitempty"`
+	Rows    []UsageRowDTO `json:"rows"`
+	Total   UsageRowDTO   `json:"total"`
+}
+
+func (q UsageQueryDTO) filter() (discovery.UsageFilter, error) {
+	filter := discovery.UsageFilter{ThreadID: q.ThreadID, ProjectID: q.ProjectID, Model: strings.TrimSpace(q.Model)}
+	var err error
+	if filter.From, err = parseTimeBound(q.From); err != nil {
+		return filter, fmt.Errorf("from: %w", err)
+	}
+	if filter.To, err = parseTimeBound(q.To); err != nil {
+		return filter, fmt.Errorf("to: %w", err)
+	}
+	return filter, nil
+}
+
+func parseTimeBound(value string) (*time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02", "2006-01"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid time %q (use RFC 3339, YYYY-MM-DD or YYYY-MM)", value)
+}
+
+// UsageReport totals the usage ledger for query.
+func (s *Service) UsageReport(ctx context.Context, query UsageQueryDTO) (UsageReportDTO, error) {
+	if err := s.ensureRepo(); err != nil {
+		return UsageReportDTO{}, err
+	}
+	filter, err := query.filter()
+	if err != nil {
+		return UsageReportDTO{}, err
+	}
+	groupBy := make([]string, 0, len(query.GroupBy))
+	for _, key := range query.GroupBy {
+		if key = strings.TrimSpace(key); key != "" {
+			groupBy = append(groupBy, key)
+		}
+	}
+	totals, err := s.repo.SumTurnUsage(ctx, filter, groupBy)
+	if err != nil {
+		return UsageReportDTO{}, err
+	}
+	report := UsageReportDTO{GroupBy: groupBy, Rows: make([]UsageRowDTO, 0, len(totals))}
+	labels := newUsageLabeler(s.repo)
+	for _, total := range totals {
+		row := UsageRowDTO{
+			Keys:              total.Keys,
+			Turns:             total.Turns,
+			InputTokens:       total.InputTokens,
+			CachedInputTokens: total.CachedInputTokens,
+			OutputTokens:      total.OutputTokens,
+		}
+		if len(groupBy) > 0 {
+			row.Labels = make([]string, len(groupBy))
+			for i, key := range groupBy {
+				row.Labels[i] = labels.label(ctx, key, total.Keys[i])
+			}
+		}
+		report.Total.Turns += row.Turns
+		report.Total.InputTokens += row.InputTokens
+		report.Total.CachedInputTokens += row.CachedInputTokens
+		report.Total.OutputTokens += row.OutputTokens
+		report.Rows = append(report.Rows, row)
+	}
+	return report, nil
+}
+
+// ExportUsageCSV renders query as CSV. Without GroupBy every turn is a row;
+// otherwise there is one row per group.
+func (s *Service) ExportUsageCSV(ctx context.Context, query UsageQueryDTO) (string, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if len(query.GroupBy) > 0 {
+		report, err := s.UsageReport(ctx, query)
+		if err != nil {
+			return "", err
+		}
+		header := make([]string, 0, len(report.GroupBy)+4)
+		for _, key := range report.GroupBy {
+			header = append(header, key)
+			if key == discovery.UsageGroupProject || key == discovery.UsageGroupThread {
+				header = append(header, key+"_name")
+			}
+		}
+		_ = w.Write(append(header, "turns", "input_tokens", "cached_input_tokens", "output_tokens"))
+		for _, row := range report.Rows {
+			record := make([]string, 0, len(header)+4)
+			for i, key := range report.GroupBy {
+				record = append(record, row.Keys[i])
+				if key == discovery.UsageGroupProject || key == discovery.UsageGroupThread {
+					record = append(record, row.Labels[i])
+				}
+			}
+			_ = w.Write(append(record, usageCounts(row.Turns, row.InputTokens, row.CachedInputTokens, row.OutputTokens)...))
+		}
+	} else {
+		if err := s.ensureRepo(); err != nil {
+			return "", err
+		}
+		filter, err := query.filter()
+		if err != nil {
+			return "", err
+		}
+		turns, err := s.repo.ListTurnUsage(ctx, filter)
+		if err != nil {
+			return "", err
+		}
+		labels := newUsageLabeler(s.repo)
+		_ = w.Write([]string{"completed_at", "project", "project_name", "thread", "thread_name", "agent", "model", "reasoning_level", "status", "input_tokens", "cached_input_tokens", "output_tokens"})
+		for _, turn := range turns {
+			projectKey := strconv.FormatInt(turn.ProjectID, 10)
+			threadKey := strconv.FormatInt(turn.ThreadID, 10)
+			record := []string{
+				turn.CompletedAt.UTC().Format(time.RFC3339),
+				projectKey, labels.label(ctx, discovery.UsageGroupProject, projectKey),
+				threadKey, labels.label(ctx, discovery.UsageGroupThread, threadKey),
+				turn.AgentID, turn.Model, turn.ReasoningLevel, turn.Status,
+			}
+			_ = w.Write(append(record, usageCounts(-1, turn.InputTokens, turn.CachedInputTokens, turn.OutputTokens)...))
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", fmt.Errorf("write usage csv: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// usageCounts formats token counts; turns < 0 omits the turns column.
+func usageCounts(turns, input, cached, output int64) []string {
+	counts := []string{strconv.FormatInt(input, 10), strconv.FormatInt(cached, 10), strconv.FormatInt(output, 10)}
+	if turns < 0 {
+		return counts
+	}
+	return append([]string{strconv.FormatInt(turns, 10)}, counts...)
+}
+
+// usageLabeler resolves project and thread IDs to names, caching lookups.
+type usageLabeler struct {
+	repo  *discovery.Repository
+	cache map[string]string
+}
+
+func newUsageLabeler(repo *discovery.Repository) *usageLabeler {
+	return &usageLabeler{repo: repo, cache: make(map[string]string)}
+}
+
+func (l *usageLabeler) label(ctx context.Context, key, value string) string {
+	if key != discovery.UsageGroupProject && key != discovery.UsageGroupThread {
+		return value
+	}
+	cacheKey := key + ":" + value
+	if label, ok := l.cache[cacheKey]; ok {
+		return label
+	}
+	label := ""
+	if id, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if key == discovery.UsageGroupProject {
+			if project, err := l.repo.GetProjectByID(ctx, id); err == nil {
+				label = project.DisplayName
+				if label == "" {
+					label = project.Path
+				}
+			}
+		} else if thread, err := l.repo.GetThread(ctx, id); err == nil {
+			label = thread.Title
+		}
+	}
+	l.cache[cacheKey] = label
+	return label
+}
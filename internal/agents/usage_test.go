@@ -0,0 +1,110 @@
+package agents
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"codex-ui/internal/storage/discovery"
+)
+
+func TestService_UsageReportGroupsByModelAndMonth(t *testing.T) {
+	svc, repo, project := newTestService(t, modelEchoAdapter{})
+	ctx := context.Background()
+
+	stream, thread, err := svc.Send(ctx, MessageRequest{ProjectID: project.ID, Input: "hi", ThreadOptions: ThreadOptionsDTO{Model: "abc"}})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	for range stream.Events() {
+	}
+	if err := stream.Wait(); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	rows, err := repo.ListTurnUsage(ctx, discovery.UsageFilter{ThreadID: thread.ID})
+	if err != nil || len(rows) != 1 {
+		t.Fatalf("expected one ledger row for the turn, got %+v (%v)", rows, err)
+	}
+	if got := rows[0]; got.AgentID != "fake" || got.Model != "abc" || got.InputTokens != 3 || got.OutputTokens != 1 || got.Status != "completed" {
+		t.Fatalf("unexpected ledger row %+v", got)
+	}
+
+	for _, u := range []discovery.TurnUsage{
+		{ThreadID: thread.ID, ProjectID: project.ID, Model: "abc", InputTokens: 10, OutputTokens: 2, CompletedAt: time.Date(2024, 1, 31, 23, 0, 0, 0, time.UTC)},
+		{ThreadID: thread.ID, ProjectID: project.ID, Model: "xyz", InputTokens: 100, CachedInputTokens: 40, OutputTokens: 20, CompletedAt: time.Date(2024, 2, 1, 1, 0, 0, 0, time.UTC)},
+		{ThreadID: thread.ID, ProjectID: project.ID, Model: "abc", InputTokens: 1, OutputTokens: 1, CompletedAt: time.Date(2024, 2, 15, 0, 0, 0, 0, time.UTC)},
+	} {
+		if _, err := repo.RecordTurnUsage(ctx, u); err != nil {
+			t.Fatalf("record usage: %v", err)
+		}
+	}
+
+	report, err := svc.UsageReport(ctx, UsageQueryDTO{ProjectID: project.ID, From: "2024-01", To: "2024-03-01", GroupBy: []string{"month", "model"}})
+	if err != nil {
+		t.Fatalf("report: %v", err)
+	}
+	var got []string
+	for _, row := range report.Rows {
+		got = append(got, strings.Join(row.Keys, "/"))
+	}
+	if strings.Join(got, ",") != "2024-01/abc,2024-02/abc,2024-02/xyz" {
+		t.Fatalf("unexpected groups %v", got)
+	}
+	if report.Total.Turns != 3 || report.Total.InputTokens != 111 || report.Total.CachedInputTokens != 40 {
+		t.Fatalf("unexpected total %+v", report.Total)
+	}
+
+	byProject, err := svc.UsageReport(ctx, UsageQueryDTO{GroupBy: []string{"project"}})
+	if err != nil {
+		t.Fatalf("report by project: %v", err)
+	}
+	if len(byProject.Rows) != 1 || byProject.Rows[0].Labels[0] != project.Path || byProject.Rows[0].Turns != 4 {
+		t.Fatalf("unexpected project report %+v", byProject.Rows)
+	}
+
+	csv, err := svc.ExportUsageCSV(ctx, UsageQueryDTO{Model: "xyz"})
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(csv), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], "2024-02-01T01:00:00Z,") || !strings.HasSuffix(lines[1], ",xyz,,,100,40,20") {
+		t.Fatalf("unexpected csv %q", csv)
+	}
+
+	if _, err := svc.UsageReport(ctx, UsageQueryDTO{From: "last week"}); err == nil {
+		t.Fatal("expected invalid date error")
+	}
+}
+
+func TestService_ReportsUnrecordedUsage(t *testing.T) {
+	svc, repo, project := newTestService(t, modelEchoAdapter{})
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name()))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if _, err := db.Exec(`CREATE TRIGGER reject_usage BEFORE INSERT ON turn_usage BEGIN SELECT RAISE(ABORT, 'ledger unavailable'); END`); err != nil {
+		t.Fatalf("create trigger: %v", err)
+	}
+
+	thread, err := sendAndWait(t, svc, MessageRequest{ProjectID: project.ID, Input: "hi", ThreadOptions: ThreadOptionsDTO{Model: "abc"}})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	entries, err := repo.ListConversationEntries(context.Background(), thread.ID)
+	if err != nil {
+		t.Fatalf("list entries: %v", err)
+	}
+	var reported bool
+	for _, entry := range entries {
+		if entry.Role == "system" && strings.Contains(string(entry.Payload), "ledger unavailable") {
+			reported = true
+		}
+	}
+	if !reported {
+		t.Fatalf("expected an error entry for the unrecorded usage, got %+v", entries)
+	}
+}
@@ -0,0 +1,315 @@
+// Package cli implements the headless command-line front end. It drives the
+// same project and agent services as the desktop app against the same catalog.
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"codex-ui/internal/agents"
+	"codex-ui/internal/projects"
+	"codex-ui/internal/storage/discovery"
+)
+
+// Deps bundles the services used by CLI commands.
+type Deps struct {
+	Projects *projects.Service
+	Agents   *agents.Service
+	Repo     *discovery.Repository
+	Stdin    io.Reader
+	Stdout   io.Writer
+	Stderr   io.Writer
+}
+
+type command struct {
+	usage string
+	help  string
+	run   func(ctx context.Context, d Deps, args []string) error
+}
+
+var commands = map[string]command{
+	"projects":        {"projects", "List registered projects", runProjects},
+	"threads":         {"threads [--archived] [--pinned] [--status <list>] <project-id>", "List threads of a project", runThreads},
+	"send":            {"send [flags] [--schema <file>] (--project <id> | --thread <id>) <prompt|->", "Send a prompt and stream the turn", runSend},
+	"cancel":          {"cancel <thread-id>", "Stop the running turn of a thread, in the CLI or the app", runCancel},
+	"show":            {"show <thread-id>", "Print a thread's conversation", runShow},
+	"diffs":           {"diffs <thread-id>", "Show file changes in a thread's worktree", runDiffs},
+	"pr":              {"pr <thread-id>", "Commit, push and open a pull request for a thread", runPR},
+	"export":          {"export [--format markdown|html|json] <thread-id>", "Print a thread's transcript", runExport},
+	"import":          {"import --project <id> [--read-only] <archive.json|->", "Import a JSON transcript archive as a new thread", runImport},
+	"import-sessions": {"import-sessions [--dir <path>]", "Import Codex CLI sessions of registered projects", runImportSessions},
+	"usage":           {"usage [--project <id>] [--thread <id>] [--model <m>] [--from <date>] [--to <date>] [--by project,month] [--csv]", "Report token usage", runUsage},
+}
+
+// errUsage signals that the command was invoked incorrectly.
+var errUsage = errors.New("invalid usage")
+
+// Run executes a CLI command and returns the process exit code.
+func Run(ctx context.Context, args []string, d Deps) int {
+	if d.Stdout == nil {
+		d.Stdout = io.Discard
+	}
+	if d.Stderr == nil {
+		d.Stderr = io.Discard
+	}
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printUsage(d.Stdout)
+		return 0
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(d.Stderr, "unknown command %q\n\n", args[0])
+		printUsage(d.Stderr)
+		return 2
+	}
+	if err := cmd.run(ctx, d, args[1:]); err != nil {
+		if errors.Is(err, errUsage) || errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(d.Stderr, "usage: codex-ui cli %s\n", cmd.usage)
+			return 2
+		}
+		fmt.Fprintf(d.Stderr, "error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: codex-ui cli <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-16s %s\n", name, commands[name].help)
+	}
+}
+
+func runProjects(ctx context.Context, d Deps, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	list, err := d.Projects.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, p := range list {
+		name := p.DisplayName
+		if name == "" {
+			name = "-"
+		}
+		fmt.Fprintf(d.Stdout, "%d\t%s\t%s\n", p.ID, name, p.Path)
+	}
+	return nil
+}
+
+func runThreads(ctx context.Context, d Deps, args []string) error {
+	fs := flag.NewFlagSet("threads", flag.ContinueOnError)
+	fs.SetOutput(d.Stderr)
+	archived := fs.Bool("archived", false, "include archived threads")
+	pinned := fs.Bool("pinned", false, "only pinned threads")
+	statuses := fs.String("status", "", "comma-separated statuses to keep")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	projectID, err := singleID(fs.Args())
+	if err != nil {
+		return err
+	}
+	filter := agents.ThreadFilterDTO{IncludeArchived: *archived, PinnedOnly: *pinned}
+	for _, status := range strings.Split(*statuses, ",") {
+		if status = strings.TrimSpace(status); status != "" {
+			filter.Statuses = append(filter.Statuses, status)
+		}
+	}
+	threads, err := d.Agents.ListThreads(ctx, projectID, filter)
+	if err != nil {
+		return err
+	}
+	for _, t := range threads {
+		fmt.Fprintf(d.Stdout, "%d\t%s\t%s\t%s\n", t.ID, t.Status, t.Branch, t.Title)
+	}
+	return nil
+}
+
+func runShow(ctx context.Context, d Deps, args []string) error {
+	threadID, err := singleID(args)
+	if err != nil {
+		return err
+	}
+	entries, err := d.Agents.LoadThreadConversation(ctx, threadID)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		printEntry(d.Stdout, entry)
+	}
+	return nil
+}
+
+func runDiffs(ctx context.Context, d Deps, args []string) error {
+	threadID, err := singleID(args)
+	if err != nil {
+		return err
+	}
+	stats, err := d.Agents.ListThreadDiffStats(ctx, threadID)
+	if err != nil {
+		return err
+	}
+	var added, removed int
+	for _, st := range stats {
+		status := st.Status
+		if status == "" {
+			status = "M"
+		}
+		fmt.Fprintf(d.Stdout, "%-2s +%d -%d\t%s\n", status, st.Added, st.Removed, st.Path)
+		added += st.Added
+		removed += st.Removed
+	}
+	fmt.Fprintf(d.Stdout, "%d files changed, +%d -%d\n", len(stats), added, removed)
+	return nil
+}
+
+func runPR(ctx context.Context, d Deps, args []string) error {
+	threadID, err := singleID(args)
+	if err != nil {
+		return err
+	}
+	url, err := d.Agents.CreatePullRequest(ctx, threadID)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(d.Stdout, url)
+	return nil
+}
+
+func runUsage(ctx context.Context, d Deps, args []string) error {
+	fs := flag.NewFlagSet("usage", flag.ContinueOnError)
+	fs.SetOutput(d.Stderr)
+	projectID := fs.Int64("project", 0, "only this project")
+	threadID := fs.Int64("thread", 0, "only this thread")
+	model := fs.String("model", "", "only this model")
+	from := fs.String("from", "", "start date, inclusive (YYYY-MM-DD, YYYY-MM or RFC 3339, UTC)")
+	to := fs.String("to", "", "end date, exclusive")
+	by := fs.String("by", "project,month", "comma-separated grouping: thread, project, model, agent, day, month; empty lists every turn")
+	asCSV := fs.Bool("csv", false, "print CSV")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errUsage
+	}
+	query := agents.UsageQueryDTO{ProjectID: *projectID, ThreadID: *threadID, Model: *model, From: *from, To: *to}
+	for _, key := range strings.Split(*by, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			query.GroupBy = append(query.GroupBy, key)
+		}
+	}
+	if *asCSV || len(query.GroupBy) == 0 {
+		out, err := d.Agents.ExportUsageCSV(ctx, query)
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(d.Stdout, out)
+		return nil
+	}
+	report, err := d.Agents.UsageReport(ctx, query)
+	if err != nil {
+		return err
+	}
+	for _, row := range report.Rows {
+		names := make([]string, len(row.Keys))
+		for i, key := range row.Keys {
+			names[i] = key
+			if row.Labels[i] != "" && row.Labels[i] != key {
+				names[i] = fmt.Sprintf("%s (%s)", key, row.Labels[i])
+			}
+		}
+		fmt.Fprintf(d.Stdout, "%s\t%d turns\tin %d (cached %d)\tout %d\n", strings.Join(names, "\t"), row.Turns, row.InputTokens, row.CachedInputTokens, row.OutputTokens)
+	}
+	t := report.Total
+	fmt.Fprintf(d.Stdout, "total\t%d turns\tin %d (cached %d)\tout %d\n", t.Turns, t.InputTokens, t.CachedInputTokens, t.OutputTokens)
+	return nil
+}
+
+func singleID(args []string) (int64, error) {
+	if len(args) != 1 {
+		return 0, errUsage
+	}
+	id, err := strconv.ParseInt(strings.TrimSpace(args[0]), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid id %q", args[0])
+	}
+	return id, nil
+}
+
+func printEntry(w io.Writer, entry agents.ConversationEntryDTO) {
+	switch entry.Role {
+	case "user":
+		fmt.Fprintf(w, "[%s] user\n%s\n\n", entry.CreatedAt, entry.Text)
+	case "system":
+		fmt.Fprintf(w, "[%s] system (%s): %s\n\n", entry.CreatedAt, entry.Tone, entry.Message)
+	case "agent":
+		if entry.Item == nil {
+			return
+		}
+		fmt.Fprintf(w, "[%s] %s\n", entry.CreatedAt, entry.Item.Type)
+		printItem(w, entry.Item, true)
+		fmt.Fprintln(w)
+	}
+}
+
+// printItem renders an agent item as plain text. Command output is included
+// only when withOutput is set.
+func printItem(w io.Writer, item *agents.AgentItemDTO, withOutput bool) {
+	switch {
+	case item.Command != nil:
+		fmt.Fprintf(w, "$ %s\n", item.Command.Command)
+		if withOutput && strings.TrimSpace(item.Command.AggregatedOutput) != "" {
+			fmt.Fprintln(w, strings.TrimRight(item.Command.AggregatedOutput, "\n"))
+		}
+		if item.Command.ExitCode != nil {
+			fmt.Fprintf(w, "(exit %d)\n", *item.Command.ExitCode)
+		}
+	case len(item.FileDiffs) > 0:
+		for _, change := range item.FileDiffs {
+			fmt.Fprintf(w, "%s %s\n", change.Kind, change.Path)
+		}
+	case item.TodoList != nil:
+		for _, todo := range item.TodoList.Items {
+			mark := " "
+			if todo.Completed {
+				mark = "x"
+			}
+			fmt.Fprintf(w, "[%s] %s\n", mark, todo.Text)
+		}
+	case item.ToolCall != nil:
+		fmt.Fprintf(w, "tool %s/%s (%s)\n", item.ToolCall.Server, item.ToolCall.Tool, item.ToolCall.Status)
+	case item.WebSearch != nil:
+		fmt.Fprintf(w, "search: %s\n", item.WebSearch.Query)
+	case item.Error != nil:
+		fmt.Fprintf(w, "error: %s\n", item.Error.Message)
+	case item.StructuredOutput != nil:
+		if len(item.StructuredOutput.Output) > 0 {
+			fmt.Fprintln(w, string(item.StructuredOutput.Output))
+		}
+		if item.StructuredOutput.Valid {
+			fmt.Fprintln(w, "(matches schema)")
+		}
+		for _, violation := range item.StructuredOutput.Violations {
+			fmt.Fprintf(w, "schema violation: %s\n", violation)
+		}
+	case strings.TrimSpace(item.Reasoning) != "":
+		fmt.Fprintln(w, item.Reasoning)
+	default:
+		fmt.Fprintln(w, item.Text)
+	}
+}
@@ -0,0 +1,237 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"codex-ui/internal/agents"
+	"codex-ui/internal/projects"
+	"codex-ui/internal/storage/discovery"
+	"codex-ui/internal/storage/migrate"
+
+	_ "modernc.org/sqlite"
+)
+
+type echoAdapter struct{}
+
+func (echoAdapter) Stream(ctx context.Context, req agents.MessageRequest) (*agents.StreamResult, error) {
+	events := make(chan agents.StreamEvent, 2)
+	done := make(chan error, 1)
+	events <- agents.StreamEvent{Type: "item.completed", Item: &agents.AgentItemDTO{ID: "item_0", Type: "agent_message", Text: "echo: " + req.Input}}
+	events <- agents.StreamEvent{Type: "turn.completed", Usage: &agents.UsageDTO{InputTokens: 3, OutputTokens: 4}}
+	close(events)
+	done <- nil
+	close(done)
+	return &agents.StreamResult{Events: events, Done: done}, nil
+}
+
+func newTestDeps(t *testing.T) (Deps, *bytes.Buffer, *bytes.Buffer, discovery.Project) {
+	t.Helper()
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", strings.ReplaceAll(t.Name(), "/", "_"))
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatalf("open in-memory database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	if err := migrate.Up(db); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	repo := discovery.NewRepository(db)
+	project, err := repo.UpsertProject(context.Background(), discovery.UpsertProjectParams{Path: "/tmp/cli-project", DisplayName: "CLI"})
+	if err != nil {
+		t.Fatalf("upsert project: %v", err)
+	}
+	svc := agents.NewService("echo", repo, agents.WithoutQueueDispatch())
+	if err := svc.Register("echo", echoAdapter{}); err != nil {
+		t.Fatalf("register adapter: %v", err)
+	}
+	var stdout, stderr bytes.Buffer
+	deps := Deps{
+		Projects: projects.NewService(repo, nil),
+		Agents:   svc,
+		Repo:     repo,
+		Stdout:   &stdout,
+		Stderr:   &stderr,
+	}
+	return deps, &stdout, &stderr, project
+}
+
+func TestRunProjectsAndUsage(t *testing.T) {
+	deps, stdout, stderr, project := newTestDeps(t)
+	ctx := context.Background()
+
+	if code := Run(ctx, []string{"projects"}, deps); code != 0 {
+		t.Fatalf("projects exit %d: %s", code, stderr.String())
+	}
+	if want := fmt.Sprintf("%d\tCLI\t/tmp/cli-project\n", project.ID); stdout.String() != want {
+		t.Fatalf("unexpected projects output %q", stdout.String())
+	}
+	if code := Run(ctx, []string{"bogus"}, deps); code != 2 {
+		t.Fatalf("expected exit 2 for unknown command, got %d", code)
+	}
+	if code := Run(ctx, []string{"show"}, deps); code != 2 {
+		t.Fatalf("expected exit 2 for missing id, got %d", code)
+	}
+}
+
+func TestRunSendThenShow(t *testing.T) {
+	deps, stdout, stderr, project := newTestDeps(t)
+	ctx := context.Background()
+
+	args := []string{"send", "--project", fmt.Sprint(project.ID), "--model", "m", "hello", "there"}
+	if code := Run(ctx, args, deps); code != 0 {
+		t.Fatalf("send exit %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "echo: hello there") {
+		t.Fatalf("expected streamed agent message, got %q", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "usage: in 3 (cached 0) / out 4") {
+		t.Fatalf("expected usage line, got %q", stderr.String())
+	}
+
+	threads, err := deps.Agents.ListThreads(ctx, project.ID, agents.ThreadFilterDTO{})
+	if err != nil || len(threads) != 1 {
+		t.Fatalf("expected one thread, got %v (err %v)", threads, err)
+	}
+	stdout.Reset()
+	if code := Run(ctx, []string{"show", fmt.Sprint(threads[0].ID)}, deps); code != 0 {
+		t.Fatalf("show exit %d: %s", code, stderr.String())
+	}
+	out := stdout.String()
+	if !strings.Contains(out, "user\nhello there") || !strings.Contains(out, "echo: hello there") {
+		t.Fatalf("unexpected conversation output %q", out)
+	}
+}
+
+func TestRunSendQueuesOnBusyThreadAndCancelReachesIt(t *testing.T) {
+	deps, stdout, stderr, project := newTestDeps(t)
+	ctx := context.Background()
+
+	if code := Run(ctx, []string{"send", "--project", fmt.Sprint(project.ID), "--model", "m", "first"}, deps); code != 0 {
+		t.Fatalf("send exit %d: %s", code, stderr.String())
+	}
+	threads, err := deps.Agents.ListThreads(ctx, project.ID, agents.ThreadFilterDTO{})
+	if err != nil || len(threads) != 1 {
+		t.Fatalf("expected one thread, got %v (err %v)", threads, err)
+	}
+	threadID := threads[0].ID
+	if code := Run(ctx, []string{"cancel", fmt.Sprint(threadID)}, deps); code == 0 {
+		t.Fatalf("expected cancel to fail without a running turn")
+	}
+
+	// Another process (the app) runs a turn on the thread.
+	if ok, err := deps.Repo.AcquireThreadLock(ctx, threadID, "app", time.Minute); err != nil || !ok {
+		t.Fatalf("lock thread: %v (err %v)", ok, err)
+	}
+	stderr.Reset()
+	if code := Run(ctx, []string{"send", "--thread", fmt.Sprint(threadID), "second"}, deps); code != 0 {
+		t.Fatalf("send exit %d: %s", code, stderr.String())
+	}
+	queued, err := deps.Agents.ListQueuedTurns(ctx, threadID)
+	if err != nil || len(queued) != 1 {
+		t.Fatalf("expected one queued turn, got %v (err %v)", queued, err)
+	}
+	if want := fmt.Sprintf("queued as #%d", queued[0].ID); !strings.Contains(stderr.String(), want) {
+		t.Fatalf("expected %q, got %q", want, stderr.String())
+	}
+
+	stdout.Reset()
+	if code := Run(ctx, []string{"cancel", fmt.Sprint(threadID)}, deps); code != 0 {
+		t.Fatalf("cancel exit %d: %s", code, stderr.String())
+	}
+	if requested, err := deps.Repo.ThreadCancelRequested(ctx, threadID, "app"); err != nil || !requested {
+		t.Fatalf("expected the app's turn to be asked to stop, got %v (err %v)", requested, err)
+	}
+}
+
+func TestRunUsageReportsMonthlyTotals(t *testing.T) {
+	deps, stdout, stderr, project := newTestDeps(t)
+	ctx := context.Background()
+
+	for _, prompt := range []string{"one", "two"} {
+		if code := Run(ctx, []string{"send", "--project", fmt.Sprint(project.ID), "--model", "m", prompt}, deps); code != 0 {
+			t.Fatalf("send exit %d: %s", code, stderr.String())
+		}
+	}
+	stdout.Reset()
+	if code := Run(ctx, []string{"usage", "--by", "project", "--csv"}, deps); code != 0 {
+		t.Fatalf("usage exit %d: %s", code, stderr.String())
+	}
+	want := fmt.Sprintf("project,project_name,turns,input_tokens,cached_input_tokens,output_tokens\n%d,CLI,2,6,0,8\n", project.ID)
+	if stdout.String() != want {
+		t.Fatalf("unexpected csv %q", stdout.String())
+	}
+	stdout.Reset()
+	if code := Run(ctx, []string{"usage"}, deps); code != 0 {
+		t.Fatalf("usage exit %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "total\t2 turns\tin 6 (cached 0)\tout 8") {
+		t.Fatalf("unexpected report %q", stdout.String())
+	}
+	if code := Run(ctx, []string{"usage", "--by", "week"}, deps); code != 1 {
+		t.Fatalf("expected unknown grouping to fail, got %d", code)
+	}
+}
+
+func TestRunExportThenImport(t *testing.T) {
+	deps, stdout, stderr, project := newTestDeps(t)
+	ctx := context.Background()
+
+	if code := Run(ctx, []string{"send", "--project", fmt.Sprint(project.ID), "--model", "m", "hello"}, deps); code != 0 {
+		t.Fatalf("send exit %d: %s", code, stderr.String())
+	}
+	threads, _ := deps.Agents.ListThreads(ctx, project.ID, agents.ThreadFilterDTO{})
+	stdout.Reset()
+	if code := Run(ctx, []string{"export", "--format", "json", fmt.Sprint(threads[0].ID)}, deps); code != 0 {
+		t.Fatalf("export exit %d: %s", code, stderr.String())
+	}
+	archive := stdout.String()
+
+	stdout.Reset()
+	deps.Stdin = strings.NewReader(archive)
+	if code := Run(ctx, []string{"import", "--project", fmt.Sprint(project.ID), "--read-only", "-"}, deps); code != 0 {
+		t.Fatalf("import exit %d: %s", code, stderr.String())
+	}
+	threads, _ = deps.Agents.ListThreads(ctx, project.ID, agents.ThreadFilterDTO{})
+	if len(threads) != 2 {
+		t.Fatalf("expected the imported thread, got %d threads", len(threads))
+	}
+	stdout.Reset()
+	if code := Run(ctx, []string{"export", fmt.Sprint(threads[0].ID)}, deps); code != 0 {
+		t.Fatalf("export exit %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "echo: hello") {
+		t.Fatalf("expected imported transcript in markdown, got %q", stdout.String())
+	}
+}
+
+func TestRunImportSessions(t *testing.T) {
+	deps, stdout, stderr, project := newTestDeps(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	session := `{"timestamp":"2025-01-02T10:00:00Z","type":"session_meta","payload":{"id":"sess-cli","cwd":"/tmp/cli-project"}}
+{"timestamp":"2025-01-02T10:00:01Z","type":"response_item","payload":{"type":"message","role":"user","content":[{"type":"input_text","text":"Tidy the logs"}]}}
+`
+	if err := os.WriteFile(filepath.Join(dir, "rollout-sess-cli.jsonl"), []byte(session), 0o644); err != nil {
+		t.Fatalf("write session: %v", err)
+	}
+
+	if code := Run(ctx, []string{"import-sessions", "--dir", dir}, deps); code != 0 {
+		t.Fatalf("import-sessions exit %d: %s", code, stderr.String())
+	}
+	threads, _ := deps.Agents.ListThreads(ctx, project.ID, agents.ThreadFilterDTO{})
+	if len(threads) != 1 || threads[0].ExternalID != "sess-cli" || threads[0].Title != "Tidy the logs" {
+		t.Fatalf("expected the imported session, got %+v", threads)
+	}
+	if !strings.Contains(stdout.String(), "Tidy the logs") || !strings.Contains(stderr.String(), "1 imported, 0 already known") {
+		t.Fatalf("unexpected output %q / %q", stdout.String(), stderr.String())
+	}
+}
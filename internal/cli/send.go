@@ -0,0 +1,170 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"codex-ui/internal/agents"
+)
+
+func runSend(ctx context.Context, d Deps, args []string) error {
+	fs := flag.NewFlagSet("send", flag.ContinueOnError)
+	fs.SetOutput(d.Stderr)
+	projectID := fs.Int64("project", 0, "project id for a new thread")
+	threadID := fs.Int64("thread", 0, "existing thread id to continue")
+	model := fs.String("model", "", "model identifier")
+	sandbox := fs.String("sandbox", "", "sandbox mode (read-only, workspace-write, danger-full-access)")
+	reasoning := fs.String("reasoning", "", "reasoning level")
+	agentID := fs.String("agent", "", "agent adapter id")
+	asJSON := fs.Bool("json", false, "print raw stream events as JSON lines")
+	verbose := fs.Bool("v", false, "also print reasoning and command output")
+	schemaFile := fs.String("schema", "", "JSON Schema file the final answer must match")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if (*projectID == 0) == (*threadID == 0) || fs.NArg() == 0 {
+		return errUsage
+	}
+	prompt, err := readPrompt(fs.Args(), d.Stdin)
+	if err != nil {
+		return err
+	}
+
+	req := agents.MessageRequest{
+		AgentID:   *agentID,
+		ProjectID: *projectID,
+		ThreadID:  *threadID,
+		Input:     prompt,
+		ThreadOptions: agents.ThreadOptionsDTO{
+			Model:          *model,
+			SandboxMode:    *sandbox,
+			ReasoningLevel: *reasoning,
+		},
+	}
+
+	if *schemaFile != "" {
+		schema, err := os.ReadFile(*schemaFile)
+		if err != nil {
+			return err
+		}
+		req.TurnOptions = &agents.TurnOptionsDTO{OutputSchema: schema}
+	}
+
+	stream, thread, err := d.Agents.Send(ctx, req)
+	if errors.Is(err, agents.ErrThreadBusy) {
+		// The CLI does not start queued turns; the app runs this one once the
+		// thread's running turn ends.
+		turn, qerr := d.Agents.EnqueueTurn(ctx, req)
+		if qerr != nil {
+			return qerr
+		}
+		fmt.Fprintf(d.Stderr, "thread %d is busy; queued as #%d\n", turn.ThreadID, turn.ID)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer stream.Close()
+	fmt.Fprintf(d.Stderr, "thread %d · stream %s\n", thread.ID, stream.ID())
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-finished:
+		case <-interrupts:
+			if resp, err := d.Agents.Cancel(context.Background(), stream.ID()); err == nil {
+				fmt.Fprintf(d.Stderr, "\nstopped thread %d (%s)\n", resp.ThreadID, resp.Status)
+			}
+		case <-ctx.Done():
+			_, _ = d.Agents.Cancel(context.Background(), stream.ID())
+		}
+	}()
+
+	encoder := json.NewEncoder(d.Stdout)
+	for event := range stream.Events() {
+		if *asJSON {
+			_ = encoder.Encode(event)
+			continue
+		}
+		printEvent(d.Stdout, d.Stderr, event, *verbose)
+	}
+	if err := stream.Wait(); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return nil
+}
+
+func readPrompt(args []string, stdin io.Reader) (string, error) {
+	if len(args) == 1 && args[0] == "-" {
+		if stdin == nil {
+			return "", fmt.Errorf("no stdin available")
+		}
+		raw, err := io.ReadAll(stdin)
+		if err != nil {
+			return "", fmt.Errorf("read prompt from stdin: %w", err)
+		}
+		return strings.TrimSpace(string(raw)), nil
+	}
+	return strings.TrimSpace(strings.Join(args, " ")), nil
+}
+
+func printEvent(stdout, stderr io.Writer, event agents.StreamEvent, verbose bool) {
+	switch event.Type {
+	case "item.started":
+		if event.Item != nil && event.Item.Command != nil {
+			fmt.Fprintf(stdout, "$ %s\n", event.Item.Command.Command)
+		}
+	case "item.completed":
+		item := event.Item
+		if item == nil {
+			return
+		}
+		switch {
+		case item.Command != nil:
+			if verbose && strings.TrimSpace(item.Command.AggregatedOutput) != "" {
+				fmt.Fprintln(stdout, strings.TrimRight(item.Command.AggregatedOutput, "\n"))
+			}
+			if item.Command.ExitCode != nil && *item.Command.ExitCode != 0 {
+				fmt.Fprintf(stdout, "(exit %d)\n", *item.Command.ExitCode)
+			}
+		case strings.TrimSpace(item.Reasoning) != "":
+			if verbose {
+				fmt.Fprintf(stderr, "… %s\n", item.Reasoning)
+			}
+		default:
+			printItem(stdout, item, verbose)
+		}
+	case "turn.completed":
+		if event.Usage != nil {
+			fmt.Fprintf(stderr, "usage: in %d (cached %d) / out %d\n", event.Usage.InputTokens, event.Usage.CachedInputTokens, event.Usage.OutputTokens)
+		}
+	case "turn.failed", "error":
+		message := event.Message
+		if event.Error != nil {
+			message = event.Error.Message
+		}
+		fmt.Fprintf(stderr, "error: %s\n", message)
+	}
+}
+
+func runCancel(ctx context.Context, d Deps, args []string) error {
+	threadID, err := singleID(args)
+	if err != nil {
+		return err
+	}
+	if err := d.Agents.CancelThread(ctx, threadID); err != nil {
+		return err
+	}
+	fmt.Fprintf(d.Stdout, "cancel requested for thread %d\n", threadID)
+	return nil
+}
@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"codex-ui/internal/sessions"
+)
+
+func runImportSessions(ctx context.Context, d Deps, args []string) error {
+	fs := flag.NewFlagSet("import-sessions", flag.ContinueOnError)
+	fs.SetOutput(d.Stderr)
+	dir := fs.String("dir", "", "sessions directory (default $CODEX_UI_SESSIONS_DIR, $CODEX_HOME/sessions or ~/.codex/sessions)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errUsage
+	}
+	report, err := sessions.New(d.Repo, d.Agents, nil).Import(ctx, *dir)
+	if err != nil {
+		return err
+	}
+	for _, thread := range report.Imported {
+		fmt.Fprintf(d.Stdout, "%d\t%d\t%s\n", thread.ID, thread.ProjectID, thread.Title)
+	}
+	for _, path := range report.Unmatched {
+		fmt.Fprintf(d.Stderr, "no project for %s\n", path)
+	}
+	for _, msg := range report.Errors {
+		fmt.Fprintf(d.Stderr, "skipped %s\n", msg)
+	}
+	fmt.Fprintf(d.Stderr, "%d sessions scanned in %s: %d imported, %d already known, %d without project\n",
+		report.Scanned, report.Dir, len(report.Imported), report.Known, len(report.Unmatched))
+	return nil
+}
@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"codex-ui/internal/transcripts"
+)
+
+func runExport(ctx context.Context, d Deps, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.SetOutput(d.Stderr)
+	format := fs.String("format", transcripts.FormatMarkdown, "markdown, html or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	threadID, err := singleID(fs.Args())
+	if err != nil {
+		return err
+	}
+	export, err := transcripts.New(d.Agents).Export(ctx, threadID, *format)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(d.Stdout, export.Content)
+	return nil
+}
+
+func runImport(ctx context.Context, d Deps, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.SetOutput(d.Stderr)
+	projectID := fs.Int64("project", 0, "project to import into")
+	readOnly := fs.Bool("read-only", false, "refuse new turns on the imported thread")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *projectID == 0 || fs.NArg() != 1 {
+		return errUsage
+	}
+	var (
+		data []byte
+		err  error
+	)
+	if path := fs.Arg(0); path == "-" {
+		if d.Stdin == nil {
+			return fmt.Errorf("no stdin available")
+		}
+		data, err = io.ReadAll(d.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return fmt.Errorf("read archive: %w", err)
+	}
+	thread, err := transcripts.New(d.Agents).Import(ctx, *projectID, data, *readOnly)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(d.Stdout, "%d\t%s\n", thread.ID, thread.Title)
+	return nil
+}
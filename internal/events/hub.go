@@ -0,0 +1,103 @@
+// Package events fans runtime events out to the Wails webview and to
+// in-process subscribers such as the local API server.
+package events
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Event is a single emitted runtime event.
+type Event struct {
+	Topic   string `json:"topic"`
+	Payload any    `json:"payload"`
+}
+
+// Hub emits events to the Wails runtime (when its context is available) and to
+// every subscription whose prefixes match the topic.
+type Hub struct {
+	ctxFn func() context.Context
+
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+// NewHub constructs a hub. ctxProvider may be nil or return nil when no Wails
+// runtime is attached, in which case events only reach subscribers.
+func NewHub(ctxProvider func() context.Context) *Hub {
+	return &Hub{ctxFn: ctxProvider, subs: make(map[*Subscription]struct{})}
+}
+
+// Emit publishes payload on topic.
+func (h *Hub) Emit(topic string, payload any) {
+	if h == nil {
+		return
+	}
+	if h.ctxFn != nil {
+		if ctx := h.ctxFn(); ctx != nil {
+			wailsruntime.EventsEmit(ctx, topic, payload)
+		}
+	}
+	evt := Event{Topic: topic, Payload: payload}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for sub := range h.subs {
+		if !sub.matches(topic) {
+			continue
+		}
+		select {
+		case sub.ch <- evt:
+		default:
+			// Slow subscribers drop events instead of stalling emitters.
+		}
+	}
+}
+
+// Subscribe registers a subscription for topics starting with any of the given
+// prefixes. An empty prefix list matches every topic.
+func (h *Hub) Subscribe(prefixes []string, buffer int) *Subscription {
+	if buffer <= 0 {
+		buffer = 256
+	}
+	sub := &Subscription{hub: h, prefixes: prefixes, ch: make(chan Event, buffer)}
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+	return sub
+}
+
+// Subscription receives events matching its prefixes until closed.
+type Subscription struct {
+	hub      *Hub
+	prefixes []string
+	ch       chan Event
+	once     sync.Once
+}
+
+// C yields matching events. It is closed by Close.
+func (s *Subscription) C() <-chan Event { return s.ch }
+
+// Close unregisters the subscription and closes its channel.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.hub.mu.Lock()
+		delete(s.hub.subs, s)
+		close(s.ch)
+		s.hub.mu.Unlock()
+	})
+}
+
+func (s *Subscription) matches(topic string) bool {
+	if len(s.prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range s.prefixes {
+		if strings.HasPrefix(topic, prefix) {
+			return true
+		}
+	}
+	return false
+}
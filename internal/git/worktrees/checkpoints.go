@@ -0,0 +1,126 @@
+package worktrees
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// CheckpointRefPrefix namespaces the hidden refs that keep checkpoint commits
+// reachable. They are not branches or tags, so they stay out of the way of
+// push, fetch and branch listings.
+const CheckpointRefPrefix = "refs/codex/checkpoints/"
+
+// fallbackIdentity signs checkpoint and automatic commits in repos without a
+// configured user.
+var fallbackIdentity = []string{
+	"GIT_AUTHOR_NAME=codex-ui",
+	"GIT_AUTHOR_EMAIL=codex-ui@localhost",
+	"GIT_COMMITTER_NAME=codex-ui",
+	"GIT_COMMITTER_EMAIL=codex-ui@localhost",
+}
+
+// Checkpoint is a snapshot of a worktree: its tracked and untracked files
+// (ignored files excluded) and the commit HEAD pointed to.
+type Checkpoint struct {
+	Ref    string
+	Commit string
+	// Head is empty when the snapshot was taken on an unborn branch.
+	Head string
+}
+
+// CheckpointRef returns the hidden ref for a thread's checkpoint taken before
+// the turn started by entryID.
+func CheckpointRef(threadID, entryID int64) string {
+	return fmt.Sprintf("%sthread-%d/%d", CheckpointRefPrefix, threadID, entryID)
+}
+
+// Snapshot records the worktree's current files as a commit stored under ref.
+// The index, HEAD and working tree are left untouched.
+func (m *Manager) Snapshot(ctx context.Context, worktreePath, ref, message string) (Checkpoint, error) {
+	if strings.TrimSpace(worktreePath) == "" {
+		return Checkpoint{}, fmt.Errorf("worktree path is required")
+	}
+	head := ""
+	if out, err := m.runGit(ctx, worktreePath, "rev-parse", "--verify", "-q", "HEAD"); err == nil {
+		head = strings.TrimSpace(out)
+	}
+
+	index, err := os.CreateTemp("", "codex-checkpoint-*.index")
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("create checkpoint index: %w", err)
+	}
+	indexPath := index.Name()
+	_ = index.Close()
+	// git refuses an empty index file; let it create a fresh one.
+	_ = os.Remove(indexPath)
+	defer os.Remove(indexPath)
+	env := append([]string{"GIT_INDEX_FILE=" + indexPath}, fallbackIdentity...)
+
+	if head != "" {
+		if _, err := m.runGitEnv(ctx, worktreePath, env, "read-tree", head); err != nil {
+			return Checkpoint{}, fmt.Errorf("checkpoint: %w", err)
+		}
+	}
+	if _, err := m.runGitEnv(ctx, worktreePath, env, "add", "-A"); err != nil {
+		return Checkpoint{}, fmt.Errorf("checkpoint: %w", err)
+	}
+	tree, err := m.runGitEnv(ctx, worktreePath, env, "write-tree")
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("checkpoint: %w", err)
+	}
+	args := []string{"commit-tree", strings.TrimSpace(tree), "-m", message}
+	if head != "" {
+		args = append(args, "-p", head)
+	}
+	commit, err := m.runGitEnv(ctx, worktreePath, env, args...)
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("checkpoint: %w", err)
+	}
+	cp := Checkpoint{Ref: ref, Commit: strings.TrimSpace(commit), Head: head}
+	if _, err := m.runGit(ctx, worktreePath, "update-ref", ref, cp.Commit); err != nil {
+		return Checkpoint{}, fmt.Errorf("checkpoint: %w", err)
+	}
+	return cp, nil
+}
+
+// Restore resets the worktree to a checkpoint: HEAD moves back to the
+// checkpoint's HEAD, files created since are removed, and the snapshot's files
+// are written back as uncommitted changes. Ignored files are kept.
+func (m *Manager) Restore(ctx context.Context, worktreePath string, cp Checkpoint) error {
+	if strings.TrimSpace(worktreePath) == "" {
+		return fmt.Errorf("worktree path is required")
+	}
+	if cp.Head == "" {
+		return fmt.Errorf("checkpoint was taken before the first commit and cannot be restored")
+	}
+	steps := [][]string{
+		{"reset", "-q", "--hard", cp.Head},
+		{"clean", "-q", "-fd"},
+		{"read-tree", "--reset", "-u", cp.Commit},
+		{"reset", "-q"},
+	}
+	for _, args := range steps {
+		if _, err := m.runGit(ctx, worktreePath, args...); err != nil {
+			return fmt.Errorf("restore checkpoint: %w", err)
+		}
+	}
+	return nil
+}
+
+// RemoveCheckpoints deletes the hidden refs of a thread's checkpoints. dir is
+// any path inside the repository.
+func (m *Manager) RemoveCheckpoints(ctx context.Context, dir string, threadID int64) error {
+	prefix := fmt.Sprintf("%sthread-%d/", CheckpointRefPrefix, threadID)
+	out, err := m.runGit(ctx, dir, "for-each-ref", "--format=%(refname)", prefix)
+	if err != nil {
+		return err
+	}
+	for _, ref := range strings.Fields(out) {
+		if _, err := m.runGit(ctx, dir, "update-ref", "-d", ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package worktrees
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSnapshotAndRestore(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available in PATH")
+	}
+	dir := t.TempDir()
+	run := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return "<missing>"
+		}
+		return string(data)
+	}
+	run("init", "-q")
+	run("config", "user.email", "you@example.com")
+	run("config", "user.name", "Your Name")
+	write(".gitignore", "*.log\n")
+	write("a.txt", "one\n")
+	run("add", ".")
+	run("commit", "-q", "-m", "init")
+
+	// Uncommitted and untracked work present before the turn.
+	write("a.txt", "one\ntwo\n")
+	write("notes.txt", "draft\n")
+
+	m := NewManager(t.TempDir(), "")
+	ctx := context.Background()
+	ref := CheckpointRef(7, 42)
+	cp, err := m.Snapshot(ctx, dir, ref, "checkpoint")
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	if run("rev-parse", ref) != cp.Commit || cp.Head != run("rev-parse", "HEAD") {
+		t.Fatalf("unexpected checkpoint %+v", cp)
+	}
+	if staged := run("diff", "--cached", "--name-only"); staged != "" {
+		t.Fatalf("snapshot must not touch the index, got %q staged", staged)
+	}
+
+	// The turn edits, deletes, creates and commits.
+	write("a.txt", "rewritten\n")
+	write("b.txt", "new\n")
+	write("debug.log", "ignored\n")
+	run("add", "a.txt", "b.txt")
+	run("commit", "-q", "-m", "agent work")
+	write("c.txt", "scratch\n")
+	os.Remove(filepath.Join(dir, "notes.txt"))
+
+	if err := m.Restore(ctx, dir, cp); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if run("rev-parse", "HEAD") != cp.Head {
+		t.Fatal("expected HEAD to move back to the checkpoint")
+	}
+	for name, want := range map[string]string{
+		"a.txt": "one\ntwo\n", "notes.txt": "draft\n", "b.txt": "<missing>", "c.txt": "<missing>", "debug.log": "ignored\n",
+	} {
+		if got := read(name); got != want {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+	if staged, untracked := run("diff", "--cached", "--name-only"), run("ls-files", "--others", "--exclude-standard"); staged != "" || untracked != "notes.txt" {
+		t.Fatalf("expected restored changes to be uncommitted, got staged %q untracked %q", staged, untracked)
+	}
+
+	if err := m.RemoveCheckpoints(ctx, dir, 7); err != nil {
+		t.Fatalf("remove checkpoints: %v", err)
+	}
+	if refs := run("for-each-ref", CheckpointRefPrefix); refs != "" {
+		t.Fatalf("expected checkpoint refs to be removed, got %q", refs)
+	}
+}
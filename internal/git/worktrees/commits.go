@@ -0,0 +1,38 @@
+package worktrees
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// CommitAll stages every change in the worktree, ignored files excluded, and
+// commits it on the current branch. It returns an empty hash when there is
+// nothing to commit. The repository's user is used when configured.
+func (m *Manager) CommitAll(ctx context.Context, worktreePath, message string) (string, error) {
+	if strings.TrimSpace(worktreePath) == "" {
+		return "", fmt.Errorf("worktree path is required")
+	}
+	if _, err := m.runGit(ctx, worktreePath, "add", "-A"); err != nil {
+		return "", fmt.Errorf("commit worktree: %w", err)
+	}
+	staged, err := m.runGit(ctx, worktreePath, "diff", "--cached", "--name-only")
+	if err != nil {
+		return "", fmt.Errorf("commit worktree: %w", err)
+	}
+	if strings.TrimSpace(staged) == "" {
+		return "", nil
+	}
+	var env []string
+	if email, err := m.runGit(ctx, worktreePath, "config", "user.email"); err != nil || strings.TrimSpace(email) == "" {
+		env = fallbackIdentity
+	}
+	if _, err := m.runGitEnv(ctx, worktreePath, env, "commit", "-q", "-m", message); err != nil {
+		return "", fmt.Errorf("commit worktree: %w", err)
+	}
+	head, err := m.runGit(ctx, worktreePath, "rev-parse", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("commit worktree: %w", err)
+	}
+	return strings.TrimSpace(head), nil
+}
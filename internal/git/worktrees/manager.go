@@ -3,6 +3,7 @@ package worktrees
 import (
     "bytes"
     "context"
+    "errors"
     "fmt"
     "os"
     "os/exec"
@@ -38,6 +39,13 @@ func (m *Manager) Root() string { return m.root }
 // Returns the absolute worktree path, the working directory inside the worktree
 // (accounting for subdirectory projects), and the repository root.
 func (m *Manager) EnsureForThread(ctx context.Context, projectPath string, threadID int64, nameHint string, branchName string) (string, string, string, error) {
+	return m.EnsureForThreadFrom(ctx, projectPath, threadID, nameHint, branchName, "")
+}
+
+// EnsureForThreadFrom behaves like EnsureForThread but creates a new worktree
+// branch from baseRef instead of the project's current ref. An empty baseRef
+// falls back to the project's current ref. Existing worktrees are reused as-is.
+func (m *Manager) EnsureForThreadFrom(ctx context.Context, projectPath string, threadID int64, nameHint string, branchName string, baseRef string) (string, string, string, error) {
 	if strings.TrimSpace(projectPath) == "" {
 		return "", "", "", fmt.Errorf("project path is required")
 	}
@@ -74,12 +82,12 @@ func (m *Manager) EnsureForThread(ctx context.Context, projectPath string, threa
             // reuse existing
         } else {
             // try force re-add on top of existing content
-            if err := m.addWorktree(ctx, repoRoot, worktreePath, projectPath, threadID, branchName, true); err != nil {
+            if err := m.addWorktree(ctx, repoRoot, worktreePath, projectPath, threadID, branchName, baseRef, true); err != nil {
                 return "", "", "", err
 			}
 		}
 	} else {
-		if err := m.addWorktree(ctx, repoRoot, worktreePath, projectPath, threadID, branchName, false); err != nil {
+		if err := m.addWorktree(ctx, repoRoot, worktreePath, projectPath, threadID, branchName, baseRef, false); err != nil {
 			return "", "", "", err
 		}
 	}
@@ -120,10 +128,33 @@ func (m *Manager) RemoveForThread(ctx context.Context, worktreePath string) erro
 	return nil
 }
 
-func (m *Manager) addWorktree(ctx context.Context, repoRoot, worktreePath, projectPath string, threadID int64, branchName string, force bool) error {
-    baseRef, err := m.git.CurrentRef(ctx, projectPath)
-    if err != nil {
-        return err
+// BranchExists reports whether the repository of projectPath has a local
+// branch with the given name.
+func (m *Manager) BranchExists(ctx context.Context, projectPath, branch string) (bool, error) {
+	repoRoot, err := m.git.RepoRoot(ctx, projectPath)
+	if err != nil {
+		return false, fmt.Errorf("project not a git repo: %w", err)
+	}
+	cmd := exec.CommandContext(ctx, m.gitBin, "show-ref", "--verify", "--quiet", "refs/heads/"+strings.TrimSpace(branch))
+	cmd.Dir = repoRoot
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return false, nil
+		}
+		return false, fmt.Errorf("check branch %s: %w", branch, err)
+	}
+	return true, nil
+}
+
+func (m *Manager) addWorktree(ctx context.Context, repoRoot, worktreePath, projectPath string, threadID int64, branchName string, baseRef string, force bool) error {
+    baseRef = strings.TrimSpace(baseRef)
+    if baseRef == "" {
+        current, err := m.git.CurrentRef(ctx, projectPath)
+        if err != nil {
+            return err
+        }
+        baseRef = current
     }
 	branch := strings.TrimSpace(branchName)
 	if branch == "" {
@@ -143,10 +174,18 @@ func (m *Manager) addWorktree(ctx context.Context, repoRoot, worktreePath, proje
 // currentBranchOrHead/isGitDir/gitShowTopLevel now delegated via m.git
 
 func (m *Manager) runGit(ctx context.Context, dir string, args ...string) (string, error) {
+	return m.runGitEnv(ctx, dir, nil, args...)
+}
+
+// runGitEnv runs git with extra environment variables such as GIT_INDEX_FILE.
+func (m *Manager) runGitEnv(ctx context.Context, dir string, env []string, args ...string) (string, error) {
 	cmd := exec.CommandContext(ctx, m.gitBin, args...)
 	if dir != "" {
 		cmd.Dir = dir
 	}
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
@@ -0,0 +1,573 @@
+// Package jsonschema validates JSON documents against the subset of JSON
+// Schema used for structured model outputs: types, enums and constants,
+// object properties, arrays, string and number bounds, the anyOf/oneOf/allOf/
+// not combinators and local $ref pointers. Patterns use ECMA-262 syntax, as
+// the specification requires. Compile rejects schemas with keywords outside
+// this subset, so no constraint is silently skipped; annotations such as
+// title, description and format are allowed and ignored.
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dlclark/regexp2"
+)
+
+// Schema is a compiled JSON Schema.
+type Schema struct {
+	root     any
+	patterns map[string]*regexp2.Regexp
+}
+
+// Violation is one way a document fails its schema. Path is a JSON pointer to
+// the offending value ("" for the document itself).
+type Violation struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (v Violation) String() string {
+	if v.Path == "" {
+		return v.Message
+	}
+	return v.Path + ": " + v.Message
+}
+
+// ValidationError lists the violations of a document.
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		parts[i] = v.String()
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Compile parses a schema document and checks that it only uses supported
+// keywords and that its patterns and $refs resolve.
+func Compile(raw []byte) (*Schema, error) {
+	var root any
+	if err := json.Unmarshal(raw, &root); err != nil {
+		return nil, fmt.Errorf("parse schema: %w", err)
+	}
+	s := &Schema{root: root, patterns: make(map[string]*regexp2.Regexp)}
+	if err := s.check(root, "#"); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// check walks every subschema to compile patterns and resolve references.
+func (s *Schema) check(node any, at string) error {
+	switch n := node.(type) {
+	case bool:
+		return nil
+	case map[string]any:
+		if p, ok := n["pattern"].(string); ok {
+			if err := s.compilePattern(p); err != nil {
+				return fmt.Errorf("%s: %w", at, err)
+			}
+		}
+		if ref, ok := n["$ref"].(string); ok {
+			if _, err := s.resolve(ref); err != nil {
+				return fmt.Errorf("%s: %w", at, err)
+			}
+		}
+		keys := make([]string, 0, len(n))
+		for key := range n {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if !supportedKeywords[key] {
+				return fmt.Errorf("%s: unsupported keyword %q", at, key)
+			}
+		}
+		for _, key := range keys {
+			switch key {
+			case "enum", "const", "required", "type", "default", "examples":
+				continue
+			}
+			switch child := n[key].(type) {
+			case map[string]any:
+				if key == "properties" || key == "patternProperties" || key == "$defs" || key == "definitions" {
+					names := make([]string, 0, len(child))
+					for name := range child {
+						names = append(names, name)
+					}
+					sort.Strings(names)
+					for _, name := range names {
+						if key == "patternProperties" {
+							if err := s.compilePattern(name); err != nil {
+								return fmt.Errorf("%s: %w", at, err)
+							}
+						}
+						if err := s.check(child[name], at+"/"+key+"/"+name); err != nil {
+							return err
+						}
+					}
+					continue
+				}
+				if err := s.check(child, at+"/"+key); err != nil {
+					return err
+				}
+			case []any:
+				for i, item := range child {
+					if err := s.check(item, at+"/"+key+"/"+strconv.Itoa(i)); err != nil {
+						return err
+					}
+				}
+			case bool:
+			default:
+				if subschemaKeywords[key] {
+					return fmt.Errorf("%s/%s: schema must be an object or boolean", at, key)
+				}
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("%s: schema must be an object or boolean", at)
+	}
+}
+
+// subschemaKeywords take a single schema as their value.
+var subschemaKeywords = map[string]bool{
+	"items": true, "additionalProperties": true, "not": true,
+}
+
+// supportedKeywords are the keywords Validate checks, plus annotations that
+// do not constrain the document.
+var supportedKeywords = map[string]bool{
+	"type": true, "enum": true, "const": true,
+	"properties": true, "patternProperties": true, "additionalProperties": true,
+	"required": true, "minProperties": true, "maxProperties": true,
+	"items": true, "prefixItems": true, "minItems": true, "maxItems": true, "uniqueItems": true,
+	"minLength": true, "maxLength": true, "pattern": true,
+	"minimum": true, "maximum": true, "exclusiveMinimum": true, "exclusiveMaximum": true, "multipleOf": true,
+	"allOf": true, "anyOf": true, "oneOf": true, "not": true,
+	"$ref": true, "$defs": true, "definitions": true,
+	"$schema": true, "$id": true, "$comment": true, "title": true, "description": true,
+	"default": true, "examples": true, "format": true, "deprecated": true, "readOnly": true, "writeOnly": true,
+}
+
+// patternTimeout bounds a single pattern match; ECMA-262 patterns are matched
+// by backtracking.
+const patternTimeout = time.Second
+
+func (s *Schema) compilePattern(p string) error {
+	if _, ok := s.patterns[p]; ok {
+		return nil
+	}
+	re, err := regexp2.Compile(p, regexp2.ECMAScript)
+	if err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", p, err)
+	}
+	re.MatchTimeout = patternTimeout
+	s.patterns[p] = re
+	return nil
+}
+
+// matches reports whether value matches the compiled pattern p. A match that
+// times out counts as a mismatch.
+func (s *Schema) matches(p, value string) bool {
+	re := s.patterns[p]
+	if re == nil {
+		return true
+	}
+	ok, err := re.MatchString(value)
+	return err == nil && ok
+}
+
+// resolve follows a local "#/..." JSON pointer.
+func (s *Schema) resolve(ref string) (any, error) {
+	if ref == "#" {
+		return s.root, nil
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("unsupported $ref %q: only local references are supported", ref)
+	}
+	node := s.root
+	for _, token := range strings.Split(ref[2:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		obj, ok := node.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unresolved $ref %q", ref)
+		}
+		if node, ok = obj[token]; !ok {
+			return nil, fmt.Errorf("unresolved $ref %q", ref)
+		}
+	}
+	return node, nil
+}
+
+// ValidateJSON parses doc and validates it. It returns a *ValidationError when
+// the document does not match the schema.
+func (s *Schema) ValidateJSON(doc []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return fmt.Errorf("parse document: %w", err)
+	}
+	if dec.More() {
+		return errors.New("parse document: unexpected data after the JSON value")
+	}
+	return s.Validate(value)
+}
+
+// Validate checks a decoded document (numbers as json.Number or float64).
+func (s *Schema) Validate(value any) error {
+	var violations []Violation
+	s.validate(s.root, value, "", &violations, 0)
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+// maxDepth stops runaway recursion through self-referencing schemas.
+const maxDepth = 64
+
+func (s *Schema) validate(node, value any, path string, out *[]Violation, depth int) {
+	fail := func(format string, args ...any) {
+		*out = append(*out, Violation{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	if depth > maxDepth {
+		fail("schema nesting is too deep")
+		return
+	}
+	schema, ok := node.(map[string]any)
+	if !ok {
+		if b, isBool := node.(bool); isBool && !b {
+			fail("no value is allowed here")
+		}
+		return
+	}
+
+	if ref, ok := schema["$ref"].(string); ok {
+		if target, err := s.resolve(ref); err == nil {
+			s.validate(target, value, path, out, depth+1)
+		}
+	}
+	if types, ok := schema["type"]; ok && !matchesType(types, value) {
+		fail("expected %s, got %s", describeTypes(types), typeName(value))
+		return
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, option := range enum {
+			if equal(option, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("must be one of %s", compact(enum))
+		}
+	}
+	if c, ok := schema["const"]; ok && !equal(c, value) {
+		fail("must equal %s", compact(c))
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		s.validateObject(schema, v, path, out, depth)
+	case []any:
+		s.validateArray(schema, v, path, out, depth)
+	case string:
+		length := utf8.RuneCountInString(v)
+		if n, ok := number(schema["minLength"]); ok && float64(length) < n {
+			fail("must be at least %s characters", formatNumber(n))
+		}
+		if n, ok := number(schema["maxLength"]); ok && float64(length) > n {
+			fail("must be at most %s characters", formatNumber(n))
+		}
+		if p, ok := schema["pattern"].(string); ok {
+			if !s.matches(p, v) {
+				fail("must match pattern %q", p)
+			}
+		}
+	default:
+		if n, ok := number(value); ok {
+			validateNumber(schema, n, fail)
+		}
+	}
+
+	for _, key := range []string{"allOf", "anyOf", "oneOf"} {
+		branches, ok := schema[key].([]any)
+		if !ok {
+			continue
+		}
+		matched := 0
+		var first []Violation
+		for i, branch := range branches {
+			var sub []Violation
+			s.validate(branch, value, path, &sub, depth+1)
+			if len(sub) == 0 {
+				matched++
+			} else if key == "allOf" {
+				*out = append(*out, sub...)
+			} else if i == 0 {
+				first = sub
+			}
+		}
+		switch {
+		case key == "anyOf" && matched == 0:
+			fail("must match at least one schema in anyOf")
+			if len(branches) == 1 {
+				*out = append(*out, first...)
+			}
+		case key == "oneOf" && matched != 1:
+			fail("must match exactly one schema in oneOf, matched %d", matched)
+		}
+	}
+	if not, ok := schema["not"]; ok {
+		var sub []Violation
+		s.validate(not, value, path, &sub, depth+1)
+		if len(sub) == 0 {
+			fail("must not match the schema in not")
+		}
+	}
+}
+
+func (s *Schema) validateObject(schema map[string]any, obj map[string]any, path string, out *[]Violation, depth int) {
+	fail := func(format string, args ...any) {
+		*out = append(*out, Violation{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	if required, ok := schema["required"].([]any); ok {
+		for _, name := range required {
+			if key, ok := name.(string); ok {
+				if _, present := obj[key]; !present {
+					fail("missing required property %q", key)
+				}
+			}
+		}
+	}
+	if n, ok := number(schema["minProperties"]); ok && float64(len(obj)) < n {
+		fail("must have at least %s properties", formatNumber(n))
+	}
+	if n, ok := number(schema["maxProperties"]); ok && float64(len(obj)) > n {
+		fail("must have at most %s properties", formatNumber(n))
+	}
+	properties, _ := schema["properties"].(map[string]any)
+	patternProperties, _ := schema["patternProperties"].(map[string]any)
+	additional, hasAdditional := schema["additionalProperties"]
+
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		childPath := path + "/" + escapePointer(key)
+		known := false
+		if prop, ok := properties[key]; ok {
+			known = true
+			s.validate(prop, obj[key], childPath, out, depth+1)
+		}
+		for pattern, prop := range patternProperties {
+			if s.patterns[pattern] != nil && s.matches(pattern, key) {
+				known = true
+				s.validate(prop, obj[key], childPath, out, depth+1)
+			}
+		}
+		if known || !hasAdditional {
+			continue
+		}
+		if allowed, ok := additional.(bool); ok {
+			if !allowed {
+				*out = append(*out, Violation{Path: childPath, Message: "property is not allowed"})
+			}
+			continue
+		}
+		s.validate(additional, obj[key], childPath, out, depth+1)
+	}
+}
+
+func (s *Schema) validateArray(schema map[string]any, arr []any, path string, out *[]Violation, depth int) {
+	fail := func(format string, args ...any) {
+		*out = append(*out, Violation{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	if n, ok := number(schema["minItems"]); ok && float64(len(arr)) < n {
+		fail("must have at least %s items", formatNumber(n))
+	}
+	if n, ok := number(schema["maxItems"]); ok && float64(len(arr)) > n {
+		fail("must have at most %s items", formatNumber(n))
+	}
+	if unique, _ := schema["uniqueItems"].(bool); unique {
+		for i := range arr {
+			for j := i + 1; j < len(arr); j++ {
+				if equal(arr[i], arr[j]) {
+					fail("items %d and %d are equal", i, j)
+				}
+			}
+		}
+	}
+	prefix, _ := schema["prefixItems"].([]any)
+	for i, item := range arr {
+		childPath := path + "/" + strconv.Itoa(i)
+		if i < len(prefix) {
+			s.validate(prefix[i], item, childPath, out, depth+1)
+		} else if items, ok := schema["items"]; ok {
+			s.validate(items, item, childPath, out, depth+1)
+		}
+	}
+}
+
+func validateNumber(schema map[string]any, n float64, fail func(string, ...any)) {
+	if min, ok := number(schema["minimum"]); ok && n < min {
+		fail("must be at least %s", formatNumber(min))
+	}
+	if max, ok := number(schema["maximum"]); ok && n > max {
+		fail("must be at most %s", formatNumber(max))
+	}
+	if min, ok := number(schema["exclusiveMinimum"]); ok && n <= min {
+		fail("must be greater than %s", formatNumber(min))
+	}
+	if max, ok := number(schema["exclusiveMaximum"]); ok && n >= max {
+		fail("must be less than %s", formatNumber(max))
+	}
+	if step, ok := number(schema["multipleOf"]); ok && step > 0 {
+		if q := n / step; math.Abs(q-math.Round(q)) > 1e-9 {
+			fail("must be a multiple of %s", formatNumber(step))
+		}
+	}
+}
+
+func matchesType(types, value any) bool {
+	switch t := types.(type) {
+	case string:
+		return isType(t, value)
+	case []any:
+		for _, item := range t {
+			if name, ok := item.(string); ok && isType(name, value) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func isType(name string, value any) bool {
+	switch name {
+	case "integer":
+		n, ok := number(value)
+		return ok && n == math.Trunc(n)
+	case "number":
+		_, ok := number(value)
+		return ok
+	}
+	return typeName(value) == name
+}
+
+func typeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	if _, ok := number(value); ok {
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func describeTypes(types any) string {
+	switch t := types.(type) {
+	case string:
+		return t
+	case []any:
+		names := make([]string, 0, len(t))
+		for _, item := range t {
+			names = append(names, fmt.Sprint(item))
+		}
+		return strings.Join(names, " or ")
+	}
+	return compact(types)
+}
+
+func number(value any) (float64, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+// equal compares two decoded JSON values, treating numbers by value.
+func equal(a, b any) bool {
+	if x, ok := number(a); ok {
+		y, ok := number(b)
+		return ok && x == y
+	}
+	switch x := a.(type) {
+	case []any:
+		y, ok := b.([]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		y, ok := b.(map[string]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for key, value := range x {
+			other, present := y[key]
+			if !present || !equal(value, other) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func compact(value any) string {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(raw)
+}
+
+func escapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
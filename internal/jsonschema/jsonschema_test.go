@@ -0,0 +1,105 @@
+package jsonschema
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const migrationPlanSchema = `{
+  "type": "object",
+  "additionalProperties": false,
+  "required": ["steps", "risk"],
+  "properties": {
+    "risk": {"enum": ["low", "medium", "high"]},
+    "steps": {
+      "type": "array",
+      "minItems": 1,
+      "items": {"$ref": "#/$defs/step"}
+    },
+    "notes": {"type": ["string", "null"], "maxLength": 20}
+  },
+  "$defs": {
+    "step": {
+      "type": "object",
+      "required": ["table", "order"],
+      "properties": {
+        "table": {"type": "string", "pattern": "^[a-z_]+$"},
+        "order": {"type": "integer", "minimum": 1}
+      }
+    }
+  }
+}`
+
+func TestValidateJSON(t *testing.T) {
+	schema, err := Compile([]byte(migrationPlanSchema))
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	if err := schema.ValidateJSON([]byte(`{"risk":"low","steps":[{"table":"users","order":1}],"notes":null}`)); err != nil {
+		t.Fatalf("expected a valid document, got %v", err)
+	}
+
+	err = schema.ValidateJSON([]byte(`{"risk":"extreme","steps":[{"table":"Users","order":1.5},{"order":0}],"extra":true}`))
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	want := []string{
+		`/extra: property is not allowed`,
+		`/risk: must be one of ["low","medium","high"]`,
+		`/steps/0/order: expected integer, got number`,
+		`/steps/0/table: must match pattern "^[a-z_]+$"`,
+		`/steps/1: missing required property "table"`,
+		`/steps/1/order: must be at least 1`,
+	}
+	if len(invalid.Violations) != len(want) {
+		t.Fatalf("expected %d violations, got %v", len(want), invalid)
+	}
+	for i, v := range invalid.Violations {
+		if v.String() != want[i] {
+			t.Errorf("violation %d = %q, want %q", i, v.String(), want[i])
+		}
+	}
+
+	if err := schema.ValidateJSON([]byte(`{"risk":"low"`)); err == nil || errors.As(err, &invalid) {
+		t.Fatalf("expected a parse error, got %v", err)
+	}
+}
+
+func TestCompileRejectsBadSchemas(t *testing.T) {
+	for _, raw := range []string{`[`, `{"pattern":"("}`, `{"$ref":"#/$defs/missing"}`, `{"$ref":"https://example.com/s.json"}`, `{"items":3}`, `{"if":{"type":"string"},"then":{"minLength":2}}`, `{"properties":{"tags":{"contains":{"const":"x"}}}}`} {
+		if _, err := Compile([]byte(raw)); err == nil {
+			t.Errorf("expected %s to be rejected", raw)
+		}
+	}
+}
+
+func TestCombinators(t *testing.T) {
+	schema, err := Compile([]byte(`{"oneOf":[{"type":"integer"},{"type":"number","multipleOf":0.5}],"not":{"const":3}}`))
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	if err := schema.ValidateJSON([]byte(`2.5`)); err != nil {
+		t.Fatalf("expected 2.5 to match one branch, got %v", err)
+	}
+	if err := schema.ValidateJSON([]byte(`2`)); err == nil || !strings.Contains(err.Error(), "exactly one") {
+		t.Fatalf("expected 2 to match both branches, got %v", err)
+	}
+	if err := schema.ValidateJSON([]byte(`3`)); err == nil || !strings.Contains(err.Error(), "must not match") {
+		t.Fatalf("expected 3 to be excluded, got %v", err)
+	}
+}
+
+func TestPatternsUseECMAScriptSyntax(t *testing.T) {
+	schema, err := Compile([]byte(`{"type":"string","pattern":"^(?=.*\\d)[a-z\\d]{4,}$","title":"Password","format":"password"}`))
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	if err := schema.ValidateJSON([]byte(`"abc1"`)); err != nil {
+		t.Fatalf("expected the lookahead to match, got %v", err)
+	}
+	if err := schema.ValidateJSON([]byte(`"abcd"`)); err == nil || !strings.Contains(err.Error(), "must match pattern") {
+		t.Fatalf("expected a string without digits to fail, got %v", err)
+	}
+}
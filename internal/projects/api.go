@@ -22,3 +22,13 @@ func (a *API) RegisterProject(req RegisterProjectRequest) (ProjectDTO, error) {
 }
 func (a *API) DeleteProject(id int64) error { return a.svc.Remove(context.Background(), id) }
 func (a *API) MarkProjectOpened(id int64) error { return a.svc.MarkOpened(context.Background(), id) }
+
+// GetProjectSettings returns a project's thread defaults and standing instructions.
+func (a *API) GetProjectSettings(id int64) (SettingsDTO, error) {
+    return a.svc.GetSettings(context.Background(), id)
+}
+
+// UpdateProjectSettings replaces a project's thread defaults and standing instructions.
+func (a *API) UpdateProjectSettings(settings SettingsDTO) (SettingsDTO, error) {
+    return a.svc.UpdateSettings(context.Background(), settings)
+}
@@ -5,6 +5,7 @@ import (
     "database/sql"
     "errors"
     "fmt"
+    "strings"
     "time"
 
     "codex-ui/internal/logging"
@@ -12,23 +13,56 @@ import (
 )
 
 type Service struct {
-    repo   *discovery.Repository
-    logger logging.Logger
+    repo          *discovery.Repository
+    logger        logging.Logger
+    modelDefaults ModelDefaultsSetter
 }
 
-func NewService(repo *discovery.Repository, logger logging.Logger) *Service {
+// ModelDefaultsSetter validates and stores a project's thread defaults.
+type ModelDefaultsSetter func(ctx context.Context, projectID int64, model, reasoningLevel, sandboxMode string) error
+
+// ServiceOption configures optional Service dependencies.
+type ServiceOption func(*Service)
+
+// WithModelDefaults makes UpdateSettings store thread defaults through fn,
+// e.g. the agent service, which checks them against its model catalog.
+func WithModelDefaults(fn ModelDefaultsSetter) ServiceOption {
+	return func(s *Service) { s.modelDefaults = fn }
+}
+
+func NewService(repo *discovery.Repository, logger logging.Logger, opts ...ServiceOption) *Service {
     if logger == nil { logger = logging.Nop() }
-    return &Service{repo: repo, logger: logger}
+    svc := &Service{repo: repo, logger: logger}
+    for _, opt := range opts {
+        if opt != nil {
+            opt(svc)
+        }
+    }
+    return svc
 }
 
 type ProjectDTO struct {
-	ID           int64     `json:"id"`
-	Path         string    `json:"path"`
-	DisplayName  string    `json:"displayName,omitempty"`
-	Tags         []string  `json:"tags,omitempty"`
-	LastOpenedAt time.Time `json:"lastOpenedAt,omitempty" ts_type:"string"`
-	CreatedAt    time.Time `json:"createdAt" ts_type:"string"`
-	UpdatedAt    time.Time `json:"updatedAt" ts_type:"string"`
+	ID           int64       `json:"id"`
+	Path         string      `json:"path"`
+	DisplayName  string      `json:"displayName,omitempty"`
+	Tags         []string    `json:"tags,omitempty"`
+	LastOpenedAt time.Time   `json:"lastOpenedAt,omitempty" ts_type:"string"`
+	CreatedAt    time.Time   `json:"createdAt" ts_type:"string"`
+	UpdatedAt    time.Time   `json:"updatedAt" ts_type:"string"`
+	Settings     SettingsDTO `json:"settings"`
+}
+
+// SettingsDTO holds the defaults new threads of a project start from and the
+// standing instructions prepended to their first turn. Empty fields fall back
+// to the model catalog defaults. AutoCommit commits each thread's worktree
+// after every completed turn.
+type SettingsDTO struct {
+	ProjectID      int64  `json:"projectId"`
+	Model          string `json:"model,omitempty"`
+	ReasoningLevel string `json:"reasoningLevel,omitempty"`
+	SandboxMode    string `json:"sandboxMode,omitempty"`
+	Instructions   string `json:"instructions,omitempty"`
+	AutoCommit     bool   `json:"autoCommit,omitempty"`
 }
 
 type RegisterProjectRequest struct {
@@ -76,6 +110,48 @@ func (s *Service) Remove(ctx context.Context, id int64) error {
 	return nil
 }
 
+// GetSettings returns a project's thread defaults and standing instructions.
+func (s *Service) GetSettings(ctx context.Context, id int64) (SettingsDTO, error) {
+	project, err := s.repo.GetProjectByID(ctx, id)
+	if err != nil {
+		return SettingsDTO{}, err
+	}
+	return mapSettings(project.Settings), nil
+}
+
+// UpdateSettings replaces a project's thread defaults and standing instructions.
+func (s *Service) UpdateSettings(ctx context.Context, settings SettingsDTO) (SettingsDTO, error) {
+	if settings.ProjectID == 0 {
+		return SettingsDTO{}, errors.New("projectId is required")
+	}
+	if _, err := s.repo.GetProjectByID(ctx, settings.ProjectID); err != nil {
+		return SettingsDTO{}, err
+	}
+	model := strings.TrimSpace(settings.Model)
+	reasoningLevel := strings.TrimSpace(settings.ReasoningLevel)
+	sandboxMode := strings.TrimSpace(settings.SandboxMode)
+	if s.modelDefaults != nil {
+		if err := s.modelDefaults(ctx, settings.ProjectID, model, reasoningLevel, sandboxMode); err != nil {
+			return SettingsDTO{}, err
+		}
+	}
+	record, err := s.repo.GetProjectSettings(ctx, settings.ProjectID)
+	if err != nil {
+		return SettingsDTO{}, err
+	}
+	if s.modelDefaults == nil {
+		record.DefaultModel = model
+		record.DefaultReasoningLevel = reasoningLevel
+		record.DefaultSandboxMode = sandboxMode
+	}
+	record.Instructions = strings.TrimSpace(settings.Instructions)
+	record.AutoCommit = settings.AutoCommit
+	if err := s.repo.SaveProjectSettings(ctx, record); err != nil {
+		return SettingsDTO{}, err
+	}
+	return mapSettings(record), nil
+}
+
 func (s *Service) MarkOpened(ctx context.Context, id int64) error {
 	return s.repo.MarkProjectOpened(ctx, id, time.Now().UTC())
 }
@@ -89,5 +165,17 @@ func mapProject(p discovery.Project) ProjectDTO {
 		LastOpenedAt: p.LastOpenedAt,
 		CreatedAt:    p.CreatedAt,
 		UpdatedAt:    p.UpdatedAt,
+		Settings:     mapSettings(p.Settings),
+	}
+}
+
+func mapSettings(settings discovery.ProjectSettings) SettingsDTO {
+	return SettingsDTO{
+		ProjectID:      settings.ProjectID,
+		Model:          settings.DefaultModel,
+		ReasoningLevel: settings.DefaultReasoningLevel,
+		SandboxMode:    settings.DefaultSandboxMode,
+		Instructions:   settings.Instructions,
+		AutoCommit:     settings.AutoCommit,
 	}
 }
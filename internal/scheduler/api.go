@@ -0,0 +1,64 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+)
+
+// API exposes scheduled jobs to the frontend via Wails binding.
+type API struct {
+	s *Scheduler
+}
+
+func NewAPI(s *Scheduler) *API { return &API{s: s} }
+
+func (a *API) ListJobs(projectID int64) ([]JobDTO, error) {
+	if a.s == nil {
+		return nil, fmt.Errorf("scheduler not initialised")
+	}
+	return a.s.ListJobs(context.Background(), projectID)
+}
+
+func (a *API) CreateJob(req SaveJobRequest) (JobDTO, error) {
+	if a.s == nil {
+		return JobDTO{}, fmt.Errorf("scheduler not initialised")
+	}
+	return a.s.CreateJob(context.Background(), req)
+}
+
+func (a *API) UpdateJob(id int64, req SaveJobRequest) (JobDTO, error) {
+	if a.s == nil {
+		return JobDTO{}, fmt.Errorf("scheduler not initialised")
+	}
+	return a.s.UpdateJob(context.Background(), id, req)
+}
+
+func (a *API) SetJobEnabled(id int64, enabled bool) (JobDTO, error) {
+	if a.s == nil {
+		return JobDTO{}, fmt.Errorf("scheduler not initialised")
+	}
+	return a.s.SetJobEnabled(context.Background(), id, enabled)
+}
+
+func (a *API) DeleteJob(id int64) error {
+	if a.s == nil {
+		return fmt.Errorf("scheduler not initialised")
+	}
+	return a.s.DeleteJob(context.Background(), id)
+}
+
+// RunJobNow fires a job immediately; the run's thread streams like any other
+// background turn.
+func (a *API) RunJobNow(id int64) (RunDTO, error) {
+	if a.s == nil {
+		return RunDTO{}, fmt.Errorf("scheduler not initialised")
+	}
+	return a.s.RunJobNow(context.Background(), id)
+}
+
+func (a *API) ListRuns(jobID int64, limit int) ([]RunDTO, error) {
+	if a.s == nil {
+		return nil, fmt.Errorf("scheduler not initialised")
+	}
+	return a.s.ListRuns(context.Background(), jobID, limit)
+}
@@ -0,0 +1,55 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	base := time.Date(2024, time.March, 15, 10, 30, 0, 0, time.UTC) // Friday
+	cases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2024, time.March, 15, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, time.March, 15, 10, 45, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2024, time.March, 16, 2, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, time.March, 16, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, time.March, 15, 11, 0, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2024, time.March, 18, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 7", time.Date(2024, time.March, 17, 9, 0, 0, 0, time.UTC)},
+		{"30 10 1 * *", time.Date(2024, time.April, 1, 10, 30, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: either may match.
+		{"0 0 20 * 6", time.Date(2024, time.March, 16, 0, 0, 0, 0, time.UTC)},
+		{"0 8-18/4 * * *", time.Date(2024, time.March, 15, 12, 0, 0, 0, time.UTC)},
+		{"5,50 * * * *", time.Date(2024, time.March, 15, 10, 50, 0, 0, time.UTC)},
+	}
+	for _, tc := range cases {
+		sched, err := ParseSchedule(tc.expr)
+		if err != nil {
+			t.Fatalf("parse %q: %v", tc.expr, err)
+		}
+		if got := sched.Next(base); !got.Equal(tc.want) {
+			t.Errorf("%q: next = %v, want %v", tc.expr, got, tc.want)
+		}
+	}
+}
+
+func TestScheduleNeverFires(t *testing.T) {
+	sched, err := ParseSchedule("0 0 30 2 *")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := sched.Next(time.Now()); !got.IsZero() {
+		t.Fatalf("expected zero time, got %v", got)
+	}
+}
+
+func TestParseScheduleRejectsInvalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@fortnightly"} {
+		if _, err := ParseSchedule(expr); err == nil {
+			t.Errorf("expected error for %q", expr)
+		}
+	}
+}
@@ -0,0 +1,439 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"codex-ui/internal/agents"
+	"codex-ui/internal/git/worktrees"
+	"codex-ui/internal/logging"
+	"codex-ui/internal/storage/discovery"
+)
+
+// Run statuses recorded in scheduled_runs.
+const (
+	RunStatusStarted   = "started"
+	RunStatusCompleted = "completed"
+	RunStatusFailed    = "failed"
+)
+
+// Scheduler fires scheduled jobs through the agent service. Every firing
+// creates a new thread tagged with the job, so results wait for review.
+type Scheduler struct {
+	repo   *discovery.Repository
+	agents *agents.Service
+	log    logging.Logger
+	stop   chan struct{}
+}
+
+func New(repo *discovery.Repository, svc *agents.Service, logger logging.Logger) *Scheduler {
+	if logger == nil {
+		logger = logging.Nop()
+	}
+	return &Scheduler{repo: repo, agents: svc, log: logger}
+}
+
+// JobDTO is the frontend view of a scheduled job.
+type JobDTO struct {
+	ID            int64                   `json:"id"`
+	ProjectID     int64                   `json:"projectId"`
+	Name          string                  `json:"name"`
+	Schedule      string                  `json:"schedule"`
+	Prompt        string                  `json:"prompt"`
+	AgentID       string                  `json:"agentId,omitempty"`
+	ThreadOptions agents.ThreadOptionsDTO `json:"threadOptions"`
+	TargetBranch  string                  `json:"targetBranch,omitempty"`
+	Enabled       bool                    `json:"enabled"`
+	LastRunAt     *string                 `json:"lastRunAt,omitempty"`
+	NextRunAt     *string                 `json:"nextRunAt,omitempty"`
+	CreatedAt     string                  `json:"createdAt"`
+	UpdatedAt     string                  `json:"updatedAt"`
+}
+
+// SaveJobRequest creates or updates a scheduled job. TargetBranch is the ref
+// new worktrees branch from; empty means the project's current HEAD.
+type SaveJobRequest struct {
+	ProjectID     int64                   `json:"projectId"`
+	Name          string                  `json:"name"`
+	Schedule      string                  `json:"schedule"`
+	Prompt        string                  `json:"prompt"`
+	AgentID       string                  `json:"agentId,omitempty"`
+	ThreadOptions agents.ThreadOptionsDTO `json:"threadOptions"`
+	TargetBranch  string                  `json:"targetBranch,omitempty"`
+	Enabled       bool                    `json:"enabled"`
+}
+
+// RunDTO describes one firing of a job.
+type RunDTO struct {
+	ID           int64  `json:"id"`
+	JobID        int64  `json:"jobId"`
+	ThreadID     int64  `json:"threadId,omitempty"`
+	Status       string `json:"status"`
+	Error        string `json:"error,omitempty"`
+	ScheduledFor string `json:"scheduledFor"`
+	StartedAt    string `json:"startedAt"`
+	FinishedAt   string `json:"finishedAt,omitempty"`
+}
+
+// Start launches the ticker that fires due jobs. Interval defaults to 1m if
+// zero or negative. Runs still started by an earlier process, whose turns
+// ended with it, are marked failed first.
+func (s *Scheduler) Start(interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	if s.stop != nil {
+		return
+	}
+	s.failOrphanedRuns(context.Background())
+	stop := make(chan struct{})
+	s.stop = stop
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				if err := s.RunDue(context.Background(), now); err != nil {
+					s.log.Error("scheduler run", "error", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// failOrphanedRuns marks runs left started by a process that exited before
+// their turns finished as failed.
+func (s *Scheduler) failOrphanedRuns(ctx context.Context) {
+	closed, err := s.repo.FinishScheduledRunsInStatus(ctx, RunStatusStarted, RunStatusFailed, "the app exited before the turn finished", time.Now())
+	if err != nil {
+		s.log.Error("close orphaned scheduled runs", "error", err)
+		return
+	}
+	if closed > 0 {
+		s.log.Info("closed orphaned scheduled runs", "count", closed)
+	}
+}
+
+// Stop stops the ticker if running.
+func (s *Scheduler) Stop() {
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+}
+
+// RunDue fires every enabled job whose next run is at or before now. A job
+// that missed several slots (e.g. the app was closed overnight) fires once.
+func (s *Scheduler) RunDue(ctx context.Context, now time.Time) error {
+	jobs, err := s.repo.ListDueScheduledJobs(ctx, now)
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		scheduledFor := now
+		if job.NextRunAt != nil {
+			scheduledFor = *job.NextRunAt
+		}
+		var next *time.Time
+		if sched, err := ParseSchedule(job.Schedule); err != nil {
+			s.log.Error("scheduled job has invalid schedule", "jobId", job.ID, "error", err)
+		} else {
+			next = nextRun(sched, now)
+		}
+		// Advance first so a slow or failing send never fires the job twice.
+		if err := s.repo.AdvanceScheduledJob(ctx, job.ID, now, next); err != nil {
+			return err
+		}
+		if next == nil {
+			continue
+		}
+		if _, err := s.fire(ctx, job, scheduledFor); err != nil {
+			s.log.Error("record scheduled run", "jobId", job.ID, "error", err)
+		}
+	}
+	return nil
+}
+
+// RunJobNow fires a job immediately without changing its schedule.
+func (s *Scheduler) RunJobNow(ctx context.Context, id int64) (RunDTO, error) {
+	job, err := s.repo.GetScheduledJob(ctx, id)
+	if err != nil {
+		return RunDTO{}, err
+	}
+	now := time.Now()
+	if err := s.repo.MarkScheduledJobRan(ctx, id, now); err != nil {
+		return RunDTO{}, err
+	}
+	run, err := s.fire(ctx, job, now)
+	if err != nil {
+		return RunDTO{}, err
+	}
+	return toRunDTO(run), nil
+}
+
+// fire starts one run of job on a fresh thread and records the outcome. The
+// run stays started until its turn ends.
+func (s *Scheduler) fire(ctx context.Context, job discovery.ScheduledJob, scheduledFor time.Time) (discovery.ScheduledRun, error) {
+	run := discovery.ScheduledRun{JobID: job.ID, Status: RunStatusStarted, ScheduledFor: scheduledFor}
+	threadID, stream, err := s.startRun(ctx, job, scheduledFor)
+	run.ThreadID = threadID
+	if err != nil {
+		run.Status = RunStatusFailed
+		run.Error = err.Error()
+		s.log.Error("scheduled job failed to start", "jobId", job.ID, "error", err)
+	} else {
+		s.log.Info("scheduled job started", "jobId", job.ID, "threadId", threadID)
+	}
+	run, err = s.repo.CreateScheduledRun(ctx, run)
+	if err != nil {
+		return run, err
+	}
+	if stream != nil {
+		go s.finishRun(run, stream)
+	}
+	return run, nil
+}
+
+// finishRun waits for a run's turn and records whether it completed.
+func (s *Scheduler) finishRun(run discovery.ScheduledRun, stream *agents.Stream) {
+	ctx := context.Background()
+	status, errText := RunStatusCompleted, ""
+	if err := stream.Wait(); err != nil {
+		status, errText = RunStatusFailed, err.Error()
+	} else if thread, err := s.repo.GetThread(ctx, run.ThreadID); err == nil && thread.Status != discovery.ThreadStatusCompleted {
+		status, errText = RunStatusFailed, fmt.Sprintf("turn ended with status %s", thread.Status)
+	}
+	if err := s.repo.FinishScheduledRun(ctx, run.ID, status, errText, time.Now()); err != nil {
+		s.log.Error("record scheduled run outcome", "jobId", run.JobID, "runId", run.ID, "error", err)
+		return
+	}
+	s.log.Info("scheduled job finished", "jobId", run.JobID, "threadId", run.ThreadID, "status", status)
+}
+
+func (s *Scheduler) startRun(ctx context.Context, job discovery.ScheduledJob, scheduledFor time.Time) (int64, *agents.Stream, error) {
+	var opts agents.ThreadOptionsDTO
+	if len(job.ThreadOptions) > 0 {
+		if err := json.Unmarshal(job.ThreadOptions, &opts); err != nil {
+			return 0, nil, fmt.Errorf("decode thread options: %w", err)
+		}
+	}
+	title := fmt.Sprintf("%s · %s", job.Name, scheduledFor.Local().Format("2006-01-02 15:04"))
+	thread, err := s.repo.CreateThread(ctx, discovery.CreateThreadParams{
+		ProjectID:      job.ProjectID,
+		Title:          title,
+		Model:          opts.Model,
+		SandboxMode:    opts.SandboxMode,
+		ReasoningLevel: opts.ReasoningLevel,
+		ScheduledJobID: job.ID,
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	_ = s.repo.UpdateThreadBranchName(ctx, thread.ID, worktrees.BranchName(title, thread.ID))
+
+	stream, _, err := s.agents.SendDetached(ctx, agents.MessageRequest{
+		AgentID:       job.AgentID,
+		ThreadID:      thread.ID,
+		Input:         job.Prompt,
+		ThreadOptions: opts,
+		BaseBranch:    job.TargetBranch,
+	})
+	if err != nil {
+		_ = s.repo.UpdateThreadStatus(ctx, thread.ID, discovery.ThreadStatusFailed, nil)
+		return thread.ID, nil, err
+	}
+	return thread.ID, stream, nil
+}
+
+// ListJobs returns the scheduled jobs of a project.
+func (s *Scheduler) ListJobs(ctx context.Context, projectID int64) ([]JobDTO, error) {
+	jobs, err := s.repo.ListScheduledJobs(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]JobDTO, 0, len(jobs))
+	for _, job := range jobs {
+		list = append(list, toJobDTO(job))
+	}
+	return list, nil
+}
+
+// CreateJob validates and stores a new job.
+func (s *Scheduler) CreateJob(ctx context.Context, req SaveJobRequest) (JobDTO, error) {
+	if req.ProjectID == 0 {
+		return JobDTO{}, errors.New("projectId is required")
+	}
+	if _, err := s.repo.GetProjectByID(ctx, req.ProjectID); err != nil {
+		return JobDTO{}, fmt.Errorf("project %d: %w", req.ProjectID, err)
+	}
+	params, err := s.jobParams(req, time.Now())
+	if err != nil {
+		return JobDTO{}, err
+	}
+	job, err := s.repo.CreateScheduledJob(ctx, params)
+	if err != nil {
+		return JobDTO{}, err
+	}
+	return toJobDTO(job), nil
+}
+
+// UpdateJob replaces a job's definition and recomputes its next run.
+func (s *Scheduler) UpdateJob(ctx context.Context, id int64, req SaveJobRequest) (JobDTO, error) {
+	existing, err := s.repo.GetScheduledJob(ctx, id)
+	if err != nil {
+		return JobDTO{}, err
+	}
+	req.ProjectID = existing.ProjectID
+	params, err := s.jobParams(req, time.Now())
+	if err != nil {
+		return JobDTO{}, err
+	}
+	job, err := s.repo.UpdateScheduledJob(ctx, id, params)
+	if err != nil {
+		return JobDTO{}, err
+	}
+	return toJobDTO(job), nil
+}
+
+// SetJobEnabled pauses or resumes a job. Resuming schedules the next slot
+// from now rather than replaying missed ones.
+func (s *Scheduler) SetJobEnabled(ctx context.Context, id int64, enabled bool) (JobDTO, error) {
+	job, err := s.repo.GetScheduledJob(ctx, id)
+	if err != nil {
+		return JobDTO{}, err
+	}
+	req := toSaveRequest(job)
+	req.Enabled = enabled
+	return s.UpdateJob(ctx, id, req)
+}
+
+// DeleteJob removes a job and its run history; its threads are kept.
+func (s *Scheduler) DeleteJob(ctx context.Context, id int64) error {
+	return s.repo.DeleteScheduledJob(ctx, id)
+}
+
+// ListRuns returns the most recent runs of a job, newest first.
+func (s *Scheduler) ListRuns(ctx context.Context, jobID int64, limit int) ([]RunDTO, error) {
+	runs, err := s.repo.ListScheduledRuns(ctx, jobID, limit)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]RunDTO, 0, len(runs))
+	for _, run := range runs {
+		list = append(list, toRunDTO(run))
+	}
+	return list, nil
+}
+
+func (s *Scheduler) jobParams(req SaveJobRequest, now time.Time) (discovery.SaveScheduledJobParams, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return discovery.SaveScheduledJobParams{}, errors.New("job name is required")
+	}
+	if strings.TrimSpace(req.Prompt) == "" {
+		return discovery.SaveScheduledJobParams{}, errors.New("job prompt is required")
+	}
+	sched, err := ParseSchedule(req.Schedule)
+	if err != nil {
+		return discovery.SaveScheduledJobParams{}, err
+	}
+	agentID := strings.TrimSpace(req.AgentID)
+	if agentID != "" && s.agents != nil && !slices.Contains(s.agents.ListAgents(), agentID) {
+		return discovery.SaveScheduledJobParams{}, fmt.Errorf("agent %q is not registered", agentID)
+	}
+	options, err := json.Marshal(req.ThreadOptions)
+	if err != nil {
+		return discovery.SaveScheduledJobParams{}, fmt.Errorf("encode thread options: %w", err)
+	}
+	params := discovery.SaveScheduledJobParams{
+		ProjectID:     req.ProjectID,
+		Name:          name,
+		Schedule:      strings.TrimSpace(req.Schedule),
+		Prompt:        req.Prompt,
+		AgentID:       agentID,
+		ThreadOptions: options,
+		TargetBranch:  strings.TrimSpace(req.TargetBranch),
+		Enabled:       req.Enabled,
+	}
+	if req.Enabled {
+		params.NextRunAt = nextRun(sched, now)
+		if params.NextRunAt == nil {
+			return discovery.SaveScheduledJobParams{}, fmt.Errorf("schedule %q never fires", req.Schedule)
+		}
+	}
+	return params, nil
+}
+
+func nextRun(sched Schedule, after time.Time) *time.Time {
+	next := sched.Next(after.Local())
+	if next.IsZero() {
+		return nil
+	}
+	next = next.UTC()
+	return &next
+}
+
+func toJobDTO(job discovery.ScheduledJob) JobDTO {
+	dto := JobDTO{
+		ID:           job.ID,
+		ProjectID:    job.ProjectID,
+		Name:         job.Name,
+		Schedule:     job.Schedule,
+		Prompt:       job.Prompt,
+		AgentID:      job.AgentID,
+		TargetBranch: job.TargetBranch,
+		Enabled:      job.Enabled,
+		CreatedAt:    job.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:    job.UpdatedAt.Format(time.RFC3339),
+	}
+	if len(job.ThreadOptions) > 0 {
+		_ = json.Unmarshal(job.ThreadOptions, &dto.ThreadOptions)
+	}
+	if job.LastRunAt != nil {
+		formatted := job.LastRunAt.Format(time.RFC3339)
+		dto.LastRunAt = &formatted
+	}
+	if job.NextRunAt != nil {
+		formatted := job.NextRunAt.Format(time.RFC3339)
+		dto.NextRunAt = &formatted
+	}
+	return dto
+}
+
+func toSaveRequest(job discovery.ScheduledJob) SaveJobRequest {
+	dto := toJobDTO(job)
+	return SaveJobRequest{
+		ProjectID:     dto.ProjectID,
+		Name:          dto.Name,
+		Schedule:      dto.Schedule,
+		Prompt:        dto.Prompt,
+		AgentID:       dto.AgentID,
+		ThreadOptions: dto.ThreadOptions,
+		TargetBranch:  dto.TargetBranch,
+		Enabled:       dto.Enabled,
+	}
+}
+
+func toRunDTO(run discovery.ScheduledRun) RunDTO {
+	dto := RunDTO{
+		ID:           run.ID,
+		JobID:        run.JobID,
+		ThreadID:     run.ThreadID,
+		Status:       run.Status,
+		Error:        run.Error,
+		ScheduledFor: run.ScheduledFor.Format(time.RFC3339),
+		StartedAt:    run.StartedAt.Format(time.RFC3339),
+	}
+	if run.FinishedAt != nil {
+		dto.FinishedAt = run.FinishedAt.Format(time.RFC3339)
+	}
+	return dto
+}
@@ -0,0 +1,258 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"codex-ui/internal/agents"
+	"codex-ui/internal/storage/discovery"
+	"codex-ui/internal/storage/migrate"
+
+	_ "modernc.org/sqlite"
+)
+
+// recordingAdapter completes every turn immediately and records the prompts.
+// fail refuses to start turns; turnErr fails them once started.
+type recordingAdapter struct {
+	mu      sync.Mutex
+	inputs  []string
+	fail    error
+	turnErr error
+}
+
+func (a *recordingAdapter) Stream(ctx context.Context, req agents.MessageRequest) (*agents.StreamResult, error) {
+	a.mu.Lock()
+	a.inputs = append(a.inputs, req.Input)
+	a.mu.Unlock()
+	if a.fail != nil {
+		return nil, a.fail
+	}
+	events := make(chan agents.StreamEvent, 1)
+	done := make(chan error, 1)
+	if a.turnErr != nil {
+		events <- agents.StreamEvent{Type: "turn.failed", Error: &agents.StreamError{Message: a.turnErr.Error()}}
+	} else {
+		events <- agents.StreamEvent{Type: "turn.completed"}
+	}
+	close(events)
+	done <- a.turnErr
+	close(done)
+	return &agents.StreamResult{Events: events, Done: done}, nil
+}
+
+func newTestScheduler(t *testing.T, adapter agents.Adapter) (*Scheduler, *discovery.Repository, discovery.Project) {
+	t.Helper()
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", strings.ReplaceAll(t.Name(), "/", "_"))
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatalf("open in-memory database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	if err := migrate.Up(db); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	repo := discovery.NewRepository(db)
+	project, err := repo.UpsertProject(context.Background(), discovery.UpsertProjectParams{Path: "/tmp/" + t.Name()})
+	if err != nil {
+		t.Fatalf("upsert project: %v", err)
+	}
+	svc := agents.NewService("fake", repo)
+	if err := svc.Register("fake", adapter); err != nil {
+		t.Fatalf("register adapter: %v", err)
+	}
+	return New(repo, svc, nil), repo, project
+}
+
+func TestSchedulerRunDueFiresOnceAndTagsThread(t *testing.T) {
+	adapter := &recordingAdapter{}
+	s, repo, project := newTestScheduler(t, adapter)
+	ctx := context.Background()
+
+	job, err := s.CreateJob(ctx, SaveJobRequest{
+		ProjectID: project.ID,
+		Name:      "Update dependencies",
+		Schedule:  "0 2 * * *",
+		Prompt:    "update go modules",
+		Enabled:   true,
+	})
+	if err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+	if job.NextRunAt == nil {
+		t.Fatal("expected next run to be scheduled")
+	}
+	next, _ := time.Parse(time.RFC3339, *job.NextRunAt)
+
+	// Nothing is due before the slot.
+	if err := s.RunDue(ctx, next.Add(-time.Minute)); err != nil {
+		t.Fatalf("run due early: %v", err)
+	}
+	// Two days late still fires only once.
+	late := next.Add(48 * time.Hour)
+	if err := s.RunDue(ctx, late); err != nil {
+		t.Fatalf("run due: %v", err)
+	}
+	if err := s.RunDue(ctx, late); err != nil {
+		t.Fatalf("run due again: %v", err)
+	}
+	adapter.mu.Lock()
+	inputs := append([]string(nil), adapter.inputs...)
+	adapter.mu.Unlock()
+	if len(inputs) != 1 || inputs[0] != "update go modules" {
+		t.Fatalf("expected one run with the job prompt, got %q", inputs)
+	}
+
+	runs, err := s.ListRuns(ctx, job.ID, 0)
+	if err != nil {
+		t.Fatalf("list runs: %v", err)
+	}
+	if len(runs) != 1 || runs[0].ThreadID == 0 {
+		t.Fatalf("unexpected runs %+v", runs)
+	}
+	if run := waitForRun(t, s, job.ID); run.Status != RunStatusCompleted || run.FinishedAt == "" {
+		t.Fatalf("expected the run to complete, got %+v", run)
+	}
+	thread, err := repo.GetThread(ctx, runs[0].ThreadID)
+	if err != nil {
+		t.Fatalf("get thread: %v", err)
+	}
+	if thread.ScheduledJobID != job.ID || !strings.HasPrefix(thread.Title, "Update dependencies · ") {
+		t.Fatalf("thread not tagged with job: %+v", thread)
+	}
+
+	reloaded, err := repo.GetScheduledJob(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if reloaded.NextRunAt == nil || !reloaded.NextRunAt.After(late) {
+		t.Fatalf("expected next run after %v, got %v", late, reloaded.NextRunAt)
+	}
+}
+
+func TestSchedulerRecordsFailedRuns(t *testing.T) {
+	adapter := &recordingAdapter{fail: errors.New("agent offline")}
+	s, repo, project := newTestScheduler(t, adapter)
+	ctx := context.Background()
+
+	job, err := s.CreateJob(ctx, SaveJobRequest{ProjectID: project.ID, Name: "Triage TODOs", Schedule: "@daily", Prompt: "triage", Enabled: true})
+	if err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+	run, err := s.RunJobNow(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("run now: %v", err)
+	}
+	if run.Status != RunStatusFailed || !strings.Contains(run.Error, "agent offline") {
+		t.Fatalf("expected failed run, got %+v", run)
+	}
+	thread, err := repo.GetThread(ctx, run.ThreadID)
+	if err != nil {
+		t.Fatalf("get thread: %v", err)
+	}
+	if thread.Status != discovery.ThreadStatusFailed {
+		t.Fatalf("expected failed thread, got %s", thread.Status)
+	}
+	// Running by hand leaves the schedule alone.
+	after, err := s.ListJobs(ctx, project.ID)
+	if err != nil {
+		t.Fatalf("list jobs: %v", err)
+	}
+	if len(after) != 1 || after[0].NextRunAt == nil || *after[0].NextRunAt != *job.NextRunAt || after[0].LastRunAt == nil {
+		t.Fatalf("unexpected job after manual run %+v", after)
+	}
+}
+
+func TestSchedulerRecordsFailedTurns(t *testing.T) {
+	s, _, project := newTestScheduler(t, &recordingAdapter{turnErr: errors.New("model crashed")})
+	ctx := context.Background()
+
+	job, err := s.CreateJob(ctx, SaveJobRequest{ProjectID: project.ID, Name: "Nightly", Schedule: "@daily", Prompt: "run", Enabled: true})
+	if err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+	if _, err := s.RunJobNow(ctx, job.ID); err != nil {
+		t.Fatalf("run now: %v", err)
+	}
+	if run := waitForRun(t, s, job.ID); run.Status != RunStatusFailed || !strings.Contains(run.Error, "model crashed") || run.FinishedAt == "" {
+		t.Fatalf("expected the run to fail with the turn, got %+v", run)
+	}
+}
+
+func TestSchedulerStartFailsOrphanedRuns(t *testing.T) {
+	s, repo, project := newTestScheduler(t, &recordingAdapter{})
+	ctx := context.Background()
+
+	job, err := s.CreateJob(ctx, SaveJobRequest{ProjectID: project.ID, Name: "Nightly", Schedule: "@daily", Prompt: "run", Enabled: true})
+	if err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+	// A run the previous process started but never finished.
+	if _, err := repo.CreateScheduledRun(ctx, discovery.ScheduledRun{JobID: job.ID, Status: RunStatusStarted, ScheduledFor: time.Now()}); err != nil {
+		t.Fatalf("create run: %v", err)
+	}
+	s.Start(time.Hour)
+	defer s.Stop()
+
+	runs, err := s.ListRuns(ctx, job.ID, 0)
+	if err != nil {
+		t.Fatalf("list runs: %v", err)
+	}
+	if len(runs) != 1 || runs[0].Status != RunStatusFailed || runs[0].FinishedAt == "" || !strings.Contains(runs[0].Error, "exited") {
+		t.Fatalf("expected the orphaned run to be failed, got %+v", runs)
+	}
+}
+
+// waitForRun waits until the latest run of a job has finished.
+func waitForRun(t *testing.T, s *Scheduler, jobID int64) RunDTO {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		runs, err := s.ListRuns(context.Background(), jobID, 1)
+		if err != nil {
+			t.Fatalf("list runs: %v", err)
+		}
+		if len(runs) == 1 && runs[0].Status != RunStatusStarted {
+			return runs[0]
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("run of job %d did not finish: %+v", jobID, runs)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSchedulerValidatesAndPauses(t *testing.T) {
+	s, _, project := newTestScheduler(t, &recordingAdapter{})
+	ctx := context.Background()
+
+	if _, err := s.CreateJob(ctx, SaveJobRequest{ProjectID: project.ID, Name: "bad", Schedule: "every night", Prompt: "x", Enabled: true}); err == nil {
+		t.Fatal("expected invalid schedule to be rejected")
+	}
+	if _, err := s.CreateJob(ctx, SaveJobRequest{ProjectID: project.ID, Name: "x", Schedule: "@daily", Prompt: "x", AgentID: "missing"}); err == nil {
+		t.Fatal("expected unknown agent to be rejected")
+	}
+	job, err := s.CreateJob(ctx, SaveJobRequest{ProjectID: project.ID, Name: "nightly", Schedule: "@daily", Prompt: "x", Enabled: true})
+	if err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+	paused, err := s.SetJobEnabled(ctx, job.ID, false)
+	if err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	if paused.Enabled || paused.NextRunAt != nil {
+		t.Fatalf("expected paused job without next run, got %+v", paused)
+	}
+	if err := s.DeleteJob(ctx, job.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := s.DeleteJob(ctx, job.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected ErrNoRows deleting twice, got %v", err)
+	}
+}
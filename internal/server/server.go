@@ -0,0 +1,381 @@
+// Package server exposes the bound Wails APIs over a local HTTP + WebSocket
+// endpoint so editor plugins and dashboards can drive a running instance.
+//
+// Every exported method of a registered binding is callable as
+//
+//	POST /api/<binding>/<Method>   body: JSON array of positional arguments
+//
+// mirroring the generated frontend bindings. Runtime events are streamed over
+// GET /ws?topics=<prefix>,<prefix>. All requests require the bearer token;
+// only /ws also accepts it as ?token=.
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"codex-ui/internal/events"
+	"codex-ui/internal/logging"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	unixPrefix    = "unix:"
+	tokenFileName = "server-token"
+	// maxRequestBody bounds JSON request bodies (clipboard images are the largest payloads).
+	maxRequestBody = 32 << 20
+)
+
+// DefaultTopicPrefixes lists the runtime topics streamed when a WebSocket
+// client does not ask for specific ones.
+var DefaultTopicPrefixes = []string{"agent:stream:", "agent:file-change:", "agent:terminal:", "agent:queue:"}
+
+// Config controls where the server listens and how clients authenticate.
+type Config struct {
+	// Addr is a loopback host:port or "unix:<path>". Empty disables the server.
+	Addr string
+	// Token is the bearer token clients must present.
+	Token string
+}
+
+// ConfigFromEnv reads CODEX_UI_SERVER_ADDR and CODEX_UI_SERVER_TOKEN. When no
+// token is provided one is generated once and stored under dataDir.
+func ConfigFromEnv(dataDir string) (Config, error) {
+	cfg := Config{
+		Addr:  strings.TrimSpace(os.Getenv("CODEX_UI_SERVER_ADDR")),
+		Token: strings.TrimSpace(os.Getenv("CODEX_UI_SERVER_TOKEN")),
+	}
+	if cfg.Addr == "" || cfg.Token != "" {
+		return cfg, nil
+	}
+	token, err := loadOrCreateToken(filepath.Join(dataDir, tokenFileName))
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.Token = token
+	return cfg, nil
+}
+
+func loadOrCreateToken(path string) (string, error) {
+	if raw, err := os.ReadFile(path); err == nil {
+		if token := strings.TrimSpace(string(raw)); token != "" {
+			return token, nil
+		}
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate server token: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+	if err := os.WriteFile(path, []byte(token+"\n"), 0o600); err != nil {
+		return "", fmt.Errorf("write server token: %w", err)
+	}
+	return token, nil
+}
+
+// Server dispatches JSON calls to bound APIs and streams hub events.
+type Server struct {
+	cfg      Config
+	hub      *events.Hub
+	log      logging.Logger
+	bindings map[string]map[string]reflect.Value
+	upgrader websocket.Upgrader
+
+	mu       sync.Mutex
+	httpSrv  *http.Server
+	listener net.Listener
+}
+
+// New constructs a server for the given bindings, keyed by binding name
+// (e.g. "agents" for *agents.API).
+func New(cfg Config, hub *events.Hub, logger logging.Logger, bindings map[string]any) (*Server, error) {
+	if strings.TrimSpace(cfg.Token) == "" {
+		return nil, errors.New("server token is required")
+	}
+	if logger == nil {
+		logger = logging.Nop()
+	}
+	s := &Server{
+		cfg:      cfg,
+		hub:      hub,
+		log:      logger,
+		bindings: make(map[string]map[string]reflect.Value),
+		// Browser origins are allowed because every upgrade is token-authenticated.
+		upgrader: websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }},
+	}
+	for name, binding := range bindings {
+		if binding == nil {
+			continue
+		}
+		value := reflect.ValueOf(binding)
+		methods := make(map[string]reflect.Value)
+		for i := 0; i < value.NumMethod(); i++ {
+			methods[value.Type().Method(i).Name] = value.Method(i)
+		}
+		s.bindings[name] = methods
+	}
+	return s, nil
+}
+
+// Handler returns the HTTP handler serving the API and WebSocket endpoints.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api", s.handleIndex)
+	mux.HandleFunc("POST /api/{binding}/{method}", s.handleCall)
+	mux.HandleFunc("GET /ws", s.handleWebSocket)
+	return s.authenticate(mux)
+}
+
+// Start listens on the configured address and serves in the background.
+func (s *Server) Start() error {
+	listener, err := listen(s.cfg.Addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: s.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	s.mu.Lock()
+	s.httpSrv = srv
+	s.listener = listener
+	s.mu.Unlock()
+	go func() {
+		if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.log.Error("local api server stopped", "error", err)
+		}
+	}()
+	s.log.Info("local api server listening", "addr", listener.Addr().String())
+	return nil
+}
+
+// Addr reports the bound listener address once started.
+func (s *Server) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Shutdown stops the server and closes open connections.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	srv := s.httpSrv
+	s.httpSrv = nil
+	s.mu.Unlock()
+	if srv == nil {
+		return nil
+	}
+	return srv.Shutdown(ctx)
+}
+
+func listen(addr string) (net.Listener, error) {
+	addr = strings.TrimSpace(addr)
+	if addr == "" {
+		return nil, errors.New("server address is required")
+	}
+	if path, ok := strings.CutPrefix(addr, unixPrefix); ok {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("remove stale socket: %w", err)
+		}
+		listener, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, fmt.Errorf("listen on unix socket: %w", err)
+		}
+		if err := os.Chmod(path, 0o600); err != nil {
+			_ = listener.Close()
+			return nil, fmt.Errorf("restrict socket permissions: %w", err)
+		}
+		return listener, nil
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server address %q: %w", addr, err)
+	}
+	if !isLoopbackHost(host) {
+		return nil, fmt.Errorf("server address %q is not a loopback address", addr)
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen on %s: %w", addr, err)
+	}
+	return listener, nil
+}
+
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	expected := []byte(s.cfg.Token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		if token == "" && r.URL.Path == "/ws" {
+			// Browsers cannot set headers on WebSocket upgrades. Other
+			// endpoints take the header only, keeping the token out of URLs.
+			token = r.URL.Query().Get("token")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), expected) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("invalid or missing token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	index := make(map[string][]string, len(s.bindings))
+	for name, methods := range s.bindings {
+		names := make([]string, 0, len(methods))
+		for method := range methods {
+			names = append(names, method)
+		}
+		sort.Strings(names)
+		index[name] = names
+	}
+	writeJSON(w, http.StatusOK, index)
+}
+
+func (s *Server) handleCall(w http.ResponseWriter, r *http.Request) {
+	methods, ok := s.bindings[r.PathValue("binding")]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown binding %q", r.PathValue("binding")))
+		return
+	}
+	method, ok := methods[r.PathValue("method")]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown method %q", r.PathValue("method")))
+		return
+	}
+	var rawArgs []json.RawMessage
+	body := http.MaxBytesReader(w, r.Body, maxRequestBody)
+	if err := json.NewDecoder(body).Decode(&rawArgs); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("decode arguments: %w", err))
+		return
+	}
+	args, err := decodeArgs(method.Type(), rawArgs)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	result, err := invoke(method, args)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func decodeArgs(fn reflect.Type, raw []json.RawMessage) ([]reflect.Value, error) {
+	if len(raw) != fn.NumIn() {
+		return nil, fmt.Errorf("expected %d arguments, got %d", fn.NumIn(), len(raw))
+	}
+	args := make([]reflect.Value, fn.NumIn())
+	for i := range args {
+		ptr := reflect.New(fn.In(i))
+		if err := json.Unmarshal(raw[i], ptr.Interface()); err != nil {
+			return nil, fmt.Errorf("decode argument %d: %w", i+1, err)
+		}
+		args[i] = ptr.Elem()
+	}
+	return args, nil
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// invoke calls a bound method following Wails conventions: the optional last
+// return value is an error and the optional first one is the result.
+func invoke(method reflect.Value, args []reflect.Value) (any, error) {
+	out := method.Call(args)
+	var result any
+	for i, value := range out {
+		if value.Type() == errorType {
+			if !value.IsNil() {
+				return nil, value.Interface().(error)
+			}
+			continue
+		}
+		if i == 0 {
+			result = value.Interface()
+		}
+	}
+	return result, nil
+}
+
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if s.hub == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("event hub not configured"))
+		return
+	}
+	prefixes := DefaultTopicPrefixes
+	if raw := strings.TrimSpace(r.URL.Query().Get("topics")); raw != "" {
+		prefixes = nil
+		for _, p := range strings.Split(raw, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				prefixes = append(prefixes, p)
+			}
+		}
+	}
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	sub := s.hub.Subscribe(prefixes, 1024)
+	defer sub.Close()
+	defer conn.Close()
+
+	// Reader loop: detects client close; incoming messages are ignored.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case evt, ok := <-sub.C():
+			if !ok {
+				return
+			}
+			_ = conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := conn.WriteJSON(evt); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, payload any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(payload)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"codex-ui/internal/events"
+
+	"github.com/gorilla/websocket"
+)
+
+type fakeAPI struct{}
+
+type greeting struct {
+	Message string `json:"message"`
+}
+
+func (fakeAPI) Greet(name string, times int) (greeting, error) {
+	return greeting{Message: strings.Repeat("hi "+name+" ", times)}, nil
+}
+
+func (fakeAPI) Fail() error { return errors.New("boom") }
+
+func newTestServer(t *testing.T, hub *events.Hub) *httptest.Server {
+	t.Helper()
+	srv, err := New(Config{Token: "secret"}, hub, nil, map[string]any{"fake": fakeAPI{}})
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func call(t *testing.T, ts *httptest.Server, path, token, body string) (*http.Response, string) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(resp.Body)
+	return resp, strings.TrimSpace(string(raw))
+}
+
+func TestCallDispatchesToBinding(t *testing.T) {
+	ts := newTestServer(t, nil)
+
+	resp, body := call(t, ts, "/api/fake/Greet", "secret", `["ada", 2]`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d: %s", resp.StatusCode, body)
+	}
+	if body != `{"message":"hi ada hi ada "}` {
+		t.Fatalf("unexpected body %s", body)
+	}
+
+	resp, body = call(t, ts, "/api/fake/Fail", "secret", `[]`)
+	if resp.StatusCode != http.StatusInternalServerError || !strings.Contains(body, "boom") {
+		t.Fatalf("expected error response, got %d %s", resp.StatusCode, body)
+	}
+
+	resp, _ = call(t, ts, "/api/fake/Greet", "secret", `["ada"]`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected bad request for wrong arity, got %d", resp.StatusCode)
+	}
+
+	resp, _ = call(t, ts, "/api/fake/Missing", "secret", `[]`)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected not found, got %d", resp.StatusCode)
+	}
+}
+
+func TestRejectsMissingOrWrongToken(t *testing.T) {
+	ts := newTestServer(t, nil)
+	for _, token := range []string{"", "nope"} {
+		resp, _ := call(t, ts, "/api/fake/Fail", token, `[]`)
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("token %q: expected 401, got %d", token, resp.StatusCode)
+		}
+	}
+	// Only the WebSocket upgrade takes the token from the query string.
+	resp, _ := call(t, ts, "/api/fake/Fail?token=secret", "", `[]`)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("query token on an API call: expected 401, got %d", resp.StatusCode)
+	}
+}
+
+func TestWebSocketStreamsMatchingTopics(t *testing.T) {
+	hub := events.NewHub(nil)
+	ts := newTestServer(t, hub)
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws?token=secret&topics=agent:stream:"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	// The subscription is registered after the upgrade; emit until it is delivered.
+	deadline := time.Now().Add(2 * time.Second)
+	_ = conn.SetReadDeadline(deadline)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(10 * time.Millisecond):
+				hub.Emit("agent:terminal:1", "ignored")
+				hub.Emit("agent:stream:abc", map[string]string{"type": "turn.started"})
+			}
+		}
+	}()
+	defer close(done)
+
+	var evt struct {
+		Topic   string            `json:"topic"`
+		Payload map[string]string `json:"payload"`
+	}
+	if err := conn.ReadJSON(&evt); err != nil {
+		t.Fatalf("read event: %v", err)
+	}
+	if evt.Topic != "agent:stream:abc" || evt.Payload["type"] != "turn.started" {
+		t.Fatalf("unexpected event %+v", evt)
+	}
+}
+
+func TestListenRejectsNonLoopback(t *testing.T) {
+	if _, err := listen("0.0.0.0:0"); err == nil {
+		t.Fatal("expected non-loopback address to be rejected")
+	}
+	l, err := listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen loopback: %v", err)
+	}
+	_ = l.Close()
+}
@@ -0,0 +1,25 @@
+package sessions
+
+import (
+	"context"
+	"fmt"
+)
+
+// API exposes the Codex session importer to the frontend via Wails binding.
+type API struct {
+	im *Importer
+}
+
+func NewAPI(im *Importer) *API { return &API{im: im} }
+
+// SessionsDir returns the directory ImportSessions scans by default.
+func (a *API) SessionsDir() string { return DefaultDir() }
+
+// ImportSessions imports new Codex CLI sessions from dir (the default
+// sessions directory when empty) into their projects.
+func (a *API) ImportSessions(dir string) (ImportReportDTO, error) {
+	if a.im == nil {
+		return ImportReportDTO{}, fmt.Errorf("session importer not initialised")
+	}
+	return a.im.Import(context.Background(), dir)
+}
@@ -0,0 +1,161 @@
+// Package sessions imports sessions started from the plain Codex CLI into the
+// catalog so they can be read and resumed from the UI.
+package sessions
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"codex-ui/internal/agents"
+	"codex-ui/internal/logging"
+	"codex-ui/internal/storage/discovery"
+)
+
+// DirEnv overrides the directory scanned for session files.
+const DirEnv = "CODEX_UI_SESSIONS_DIR"
+
+// codexAgentID is the agent that resumes imported sessions.
+const codexAgentID = "codex"
+
+// DefaultDir returns the Codex sessions directory: $CODEX_UI_SESSIONS_DIR,
+// else $CODEX_HOME/sessions, else ~/.codex/sessions.
+func DefaultDir() string {
+	if dir := strings.TrimSpace(os.Getenv(DirEnv)); dir != "" {
+		return dir
+	}
+	if home := strings.TrimSpace(os.Getenv("CODEX_HOME")); home != "" {
+		return filepath.Join(home, "sessions")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".codex", "sessions")
+}
+
+// ImportReportDTO summarises an import scan.
+type ImportReportDTO struct {
+	Dir      string             `json:"dir"`
+	Scanned  int                `json:"scanned"`
+	Imported []agents.ThreadDTO `json:"imported"`
+	// Known counts sessions that already have a thread.
+	Known int `json:"known"`
+	// Unmatched lists session files whose working directory is outside every
+	// registered project.
+	Unmatched []string `json:"unmatched,omitempty"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+// Importer turns Codex session files into threads.
+type Importer struct {
+	repo   *discovery.Repository
+	agents *agents.Service
+	log    logging.Logger
+}
+
+func New(repo *discovery.Repository, svc *agents.Service, logger logging.Logger) *Importer {
+	if logger == nil {
+		logger = logging.Nop()
+	}
+	return &Importer{repo: repo, agents: svc, log: logger}
+}
+
+// Import scans dir (DefaultDir when empty) for session files and creates a
+// thread for each new session whose working directory lies in a registered
+// project. Threads keep the session ID, so new turns resume the session.
+func (im *Importer) Import(ctx context.Context, dir string) (ImportReportDTO, error) {
+	if strings.TrimSpace(dir) == "" {
+		dir = DefaultDir()
+	}
+	report := ImportReportDTO{Dir: dir, Imported: []agents.ThreadDTO{}}
+	if dir == "" {
+		return report, errors.New("no sessions directory")
+	}
+	projects, err := im.repo.ListProjects(ctx)
+	if err != nil {
+		return report, err
+	}
+	var paths []string
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			return nil
+		}
+		if !d.IsDir() && strings.HasSuffix(strings.ToLower(d.Name()), ".jsonl") {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		report.Scanned++
+		session, err := ParseFile(path)
+		if err != nil || session.ID == "" {
+			if err == nil {
+				err = errors.New(path + ": no session id")
+			}
+			report.Errors = append(report.Errors, err.Error())
+			continue
+		}
+		if _, err := im.repo.GetThreadByExternalID(ctx, session.ID); err == nil {
+			report.Known++
+			continue
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return report, err
+		}
+		project, ok := matchProject(projects, session.Cwd)
+		if !ok {
+			report.Unmatched = append(report.Unmatched, path)
+			continue
+		}
+		thread, err := im.agents.ImportThread(ctx, agents.ImportThreadRequest{
+			ProjectID:        project.ID,
+			Thread:           agents.ThreadDTO{Model: session.Model, ReasoningLevel: session.ReasoningLevel, AgentID: codexAgentID},
+			Entries:          session.Entries,
+			ExternalID:       session.ID,
+			ConversationPath: path,
+			Usage:            session.Usage,
+		})
+		if err != nil {
+			report.Errors = append(report.Errors, path+": "+err.Error())
+			continue
+		}
+		im.log.Info("imported codex session", "session", session.ID, "thread", thread.ID)
+		report.Imported = append(report.Imported, thread)
+	}
+	return report, nil
+}
+
+// matchProject returns the project with the longest path containing cwd.
+func matchProject(projects []discovery.Project, cwd string) (discovery.Project, bool) {
+	cwd = filepath.Clean(strings.TrimSpace(cwd))
+	if cwd == "." {
+		return discovery.Project{}, false
+	}
+	var (
+		best  discovery.Project
+		found bool
+	)
+	for _, project := range projects {
+		root := filepath.Clean(project.Path)
+		if cwd != root && !strings.HasPrefix(cwd, root+string(filepath.Separator)) {
+			continue
+		}
+		if !found || len(root) > len(best.Path) {
+			best, found = project, true
+		}
+	}
+	return best, found
+}
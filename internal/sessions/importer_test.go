@@ -0,0 +1,194 @@
+package sessions
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"codex-ui/internal/agents"
+	"codex-ui/internal/storage/discovery"
+	"codex-ui/internal/storage/migrate"
+
+	_ "modernc.org/sqlite"
+)
+
+// recordingAdapter completes every turn immediately and records the requests.
+type recordingAdapter struct {
+	mu       sync.Mutex
+	requests []agents.MessageRequest
+}
+
+func (a *recordingAdapter) Stream(ctx context.Context, req agents.MessageRequest) (*agents.StreamResult, error) {
+	a.mu.Lock()
+	a.requests = append(a.requests, req)
+	a.mu.Unlock()
+	events := make(chan agents.StreamEvent)
+	done := make(chan error, 1)
+	close(events)
+	done <- nil
+	close(done)
+	return &agents.StreamResult{Events: events, Done: done}, nil
+}
+
+func newTestImporter(t *testing.T, adapter agents.Adapter, projectPath string) (*Importer, *agents.Service, discovery.Project) {
+	t.Helper()
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", strings.ReplaceAll(t.Name(), "/", "_"))
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatalf("open in-memory database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	if err := migrate.Up(db); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	repo := discovery.NewRepository(db)
+	project, err := repo.UpsertProject(context.Background(), discovery.UpsertProjectParams{Path: projectPath})
+	if err != nil {
+		t.Fatalf("upsert project: %v", err)
+	}
+	svc := agents.NewService(codexAgentID, repo)
+	if err := svc.Register(codexAgentID, adapter); err != nil {
+		t.Fatalf("register adapter: %v", err)
+	}
+	return New(repo, svc, nil), svc, project
+}
+
+func writeSession(t *testing.T, dir, name string, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatalf("write session: %v", err)
+	}
+	return path
+}
+
+func TestImportMatchesProjectsAndResumesSessions(t *testing.T) {
+	adapter := &recordingAdapter{}
+	im, svc, project := newTestImporter(t, adapter, "/work/api")
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	writeSession(t, dir, "2025/01/02/rollout-2025-01-02T10-00-00-sess-1.jsonl",
+		`{"timestamp":"2025-01-02T10:00:00Z","type":"session_meta","payload":{"id":"sess-1","timestamp":"2025-01-02T10:00:00Z","cwd":"/work/api/server"}}`,
+		`{"timestamp":"2025-01-02T10:00:00Z","type":"turn_context","payload":{"cwd":"/work/api/server","model":"gpt-5-codex","effort":"high"}}`,
+		`{"timestamp":"2025-01-02T10:00:01Z","type":"response_item","payload":{"type":"message","role":"user","content":[{"type":"input_text","text":"<environment_context>\n<cwd>/work/api/server</cwd>\n</environment_context>"}]}}`,
+		`{"timestamp":"2025-01-02T10:00:02Z","type":"response_item","payload":{"type":"message","role":"user","content":[{"type":"input_text","text":"Fix the failing handler tests"}]}}`,
+		`{"timestamp":"2025-01-02T10:00:03Z","type":"response_item","payload":{"type":"reasoning","summary":[{"type":"summary_text","text":"Run the tests first."}]}}`,
+		`{"timestamp":"2025-01-02T10:00:04Z","type":"response_item","payload":{"type":"function_call","name":"shell","arguments":"{\"command\":[\"bash\",\"-lc\",\"go test ./handler\"]}","call_id":"call-1"}}`,
+		`{"timestamp":"2025-01-02T10:00:05Z","type":"response_item","payload":{"type":"function_call_output","call_id":"call-1","output":"{\"output\":\"FAIL TestCreate\\n\",\"metadata\":{\"exit_code\":1}}"}}`,
+		`{"timestamp":"2025-01-02T10:00:06Z","type":"response_item","payload":{"type":"custom_tool_call","name":"apply_patch","call_id":"call-2","input":"*** Begin Patch\n*** Update File: handler/create.go\n*** Add File: handler/create_test.go\n*** End Patch"}}`,
+		`{"timestamp":"2025-01-02T10:00:07Z","type":"response_item","payload":{"type":"message","role":"assistant","content":[{"type":"output_text","text":"The handler now validates input."}]}}`,
+		`{"timestamp":"2025-01-02T10:00:08Z","type":"event_msg","payload":{"type":"token_count","info":{"total_token_usage":{"input_tokens":1200,"cached_input_tokens":200,"output_tokens":300}}}}`,
+	)
+	writeSession(t, dir, "rollout-2024-06-01-sess-0.jsonl",
+		`{"id":"sess-0","timestamp":"2024-06-01T08:00:00Z","instructions":null}`,
+		`{"type":"message","role":"user","content":[{"type":"input_text","text":"<environment_context>\n<cwd>/work/api</cwd>\n</environment_context>"}]}`,
+		`{"type":"message","role":"user","content":[{"type":"input_text","text":"Summarise the README"}]}`,
+		`{"type":"message","role":"assistant","content":[{"type":"output_text","text":"It documents the API."}]}`,
+	)
+	unmatched := writeSession(t, dir, "rollout-2025-01-03-sess-2.jsonl",
+		`{"timestamp":"2025-01-03T10:00:00Z","type":"session_meta","payload":{"id":"sess-2","cwd":"/work/other"}}`,
+	)
+
+	report, err := im.Import(ctx, dir)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if report.Scanned != 3 || len(report.Imported) != 2 || report.Known != 0 || len(report.Errors) != 0 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if len(report.Unmatched) != 1 || report.Unmatched[0] != unmatched {
+		t.Fatalf("expected %s to be unmatched, got %v", unmatched, report.Unmatched)
+	}
+
+	bySession := make(map[string]agents.ThreadDTO)
+	for _, thread := range report.Imported {
+		if thread.ProjectID != project.ID || thread.ReadOnly || thread.ImportedAt == nil {
+			t.Fatalf("unexpected imported thread %+v", thread)
+		}
+		bySession[thread.ExternalID] = thread
+	}
+	thread := bySession["sess-1"]
+	if thread.ID == 0 || thread.Model != "gpt-5-codex" || thread.ReasoningLevel != "high" || thread.Title != "Fix the failing handler tests" {
+		t.Fatalf("unexpected thread %+v", thread)
+	}
+	if legacy := bySession["sess-0"]; legacy.Title != "Summarise the README" {
+		t.Fatalf("unexpected legacy thread %+v", legacy)
+	}
+
+	entries, err := svc.LoadThreadConversation(ctx, thread.ID)
+	if err != nil {
+		t.Fatalf("load conversation: %v", err)
+	}
+	if len(entries) != 6 {
+		t.Fatalf("expected 6 entries, got %d: %+v", len(entries), entries)
+	}
+	if entries[0].Role != "user" || entries[0].Text != "Fix the failing handler tests" || entries[0].CreatedAt != "2025-01-02T10:00:02Z" {
+		t.Fatalf("unexpected prompt %+v", entries[0])
+	}
+	if entries[1].Item == nil || entries[1].Item.Reasoning != "Run the tests first." {
+		t.Fatalf("unexpected reasoning %+v", entries[1])
+	}
+	command := entries[2].Item.Command
+	if command == nil || command.Command != "go test ./handler" || command.AggregatedOutput != "FAIL TestCreate\n" || command.ExitCode == nil || *command.ExitCode != 1 {
+		t.Fatalf("unexpected command %+v", entries[2].Item)
+	}
+	if diffs := entries[3].Item.FileDiffs; len(diffs) != 2 || diffs[0].Path != "handler/create.go" || diffs[0].Kind != "update" || diffs[1].Kind != "add" {
+		t.Fatalf("unexpected file changes %+v", entries[3].Item)
+	}
+	if entries[4].Item == nil || entries[4].Item.Text != "The handler now validates input." {
+		t.Fatalf("unexpected reply %+v", entries[4])
+	}
+	if entries[5].Role != "system" || entries[5].Meta["inputTokens"] != float64(1200) || entries[5].Meta["outputTokens"] != float64(300) {
+		t.Fatalf("unexpected usage entry %+v", entries[5])
+	}
+	usage, err := svc.UsageReport(ctx, agents.UsageQueryDTO{ThreadID: thread.ID, GroupBy: []string{"agent", "day"}})
+	if err != nil {
+		t.Fatalf("usage report: %v", err)
+	}
+	if len(usage.Rows) != 1 || strings.Join(usage.Rows[0].Keys, "/") != "codex/2025-01-02" || usage.Total.Turns != 1 || usage.Total.InputTokens != 1200 || usage.Total.CachedInputTokens != 200 || usage.Total.OutputTokens != 300 {
+		t.Fatalf("expected one ledger row for the imported turn, got %+v", usage)
+	}
+
+	again, err := im.Import(ctx, dir)
+	if err != nil {
+		t.Fatalf("second import: %v", err)
+	}
+	if len(again.Imported) != 0 || again.Known != 2 {
+		t.Fatalf("expected sessions to be known on rescan, got %+v", again)
+	}
+
+	stream, _, err := svc.Send(ctx, agents.MessageRequest{ThreadID: thread.ID, Input: "Also cover the update handler"})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	for range stream.Events() {
+	}
+	_ = stream.Wait()
+	if len(adapter.requests) != 1 {
+		t.Fatalf("expected one adapter call, got %d", len(adapter.requests))
+	}
+	req := adapter.requests[0]
+	if req.ThreadExternalID != "sess-1" || req.Input != "Also cover the update handler" {
+		t.Fatalf("expected the session to be resumed without replay, got %+v", req)
+	}
+}
+
+func TestMatchProjectPrefersDeepestPath(t *testing.T) {
+	projects := []discovery.Project{{ID: 1, Path: "/work"}, {ID: 2, Path: "/work/api"}, {ID: 3, Path: "/work/api-old"}}
+	for cwd, want := range map[string]int64{"/work/api/cmd": 2, "/work/api": 2, "/work/api-old/x": 3, "/work/web": 1, "/elsewhere": 0, "": 0} {
+		project, ok := matchProject(projects, cwd)
+		if got := project.ID; ok != (want != 0) || got != want {
+			t.Errorf("matchProject(%q) = %d, %v; want %d", cwd, got, ok, want)
+		}
+	}
+}
@@ -0,0 +1,392 @@
+package sessions
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"codex-ui/internal/agents"
+)
+
+// Session is a Codex CLI session file converted to conversation entries.
+type Session struct {
+	ID             string
+	Path           string
+	Cwd            string
+	StartedAt      time.Time
+	Model          string
+	ReasoningLevel string
+	Entries        []agents.ConversationEntryDTO
+	// Usage holds the tokens of each turn, matching the usage entries.
+	Usage []agents.ImportedUsageDTO
+}
+
+// rolloutLine is one line of a session file. Current files wrap every record
+// as {timestamp, type, payload}; older files start with a bare session header
+// followed by unwrapped response items.
+type rolloutLine struct {
+	Timestamp string          `json:"timestamp"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	// Legacy session header fields.
+	ID string `json:"id"`
+}
+
+type sessionMeta struct {
+	ID        string `json:"id"`
+	Timestamp string `json:"timestamp"`
+	Cwd       string `json:"cwd"`
+}
+
+type turnContext struct {
+	Cwd    string `json:"cwd"`
+	Model  string `json:"model"`
+	Effort string `json:"effort"`
+}
+
+type responseItem struct {
+	Type    string `json:"type"`
+	Role    string `json:"role"`
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Summary []struct {
+		Text string `json:"text"`
+	} `json:"summary"`
+	Name      string          `json:"name"`
+	Arguments string          `json:"arguments"`
+	Input     string          `json:"input"`
+	CallID    string          `json:"call_id"`
+	Output    json.RawMessage `json:"output"`
+	Status    string          `json:"status"`
+	Action    struct {
+		Type    string   `json:"type"`
+		Command []string `json:"command"`
+		Query   string   `json:"query"`
+	} `json:"action"`
+}
+
+type eventMsg struct {
+	Type string `json:"type"`
+	Info *struct {
+		Total tokenUsage `json:"total_token_usage"`
+	} `json:"info"`
+}
+
+type tokenUsage struct {
+	InputTokens       int `json:"input_tokens"`
+	CachedInputTokens int `json:"cached_input_tokens"`
+	OutputTokens      int `json:"output_tokens"`
+}
+
+var environmentCwd = regexp.MustCompile(`<cwd>([^<]+)</cwd>`)
+
+// injectedUserPrefixes mark user messages that Codex adds itself.
+var injectedUserPrefixes = []string{"<environment_context>", "<user_instructions>", "# AGENTS.md instructions"}
+
+// maxLineSize bounds a single JSONL record; command output can be large.
+const maxLineSize = 16 << 20
+
+// ParseFile reads a Codex session JSONL file.
+func ParseFile(path string) (Session, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Session{}, err
+	}
+	defer f.Close()
+
+	p := &parser{session: Session{Path: path}, calls: make(map[string]int)}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var record rolloutLine
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			return Session{}, fmt.Errorf("parse %s: %w", path, err)
+		}
+		p.handle(record, []byte(line))
+	}
+	if err := scanner.Err(); err != nil {
+		return Session{}, fmt.Errorf("read %s: %w", path, err)
+	}
+	p.flushUsage()
+	return p.session, nil
+}
+
+type parser struct {
+	session Session
+	// now is the timestamp of the record being handled.
+	now string
+	// calls maps a shell call ID to its command entry awaiting output.
+	calls     map[string]int
+	total     tokenUsage
+	reported  tokenUsage
+	sawPrompt bool
+}
+
+func (p *parser) handle(record rolloutLine, raw []byte) {
+	if ts := parseTimestamp(record.Timestamp); !ts.IsZero() {
+		p.now = ts.UTC().Format(time.RFC3339)
+		if p.session.StartedAt.IsZero() {
+			p.session.StartedAt = ts.UTC()
+		}
+	}
+	switch record.Type {
+	case "session_meta":
+		var meta sessionMeta
+		if json.Unmarshal(record.Payload, &meta) == nil {
+			p.session.ID = meta.ID
+			if meta.Cwd != "" {
+				p.session.Cwd = meta.Cwd
+			}
+			if ts := parseTimestamp(meta.Timestamp); !ts.IsZero() {
+				p.session.StartedAt = ts.UTC()
+			}
+		}
+	case "turn_context":
+		var tc turnContext
+		if json.Unmarshal(record.Payload, &tc) == nil {
+			if p.session.Cwd == "" {
+				p.session.Cwd = tc.Cwd
+			}
+			if tc.Model != "" {
+				p.session.Model = tc.Model
+			}
+			if tc.Effort != "" {
+				p.session.ReasoningLevel = tc.Effort
+			}
+		}
+	case "response_item":
+		p.responseItem(record.Payload)
+	case "event_msg":
+		var msg eventMsg
+		if json.Unmarshal(record.Payload, &msg) == nil && msg.Type == "token_count" && msg.Info != nil {
+			p.total = msg.Info.Total
+		}
+	default:
+		// Legacy files: a session header, then bare response items.
+		if record.Payload != nil {
+			return
+		}
+		if record.Type == "" && record.ID != "" && p.session.ID == "" {
+			var meta sessionMeta
+			_ = json.Unmarshal(raw, &meta)
+			p.session.ID = meta.ID
+			if ts := parseTimestamp(meta.Timestamp); !ts.IsZero() {
+				p.session.StartedAt = ts.UTC()
+				p.now = ts.UTC().Format(time.RFC3339)
+			}
+			return
+		}
+		p.responseItem(raw)
+	}
+}
+
+func (p *parser) responseItem(raw json.RawMessage) {
+	var item responseItem
+	if err := json.Unmarshal(raw, &item); err != nil {
+		return
+	}
+	switch item.Type {
+	case "message":
+		var parts []string
+		for _, c := range item.Content {
+			if strings.TrimSpace(c.Text) != "" {
+				parts = append(parts, c.Text)
+			}
+		}
+		text := strings.TrimSpace(strings.Join(parts, "\n"))
+		switch item.Role {
+		case "user":
+			if injectedUserMessage(text) {
+				if m := environmentCwd.FindStringSubmatch(text); m != nil && p.session.Cwd == "" {
+					p.session.Cwd = strings.TrimSpace(m[1])
+				}
+				return
+			}
+			if p.sawPrompt {
+				p.flushUsage()
+			}
+			p.sawPrompt = true
+			p.add(agents.ConversationEntryDTO{Role: "user", Text: text})
+		case "assistant":
+			if text != "" {
+				p.addItem(&agents.AgentItemDTO{Type: "agent_message", Text: text})
+			}
+		}
+	case "reasoning":
+		var parts []string
+		for _, s := range item.Summary {
+			if strings.TrimSpace(s.Text) != "" {
+				parts = append(parts, s.Text)
+			}
+		}
+		if len(parts) > 0 {
+			p.addItem(&agents.AgentItemDTO{Type: "reasoning", Reasoning: strings.Join(parts, "\n")})
+		}
+	case "local_shell_call":
+		p.addCommand(item.CallID, shellJoin(item.Action.Command))
+	case "function_call", "custom_tool_call":
+		p.toolCall(item)
+	case "function_call_output", "custom_tool_call_output":
+		p.callOutput(item.CallID, item.Output)
+	case "web_search_call":
+		if item.Action.Query != "" {
+			p.addItem(&agents.AgentItemDTO{Type: "web_search", WebSearch: &agents.WebSearchDTO{Query: item.Action.Query}})
+		}
+	}
+}
+
+func (p *parser) toolCall(item responseItem) {
+	switch item.Name {
+	case "shell", "container.exec", "shell_command", "exec_command":
+		var args struct {
+			Command json.RawMessage `json:"command"`
+			Cmd     string          `json:"cmd"`
+		}
+		_ = json.Unmarshal([]byte(item.Arguments), &args)
+		command := args.Cmd
+		var argv []string
+		if json.Unmarshal(args.Command, &argv) == nil {
+			command = shellJoin(argv)
+		} else {
+			var s string
+			if json.Unmarshal(args.Command, &s) == nil {
+				command = s
+			}
+		}
+		p.addCommand(item.CallID, command)
+	case "apply_patch":
+		patch := item.Input
+		if patch == "" {
+			var args struct {
+				Input string `json:"input"`
+			}
+			_ = json.Unmarshal([]byte(item.Arguments), &args)
+			patch = args.Input
+		}
+		if changes := patchChanges(patch); len(changes) > 0 {
+			p.addItem(&agents.AgentItemDTO{Type: "file_change", FileDiffs: changes})
+		}
+	default:
+		server, tool := "", item.Name
+		if i := strings.Index(tool, "__"); i > 0 {
+			server, tool = tool[:i], tool[i+2:]
+		}
+		p.addItem(&agents.AgentItemDTO{Type: "mcp_tool_call", ToolCall: &agents.ToolCallDTO{Server: server, Tool: tool, Status: "completed"}})
+	}
+}
+
+func (p *parser) addCommand(callID, command string) {
+	p.addItem(&agents.AgentItemDTO{Type: "command_execution", Command: &agents.CommandExecutionDTO{Command: command, Status: "completed"}})
+	if callID != "" {
+		p.calls[callID] = len(p.session.Entries) - 1
+	}
+}
+
+// callOutput attaches a shell call's output to its command entry. Output is a
+// JSON string holding either {output, metadata: {exit_code}} or plain text.
+func (p *parser) callOutput(callID string, raw json.RawMessage) {
+	idx, ok := p.calls[callID]
+	if !ok {
+		return
+	}
+	delete(p.calls, callID)
+	var text string
+	if json.Unmarshal(raw, &text) != nil {
+		var obj struct {
+			Content string `json:"content"`
+		}
+		if json.Unmarshal(raw, &obj) != nil {
+			return
+		}
+		text = obj.Content
+	}
+	command := p.session.Entries[idx].Item.Command
+	var structured struct {
+		Output   string `json:"output"`
+		Metadata struct {
+			ExitCode *int `json:"exit_code"`
+		} `json:"metadata"`
+	}
+	if json.Unmarshal([]byte(text), &structured) == nil && (structured.Output != "" || structured.Metadata.ExitCode != nil) {
+		command.AggregatedOutput = structured.Output
+		command.ExitCode = structured.Metadata.ExitCode
+		return
+	}
+	command.AggregatedOutput = text
+}
+
+// flushUsage records the tokens used since the last report as a usage entry
+// and a ledger row.
+func (p *parser) flushUsage() {
+	delta := agents.UsageDTO{
+		InputTokens:       p.total.InputTokens - p.reported.InputTokens,
+		CachedInputTokens: p.total.CachedInputTokens - p.reported.CachedInputTokens,
+		OutputTokens:      p.total.OutputTokens - p.reported.OutputTokens,
+	}
+	if delta.InputTokens <= 0 && delta.OutputTokens <= 0 {
+		return
+	}
+	p.reported = p.total
+	p.add(agents.UsageEntry(delta))
+	p.session.Usage = append(p.session.Usage, agents.ImportedUsageDTO{Usage: delta, CompletedAt: p.now})
+}
+
+func (p *parser) addItem(item *agents.AgentItemDTO) {
+	p.add(agents.ConversationEntryDTO{Role: "agent", Item: item})
+}
+
+func (p *parser) add(entry agents.ConversationEntryDTO) {
+	entry.CreatedAt = p.now
+	p.session.Entries = append(p.session.Entries, entry)
+}
+
+func injectedUserMessage(text string) bool {
+	for _, prefix := range injectedUserPrefixes {
+		if strings.HasPrefix(text, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// patchChanges lists the files an apply_patch body touches.
+func patchChanges(patch string) []agents.FileChangeDTO {
+	var changes []agents.FileChangeDTO
+	for _, line := range strings.Split(patch, "\n") {
+		for prefix, kind := range map[string]string{"*** Add File: ": "add", "*** Update File: ": "update", "*** Delete File: ": "delete"} {
+			if path, ok := strings.CutPrefix(strings.TrimSpace(line), prefix); ok {
+				changes = append(changes, agents.FileChangeDTO{Path: strings.TrimSpace(path), Kind: kind, Status: "completed"})
+			}
+		}
+	}
+	return changes
+}
+
+// shellJoin renders argv for display, unwrapping `bash -lc "<script>"`.
+func shellJoin(argv []string) string {
+	if len(argv) == 3 && (argv[1] == "-lc" || argv[1] == "-c") {
+		return argv[2]
+	}
+	return strings.Join(argv, " ")
+}
+
+func parseTimestamp(value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	ts, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return ts
+}
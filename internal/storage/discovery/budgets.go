@@ -0,0 +1,99 @@
+package discovery
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Budget limits the token spend and duration of agent turns. Zero fields are
+// unlimited.
+type Budget struct {
+	// MaxTurnTokens caps the input plus output tokens of a single turn.
+	MaxTurnTokens int64 `json:"maxTurnTokens,omitempty"`
+	// MaxTurnSeconds caps the wall-clock duration of a single turn.
+	MaxTurnSeconds int64 `json:"maxTurnSeconds,omitempty"`
+	// MaxThreadTokens caps the input plus output tokens of all turns of a thread.
+	MaxThreadTokens int64 `json:"maxThreadTokens,omitempty"`
+}
+
+const (
+	budgetScopeProject = "project"
+	budgetScopeThread  = "thread"
+)
+
+// GetProjectBudget returns a project's budget, or zero limits when none is stored.
+func (r *Repository) GetProjectBudget(ctx context.Context, projectID int64) (Budget, error) {
+	return r.getBudget(ctx, budgetScopeProject, projectID)
+}
+
+// SaveProjectBudget replaces a project's budget.
+func (r *Repository) SaveProjectBudget(ctx context.Context, projectID int64, budget Budget) error {
+	return r.saveBudget(ctx, budgetScopeProject, projectID, budget)
+}
+
+// GetThreadBudget returns a thread's own budget, or zero limits when none is stored.
+func (r *Repository) GetThreadBudget(ctx context.Context, threadID int64) (Budget, error) {
+	return r.getBudget(ctx, budgetScopeThread, threadID)
+}
+
+// SaveThreadBudget replaces a thread's own budget.
+func (r *Repository) SaveThreadBudget(ctx context.Context, threadID int64, budget Budget) error {
+	return r.saveBudget(ctx, budgetScopeThread, threadID, budget)
+}
+
+// DeleteThreadBudget removes a thread's own budget.
+func (r *Repository) DeleteThreadBudget(ctx context.Context, threadID int64) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM budgets WHERE scope = ? AND scope_id = ?`, budgetScopeThread, threadID); err != nil {
+		return fmt.Errorf("delete thread budget: %w", err)
+	}
+	return nil
+}
+
+// SumThreadTokens returns the input plus output tokens recorded for a thread
+// in the usage ledger.
+func (r *Repository) SumThreadTokens(ctx context.Context, threadID int64) (int64, error) {
+	var total int64
+	err := r.db.QueryRowContext(ctx, `
+        SELECT COALESCE(SUM(input_tokens + output_tokens), 0)
+        FROM turn_usage
+        WHERE thread_id = ?
+    `, threadID).Scan(&total)
+	if err != nil {
+		return 0, fmt.Errorf("sum thread tokens: %w", err)
+	}
+	return total, nil
+}
+
+func (r *Repository) getBudget(ctx context.Context, scope string, id int64) (Budget, error) {
+	var budget Budget
+	err := r.db.QueryRowContext(ctx, `
+        SELECT max_turn_tokens, max_turn_seconds, max_thread_tokens
+        FROM budgets
+        WHERE scope = ? AND scope_id = ?
+    `, scope, id).Scan(&budget.MaxTurnTokens, &budget.MaxTurnSeconds, &budget.MaxThreadTokens)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Budget{}, nil
+	}
+	if err != nil {
+		return Budget{}, fmt.Errorf("select %s budget: %w", scope, err)
+	}
+	return budget, nil
+}
+
+func (r *Repository) saveBudget(ctx context.Context, scope string, id int64, budget Budget) error {
+	_, err := r.db.ExecContext(ctx, `
+        INSERT INTO budgets (scope, scope_id, max_turn_tokens, max_turn_seconds, max_thread_tokens)
+        VALUES (?, ?, ?, ?, ?)
+        ON CONFLICT(scope, scope_id) DO UPDATE SET
+            max_turn_tokens = excluded.max_turn_tokens,
+            max_turn_seconds = excluded.max_turn_seconds,
+            max_thread_tokens = excluded.max_thread_tokens,
+            updated_at = CURRENT_TIMESTAMP
+    `, scope, id, budget.MaxTurnTokens, budget.MaxTurnSeconds, budget.MaxThreadTokens)
+	if err != nil {
+		return fmt.Errorf("save %s budget: %w", scope, err)
+	}
+	return nil
+}
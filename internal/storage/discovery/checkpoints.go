@@ -0,0 +1,133 @@
+package discovery
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Checkpoint links a snapshot of a thread's worktree, taken before a turn, to
+// the user entry that started the turn.
+type Checkpoint struct {
+	ID       int64  `json:"id"`
+	ThreadID int64  `json:"threadId"`
+	EntryID  int64  `json:"entryId"`
+	Ref      string `json:"ref"`
+	Commit   string `json:"commit"`
+	// Head is the commit HEAD pointed to; empty on an unborn branch.
+	Head       string     `json:"head,omitempty"`
+	RestoredAt *time.Time `json:"restoredAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+const checkpointColumns = `id, thread_id, entry_id, ref, commit_sha, head_sha, restored_at, created_at`
+
+func scanCheckpoint(row rowScanner) (Checkpoint, error) {
+	var (
+		cp       Checkpoint
+		head     sql.NullString
+		restored sql.NullTime
+	)
+	if err := row.Scan(&cp.ID, &cp.ThreadID, &cp.EntryID, &cp.Ref, &cp.Commit, &head, &restored, &cp.CreatedAt); err != nil {
+		return Checkpoint{}, err
+	}
+	cp.Head = head.String
+	if restored.Valid {
+		cp.RestoredAt = &restored.Time
+	}
+	return cp, nil
+}
+
+// CreateCheckpoint records a worktree snapshot.
+func (r *Repository) CreateCheckpoint(ctx context.Context, cp Checkpoint) (Checkpoint, error) {
+	res, err := r.db.ExecContext(ctx, `
+        INSERT INTO thread_checkpoints (thread_id, entry_id, ref, commit_sha, head_sha)
+        VALUES (?, ?, ?, ?, ?)
+    `, cp.ThreadID, cp.EntryID, cp.Ref, cp.Commit, nullIfEmpty(cp.Head))
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("insert checkpoint: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("checkpoint last insert id: %w", err)
+	}
+	return r.GetCheckpoint(ctx, id)
+}
+
+// GetCheckpoint retrieves a checkpoint by identifier.
+func (r *Repository) GetCheckpoint(ctx context.Context, id int64) (Checkpoint, error) {
+	cp, err := scanCheckpoint(r.db.QueryRowContext(ctx, `
+        SELECT `+checkpointColumns+`
+        FROM thread_checkpoints
+        WHERE id = ?
+    `, id))
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("select checkpoint: %w", err)
+	}
+	return cp, nil
+}
+
+// ListCheckpoints returns a thread's checkpoints, oldest first.
+func (r *Repository) ListCheckpoints(ctx context.Context, threadID int64) ([]Checkpoint, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT `+checkpointColumns+`
+        FROM thread_checkpoints
+        WHERE thread_id = ?
+        ORDER BY id
+    `, threadID)
+	if err != nil {
+		return nil, fmt.Errorf("select checkpoints: %w", err)
+	}
+	defer rows.Close()
+	var checkpoints []Checkpoint
+	for rows.Next() {
+		cp, err := scanCheckpoint(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan checkpoint: %w", err)
+		}
+		checkpoints = append(checkpoints, cp)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate checkpoints: %w", err)
+	}
+	return checkpoints, nil
+}
+
+// DeleteCheckpoints removes a thread's checkpoint records.
+func (r *Repository) DeleteCheckpoints(ctx context.Context, threadID int64) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM thread_checkpoints WHERE thread_id = ?`, threadID); err != nil {
+		return fmt.Errorf("delete checkpoints: %w", err)
+	}
+	return nil
+}
+
+// RollBackEntries marks the thread's entries from entryID onward as rolled
+// back and clears the mark on earlier entries, so restoring a later
+// checkpoint brings its turns back. It runs in a single transaction with
+// marking the checkpoint restored.
+func (r *Repository) RollBackEntries(ctx context.Context, checkpointID, threadID, entryID int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin roll back: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	now := time.Now().UTC()
+	if _, err := tx.ExecContext(ctx, `
+        UPDATE thread_entries
+        SET rolled_back_at = CASE
+                WHEN id >= ? THEN COALESCE(rolled_back_at, ?)
+                ELSE NULL
+            END
+        WHERE thread_id = ?
+    `, entryID, now, threadID); err != nil {
+		return fmt.Errorf("roll back entries: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE thread_checkpoints SET restored_at = ? WHERE id = ?`, now, checkpointID); err != nil {
+		return fmt.Errorf("mark checkpoint restored: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit roll back: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package discovery
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ThreadCommit links a commit made after a turn to the user entry that
+// started the turn and the agent message that ended it.
+type ThreadCommit struct {
+	ID           int64     `json:"id"`
+	ThreadID     int64     `json:"threadId"`
+	EntryID      int64     `json:"entryId"`
+	AgentEntryID int64     `json:"agentEntryId,omitempty"`
+	Commit       string    `json:"commit"`
+	Subject      string    `json:"subject"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+const threadCommitColumns = `id, thread_id, entry_id, agent_entry_id, commit_sha, subject, created_at`
+
+func scanThreadCommit(row rowScanner) (ThreadCommit, error) {
+	var (
+		c          ThreadCommit
+		agentEntry sql.NullInt64
+	)
+	if err := row.Scan(&c.ID, &c.ThreadID, &c.EntryID, &agentEntry, &c.Commit, &c.Subject, &c.CreatedAt); err != nil {
+		return ThreadCommit{}, err
+	}
+	c.AgentEntryID = agentEntry.Int64
+	return c, nil
+}
+
+// RecordThreadCommit stores a commit made after a turn.
+func (r *Repository) RecordThreadCommit(ctx context.Context, c ThreadCommit) (ThreadCommit, error) {
+	res, err := r.db.ExecContext(ctx, `
+        INSERT INTO thread_commits (thread_id, entry_id, agent_entry_id, commit_sha, subject)
+        VALUES (?, ?, ?, ?, ?)
+    `, c.ThreadID, c.EntryID, nullIfZero(c.AgentEntryID), c.Commit, c.Subject)
+	if err != nil {
+		return ThreadCommit{}, fmt.Errorf("insert thread commit: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return ThreadCommit{}, fmt.Errorf("thread commit last insert id: %w", err)
+	}
+	commit, err := scanThreadCommit(r.db.QueryRowContext(ctx, `
+        SELECT `+threadCommitColumns+`
+        FROM thread_commits
+        WHERE id = ?
+    `, id))
+	if err != nil {
+		return ThreadCommit{}, fmt.Errorf("select thread commit: %w", err)
+	}
+	return commit, nil
+}
+
+// ListThreadCommits returns a thread's commits, oldest first.
+func (r *Repository) ListThreadCommits(ctx context.Context, threadID int64) ([]ThreadCommit, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT `+threadCommitColumns+`
+        FROM thread_commits
+        WHERE thread_id = ?
+        ORDER BY id
+    `, threadID)
+	if err != nil {
+		return nil, fmt.Errorf("select thread commits: %w", err)
+	}
+	defer rows.Close()
+	var commits []ThreadCommit
+	for rows.Next() {
+		c, err := scanThreadCommit(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan thread commit: %w", err)
+		}
+		commits = append(commits, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate thread commits: %w", err)
+	}
+	return commits, nil
+}
+
+// DeleteThreadCommits removes a thread's commit records.
+func (r *Repository) DeleteThreadCommits(ctx context.Context, threadID int64) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM thread_commits WHERE thread_id = ?`, threadID); err != nil {
+		return fmt.Errorf("delete thread commits: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package discovery
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"codex-ui/internal/storage/migrate"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestRepositoryUpsertConversationEntryKeepsPosition(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	ctx := context.Background()
+	project, _ := repo.UpsertProject(ctx, UpsertProjectParams{Path: "/tmp/upsert"})
+	thread, err := repo.CreateThread(ctx, CreateThreadParams{ProjectID: project.ID, Title: "t", Model: "m"})
+	if err != nil {
+		t.Fatalf("create thread: %v", err)
+	}
+	start := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	upsert := func(text string, at time.Time, completed bool) ConversationEntry {
+		t.Helper()
+		params := CreateConversationEntryParams{ThreadID: thread.ID, Role: "agent", EntryType: "command_execution", Payload: json.RawMessage(`{"text":"` + text + `"}`), CreatedAt: at, UpdatedAt: at, ItemKey: "7:item_0"}
+		if completed {
+			params.CompletedAt = &at
+		}
+		entry, err := repo.UpsertConversationEntry(ctx, params)
+		if err != nil {
+			t.Fatalf("upsert: %v", err)
+		}
+		return entry
+	}
+	first := upsert("started", start, false)
+	if _, err := repo.CreateConversationEntry(ctx, CreateConversationEntryParams{ThreadID: thread.ID, Role: "system", EntryType: "system_message", CreatedAt: start.Add(time.Second)}); err != nil {
+		t.Fatalf("create entry: %v", err)
+	}
+	upsert("running", start.Add(2*time.Second), false)
+	final := upsert("done", start.Add(3*time.Second), true)
+
+	if final.ID != first.ID || !final.CreatedAt.Equal(start) || string(final.Payload) != `{"text":"done"}` {
+		t.Fatalf("expected one entry with the final state, got %+v", final)
+	}
+	if final.CompletedAt == nil || !final.CompletedAt.Equal(start.Add(3*time.Second)) || !final.UpdatedAt.Equal(*final.CompletedAt) {
+		t.Fatalf("unexpected timestamps %+v", final)
+	}
+	entries, _ := repo.ListConversationEntries(ctx, thread.ID)
+	if len(entries) != 2 || entries[0].ID != first.ID {
+		t.Fatalf("expected the item to keep its place, got %+v", entries)
+	}
+}
+
+func TestMigrationFoldsItemEventEntries(t *testing.T) {
+	db, err := sql.Open("sqlite", "file:"+t.Name()+"?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	if err := migrate.UpTo(db, 22); err != nil {
+		t.Fatalf("migrate to 22: %v", err)
+	}
+	exec := func(query string, args ...any) int64 {
+		t.Helper()
+		res, err := db.Exec(query, args...)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		id, _ := res.LastInsertId()
+		return id
+	}
+	exec(`INSERT INTO projects (path) VALUES ('/tmp/fold')`)
+	thread := exec(`INSERT INTO threads (project_id, title, model) VALUES (1, 't', 'm')`)
+	entry := func(role, payload, at string) int64 {
+		return exec(`INSERT INTO thread_entries (thread_id, role, entry_type, payload, created_at, updated_at) VALUES (?, ?, 'x', ?, ?, ?)`, thread, role, payload, at, at)
+	}
+	entry("user", `{"text":"first"}`, "2025-03-01 12:00:00")
+	started := entry("agent", `{"id":"item_0","type":"command_execution","command":{"status":"in_progress"}}`, "2025-03-01 12:00:01")
+	entry("agent", `{"id":"item_0","type":"command_execution","command":{"status":"in_progress","aggregatedOutput":"a"}}`, "2025-03-01 12:00:02")
+	reply := entry("agent", `{"id":"item_1","type":"agent_message","text":"hi"}`, "2025-03-01 12:00:03")
+	completed := entry("agent", `{"id":"item_0","type":"command_execution","command":{"status":"completed","aggregatedOutput":"ab"}}`, "2025-03-01 12:00:04")
+	entry("agent", `{"type":"reasoning","reasoning":"no id"}`, "2025-03-01 12:00:05")
+	// The next turn reuses the item IDs.
+	entry("user", `{"text":"second"}`, "2025-03-01 12:01:00")
+	entry("agent", `{"id":"item_0","type":"agent_message","text":"again"}`, "2025-03-01 12:01:01")
+	exec(`INSERT INTO thread_commits (thread_id, entry_id, agent_entry_id, commit_sha, subject) VALUES (?, 1, ?, 'abc', 's')`, thread, completed)
+
+	if err := migrate.Up(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	repo := NewRepository(db)
+	ctx := context.Background()
+	entries, err := repo.ListConversationEntries(ctx, thread)
+	if err != nil {
+		t.Fatalf("list entries: %v", err)
+	}
+	if len(entries) != 6 {
+		t.Fatalf("expected six entries after folding, got %d", len(entries))
+	}
+	command := entries[1]
+	if command.ID != started || command.ItemKey != "1:item_0" || string(command.Payload) != `{"id":"item_0","type":"command_execution","command":{"status":"completed","aggregatedOutput":"ab"}}` {
+		t.Fatalf("expected the command to keep its first row with its final state, got %+v", command)
+	}
+	if command.CreatedAt.Format(time.DateTime) != "2025-03-01 12:00:01" || command.CompletedAt == nil || command.CompletedAt.Format(time.DateTime) != "2025-03-01 12:00:04" {
+		t.Fatalf("unexpected command timestamps %+v", command)
+	}
+	if entries[2].ID != reply || entries[3].ItemKey != "" || entries[5].ItemKey != "7:item_0" {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+	commits, _ := repo.ListThreadCommits(ctx, thread)
+	if len(commits) != 1 || commits[0].AgentEntryID != started {
+		t.Fatalf("expected commits to point at the folded entry, got %+v", commits)
+	}
+	hits, err := repo.SearchConversations(ctx, "ab", 0, 10)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(hits) != 1 || hits[0].EntryID != started {
+		t.Fatalf("expected the search index to follow the folded entry, got %+v", hits)
+	}
+}
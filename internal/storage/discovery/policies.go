@@ -0,0 +1,268 @@
+package discovery
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// PolicyRule allows, denies or asks about agent commands or file paths that
+// match its pattern. Rules are evaluated in position order.
+type PolicyRule struct {
+	ID          int64     `json:"id"`
+	ProjectID   int64     `json:"projectId"`
+	Position    int64     `json:"position"`
+	Action      string    `json:"action"`
+	Target      string    `json:"target"`
+	Pattern     string    `json:"pattern"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// SavePolicyRuleParams holds the editable fields of a policy rule.
+type SavePolicyRuleParams struct {
+	ProjectID   int64
+	Position    int64
+	Action      string
+	Target      string
+	Pattern     string
+	Description string
+}
+
+// PolicyDecision records the outcome of evaluating a command or path against
+// a project's policy.
+type PolicyDecision struct {
+	ID        int64  `json:"id"`
+	ThreadID  int64  `json:"threadId"`
+	ProjectID int64  `json:"projectId"`
+	RuleID    int64  `json:"ruleId,omitempty"`
+	Pattern   string `json:"pattern,omitempty"`
+	Action    string `json:"action"`
+	Target    string `json:"target"`
+	Subject   string `json:"subject"`
+	ItemID    string `json:"itemId,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	// ApprovedAt is set once the user approves an ask decision.
+	ApprovedAt *time.Time `json:"approvedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// PolicyDecisionQuery filters ListPolicyDecisions. Zero fields match all.
+type PolicyDecisionQuery struct {
+	ProjectID int64
+	ThreadID  int64
+	Limit     int
+}
+
+const policyRuleColumns = `id, project_id, position, action, target, pattern, description, created_at, updated_at`
+
+const policyDecisionColumns = `id, thread_id, project_id, rule_id, pattern, action, target, subject, item_id, reason, approved_at, created_at`
+
+func scanPolicyRule(row rowScanner) (PolicyRule, error) {
+	var (
+		rule        PolicyRule
+		description sql.NullString
+	)
+	if err := row.Scan(&rule.ID, &rule.ProjectID, &rule.Position, &rule.Action, &rule.Target, &rule.Pattern, &description, &rule.CreatedAt, &rule.UpdatedAt); err != nil {
+		return PolicyRule{}, err
+	}
+	rule.Description = description.String
+	return rule, nil
+}
+
+func scanPolicyDecision(row rowScanner) (PolicyDecision, error) {
+	var (
+		decision PolicyDecision
+		ruleID   sql.NullInt64
+		pattern  sql.NullString
+		itemID   sql.NullString
+		reason   sql.NullString
+		approved sql.NullTime
+	)
+	if err := row.Scan(&decision.ID, &decision.ThreadID, &decision.ProjectID, &ruleID, &pattern, &decision.Action, &decision.Target,
+		&decision.Subject, &itemID, &reason, &approved, &decision.CreatedAt); err != nil {
+		return PolicyDecision{}, err
+	}
+	decision.RuleID = ruleID.Int64
+	decision.Pattern = pattern.String
+	decision.ItemID = itemID.String
+	decision.Reason = reason.String
+	if approved.Valid {
+		t := approved.Time
+		decision.ApprovedAt = &t
+	}
+	return decision, nil
+}
+
+// CreatePolicyRule inserts a policy rule.
+func (r *Repository) CreatePolicyRule(ctx context.Context, params SavePolicyRuleParams) (PolicyRule, error) {
+	res, err := r.db.ExecContext(ctx, `
+        INSERT INTO policy_rules (project_id, position, action, target, pattern, description)
+        VALUES (?, ?, ?, ?, ?, ?)
+    `, params.ProjectID, params.Position, params.Action, params.Target, params.Pattern, nullIfEmpty(params.Description))
+	if err != nil {
+		return PolicyRule{}, fmt.Errorf("insert policy rule: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return PolicyRule{}, fmt.Errorf("policy rule last insert id: %w", err)
+	}
+	return r.GetPolicyRule(ctx, id)
+}
+
+// UpdatePolicyRule replaces the editable fields of a rule. The project is fixed.
+func (r *Repository) UpdatePolicyRule(ctx context.Context, id int64, params SavePolicyRuleParams) (PolicyRule, error) {
+	res, err := r.db.ExecContext(ctx, `
+        UPDATE policy_rules
+        SET position = ?, action = ?, target = ?, pattern = ?, description = ?, updated_at = CURRENT_TIMESTAMP
+        WHERE id = ?
+    `, params.Position, params.Action, params.Target, params.Pattern, nullIfEmpty(params.Description), id)
+	if err != nil {
+		return PolicyRule{}, fmt.Errorf("update policy rule: %w", err)
+	}
+	if rows, err := res.RowsAffected(); err == nil && rows == 0 {
+		return PolicyRule{}, sql.ErrNoRows
+	}
+	return r.GetPolicyRule(ctx, id)
+}
+
+// GetPolicyRule retrieves a policy rule by identifier.
+func (r *Repository) GetPolicyRule(ctx context.Context, id int64) (PolicyRule, error) {
+	rule, err := scanPolicyRule(r.db.QueryRowContext(ctx, `
+        SELECT `+policyRuleColumns+`
+        FROM policy_rules
+        WHERE id = ?
+    `, id))
+	if err != nil {
+		return PolicyRule{}, fmt.Errorf("select policy rule: %w", err)
+	}
+	return rule, nil
+}
+
+// ListPolicyRules lists a project's rules in evaluation order.
+func (r *Repository) ListPolicyRules(ctx context.Context, projectID int64) ([]PolicyRule, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT `+policyRuleColumns+`
+        FROM policy_rules
+        WHERE project_id = ?
+        ORDER BY position, id
+    `, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("select policy rules: %w", err)
+	}
+	defer rows.Close()
+	var rules []PolicyRule
+	for rows.Next() {
+		rule, err := scanPolicyRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan policy rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate policy rules: %w", err)
+	}
+	return rules, nil
+}
+
+// DeletePolicyRule removes a policy rule. Recorded decisions are kept.
+func (r *Repository) DeletePolicyRule(ctx context.Context, id int64) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM policy_rules WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("delete policy rule: %w", err)
+	}
+	return nil
+}
+
+// RecordPolicyDecision appends a decision to the audit log.
+func (r *Repository) RecordPolicyDecision(ctx context.Context, decision PolicyDecision) (PolicyDecision, error) {
+	res, err := r.db.ExecContext(ctx, `
+        INSERT INTO policy_decisions (thread_id, project_id, rule_id, pattern, action, target, subject, item_id, reason)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `, decision.ThreadID, decision.ProjectID, nullIfZero(decision.RuleID), nullIfEmpty(decision.Pattern), decision.Action,
+		decision.Target, decision.Subject, nullIfEmpty(decision.ItemID), nullIfEmpty(decision.Reason))
+	if err != nil {
+		return PolicyDecision{}, fmt.Errorf("insert policy decision: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return PolicyDecision{}, fmt.Errorf("policy decision last insert id: %w", err)
+	}
+	return r.GetPolicyDecision(ctx, id)
+}
+
+// GetPolicyDecision retrieves a recorded decision by identifier.
+func (r *Repository) GetPolicyDecision(ctx context.Context, id int64) (PolicyDecision, error) {
+	decision, err := scanPolicyDecision(r.db.QueryRowContext(ctx, `
+        SELECT `+policyDecisionColumns+`
+        FROM policy_decisions
+        WHERE id = ?
+    `, id))
+	if err != nil {
+		return PolicyDecision{}, fmt.Errorf("select policy decision: %w", err)
+	}
+	return decision, nil
+}
+
+// ListPolicyDecisions lists recorded decisions, newest first.
+func (r *Repository) ListPolicyDecisions(ctx context.Context, query PolicyDecisionQuery) ([]PolicyDecision, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT `+policyDecisionColumns+`
+        FROM policy_decisions
+        WHERE (? = 0 OR project_id = ?) AND (? = 0 OR thread_id = ?)
+        ORDER BY id DESC
+        LIMIT ?
+    `, query.ProjectID, query.ProjectID, query.ThreadID, query.ThreadID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("select policy decisions: %w", err)
+	}
+	defer rows.Close()
+	var decisions []PolicyDecision
+	for rows.Next() {
+		decision, err := scanPolicyDecision(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan policy decision: %w", err)
+		}
+		decisions = append(decisions, decision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate policy decisions: %w", err)
+	}
+	return decisions, nil
+}
+
+// ApprovePolicyDecision marks an ask decision as approved.
+func (r *Repository) ApprovePolicyDecision(ctx context.Context, id int64) (PolicyDecision, error) {
+	res, err := r.db.ExecContext(ctx, `
+        UPDATE policy_decisions
+        SET approved_at = COALESCE(approved_at, CURRENT_TIMESTAMP)
+        WHERE id = ? AND action = 'ask'
+    `, id)
+	if err != nil {
+		return PolicyDecision{}, fmt.Errorf("approve policy decision: %w", err)
+	}
+	if rows, err := res.RowsAffected(); err == nil && rows == 0 {
+		return PolicyDecision{}, sql.ErrNoRows
+	}
+	return r.GetPolicyDecision(ctx, id)
+}
+
+// PolicySubjectApproved reports whether the user approved an ask decision for
+// the same target and subject on the thread.
+func (r *Repository) PolicySubjectApproved(ctx context.Context, threadID int64, target, subject string) (bool, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `
+        SELECT COUNT(*)
+        FROM policy_decisions
+        WHERE thread_id = ? AND target = ? AND subject = ? AND action = 'ask' AND approved_at IS NOT NULL
+    `, threadID, target, subject).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("select approved policy decisions: %w", err)
+	}
+	return count > 0, nil
+}
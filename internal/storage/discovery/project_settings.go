@@ -0,0 +1,85 @@
+package discovery
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ProjectSettings holds per-project defaults. A project without a row has
+// zero-valued settings.
+type ProjectSettings struct {
+	ProjectID             int64  `json:"projectId"`
+	DefaultModel          string `json:"defaultModel,omitempty"`
+	DefaultReasoningLevel string `json:"defaultReasoningLevel,omitempty"`
+	DefaultSandboxMode    string `json:"defaultSandboxMode,omitempty"`
+	// Instructions are prepended to the first turn of each new thread.
+	Instructions string `json:"instructions,omitempty"`
+	// AutoCommit commits a thread's worktree after each completed turn.
+	AutoCommit bool `json:"autoCommit,omitempty"`
+}
+
+// projectSettingsSelect lists the settings columns of a project_settings row
+// joined as s.
+const projectSettingsSelect = `s.default_model, s.default_reasoning_level, s.default_sandbox_mode, s.instructions, s.auto_commit`
+
+// settingsColumns receives the columns of projectSettingsSelect.
+type settingsColumns struct {
+	model        sql.NullString
+	reasoning    sql.NullString
+	sandbox      sql.NullString
+	instructions sql.NullString
+	autoCommit   sql.NullBool
+}
+
+func (c *settingsColumns) dest() []any {
+	return []any{&c.model, &c.reasoning, &c.sandbox, &c.instructions, &c.autoCommit}
+}
+
+func (c settingsColumns) settings(projectID int64) ProjectSettings {
+	return ProjectSettings{
+		ProjectID:             projectID,
+		DefaultModel:          c.model.String,
+		DefaultReasoningLevel: c.reasoning.String,
+		DefaultSandboxMode:    c.sandbox.String,
+		Instructions:          c.instructions.String,
+		AutoCommit:            c.autoCommit.Bool,
+	}
+}
+
+// GetProjectSettings returns the settings of a project, or zero values when none are stored.
+func (r *Repository) GetProjectSettings(ctx context.Context, projectID int64) (ProjectSettings, error) {
+	var set settingsColumns
+	err := r.db.QueryRowContext(ctx, `
+        SELECT `+projectSettingsSelect+`
+        FROM project_settings s
+        WHERE s.project_id = ?
+    `, projectID).Scan(set.dest()...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ProjectSettings{ProjectID: projectID}, nil
+	}
+	if err != nil {
+		return ProjectSettings{}, fmt.Errorf("select project settings: %w", err)
+	}
+	return set.settings(projectID), nil
+}
+
+// SaveProjectSettings inserts or replaces the settings of a project.
+func (r *Repository) SaveProjectSettings(ctx context.Context, settings ProjectSettings) error {
+	_, err := r.db.ExecContext(ctx, `
+        INSERT INTO project_settings (project_id, default_model, default_reasoning_level, default_sandbox_mode, instructions, auto_commit)
+        VALUES (?, ?, ?, ?, ?, ?)
+        ON CONFLICT(project_id) DO UPDATE SET
+            default_model = excluded.default_model,
+            default_reasoning_level = excluded.default_reasoning_level,
+            default_sandbox_mode = excluded.default_sandbox_mode,
+            instructions = excluded.instructions,
+            auto_commit = excluded.auto_commit,
+            updated_at = CURRENT_TIMESTAMP
+    `, settings.ProjectID, nullIfEmpty(settings.DefaultModel), nullIfEmpty(settings.DefaultReasoningLevel), nullIfEmpty(settings.DefaultSandboxMode), nullIfEmpty(settings.Instructions), settings.AutoCommit)
+	if err != nil {
+		return fmt.Errorf("save project settings: %w", err)
+	}
+	return nil
+}
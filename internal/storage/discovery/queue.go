@@ -0,0 +1,244 @@
+package discovery
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// QueuedTurn represents a follow-up turn waiting for the running turn of its thread.
+type QueuedTurn struct {
+	ID        int64           `json:"id"`
+	ThreadID  int64           `json:"threadId"`
+	Position  int             `json:"position"`
+	Request   json.RawMessage `json:"request"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// EnqueueTurn appends a serialised request to the end of a thread's turn queue.
+func (r *Repository) EnqueueTurn(ctx context.Context, threadID int64, request json.RawMessage) (QueuedTurn, error) {
+	if len(request) == 0 {
+		return QueuedTurn{}, fmt.Errorf("queued turn request is required")
+	}
+	res, err := r.db.ExecContext(ctx, `
+        INSERT INTO thread_turn_queue (thread_id, position, request, created_at)
+        VALUES (?, (SELECT COALESCE(MAX(position), 0) + 1 FROM thread_turn_queue WHERE thread_id = ?), ?, ?)
+    `, threadID, threadID, string(request), time.Now().UTC())
+	if err != nil {
+		return QueuedTurn{}, fmt.Errorf("insert queued turn: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return QueuedTurn{}, fmt.Errorf("queued turn last insert id: %w", err)
+	}
+	return r.GetQueuedTurn(ctx, id)
+}
+
+// GetQueuedTurn retrieves a queued turn by identifier.
+func (r *Repository) GetQueuedTurn(ctx context.Context, id int64) (QueuedTurn, error) {
+	var (
+		q       QueuedTurn
+		request string
+	)
+	err := r.db.QueryRowContext(ctx, `
+        SELECT id, thread_id, position, request, created_at
+        FROM thread_turn_queue
+        WHERE id = ?
+    `, id).Scan(&q.ID, &q.ThreadID, &q.Position, &request, &q.CreatedAt)
+	if err != nil {
+		return QueuedTurn{}, fmt.Errorf("select queued turn: %w", err)
+	}
+	q.Request = json.RawMessage(request)
+	return q, nil
+}
+
+// ListQueuedTurns returns the pending turns of a thread in execution order.
+func (r *Repository) ListQueuedTurns(ctx context.Context, threadID int64) ([]QueuedTurn, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT id, thread_id, position, request, created_at
+        FROM thread_turn_queue
+        WHERE thread_id = ?
+        ORDER BY position ASC, id ASC
+    `, threadID)
+	if err != nil {
+		return nil, fmt.Errorf("query queued turns: %w", err)
+	}
+	defer rows.Close()
+
+	var turns []QueuedTurn
+	for rows.Next() {
+		var (
+			q       QueuedTurn
+			request string
+		)
+		if err := rows.Scan(&q.ID, &q.ThreadID, &q.Position, &request, &q.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan queued turn: %w", err)
+		}
+		q.Request = json.RawMessage(request)
+		turns = append(turns, q)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate queued turns: %w", err)
+	}
+	return turns, nil
+}
+
+// NextQueuedTurn returns the head of a thread's queue or sql.ErrNoRows when it is empty.
+func (r *Repository) NextQueuedTurn(ctx context.Context, threadID int64) (QueuedTurn, error) {
+	var (
+		q       QueuedTurn
+		request string
+	)
+	err := r.db.QueryRowContext(ctx, `
+        SELECT id, thread_id, position, request, created_at
+        FROM thread_turn_queue
+        WHERE thread_id = ?
+        ORDER BY position ASC, id ASC
+        LIMIT 1
+    `, threadID).Scan(&q.ID, &q.ThreadID, &q.Position, &request, &q.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return QueuedTurn{}, sql.ErrNoRows
+		}
+		return QueuedTurn{}, fmt.Errorf("select next queued turn: %w", err)
+	}
+	q.Request = json.RawMessage(request)
+	return q, nil
+}
+
+// ClaimQueuedTurn reserves a queued turn for the caller until ttl has passed,
+// so it can be started without taking it off the queue. It returns
+// sql.ErrNoRows when the turn no longer exists or another dispatcher holds a
+// claim that has not expired yet.
+func (r *Repository) ClaimQueuedTurn(ctx context.Context, threadID, id int64, ttl time.Duration) error {
+	now := time.Now()
+	res, err := r.db.ExecContext(ctx, `
+        UPDATE thread_turn_queue SET claimed_until = ?
+        WHERE id = ? AND thread_id = ? AND (claimed_until IS NULL OR claimed_until <= ?)
+    `, now.Add(ttl).UnixMilli(), id, threadID, now.UnixMilli())
+	if err != nil {
+		return fmt.Errorf("claim queued turn: %w", err)
+	}
+	if rows, rerr := res.RowsAffected(); rerr == nil && rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ReleaseQueuedTurnClaim drops the claim on a queued turn, leaving it in place
+// for the next dispatch.
+func (r *Repository) ReleaseQueuedTurnClaim(ctx context.Context, threadID, id int64) error {
+	if _, err := r.db.ExecContext(ctx, `
+        UPDATE thread_turn_queue SET claimed_until = NULL WHERE id = ? AND thread_id = ?
+    `, id, threadID); err != nil {
+		return fmt.Errorf("release queued turn claim: %w", err)
+	}
+	return nil
+}
+
+// CountQueuedTurns reports how many turns are waiting for a thread.
+func (r *Repository) CountQueuedTurns(ctx context.Context, threadID int64) (int, error) {
+	var count int
+	if err := r.db.QueryRowContext(ctx, `
+        SELECT COUNT(*) FROM thread_turn_queue WHERE thread_id = ?
+    `, threadID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count queued turns: %w", err)
+	}
+	return count, nil
+}
+
+// ListThreadsWithQueuedTurns returns the identifiers of threads that have pending turns.
+func (r *Repository) ListThreadsWithQueuedTurns(ctx context.Context) ([]int64, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT DISTINCT thread_id FROM thread_turn_queue ORDER BY thread_id ASC
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("query queued threads: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan queued thread: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate queued threads: %w", err)
+	}
+	return ids, nil
+}
+
+// DeleteQueuedTurn removes a queued turn of a thread. Returns sql.ErrNoRows when
+// it no longer exists or belongs to another thread.
+func (r *Repository) DeleteQueuedTurn(ctx context.Context, threadID, id int64) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM thread_turn_queue WHERE id = ? AND thread_id = ?`, id, threadID)
+	if err != nil {
+		return fmt.Errorf("delete queued turn: %w", err)
+	}
+	if rows, rerr := res.RowsAffected(); rerr == nil && rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteQueuedTurnsForThread clears the queue of a thread.
+func (r *Repository) DeleteQueuedTurnsForThread(ctx context.Context, threadID int64) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM thread_turn_queue WHERE thread_id = ?`, threadID); err != nil {
+		return fmt.Errorf("delete queued turns: %w", err)
+	}
+	return nil
+}
+
+// ReorderQueuedTurns rewrites queue positions so that ids are executed in the given order.
+// The ids must match the thread's pending turns exactly.
+func (r *Repository) ReorderQueuedTurns(ctx context.Context, threadID int64, ids []int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin reorder queued turns: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM thread_turn_queue WHERE thread_id = ?`, threadID)
+	if err != nil {
+		return fmt.Errorf("query queued turn ids: %w", err)
+	}
+	existing := make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan queued turn id: %w", err)
+		}
+		existing[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate queued turn ids: %w", err)
+	}
+	if len(ids) != len(existing) {
+		return fmt.Errorf("reorder requires all %d queued turns, got %d", len(existing), len(ids))
+	}
+	seen := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		if !existing[id] || seen[id] {
+			return fmt.Errorf("queued turn %d does not belong to thread %d", id, threadID)
+		}
+		seen[id] = true
+	}
+	for idx, id := range ids {
+		if _, err := tx.ExecContext(ctx, `
+            UPDATE thread_turn_queue SET position = ? WHERE id = ?
+        `, idx+1, id); err != nil {
+			return fmt.Errorf("update queued turn position: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit reorder queued turns: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package discovery
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRepositoryTurnQueue(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	ctx := context.Background()
+
+	project, err := repo.UpsertProject(ctx, UpsertProjectParams{Path: "/tmp/project-queue"})
+	if err != nil {
+		t.Fatalf("upsert project: %v", err)
+	}
+	thread, err := repo.CreateThread(ctx, CreateThreadParams{ProjectID: project.ID, Title: "queue", Model: "gpt-5.1-codex"})
+	if err != nil {
+		t.Fatalf("create thread: %v", err)
+	}
+
+	var ids []int64
+	for _, input := range []string{"first", "second", "third"} {
+		q, err := repo.EnqueueTurn(ctx, thread.ID, json.RawMessage(`{"input":"`+input+`"}`))
+		if err != nil {
+			t.Fatalf("enqueue %s: %v", input, err)
+		}
+		ids = append(ids, q.ID)
+	}
+
+	next, err := repo.NextQueuedTurn(ctx, thread.ID)
+	if err != nil {
+		t.Fatalf("next queued turn: %v", err)
+	}
+	if next.ID != ids[0] {
+		t.Fatalf("expected head %d, got %d", ids[0], next.ID)
+	}
+
+	if err := repo.ClaimQueuedTurn(ctx, thread.ID, ids[0], time.Minute); err != nil {
+		t.Fatalf("claim queued turn: %v", err)
+	}
+	if err := repo.ClaimQueuedTurn(ctx, thread.ID, ids[0], time.Minute); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows claiming a claimed turn, got %v", err)
+	}
+	if err := repo.ReleaseQueuedTurnClaim(ctx, thread.ID, ids[0]); err != nil {
+		t.Fatalf("release claim: %v", err)
+	}
+	if err := repo.ClaimQueuedTurn(ctx, thread.ID, ids[0], -time.Second); err != nil {
+		t.Fatalf("claim released turn: %v", err)
+	}
+	// An expired claim no longer blocks another dispatcher.
+	if err := repo.ClaimQueuedTurn(ctx, thread.ID, ids[0], time.Minute); err != nil {
+		t.Fatalf("claim expired turn: %v", err)
+	}
+	if next, err := repo.NextQueuedTurn(ctx, thread.ID); err != nil || next.ID != ids[0] {
+		t.Fatalf("expected the claimed turn to stay at the head, got %+v (err %v)", next, err)
+	}
+
+	if err := repo.ReorderQueuedTurns(ctx, thread.ID, []int64{ids[2], ids[0], ids[1]}); err != nil {
+		t.Fatalf("reorder: %v", err)
+	}
+	if err := repo.ReorderQueuedTurns(ctx, thread.ID, []int64{ids[0], ids[1]}); err == nil {
+		t.Fatalf("expected reorder with missing ids to fail")
+	}
+	list, err := repo.ListQueuedTurns(ctx, thread.ID)
+	if err != nil {
+		t.Fatalf("list queued turns: %v", err)
+	}
+	if len(list) != 3 || list[0].ID != ids[2] || list[1].ID != ids[0] || list[2].ID != ids[1] {
+		t.Fatalf("unexpected order after reorder: %+v", list)
+	}
+
+	other, err := repo.CreateThread(ctx, CreateThreadParams{ProjectID: project.ID, Title: "other", Model: "gpt-5.1-codex"})
+	if err != nil {
+		t.Fatalf("create thread: %v", err)
+	}
+	if err := repo.DeleteQueuedTurn(ctx, other.ID, ids[2]); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows deleting through another thread, got %v", err)
+	}
+	if err := repo.DeleteQueuedTurn(ctx, thread.ID, ids[2]); err != nil {
+		t.Fatalf("delete queued turn: %v", err)
+	}
+	if err := repo.DeleteQueuedTurn(ctx, thread.ID, ids[2]); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows deleting twice, got %v", err)
+	}
+	queued, err := repo.EnqueueTurn(ctx, thread.ID, json.RawMessage(`{"input":"fourth"}`))
+	if err != nil {
+		t.Fatalf("enqueue fourth: %v", err)
+	}
+	if queued.Position <= list[2].Position {
+		t.Fatalf("expected appended position after %d, got %d", list[2].Position, queued.Position)
+	}
+
+	threads, err := repo.ListThreadsWithQueuedTurns(ctx)
+	if err != nil || len(threads) != 1 || threads[0] != thread.ID {
+		t.Fatalf("unexpected queued threads %v (err %v)", threads, err)
+	}
+	if err := repo.DeleteQueuedTurnsForThread(ctx, thread.ID); err != nil {
+		t.Fatalf("clear queue: %v", err)
+	}
+	if _, err := repo.NextQueuedTurn(ctx, thread.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected empty queue, got %v", err)
+	}
+}
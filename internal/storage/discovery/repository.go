@@ -24,6 +24,8 @@ type Project struct {
     LastOpenedAt time.Time `json:"lastOpenedAt,omitempty"`
     CreatedAt    time.Time `json:"createdAt"`
     UpdatedAt    time.Time `json:"updatedAt"`
+    // Settings holds the project's thread defaults and standing instructions.
+    Settings ProjectSettings `json:"settings"`
 }
 
 type UpsertProjectParams struct {
@@ -35,9 +37,10 @@ type UpsertProjectParams struct {
 
 func (r *Repository) ListProjects(ctx context.Context) ([]Project, error) {
 	rows, err := r.db.QueryContext(ctx, `
-		SELECT id, path, display_name, tags, last_opened_at, created_at, updated_at
-		FROM projects
-		ORDER BY COALESCE(last_opened_at, updated_at) DESC, id DESC
+		SELECT p.id, p.path, p.display_name, p.tags, p.last_opened_at, p.created_at, p.updated_at, `+projectSettingsSelect+`
+		FROM projects p
+		LEFT JOIN project_settings s ON s.project_id = p.id
+		ORDER BY COALESCE(p.last_opened_at, p.updated_at) DESC, p.id DESC
 	`)
 	if err != nil {
 		return nil, fmt.Errorf("query projects: %w", err)
@@ -51,10 +54,12 @@ func (r *Repository) ListProjects(ctx context.Context) ([]Project, error) {
 			display sql.NullString
 			tags    sql.NullString
 			last    sql.NullTime
+			set     settingsColumns
 		)
-		if err := rows.Scan(&p.ID, &p.Path, &display, &tags, &last, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		if err := rows.Scan(append([]any{&p.ID, &p.Path, &display, &tags, &last, &p.CreatedAt, &p.UpdatedAt}, set.dest()...)...); err != nil {
 			return nil, fmt.Errorf("scan project: %w", err)
 		}
+		p.Settings = set.settings(p.ID)
 		if display.Valid {
 			p.DisplayName = display.String
 		}
@@ -102,18 +107,21 @@ func (r *Repository) GetProjectByPath(ctx context.Context, path string) (Project
 		display sql.NullString
 		tags    sql.NullString
 		last    sql.NullTime
+		set     settingsColumns
 	)
 	err := r.db.QueryRowContext(ctx, `
-		SELECT id, path, display_name, tags, last_opened_at, created_at, updated_at
-		FROM projects
-		WHERE path = ?
-	`, path).Scan(&p.ID, &p.Path, &display, &tags, &last, &p.CreatedAt, &p.UpdatedAt)
+		SELECT p.id, p.path, p.display_name, p.tags, p.last_opened_at, p.created_at, p.updated_at, `+projectSettingsSelect+`
+		FROM projects p
+		LEFT JOIN project_settings s ON s.project_id = p.id
+		WHERE p.path = ?
+	`, path).Scan(append([]any{&p.ID, &p.Path, &display, &tags, &last, &p.CreatedAt, &p.UpdatedAt}, set.dest()...)...)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return Project{}, fmt.Errorf("project not found: %s", path)
 		}
 		return Project{}, fmt.Errorf("select project: %w", err)
 	}
+	p.Settings = set.settings(p.ID)
 	if display.Valid {
 		p.DisplayName = display.String
 	}
@@ -135,18 +143,21 @@ func (r *Repository) GetProjectByID(ctx context.Context, id int64) (Project, err
         display sql.NullString
         tags    sql.NullString
         last    sql.NullTime
+        set     settingsColumns
     )
     err := r.db.QueryRowContext(ctx, `
-        SELECT id, path, display_name, tags, last_opened_at, created_at, updated_at
-        FROM projects
-        WHERE id = ?
-    `, id).Scan(&p.ID, &p.Path, &display, &tags, &last, &p.CreatedAt, &p.UpdatedAt)
+        SELECT p.id, p.path, p.display_name, p.tags, p.last_opened_at, p.created_at, p.updated_at, `+projectSettingsSelect+`
+        FROM projects p
+        LEFT JOIN project_settings s ON s.project_id = p.id
+        WHERE p.id = ?
+    `, id).Scan(append([]any{&p.ID, &p.Path, &display, &tags, &last, &p.CreatedAt, &p.UpdatedAt}, set.dest()...)...)
     if err != nil {
         if err == sql.ErrNoRows {
             return Project{}, fmt.Errorf("project %d not found", id)
         }
         return Project{}, fmt.Errorf("select project by id: %w", err)
     }
+    p.Settings = set.settings(p.ID)
     if display.Valid {
         p.DisplayName = display.String
     }
@@ -0,0 +1,316 @@
+package discovery
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ScheduledJob is a recurring agent task for a project.
+type ScheduledJob struct {
+	ID            int64           `json:"id"`
+	ProjectID     int64           `json:"projectId"`
+	Name          string          `json:"name"`
+	Schedule      string          `json:"schedule"`
+	Prompt        string          `json:"prompt"`
+	AgentID       string          `json:"agentId,omitempty"`
+	ThreadOptions json.RawMessage `json:"threadOptions,omitempty"`
+	TargetBranch  string          `json:"targetBranch,omitempty"`
+	Enabled       bool            `json:"enabled"`
+	LastRunAt     *time.Time      `json:"lastRunAt,omitempty"`
+	NextRunAt     *time.Time      `json:"nextRunAt,omitempty"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	UpdatedAt     time.Time       `json:"updatedAt"`
+}
+
+// ScheduledRun records one firing of a scheduled job.
+type ScheduledRun struct {
+	ID           int64     `json:"id"`
+	JobID        int64     `json:"jobId"`
+	ThreadID     int64     `json:"threadId,omitempty"`
+	Status       string    `json:"status"`
+	Error        string    `json:"error,omitempty"`
+	ScheduledFor time.Time `json:"scheduledFor"`
+	StartedAt    time.Time `json:"startedAt"`
+	// FinishedAt is set once the run's turn has ended.
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// SaveScheduledJobParams holds the editable fields of a scheduled job.
+type SaveScheduledJobParams struct {
+	ProjectID     int64
+	Name          string
+	Schedule      string
+	Prompt        string
+	AgentID       string
+	ThreadOptions json.RawMessage
+	TargetBranch  string
+	Enabled       bool
+	NextRunAt     *time.Time
+}
+
+const scheduledJobColumns = `id, project_id, name, schedule, prompt, agent_id, thread_options, target_branch, enabled, last_run_at, next_run_at, created_at, updated_at`
+
+func scanScheduledJob(row rowScanner) (ScheduledJob, error) {
+	var (
+		job          ScheduledJob
+		agentID      sql.NullString
+		options      string
+		targetBranch sql.NullString
+		lastRunAt    sql.NullTime
+		nextRunAt    sql.NullTime
+	)
+	if err := row.Scan(&job.ID, &job.ProjectID, &job.Name, &job.Schedule, &job.Prompt, &agentID, &options, &targetBranch, &job.Enabled, &lastRunAt, &nextRunAt, &job.CreatedAt, &job.UpdatedAt); err != nil {
+		return ScheduledJob{}, err
+	}
+	job.AgentID = agentID.String
+	job.TargetBranch = targetBranch.String
+	if options != "" {
+		job.ThreadOptions = json.RawMessage(options)
+	}
+	if lastRunAt.Valid {
+		job.LastRunAt = &lastRunAt.Time
+	}
+	if nextRunAt.Valid {
+		job.NextRunAt = &nextRunAt.Time
+	}
+	return job, nil
+}
+
+func threadOptionsText(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return "{}"
+	}
+	return string(raw)
+}
+
+// CreateScheduledJob inserts a scheduled job.
+func (r *Repository) CreateScheduledJob(ctx context.Context, params SaveScheduledJobParams) (ScheduledJob, error) {
+	res, err := r.db.ExecContext(ctx, `
+        INSERT INTO scheduled_jobs (project_id, name, schedule, prompt, agent_id, thread_options, target_branch, enabled, next_run_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `, params.ProjectID, params.Name, params.Schedule, params.Prompt, nullIfEmpty(params.AgentID), threadOptionsText(params.ThreadOptions), nullIfEmpty(params.TargetBranch), params.Enabled, params.NextRunAt)
+	if err != nil {
+		return ScheduledJob{}, fmt.Errorf("insert scheduled job: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return ScheduledJob{}, fmt.Errorf("scheduled job last insert id: %w", err)
+	}
+	return r.GetScheduledJob(ctx, id)
+}
+
+// UpdateScheduledJob replaces the editable fields of a job. The project is fixed.
+func (r *Repository) UpdateScheduledJob(ctx context.Context, id int64, params SaveScheduledJobParams) (ScheduledJob, error) {
+	res, err := r.db.ExecContext(ctx, `
+        UPDATE scheduled_jobs
+        SET name = ?, schedule = ?, prompt = ?, agent_id = ?, thread_options = ?, target_branch = ?, enabled = ?, next_run_at = ?,
+            updated_at = CURRENT_TIMESTAMP
+        WHERE id = ?
+    `, params.Name, params.Schedule, params.Prompt, nullIfEmpty(params.AgentID), threadOptionsText(params.ThreadOptions), nullIfEmpty(params.TargetBranch), params.Enabled, params.NextRunAt, id)
+	if err != nil {
+		return ScheduledJob{}, fmt.Errorf("update scheduled job: %w", err)
+	}
+	if rows, err := res.RowsAffected(); err == nil && rows == 0 {
+		return ScheduledJob{}, sql.ErrNoRows
+	}
+	return r.GetScheduledJob(ctx, id)
+}
+
+// GetScheduledJob retrieves a scheduled job by identifier.
+func (r *Repository) GetScheduledJob(ctx context.Context, id int64) (ScheduledJob, error) {
+	job, err := scanScheduledJob(r.db.QueryRowContext(ctx, `
+        SELECT `+scheduledJobColumns+`
+        FROM scheduled_jobs
+        WHERE id = ?
+    `, id))
+	if err != nil {
+		return ScheduledJob{}, fmt.Errorf("select scheduled job: %w", err)
+	}
+	return job, nil
+}
+
+// ListScheduledJobs lists a project's jobs by name.
+func (r *Repository) ListScheduledJobs(ctx context.Context, projectID int64) ([]ScheduledJob, error) {
+	return r.queryScheduledJobs(ctx, `
+        SELECT `+scheduledJobColumns+`
+        FROM scheduled_jobs
+        WHERE project_id = ?
+        ORDER BY name COLLATE NOCASE, id
+    `, projectID)
+}
+
+// ListDueScheduledJobs lists enabled jobs whose next run is at or before now.
+func (r *Repository) ListDueScheduledJobs(ctx context.Context, now time.Time) ([]ScheduledJob, error) {
+	return r.queryScheduledJobs(ctx, `
+        SELECT `+scheduledJobColumns+`
+        FROM scheduled_jobs
+        WHERE enabled = 1 AND next_run_at IS NOT NULL AND next_run_at <= ?
+        ORDER BY next_run_at, id
+    `, now.UTC())
+}
+
+func (r *Repository) queryScheduledJobs(ctx context.Context, query string, args ...any) ([]ScheduledJob, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query scheduled jobs: %w", err)
+	}
+	defer rows.Close()
+	var jobs []ScheduledJob
+	for rows.Next() {
+		job, err := scanScheduledJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan scheduled job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate scheduled jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// AdvanceScheduledJob records a run at ranAt and stores the next run time.
+func (r *Repository) AdvanceScheduledJob(ctx context.Context, id int64, ranAt time.Time, next *time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE scheduled_jobs
+        SET last_run_at = ?, next_run_at = ?, updated_at = CURRENT_TIMESTAMP
+        WHERE id = ?
+    `, ranAt.UTC(), next, id)
+	if err != nil {
+		return fmt.Errorf("advance scheduled job: %w", err)
+	}
+	return nil
+}
+
+// MarkScheduledJobRan records a run that happened outside the schedule.
+func (r *Repository) MarkScheduledJobRan(ctx context.Context, id int64, ranAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE scheduled_jobs SET last_run_at = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?
+    `, ranAt.UTC(), id)
+	if err != nil {
+		return fmt.Errorf("mark scheduled job ran: %w", err)
+	}
+	return nil
+}
+
+// DeleteScheduledJob removes a job and its run history. Threads created by the
+// job are kept and untagged.
+func (r *Repository) DeleteScheduledJob(ctx context.Context, id int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin delete scheduled job: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM scheduled_runs WHERE job_id = ?`, id); err != nil {
+		return fmt.Errorf("delete scheduled runs: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE threads SET scheduled_job_id = NULL WHERE scheduled_job_id = ?`, id); err != nil {
+		return fmt.Errorf("untag scheduled threads: %w", err)
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM scheduled_jobs WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("delete scheduled job: %w", err)
+	}
+	if rows, err := res.RowsAffected(); err == nil && rows == 0 {
+		return sql.ErrNoRows
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit delete scheduled job: %w", err)
+	}
+	return nil
+}
+
+// CreateScheduledRun records a job firing.
+func (r *Repository) CreateScheduledRun(ctx context.Context, run ScheduledRun) (ScheduledRun, error) {
+	startedAt := run.StartedAt
+	if startedAt.IsZero() {
+		startedAt = time.Now().UTC()
+	}
+	res, err := r.db.ExecContext(ctx, `
+        INSERT INTO scheduled_runs (job_id, thread_id, status, error, scheduled_for, started_at)
+        VALUES (?, ?, ?, ?, ?, ?)
+    `, run.JobID, nullIfZero(run.ThreadID), run.Status, nullIfEmpty(run.Error), run.ScheduledFor.UTC(), startedAt.UTC())
+	if err != nil {
+		return ScheduledRun{}, fmt.Errorf("insert scheduled run: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return ScheduledRun{}, fmt.Errorf("scheduled run last insert id: %w", err)
+	}
+	run.ID = id
+	run.StartedAt = startedAt.UTC()
+	return run, nil
+}
+
+// FinishScheduledRun records how a run's turn ended.
+func (r *Repository) FinishScheduledRun(ctx context.Context, id int64, status, errText string, finishedAt time.Time) error {
+	res, err := r.db.ExecContext(ctx, `
+        UPDATE scheduled_runs SET status = ?, error = ?, finished_at = ? WHERE id = ?
+    `, status, nullIfEmpty(errText), finishedAt.UTC(), id)
+	if err != nil {
+		return fmt.Errorf("finish scheduled run: %w", err)
+	}
+	if rows, rerr := res.RowsAffected(); rerr == nil && rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// FinishScheduledRunsInStatus moves every run still in status from to status,
+// recording errText and finishedAt. It returns how many runs were closed.
+func (r *Repository) FinishScheduledRunsInStatus(ctx context.Context, from, status, errText string, finishedAt time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `
+        UPDATE scheduled_runs SET status = ?, error = ?, finished_at = ? WHERE status = ?
+    `, status, nullIfEmpty(errText), finishedAt.UTC(), from)
+	if err != nil {
+		return 0, fmt.Errorf("finish scheduled runs: %w", err)
+	}
+	closed, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("finish scheduled runs rows affected: %w", err)
+	}
+	return closed, nil
+}
+
+// ListScheduledRuns returns the most recent runs of a job, newest first.
+func (r *Repository) ListScheduledRuns(ctx context.Context, jobID int64, limit int) ([]ScheduledRun, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT id, job_id, thread_id, status, error, scheduled_for, started_at, finished_at
+        FROM scheduled_runs
+        WHERE job_id = ?
+        ORDER BY id DESC
+        LIMIT ?
+    `, jobID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query scheduled runs: %w", err)
+	}
+	defer rows.Close()
+	var runs []ScheduledRun
+	for rows.Next() {
+		var (
+			run      ScheduledRun
+			threadID sql.NullInt64
+			errText  sql.NullString
+			finished sql.NullTime
+		)
+		if err := rows.Scan(&run.ID, &run.JobID, &threadID, &run.Status, &errText, &run.ScheduledFor, &run.StartedAt, &finished); err != nil {
+			return nil, fmt.Errorf("scan scheduled run: %w", err)
+		}
+		run.ThreadID = threadID.Int64
+		run.Error = errText.String
+		if finished.Valid {
+			run.FinishedAt = &finished.Time
+		}
+		runs = append(runs, run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate scheduled runs: %w", err)
+	}
+	return runs, nil
+}
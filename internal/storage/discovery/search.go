@@ -0,0 +1,126 @@
+package discovery
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+)
+
+// SearchHit is one match of a conversation search. Title matches have a zero
+// EntryID.
+type SearchHit struct {
+	ThreadID    int64     `json:"threadId"`
+	ProjectID   int64     `json:"projectId"`
+	ThreadTitle string    `json:"threadTitle"`
+	EntryID     int64     `json:"entryId,omitempty"`
+	Role        string    `json:"role,omitempty"`
+	EntryType   string    `json:"entryType,omitempty"`
+	Snippet     string    `json:"snippet"`
+	Rank        float64   `json:"rank"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// Markers around matched terms in SearchHit.Snippet.
+const (
+	SearchHighlightStart = "<mark>"
+	SearchHighlightEnd   = "</mark>"
+)
+
+// FTS5 highlights matches with these private-use characters; highlightSnippet
+// escapes the text and then swaps them for the HTML markers.
+const (
+	snippetMatchStart = "\ue000"
+	snippetMatchEnd   = "\ue001"
+)
+
+// highlightSnippet HTML-escapes raw snippet text and marks its matches, so the
+// result is safe to render as HTML.
+func highlightSnippet(raw string) string {
+	return strings.NewReplacer(snippetMatchStart, SearchHighlightStart, snippetMatchEnd, SearchHighlightEnd).Replace(html.EscapeString(raw))
+}
+
+// ErrEmptySearch is returned for queries without any search terms.
+var ErrEmptySearch = errors.New("search query is empty")
+
+// searchMatchExpression turns free text into an FTS5 query that matches
+// entries containing every word. Words are quoted so punctuation never
+// becomes query syntax; a trailing * keeps prefix matching.
+func searchMatchExpression(query string) string {
+	var terms []string
+	for _, word := range strings.Fields(query) {
+		prefix := strings.HasSuffix(word, "*")
+		word = strings.Trim(strings.ReplaceAll(word, `"`, ""), "*")
+		if word == "" {
+			continue
+		}
+		term := `"` + word + `"`
+		if prefix {
+			term += "*"
+		}
+		terms = append(terms, term)
+	}
+	return strings.Join(terms, " ")
+}
+
+// SearchConversations returns the best-ranked entries and thread titles
+// matching query, optionally restricted to one project.
+func (r *Repository) SearchConversations(ctx context.Context, query string, projectID int64, limit int) ([]SearchHit, error) {
+	match := searchMatchExpression(query)
+	if match == "" {
+		return nil, ErrEmptySearch
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+	args := []any{snippetMatchStart, snippetMatchEnd, match}
+	projectClause := ""
+	if projectID != 0 {
+		projectClause = "AND t.project_id = ?"
+		args = append(args, projectID)
+	}
+	args = append(args, limit)
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT t.id, t.project_id, t.title, e.id, e.role, e.entry_type, e.created_at, t.created_at,
+               snippet(conversation_search, -1, ?, ?, '…', 16), s.rank
+        FROM conversation_search s
+        JOIN threads t ON t.id = s.thread_id
+        LEFT JOIN thread_entries e ON s.rowid > 0 AND e.id = s.rowid
+        WHERE conversation_search MATCH ?
+        `+projectClause+`
+        ORDER BY s.rank
+        LIMIT ?
+    `, args...)
+	if err != nil {
+		return nil, fmt.Errorf("search conversations: %w", err)
+	}
+	defer rows.Close()
+	var hits []SearchHit
+	for rows.Next() {
+		var (
+			hit            SearchHit
+			entryID        sql.NullInt64
+			role           sql.NullString
+			entryType      sql.NullString
+			entryCreatedAt sql.NullTime
+		)
+		if err := rows.Scan(&hit.ThreadID, &hit.ProjectID, &hit.ThreadTitle, &entryID, &role, &entryType, &entryCreatedAt, &hit.CreatedAt, &hit.Snippet, &hit.Rank); err != nil {
+			return nil, fmt.Errorf("scan search hit: %w", err)
+		}
+		hit.Snippet = highlightSnippet(hit.Snippet)
+		hit.EntryID = entryID.Int64
+		hit.Role = role.String
+		hit.EntryType = entryType.String
+		if entryCreatedAt.Valid {
+			hit.CreatedAt = entryCreatedAt.Time
+		}
+		hits = append(hits, hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate search hits: %w", err)
+	}
+	return hits, nil
+}
@@ -0,0 +1,89 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRepositorySearchConversations(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	ctx := context.Background()
+
+	project, err := repo.UpsertProject(ctx, UpsertProjectParams{Path: "/tmp/project-search"})
+	if err != nil {
+		t.Fatalf("upsert project: %v", err)
+	}
+	other, err := repo.UpsertProject(ctx, UpsertProjectParams{Path: "/tmp/project-search-other"})
+	if err != nil {
+		t.Fatalf("upsert project: %v", err)
+	}
+	auth, err := repo.CreateThread(ctx, CreateThreadParams{ProjectID: project.ID, Title: "Login fixes", Model: "m"})
+	if err != nil {
+		t.Fatalf("create thread: %v", err)
+	}
+	docs, err := repo.CreateThread(ctx, CreateThreadParams{ProjectID: other.ID, Title: "OAuth docs", Model: "m"})
+	if err != nil {
+		t.Fatalf("create thread: %v", err)
+	}
+	add := func(threadID int64, role, entryType, payload string) ConversationEntry {
+		entry, err := repo.CreateConversationEntry(ctx, CreateConversationEntryParams{ThreadID: threadID, Role: role, EntryType: entryType, Payload: []byte(payload)})
+		if err != nil {
+			t.Fatalf("create entry: %v", err)
+		}
+		return entry
+	}
+	answer := add(auth.ID, "agent", "agent_message", `{"type":"agent_message","text":"Fixed the OAuth refresh bug by retrying expired tokens."}`)
+	command := add(auth.ID, "agent", "command_execution", `{"type":"command_execution","command":{"command":"go test ./auth","aggregatedOutput":"--- FAIL: TestRefreshToken"}}`)
+	add(docs.ID, "user", "user_message", `{"text":"Document the OAuth flow"}`)
+
+	hits, err := repo.SearchConversations(ctx, "oauth refreshed", 0, 0)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(hits) != 1 || hits[0].EntryID != answer.ID || hits[0].ThreadTitle != "Login fixes" || hits[0].Role != "agent" {
+		t.Fatalf("expected the stemmed match on the agent answer, got %+v", hits)
+	}
+	if !strings.Contains(hits[0].Snippet, "<mark>OAuth</mark> <mark>refresh</mark>") {
+		t.Fatalf("expected highlighted snippet, got %q", hits[0].Snippet)
+	}
+
+	if hits, _ := repo.SearchConversations(ctx, "TestRefresh*", 0, 0); len(hits) != 1 || hits[0].EntryID != command.ID {
+		t.Fatalf("expected command output prefix match, got %+v", hits)
+	}
+
+	hits, err = repo.SearchConversations(ctx, `oauth"`, other.ID, 0)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(hits) != 2 {
+		t.Fatalf("expected the title and entry of the other project, got %+v", hits)
+	}
+	for _, hit := range hits {
+		if hit.ThreadID != docs.ID {
+			t.Fatalf("unexpected hit outside project: %+v", hit)
+		}
+	}
+
+	add(auth.ID, "agent", "agent_message", `{"type":"agent_message","text":"Rendered <img src=x onerror=alert(1)> in the preview"}`)
+	hits, _ = repo.SearchConversations(ctx, "preview", auth.ProjectID, 0)
+	if len(hits) != 1 || !strings.Contains(hits[0].Snippet, "&lt;img src=x onerror=alert(1)&gt; in the <mark>preview</mark>") {
+		t.Fatalf("expected an escaped snippet, got %+v", hits)
+	}
+
+	if err := repo.UpdateThreadTitle(ctx, docs.ID, "Provider setup"); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	if err := repo.DeleteThread(ctx, auth.ID); err != nil {
+		t.Fatalf("delete thread: %v", err)
+	}
+	hits, _ = repo.SearchConversations(ctx, "oauth", 0, 0)
+	if len(hits) != 1 || hits[0].EntryID == 0 || hits[0].ThreadTitle != "Provider setup" {
+		t.Fatalf("expected index to follow renames and deletes, got %+v", hits)
+	}
+
+	if _, err := repo.SearchConversations(ctx, ` "" * `, 0, 0); !errors.Is(err, ErrEmptySearch) {
+		t.Fatalf("expected empty search error, got %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package discovery
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// PromptTemplate is a reusable prompt with {{variable}} placeholders. A
+// ProjectID of zero makes the template global.
+type PromptTemplate struct {
+	ID          int64     `json:"id"`
+	ProjectID   int64     `json:"projectId"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Body        string    `json:"body"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// SavePromptTemplateParams holds the editable fields of a prompt template.
+type SavePromptTemplateParams struct {
+	ProjectID   int64
+	Name        string
+	Description string
+	Body        string
+}
+
+const promptTemplateColumns = `id, project_id, name, description, body, created_at, updated_at`
+
+func scanPromptTemplate(row rowScanner) (PromptTemplate, error) {
+	var (
+		tmpl        PromptTemplate
+		description sql.NullString
+	)
+	if err := row.Scan(&tmpl.ID, &tmpl.ProjectID, &tmpl.Name, &description, &tmpl.Body, &tmpl.CreatedAt, &tmpl.UpdatedAt); err != nil {
+		return PromptTemplate{}, err
+	}
+	tmpl.Description = description.String
+	return tmpl, nil
+}
+
+// CreatePromptTemplate inserts a prompt template.
+func (r *Repository) CreatePromptTemplate(ctx context.Context, params SavePromptTemplateParams) (PromptTemplate, error) {
+	res, err := r.db.ExecContext(ctx, `
+        INSERT INTO prompt_templates (project_id, name, description, body)
+        VALUES (?, ?, ?, ?)
+    `, params.ProjectID, params.Name, nullIfEmpty(params.Description), params.Body)
+	if err != nil {
+		return PromptTemplate{}, fmt.Errorf("insert prompt template: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return PromptTemplate{}, fmt.Errorf("prompt template last insert id: %w", err)
+	}
+	return r.GetPromptTemplate(ctx, id)
+}
+
+// UpdatePromptTemplate replaces the name, description and body of a template.
+// The scope is fixed.
+func (r *Repository) UpdatePromptTemplate(ctx context.Context, id int64, params SavePromptTemplateParams) (PromptTemplate, error) {
+	res, err := r.db.ExecContext(ctx, `
+        UPDATE prompt_templates
+        SET name = ?, description = ?, body = ?, updated_at = CURRENT_TIMESTAMP
+        WHERE id = ?
+    `, params.Name, nullIfEmpty(params.Description), params.Body, id)
+	if err != nil {
+		return PromptTemplate{}, fmt.Errorf("update prompt template: %w", err)
+	}
+	if rows, err := res.RowsAffected(); err == nil && rows == 0 {
+		return PromptTemplate{}, sql.ErrNoRows
+	}
+	return r.GetPromptTemplate(ctx, id)
+}
+
+// GetPromptTemplate retrieves a prompt template by identifier.
+func (r *Repository) GetPromptTemplate(ctx context.Context, id int64) (PromptTemplate, error) {
+	tmpl, err := scanPromptTemplate(r.db.QueryRowContext(ctx, `
+        SELECT `+promptTemplateColumns+`
+        FROM prompt_templates
+        WHERE id = ?
+    `, id))
+	if err != nil {
+		return PromptTemplate{}, fmt.Errorf("select prompt template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// FindPromptTemplate returns the template named name that applies to a
+// project: the project's own template, else the global one.
+func (r *Repository) FindPromptTemplate(ctx context.Context, projectID int64, name string) (PromptTemplate, error) {
+	tmpl, err := scanPromptTemplate(r.db.QueryRowContext(ctx, `
+        SELECT `+promptTemplateColumns+`
+        FROM prompt_templates
+        WHERE name = ? AND project_id IN (0, ?)
+        ORDER BY project_id DESC
+        LIMIT 1
+    `, name, projectID))
+	if err != nil {
+		return PromptTemplate{}, fmt.Errorf("select prompt template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// ListPromptTemplates lists the global templates and, when projectID is set,
+// the project's templates, by name.
+func (r *Repository) ListPromptTemplates(ctx context.Context, projectID int64) ([]PromptTemplate, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT `+promptTemplateColumns+`
+        FROM prompt_templates
+        WHERE project_id IN (0, ?)
+        ORDER BY name COLLATE NOCASE, project_id, id
+    `, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("select prompt templates: %w", err)
+	}
+	defer rows.Close()
+	var templates []PromptTemplate
+	for rows.Next() {
+		tmpl, err := scanPromptTemplate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan prompt template: %w", err)
+		}
+		templates = append(templates, tmpl)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate prompt templates: %w", err)
+	}
+	return templates, nil
+}
+
+// DeletePromptTemplate removes a prompt template.
+func (r *Repository) DeletePromptTemplate(ctx context.Context, id int64) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM prompt_templates WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("delete prompt template: %w", err)
+	}
+	return nil
+}
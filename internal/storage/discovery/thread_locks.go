@@ -0,0 +1,81 @@
+package discovery
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// AcquireThreadLock takes the turn lock of a thread for owner until ttl has
+// passed. It returns false when another owner holds a lock that has not
+// expired yet. Processes sharing the catalog use it so only one of them runs
+// a turn on a thread at a time.
+func (r *Repository) AcquireThreadLock(ctx context.Context, threadID int64, owner string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	res, err := r.db.ExecContext(ctx, `
+        INSERT INTO thread_turn_locks (thread_id, owner, expires_at)
+        VALUES (?, ?, ?)
+        ON CONFLICT(thread_id) DO UPDATE SET owner = excluded.owner, expires_at = excluded.expires_at, cancel_requested = 0
+        WHERE thread_turn_locks.owner = excluded.owner OR thread_turn_locks.expires_at <= ?
+    `, threadID, owner, now.Add(ttl).UnixMilli(), now.UnixMilli())
+	if err != nil {
+		return false, fmt.Errorf("acquire thread lock: %w", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("acquire thread lock: %w", err)
+	}
+	return rows > 0, nil
+}
+
+// RenewThreadLock extends a lock owner still holds by ttl from now.
+func (r *Repository) RenewThreadLock(ctx context.Context, threadID int64, owner string, ttl time.Duration) error {
+	if _, err := r.db.ExecContext(ctx, `
+        UPDATE thread_turn_locks SET expires_at = ? WHERE thread_id = ? AND owner = ?
+    `, time.Now().Add(ttl).UnixMilli(), threadID, owner); err != nil {
+		return fmt.Errorf("renew thread lock: %w", err)
+	}
+	return nil
+}
+
+// ReleaseThreadLock drops the lock of a thread if owner holds it.
+func (r *Repository) ReleaseThreadLock(ctx context.Context, threadID int64, owner string) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM thread_turn_locks WHERE thread_id = ? AND owner = ?`, threadID, owner); err != nil {
+		return fmt.Errorf("release thread lock: %w", err)
+	}
+	return nil
+}
+
+// RequestThreadCancel asks the process holding a thread's lock to stop its
+// running turn. It returns false when no live lock exists, i.e. no turn is
+// running on the thread.
+func (r *Repository) RequestThreadCancel(ctx context.Context, threadID int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+        UPDATE thread_turn_locks SET cancel_requested = 1 WHERE thread_id = ? AND expires_at > ?
+    `, threadID, time.Now().UnixMilli())
+	if err != nil {
+		return false, fmt.Errorf("request thread cancel: %w", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("request thread cancel: %w", err)
+	}
+	return rows > 0, nil
+}
+
+// ThreadCancelRequested reports whether a cancel was requested for the turn
+// owner runs on a thread.
+func (r *Repository) ThreadCancelRequested(ctx context.Context, threadID int64, owner string) (bool, error) {
+	var requested bool
+	err := r.db.QueryRowContext(ctx, `
+        SELECT cancel_requested FROM thread_turn_locks WHERE thread_id = ? AND owner = ?
+    `, threadID, owner).Scan(&requested)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("select thread cancel request: %w", err)
+	}
+	return requested, nil
+}
@@ -0,0 +1,68 @@
+package discovery
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRepositoryThreadLocks(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	ctx := context.Background()
+	project, _ := repo.UpsertProject(ctx, UpsertProjectParams{Path: "/tmp/locks"})
+	thread, err := repo.CreateThread(ctx, CreateThreadParams{ProjectID: project.ID, Title: "t", Model: "m"})
+	if err != nil {
+		t.Fatalf("create thread: %v", err)
+	}
+	acquire := func(owner string, ttl time.Duration) bool {
+		t.Helper()
+		ok, err := repo.AcquireThreadLock(ctx, thread.ID, owner, ttl)
+		if err != nil {
+			t.Fatalf("acquire: %v", err)
+		}
+		return ok
+	}
+
+	if !acquire("a", time.Minute) {
+		t.Fatal("expected a free thread to be locked")
+	}
+	if acquire("b", time.Minute) {
+		t.Fatal("expected a held lock to refuse another owner")
+	}
+	if err := repo.ReleaseThreadLock(ctx, thread.ID, "b"); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if acquire("b", time.Minute) {
+		t.Fatal("expected another owner's release to leave the lock alone")
+	}
+	requested := func(owner string) bool {
+		t.Helper()
+		ok, err := repo.ThreadCancelRequested(ctx, thread.ID, owner)
+		if err != nil {
+			t.Fatalf("cancel requested: %v", err)
+		}
+		return ok
+	}
+	if ok, err := repo.RequestThreadCancel(ctx, thread.ID); err != nil || !ok {
+		t.Fatalf("expected a cancel request on a held lock, got %v (err %v)", ok, err)
+	}
+	if !requested("a") || requested("b") {
+		t.Fatal("expected only the lock owner to see the cancel request")
+	}
+	if !acquire("a", time.Minute) || requested("a") {
+		t.Fatal("expected a new acquire to clear the cancel request")
+	}
+	if err := repo.ReleaseThreadLock(ctx, thread.ID, "a"); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if ok, err := repo.RequestThreadCancel(ctx, thread.ID); err != nil || ok {
+		t.Fatalf("expected no cancel request without a running turn, got %v (err %v)", ok, err)
+	}
+	if !acquire("b", -time.Second) {
+		t.Fatal("expected a released lock to be free")
+	}
+	// b's lock has already expired, as if its process had died.
+	if !acquire("a", time.Minute) {
+		t.Fatal("expected an expired lock to be taken over")
+	}
+}
@@ -0,0 +1,158 @@
+package discovery
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ThreadSort selects the key QueryThreads orders by; ties break on id.
+type ThreadSort string
+
+const (
+	// ThreadSortRecent orders by last activity: the latest message, or the
+	// last update for threads without messages.
+	ThreadSortRecent  ThreadSort = "recent"
+	ThreadSortCreated ThreadSort = "created"
+	ThreadSortTitle   ThreadSort = "title"
+)
+
+// threadSortKeys are the expressions behind each sort. The thread query
+// indexes are built on the same expressions.
+var threadSortKeys = map[ThreadSort]string{
+	ThreadSortRecent:  "COALESCE(last_message_at, updated_at)",
+	ThreadSortCreated: "created_at",
+	ThreadSortTitle:   "lower(title)",
+}
+
+// ThreadCursor marks the last thread of a page: its sort key as stored and
+// its id.
+type ThreadCursor struct {
+	Key string `json:"k"`
+	ID  int64  `json:"id"`
+}
+
+// ThreadQuery selects one page of threads. Zero fields do not filter. From
+// is inclusive and To exclusive, both on the last activity time. Limit 0
+// returns every match.
+type ThreadQuery struct {
+	ThreadFilter
+	Model         string
+	Branch        string
+	TitleContains string
+	HasPR         *bool
+	From          *time.Time
+	To            *time.Time
+	Sort          ThreadSort
+	Ascending     bool
+	After         *ThreadCursor
+	Limit         int
+}
+
+// keyedRow scans a thread followed by its sort key.
+type keyedRow struct {
+	rows *sql.Rows
+	key  *string
+}
+
+func (k keyedRow) Scan(dest ...any) error {
+	return k.rows.Scan(append(dest, k.key)...)
+}
+
+// QueryThreads returns the page of threads matching q and, when more remain,
+// the cursor to pass as After for the next page.
+func (r *Repository) QueryThreads(ctx context.Context, q ThreadQuery) ([]Thread, *ThreadCursor, error) {
+	if q.Sort == "" {
+		q.Sort = ThreadSortRecent
+	}
+	key, ok := threadSortKeys[q.Sort]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown thread sort %q", q.Sort)
+	}
+	where, args := q.ThreadFilter.where()
+	if q.Model != "" {
+		where = append(where, "model = ?")
+		args = append(args, q.Model)
+	}
+	if q.Branch != "" {
+		where = append(where, "COALESCE(NULLIF(TRIM(branch_name), ''), 'codex/thread/' || id) = ?")
+		args = append(args, q.Branch)
+	}
+	if q.TitleContains != "" {
+		where = append(where, `title LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(q.TitleContains)+"%")
+	}
+	if q.HasPR != nil {
+		if *q.HasPR {
+			where = append(where, "COALESCE(pr_url, '') <> ''")
+		} else {
+			where = append(where, "COALESCE(pr_url, '') = ''")
+		}
+	}
+	activity := threadSortKeys[ThreadSortRecent]
+	if q.From != nil {
+		where = append(where, activity+" >= ?")
+		args = append(args, q.From.UTC())
+	}
+	if q.To != nil {
+		where = append(where, activity+" < ?")
+		args = append(args, q.To.UTC())
+	}
+	cmp, order := "<", "DESC"
+	if q.Ascending {
+		cmp, order = ">", "ASC"
+	}
+	if q.After != nil {
+		where = append(where, fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", key, cmp))
+		args = append(args, q.After.Key, q.After.Key, q.After.ID)
+	}
+	clause := ""
+	if len(where) > 0 {
+		clause = "WHERE " + strings.Join(where, " AND ")
+	}
+	limit := ""
+	if q.Limit > 0 {
+		// One extra row tells whether another page follows.
+		limit = "LIMIT ?"
+		args = append(args, q.Limit+1)
+	}
+	rows, err := r.db.QueryContext(ctx, `
+            SELECT `+threadColumns+`, CAST(`+key+` AS TEXT)
+            FROM threads
+            `+clause+`
+            ORDER BY `+key+` `+order+`, id `+order+`
+            `+limit, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("query threads: %w", err)
+	}
+	defer rows.Close()
+
+	var (
+		threads []Thread
+		keys    []string
+	)
+	for rows.Next() {
+		var sortKey string
+		t, err := scanThread(keyedRow{rows: rows, key: &sortKey})
+		if err != nil {
+			return nil, nil, fmt.Errorf("scan thread: %w", err)
+		}
+		threads = append(threads, t)
+		keys = append(keys, sortKey)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("iterate threads: %w", err)
+	}
+	if q.Limit <= 0 || len(threads) <= q.Limit {
+		return threads, nil, nil
+	}
+	threads = threads[:q.Limit]
+	last := threads[len(threads)-1]
+	return threads, &ThreadCursor{Key: keys[q.Limit-1], ID: last.ID}, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
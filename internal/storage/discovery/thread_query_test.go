@@ -0,0 +1,110 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRepositoryQueryThreadsPagesAndFilters(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	ctx := context.Background()
+
+	alpha, err := repo.UpsertProject(ctx, UpsertProjectParams{Path: "/tmp/alpha"})
+	if err != nil {
+		t.Fatalf("upsert project: %v", err)
+	}
+	beta, err := repo.UpsertProject(ctx, UpsertProjectParams{Path: "/tmp/beta"})
+	if err != nil {
+		t.Fatalf("upsert project: %v", err)
+	}
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	var ids []int64
+	for i := 0; i < 7; i++ {
+		project, model := alpha.ID, "small"
+		if i%3 == 2 {
+			project, model = beta.ID, "large"
+		}
+		thread, err := repo.CreateThread(ctx, CreateThreadParams{ProjectID: project, Title: fmt.Sprintf("Fix parser bug %d", i), Model: model})
+		if err != nil {
+			t.Fatalf("create thread: %v", err)
+		}
+		// Threads 3 and 4 share an activity time so the id breaks the tie.
+		at := base.Add(time.Duration(i) * time.Hour)
+		if i == 4 {
+			at = base.Add(3 * time.Hour)
+		}
+		if err := repo.TouchThreadActivity(ctx, thread.ID, at); err != nil {
+			t.Fatalf("touch: %v", err)
+		}
+		ids = append(ids, thread.ID)
+	}
+	if err := repo.UpdateThreadPRURL(ctx, ids[1], "https://github.com/acme/app/pull/1"); err != nil {
+		t.Fatalf("pr url: %v", err)
+	}
+	if err := repo.UpdateThreadBranchName(ctx, ids[1], "fix/parser"); err != nil {
+		t.Fatalf("branch: %v", err)
+	}
+	// A blank branch name still falls back to the default branch.
+	if _, err := repo.db.ExecContext(ctx, `UPDATE threads SET branch_name = ' ' WHERE id = ?`, ids[3]); err != nil {
+		t.Fatalf("blank branch: %v", err)
+	}
+	if err := repo.UpdateThreadStatus(ctx, ids[0], ThreadStatusMerged, nil); err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if err := repo.UpdateThreadTitle(ctx, ids[6], "Add 100%_coverage"); err != nil {
+		t.Fatalf("title: %v", err)
+	}
+	if err := repo.SetThreadArchived(ctx, ids[5], true); err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+
+	query := func(q ThreadQuery) []int64 {
+		t.Helper()
+		var out []int64
+		for {
+			page, next, err := repo.QueryThreads(ctx, q)
+			if err != nil {
+				t.Fatalf("query threads: %v", err)
+			}
+			if q.Limit > 0 && len(page) > q.Limit {
+				t.Fatalf("page of %d exceeds limit %d", len(page), q.Limit)
+			}
+			for _, thread := range page {
+				out = append(out, thread.ID)
+			}
+			if next == nil {
+				return out
+			}
+			q.After = next
+		}
+	}
+	expect := func(name string, got []int64, want ...int64) {
+		t.Helper()
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("%s: got %v, want %v", name, got, want)
+		}
+	}
+
+	expect("all projects by recency, two per page", query(ThreadQuery{Limit: 2}), ids[6], ids[4], ids[3], ids[2], ids[1], ids[0])
+	expect("oldest first", query(ThreadQuery{Ascending: true, Limit: 4}), ids[0], ids[1], ids[2], ids[3], ids[4], ids[6])
+	expect("one project", query(ThreadQuery{ThreadFilter: ThreadFilter{ProjectID: alpha.ID}, Limit: 3}), ids[6], ids[4], ids[3], ids[1], ids[0])
+	expect("including archived", query(ThreadQuery{ThreadFilter: ThreadFilter{IncludeArchived: true}, Sort: ThreadSortCreated, Limit: 5}), ids[6], ids[5], ids[4], ids[3], ids[2], ids[1], ids[0])
+	expect("by title", query(ThreadQuery{Sort: ThreadSortTitle, Ascending: true, Limit: 2}), ids[6], ids[0], ids[1], ids[2], ids[3], ids[4])
+	expect("status", query(ThreadQuery{ThreadFilter: ThreadFilter{Statuses: []ThreadStatus{ThreadStatusMerged}}}), ids[0])
+	expect("model", query(ThreadQuery{Model: "large"}), ids[2])
+	expect("branch", query(ThreadQuery{Branch: "fix/parser"}), ids[1])
+	expect("default branch", query(ThreadQuery{Branch: fmt.Sprintf("codex/thread/%d", ids[3])}), ids[3])
+	hasPR, noPR := true, false
+	expect("with a PR", query(ThreadQuery{HasPR: &hasPR}), ids[1])
+	expect("without a PR", query(ThreadQuery{HasPR: &noPR, Model: "small"}), ids[6], ids[4], ids[3], ids[0])
+	from, to := base.Add(time.Hour), base.Add(3*time.Hour)
+	expect("activity range", query(ThreadQuery{From: &from, To: &to}), ids[2], ids[1])
+	expect("title substring", query(ThreadQuery{TitleContains: "PARSER BUG 3"}), ids[3])
+	expect("literal wildcards", query(ThreadQuery{TitleContains: "100%_"}), ids[6])
+
+	if _, _, err := repo.QueryThreads(ctx, ThreadQuery{Sort: "size"}); err == nil {
+		t.Fatal("expected an unknown sort to be rejected")
+	}
+}
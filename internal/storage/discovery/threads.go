@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -16,6 +17,21 @@ const (
 	ThreadStatusCompleted ThreadStatus = "completed"
 	ThreadStatusStopped   ThreadStatus = "stopped"
 	ThreadStatusFailed    ThreadStatus = "failed"
+	// ThreadStatusBudgetExceeded marks a turn stopped by a token or time budget.
+	ThreadStatusBudgetExceeded ThreadStatus = "budget-exceeded"
+	// ThreadStatusPolicyDenied marks a turn stopped by a deny policy rule.
+	ThreadStatusPolicyDenied ThreadStatus = "policy-denied"
+	// ThreadStatusAwaitingApproval marks a turn paused by an ask policy rule
+	// until the user approves the decision.
+	ThreadStatusAwaitingApproval ThreadStatus = "awaiting-approval"
+	// ThreadStatusQueued marks an idle thread whose queued turns are about to
+	// start.
+	ThreadStatusQueued ThreadStatus = "queued"
+	// ThreadStatusAwaitingReview marks a thread whose changes are waiting on a
+	// review, such as an open pull request.
+	ThreadStatusAwaitingReview ThreadStatus = "awaiting-review"
+	// ThreadStatusMerged marks a thread whose changes have been merged.
+	ThreadStatusMerged ThreadStatus = "merged"
 )
 
 // Thread represents a persisted conversation thread.
@@ -32,9 +48,23 @@ type Thread struct {
 	SandboxMode      string       `json:"sandboxMode"`
 	ReasoningLevel   string       `json:"reasoningLevel"`
 	Status           ThreadStatus `json:"status"`
+	ParentThreadID   int64        `json:"parentThreadId,omitempty"`
+	ForkedFromEntryID  int64        `json:"forkedFromEntryId,omitempty"`
+	GroupID          string       `json:"groupId,omitempty"`
+	ArchivedAt       *time.Time   `json:"archivedAt,omitempty"`
+	// PinnedAt is set on threads pinned to the top of their project's list.
+	PinnedAt       *time.Time   `json:"pinnedAt,omitempty"`
+	ScheduledJobID   int64        `json:"scheduledJobId,omitempty"`
+	// ReadOnly threads refuse new turns; ImportedAt is set on threads
+	// imported from a transcript archive.
+	ReadOnly   bool       `json:"readOnly,omitempty"`
+	ImportedAt *time.Time `json:"importedAt,omitempty"`
 	CreatedAt        time.Time    `json:"createdAt"`
 	UpdatedAt        time.Time    `json:"updatedAt"`
 	LastMessageAt    *time.Time   `json:"lastMessageAt,omitempty"`
+	// AgentID is the agent that ran the thread's latest turn; empty means
+	// the default agent.
+	AgentID string `json:"agentId,omitempty"`
 }
 
 // ConversationEntry represents a stored conversation transcript item.
@@ -46,6 +76,13 @@ type ConversationEntry struct {
 	Payload   json.RawMessage `json:"payload,omitempty"`
 	CreatedAt time.Time       `json:"createdAt"`
 	UpdatedAt time.Time       `json:"updatedAt"`
+	// RolledBackAt is set while the entry's turn is undone by a restored
+	// checkpoint.
+	RolledBackAt *time.Time `json:"rolledBackAt,omitempty"`
+	// ItemKey identifies the agent item an entry stores within its thread;
+	// CompletedAt is set once the item has finished.
+	ItemKey     string     `json:"itemKey,omitempty"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
 }
 
 // CreateThreadParams bundles the information required to persist a thread.
@@ -55,14 +92,26 @@ type CreateThreadParams struct {
 	Model          string
 	SandboxMode    string
 	ReasoningLevel string
+	// ParentThreadID and ForkedFromEntryID link a fork to its source thread.
+	ParentThreadID  int64
+	ForkedFromEntryID int64
+	// GroupID ties sibling threads started by one fan-out send.
+	GroupID string
+	// ScheduledJobID tags threads created by a scheduled job run.
+	ScheduledJobID int64
+	// ReadOnly and ImportedAt mark threads imported from a transcript archive.
+	ReadOnly   bool
+	ImportedAt *time.Time
+	// AgentID records the agent the thread runs on; empty means the default.
+	AgentID string
 }
 
 // CreateThread inserts a new thread record.
 func (r *Repository) CreateThread(ctx context.Context, params CreateThreadParams) (Thread, error) {
 	res, err := r.db.ExecContext(ctx, `
-        INSERT INTO threads (project_id, title, model, sandbox_mode, reasoning_level)
-        VALUES (?, ?, ?, ?, ?)
-    `, params.ProjectID, params.Title, params.Model, params.SandboxMode, params.ReasoningLevel)
+        INSERT INTO threads (project_id, title, model, sandbox_mode, reasoning_level, parent_thread_id, forked_from_entry_id, group_id, scheduled_job_id, read_only, imported_at, agent_id)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `, params.ProjectID, params.Title, params.Model, params.SandboxMode, params.ReasoningLevel, nullIfZero(params.ParentThreadID), nullIfZero(params.ForkedFromEntryID), nullIfEmpty(params.GroupID), nullIfZero(params.ScheduledJobID), params.ReadOnly, params.ImportedAt, nullIfEmpty(params.AgentID))
 	if err != nil {
 		return Thread{}, fmt.Errorf("insert thread: %w", err)
 	}
@@ -73,26 +122,34 @@ func (r *Repository) CreateThread(ctx context.Context, params CreateThreadParams
 	return r.GetThread(ctx, id)
 }
 
-// GetThread retrieves a thread by identifier.
-func (r *Repository) GetThread(ctx context.Context, id int64) (Thread, error) {
+// threadColumns lists the columns scanned by scanThread, in order.
+const threadColumns = `id, project_id, external_id, conversation_path, worktree_path, pr_url, branch_name, title, model, sandbox_mode, reasoning_level, status, parent_thread_id, forked_from_entry_id, group_id, archived_at, pinned_at, scheduled_job_id, read_only, imported_at, created_at, updated_at, last_message_at, agent_id`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanThread(row rowScanner) (Thread, error) {
 	var (
 		t               Thread
 		externalID      sql.NullString
 		conversationRaw sql.NullString
 		worktreePath    sql.NullString
-        prURL           sql.NullString
-        branchName      sql.NullString
+		prURL           sql.NullString
+		branchName      sql.NullString
+		parentThreadID  sql.NullInt64
+		forkedFrom      sql.NullInt64
+		groupID         sql.NullString
+		archivedAt      sql.NullTime
+		pinnedAt        sql.NullTime
+		scheduledJobID  sql.NullInt64
+		importedAt      sql.NullTime
 		lastMessageAt   sql.NullTime
+		agentID         sql.NullString
 	)
-	err := r.db.QueryRowContext(ctx, `
-            SELECT id, project_id, external_id, conversation_path, worktree_path, pr_url, branch_name, title, model, sandbox_mode, reasoning_level, status, created_at, updated_at, last_message_at
-            FROM threads
-            WHERE id = ?
-        `, id).Scan(&t.ID, &t.ProjectID, &externalID, &conversationRaw, &worktreePath, &prURL, &branchName, &t.Title, &t.Model, &t.SandboxMode, &t.ReasoningLevel, &t.Status, &t.CreatedAt, &t.UpdatedAt, &lastMessageAt)
-	if err != nil {
-		return Thread{}, fmt.Errorf("select thread: %w", err)
+	if err := row.Scan(&t.ID, &t.ProjectID, &externalID, &conversationRaw, &worktreePath, &prURL, &branchName, &t.Title, &t.Model, &t.SandboxMode, &t.ReasoningLevel, &t.Status, &parentThreadID, &forkedFrom, &groupID, &archivedAt, &pinnedAt, &scheduledJobID, &t.ReadOnly, &importedAt, &t.CreatedAt, &t.UpdatedAt, &lastMessageAt, &agentID); err != nil {
+		return Thread{}, err
 	}
-
 	if externalID.Valid {
 		t.ExternalID = externalID.String
 	}
@@ -108,63 +165,176 @@ func (r *Repository) GetThread(ctx context.Context, id int64) (Thread, error) {
 	if branchName.Valid {
 		t.BranchName = branchName.String
 	}
+	if parentThreadID.Valid {
+		t.ParentThreadID = parentThreadID.Int64
+	}
+	if forkedFrom.Valid {
+		t.ForkedFromEntryID = forkedFrom.Int64
+	}
+	if groupID.Valid {
+		t.GroupID = groupID.String
+	}
+	if archivedAt.Valid {
+		t.ArchivedAt = &archivedAt.Time
+	}
+	if pinnedAt.Valid {
+		t.PinnedAt = &pinnedAt.Time
+	}
+	if scheduledJobID.Valid {
+		t.ScheduledJobID = scheduledJobID.Int64
+	}
+	if importedAt.Valid {
+		t.ImportedAt = &importedAt.Time
+	}
 	if lastMessageAt.Valid {
 		t.LastMessageAt = &lastMessageAt.Time
 	}
+	t.AgentID = agentID.String
 	return t, nil
 }
 
-// ListThreadsByProject lists threads ordered by recency.
-func (r *Repository) ListThreadsByProject(ctx context.Context, projectID int64) ([]Thread, error) {
+// GetThread retrieves a thread by identifier.
+func (r *Repository) GetThread(ctx context.Context, id int64) (Thread, error) {
+	t, err := scanThread(r.db.QueryRowContext(ctx, `
+            SELECT `+threadColumns+`
+            FROM threads
+            WHERE id = ?
+        `, id))
+	if err != nil {
+		return Thread{}, fmt.Errorf("select thread: %w", err)
+	}
+	return t, nil
+}
+
+// ThreadFilter narrows ListThreads and QueryThreads. A zero ProjectID
+// matches every project. Archived threads are left out unless
+// IncludeArchived or ArchivedOnly is set; an empty Statuses matches every
+// status.
+type ThreadFilter struct {
+	ProjectID       int64
+	IncludeArchived bool
+	ArchivedOnly    bool
+	PinnedOnly      bool
+	Statuses        []ThreadStatus
+}
+
+func (f ThreadFilter) where() ([]string, []any) {
 	var (
-		rows *sql.Rows
-		err  error
+		clauses []string
+		args    []any
 	)
-	rows, err = r.db.QueryContext(ctx, `
-            SELECT id, project_id, external_id, conversation_path, worktree_path, pr_url, branch_name, title, model, sandbox_mode, reasoning_level, status, created_at, updated_at, last_message_at
+	if f.ProjectID != 0 {
+		clauses = append(clauses, "project_id = ?")
+		args = append(args, f.ProjectID)
+	}
+	switch {
+	case f.ArchivedOnly:
+		clauses = append(clauses, "archived_at IS NOT NULL")
+	case !f.IncludeArchived:
+		clauses = append(clauses, "archived_at IS NULL")
+	}
+	if f.PinnedOnly {
+		clauses = append(clauses, "pinned_at IS NOT NULL")
+	}
+	if len(f.Statuses) > 0 {
+		clauses = append(clauses, "status IN (?"+strings.Repeat(", ?", len(f.Statuses)-1)+")")
+		for _, status := range f.Statuses {
+			args = append(args, status)
+		}
+	}
+	return clauses, args
+}
+
+// ListThreadsByProject lists non-archived threads, pinned first, then by
+// recency.
+func (r *Repository) ListThreadsByProject(ctx context.Context, projectID int64) ([]Thread, error) {
+	return r.ListThreads(ctx, ThreadFilter{ProjectID: projectID})
+}
+
+// ListThreads lists the threads matching filter, pinned first, then by
+// recency.
+func (r *Repository) ListThreads(ctx context.Context, filter ThreadFilter) ([]Thread, error) {
+	where, args := filter.where()
+	clause := ""
+	if len(where) > 0 {
+		clause = "WHERE " + strings.Join(where, " AND ")
+	}
+	rows, err := r.db.QueryContext(ctx, `
+            SELECT `+threadColumns+`
             FROM threads
-            WHERE project_id = ?
-            ORDER BY COALESCE(last_message_at, updated_at) DESC, id DESC
-        `, projectID)
+            `+clause+`
+            ORDER BY pinned_at IS NULL, COALESCE(last_message_at, updated_at) DESC, id DESC
+        `, args...)
 	if err != nil {
 		return nil, fmt.Errorf("query threads: %w", err)
 	}
+	return collectThreads(rows)
+}
+
+// ListThreadsByGroup lists the threads of a fan-out group in creation order,
+// including archived ones.
+func (r *Repository) ListThreadsByGroup(ctx context.Context, groupID string) ([]Thread, error) {
+	rows, err := r.db.QueryContext(ctx, `
+            SELECT `+threadColumns+`
+            FROM threads
+            WHERE group_id = ?
+            ORDER BY id ASC
+        `, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("query group threads: %w", err)
+	}
+	return collectThreads(rows)
+}
+
+// SetThreadArchived archives or unarchives a thread.
+func (r *Repository) SetThreadArchived(ctx context.Context, id int64, archived bool) error {
+	query := `UPDATE threads SET archived_at = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
+	if archived {
+		query = `UPDATE threads SET archived_at = COALESCE(archived_at, CURRENT_TIMESTAMP), updated_at = CURRENT_TIMESTAMP WHERE id = ?`
+	}
+	res, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("update thread archived: %w", err)
+	}
+	if rows, err := res.RowsAffected(); err == nil && rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// UpdateThreadAgent records the agent a thread runs on.
+func (r *Repository) UpdateThreadAgent(ctx context.Context, id int64, agentID string) error {
+	if _, err := r.db.ExecContext(ctx, `UPDATE threads SET agent_id = ? WHERE id = ?`, nullIfEmpty(agentID), id); err != nil {
+		return fmt.Errorf("update thread agent: %w", err)
+	}
+	return nil
+}
+
+// SetThreadPinned pins or unpins a thread.
+func (r *Repository) SetThreadPinned(ctx context.Context, id int64, pinned bool) error {
+	query := `UPDATE threads SET pinned_at = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
+	if pinned {
+		query = `UPDATE threads SET pinned_at = COALESCE(pinned_at, CURRENT_TIMESTAMP), updated_at = CURRENT_TIMESTAMP WHERE id = ?`
+	}
+	res, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("update thread pinned: %w", err)
+	}
+	if rows, err := res.RowsAffected(); err == nil && rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func collectThreads(rows *sql.Rows) ([]Thread, error) {
 	defer rows.Close()
 
 	var threads []Thread
 	for rows.Next() {
-		var (
-			t               Thread
-			externalID      sql.NullString
-			conversationRaw sql.NullString
-			worktreePath    sql.NullString
-			prURL           sql.NullString
-			branchName      sql.NullString
-			lastMessageAt   sql.NullTime
-		)
-		scanErr := rows.Scan(&t.ID, &t.ProjectID, &externalID, &conversationRaw, &worktreePath, &prURL, &branchName, &t.Title, &t.Model, &t.SandboxMode, &t.ReasoningLevel, &t.Status, &t.CreatedAt, &t.UpdatedAt, &lastMessageAt)
+		t, scanErr := scanThread(rows)
 		if scanErr != nil {
-			fmt.Println("Scan error:", scanErr)
 			return nil, fmt.Errorf("scan thread: %w", scanErr)
 		}
-		if externalID.Valid {
-			t.ExternalID = externalID.String
-		}
-		if conversationRaw.Valid {
-			t.ConversationPath = conversationRaw.String
-		}
-		if worktreePath.Valid {
-			t.WorktreePath = worktreePath.String
-		}
-		if prURL.Valid {
-			t.PRURL = prURL.String
-		}
-		if branchName.Valid {
-			t.BranchName = branchName.String
-		}
-		if lastMessageAt.Valid {
-			t.LastMessageAt = &lastMessageAt.Time
-		}
 		threads = append(threads, t)
 	}
 	if err := rows.Err(); err != nil {
@@ -237,6 +407,21 @@ func (r *Repository) UpdateThreadOptions(ctx context.Context, id int64, model, s
 	return nil
 }
 
+// GetThreadByExternalID retrieves the thread bound to an agent session ID.
+func (r *Repository) GetThreadByExternalID(ctx context.Context, externalID string) (Thread, error) {
+	t, err := scanThread(r.db.QueryRowContext(ctx, `
+            SELECT `+threadColumns+`
+            FROM threads
+            WHERE external_id = ?
+            ORDER BY id
+            LIMIT 1
+        `, externalID))
+	if err != nil {
+		return Thread{}, fmt.Errorf("select thread by external id: %w", err)
+	}
+	return t, nil
+}
+
 // UpdateThreadExternalID stores the remote identifier for a thread.
 func (r *Repository) UpdateThreadExternalID(ctx context.Context, id int64, externalID string) error {
 	_, err := r.db.ExecContext(ctx, `
@@ -316,6 +501,13 @@ func (r *Repository) DeleteThread(ctx context.Context, id int64) error {
 	return nil
 }
 
+func nullIfZero(value int64) interface{} {
+	if value == 0 {
+		return nil
+	}
+	return value
+}
+
 func maybeNullJSON(raw json.RawMessage) interface{} {
 	if len(raw) == 0 {
 		return nil
@@ -331,6 +523,9 @@ type CreateConversationEntryParams struct {
 	Payload   json.RawMessage
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	// ItemKey and CompletedAt are used by UpsertConversationEntry.
+	ItemKey     string
+	CompletedAt *time.Time
 }
 
 // CreateConversationEntry inserts a conversation entry record.
@@ -358,30 +553,99 @@ func (r *Repository) CreateConversationEntry(ctx context.Context, params CreateC
 	return r.GetConversationEntry(ctx, id)
 }
 
-// GetConversationEntry retrieves a conversation entry by identifier.
-func (r *Repository) GetConversationEntry(ctx context.Context, id int64) (ConversationEntry, error) {
-	var (
-		entry   ConversationEntry
-		payload sql.NullString
-	)
-	err := r.db.QueryRowContext(ctx, `
-        SELECT id, thread_id, role, entry_type, payload, created_at, updated_at
+// UpsertConversationEntry stores the latest state of the agent item
+// identified by params.ItemKey. The first call inserts the entry; later calls
+// replace its type and payload and move its updated time, keeping its id and
+// created time and so its place in the timeline. A completion time, once set,
+// is kept.
+func (r *Repository) UpsertConversationEntry(ctx context.Context, params CreateConversationEntryParams) (ConversationEntry, error) {
+	if params.ItemKey == "" {
+		return ConversationEntry{}, fmt.Errorf("upsert conversation entry: item key is required")
+	}
+	createdAt := params.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now().UTC()
+	}
+	updatedAt := params.UpdatedAt
+	if updatedAt.IsZero() {
+		updatedAt = createdAt
+	}
+	if _, err := r.db.ExecContext(ctx, `
+        INSERT INTO thread_entries (thread_id, role, entry_type, payload, created_at, updated_at, item_key, completed_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+        ON CONFLICT (thread_id, item_key) WHERE item_key IS NOT NULL DO UPDATE SET
+            entry_type = excluded.entry_type,
+            payload = excluded.payload,
+            updated_at = excluded.updated_at,
+            completed_at = COALESCE(thread_entries.completed_at, excluded.completed_at)
+    `, params.ThreadID, params.Role, params.EntryType, maybeNullJSON(params.Payload), createdAt, updatedAt, params.ItemKey, params.CompletedAt); err != nil {
+		return ConversationEntry{}, fmt.Errorf("upsert conversation entry: %w", err)
+	}
+	entry, err := scanConversationEntry(r.db.QueryRowContext(ctx, `
+        SELECT `+conversationEntryColumns+`
         FROM thread_entries
-        WHERE id = ?
-    `, id).Scan(&entry.ID, &entry.ThreadID, &entry.Role, &entry.EntryType, &payload, &entry.CreatedAt, &entry.UpdatedAt)
+        WHERE thread_id = ? AND item_key = ?
+    `, params.ThreadID, params.ItemKey))
 	if err != nil {
 		return ConversationEntry{}, fmt.Errorf("select conversation entry: %w", err)
 	}
+	return entry, nil
+}
+
+const conversationEntryColumns = `id, thread_id, role, entry_type, payload, created_at, updated_at, rolled_back_at, item_key, completed_at`
+
+func scanConversationEntry(row rowScanner) (ConversationEntry, error) {
+	var (
+		entry       ConversationEntry
+		payload     sql.NullString
+		rolledBack  sql.NullTime
+		itemKey     sql.NullString
+		completedAt sql.NullTime
+	)
+	if err := row.Scan(&entry.ID, &entry.ThreadID, &entry.Role, &entry.EntryType, &payload, &entry.CreatedAt, &entry.UpdatedAt, &rolledBack, &itemKey, &completedAt); err != nil {
+		return ConversationEntry{}, err
+	}
 	if payload.Valid {
 		entry.Payload = json.RawMessage(payload.String)
 	}
+	if rolledBack.Valid {
+		entry.RolledBackAt = &rolledBack.Time
+	}
+	entry.ItemKey = itemKey.String
+	if completedAt.Valid {
+		entry.CompletedAt = &completedAt.Time
+	}
 	return entry, nil
 }
 
+// GetConversationEntry retrieves a conversation entry by identifier.
+func (r *Repository) GetConversationEntry(ctx context.Context, id int64) (ConversationEntry, error) {
+	entry, err := scanConversationEntry(r.db.QueryRowContext(ctx, `
+        SELECT `+conversationEntryColumns+`
+        FROM thread_entries
+        WHERE id = ?
+    `, id))
+	if err != nil {
+		return ConversationEntry{}, fmt.Errorf("select conversation entry: %w", err)
+	}
+	return entry, nil
+}
+
+// HasUserEntries reports whether a thread has any user entries.
+func (r *Repository) HasUserEntries(ctx context.Context, threadID int64) (bool, error) {
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, `
+        SELECT EXISTS (SELECT 1 FROM thread_entries WHERE thread_id = ? AND role = 'user')
+    `, threadID).Scan(&exists); err != nil {
+		return false, fmt.Errorf("select user entries: %w", err)
+	}
+	return exists, nil
+}
+
 // ListConversationEntries returns entries ordered chronologically for a thread.
 func (r *Repository) ListConversationEntries(ctx context.Context, threadID int64) ([]ConversationEntry, error) {
 	rows, err := r.db.QueryContext(ctx, `
-        SELECT id, thread_id, role, entry_type, payload, created_at, updated_at
+        SELECT `+conversationEntryColumns+`
         FROM thread_entries
         WHERE thread_id = ?
         ORDER BY created_at ASC, id ASC
@@ -393,16 +657,10 @@ func (r *Repository) ListConversationEntries(ctx context.Context, threadID int64
 
 	var entries []ConversationEntry
 	for rows.Next() {
-		var (
-			entry   ConversationEntry
-			payload sql.NullString
-		)
-		if err := rows.Scan(&entry.ID, &entry.ThreadID, &entry.Role, &entry.EntryType, &payload, &entry.CreatedAt, &entry.UpdatedAt); err != nil {
+		entry, err := scanConversationEntry(rows)
+		if err != nil {
 			return nil, fmt.Errorf("scan conversation entry: %w", err)
 		}
-		if payload.Valid {
-			entry.Payload = json.RawMessage(payload.String)
-		}
 		entries = append(entries, entry)
 	}
 	if err := rows.Err(); err != nil {
@@ -410,3 +668,105 @@ func (r *Repository) ListConversationEntries(ctx context.Context, threadID int64
 	}
 	return entries, nil
 }
+
+// ConversationEntryPage selects up to Limit entries of a thread immediately
+// before BeforeID or after AfterID, both entry ids of the same thread. With
+// neither set it selects the latest entries.
+type ConversationEntryPage struct {
+	ThreadID int64
+	BeforeID int64
+	AfterID  int64
+	Limit    int
+}
+
+// ListConversationEntriesPage returns a page of entries in chronological
+// order and whether more entries lie beyond it in the paging direction:
+// earlier ones unless AfterID is set. It returns sql.ErrNoRows when the
+// cursor entry is not part of the thread.
+func (r *Repository) ListConversationEntriesPage(ctx context.Context, page ConversationEntryPage) ([]ConversationEntry, bool, error) {
+	if page.Limit <= 0 {
+		return nil, false, fmt.Errorf("page limit must be positive")
+	}
+	where := "thread_id = ?"
+	args := []any{page.ThreadID}
+	order := "DESC"
+	cursor := page.BeforeID
+	if page.AfterID != 0 {
+		cursor, order = page.AfterID, "ASC"
+	}
+	if cursor != 0 {
+		var exists bool
+		if err := r.db.QueryRowContext(ctx, `
+            SELECT EXISTS (SELECT 1 FROM thread_entries WHERE id = ? AND thread_id = ?)
+        `, cursor, page.ThreadID).Scan(&exists); err != nil {
+			return nil, false, fmt.Errorf("select cursor entry: %w", err)
+		}
+		if !exists {
+			return nil, false, sql.ErrNoRows
+		}
+		cmp := "<"
+		if order == "ASC" {
+			cmp = ">"
+		}
+		where += " AND (created_at, id) " + cmp + " (SELECT created_at, id FROM thread_entries WHERE id = ?)"
+		args = append(args, cursor)
+	}
+	args = append(args, page.Limit+1)
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT `+conversationEntryColumns+`
+        FROM thread_entries
+        WHERE `+where+`
+        ORDER BY created_at `+order+`, id `+order+`
+        LIMIT ?
+    `, args...)
+	if err != nil {
+		return nil, false, fmt.Errorf("query conversation entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []ConversationEntry
+	for rows.Next() {
+		entry, err := scanConversationEntry(rows)
+		if err != nil {
+			return nil, false, fmt.Errorf("scan conversation entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, fmt.Errorf("iterate conversation entries: %w", err)
+	}
+	more := len(entries) > page.Limit
+	if more {
+		entries = entries[:page.Limit]
+	}
+	if order == "DESC" {
+		for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+			entries[i], entries[j] = entries[j], entries[i]
+		}
+	}
+	return entries, more, nil
+}
+
+// CopyConversationEntries duplicates the transcript of src into dst up to and
+// including uptoEntryID, preserving timestamps and order. Entries rolled back
+// by a restored checkpoint are left out. Returns the number of copied entries.
+func (r *Repository) CopyConversationEntries(ctx context.Context, srcThreadID, dstThreadID, uptoEntryID int64) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `
+        INSERT INTO thread_entries (thread_id, role, entry_type, payload, created_at, updated_at, item_key, completed_at)
+        SELECT ?, e.role, e.entry_type, e.payload, e.created_at, e.updated_at, e.item_key, e.completed_at
+        FROM thread_entries e, thread_entries upto
+        WHERE upto.id = ? AND upto.thread_id = ?
+          AND e.thread_id = upto.thread_id
+          AND e.rolled_back_at IS NULL
+          AND (e.created_at < upto.created_at OR (e.created_at = upto.created_at AND e.id <= upto.id))
+        ORDER BY e.created_at ASC, e.id ASC
+    `, dstThreadID, uptoEntryID, srcThreadID)
+	if err != nil {
+		return 0, fmt.Errorf("copy conversation entries: %w", err)
+	}
+	copied, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("copy conversation entries rows affected: %w", err)
+	}
+	return copied, nil
+}
@@ -0,0 +1,227 @@
+package discovery
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TurnUsage is one turn's token usage in the ledger.
+type TurnUsage struct {
+	ID                int64     `json:"id"`
+	ThreadID          int64     `json:"threadId"`
+	ProjectID         int64     `json:"projectId"`
+	AgentID           string    `json:"agentId,omitempty"`
+	Model             string    `json:"model,omitempty"`
+	ReasoningLevel    string    `json:"reasoningLevel,omitempty"`
+	Status            string    `json:"status,omitempty"`
+	InputTokens       int64     `json:"inputTokens"`
+	CachedInputTokens int64     `json:"cachedInputTokens"`
+	OutputTokens      int64     `json:"outputTokens"`
+	StartedAt         time.Time `json:"startedAt"`
+	CompletedAt       time.Time `json:"completedAt"`
+}
+
+// UsageFilter narrows ledger queries. Zero fields do not filter; From is
+// inclusive and To exclusive, both on the completion time.
+type UsageFilter struct {
+	ThreadID  int64
+	ProjectID int64
+	Model     string
+	From      *time.Time
+	To        *time.Time
+}
+
+// Usage grouping keys accepted by SumTurnUsage.
+const (
+	UsageGroupThread  = "thread"
+	UsageGroupProject = "project"
+	UsageGroupModel   = "model"
+	UsageGroupAgent   = "agent"
+	UsageGroupDay     = "day"
+	UsageGroupMonth   = "month"
+)
+
+// Times are stored in UTC, so day and month keys are UTC calendar periods.
+var usageGroupColumns = map[string]string{
+	UsageGroupThread:  "CAST(thread_id AS TEXT)",
+	UsageGroupProject: "CAST(project_id AS TEXT)",
+	UsageGroupModel:   "COALESCE(model, '')",
+	UsageGroupAgent:   "COALESCE(agent_id, '')",
+	UsageGroupDay:     "substr(completed_at, 1, 10)",
+	UsageGroupMonth:   "substr(completed_at, 1, 7)",
+}
+
+// UsageTotal aggregates ledger rows. Keys holds one value per grouping key,
+// in the order requested.
+type UsageTotal struct {
+	Keys              []string `json:"keys"`
+	Turns             int64    `json:"turns"`
+	InputTokens       int64    `json:"inputTokens"`
+	CachedInputTokens int64    `json:"cachedInputTokens"`
+	OutputTokens      int64    `json:"outputTokens"`
+}
+
+// RecordTurnUsage appends a turn to the usage ledger.
+func (r *Repository) RecordTurnUsage(ctx context.Context, usage TurnUsage) (TurnUsage, error) {
+	return insertTurnUsage(ctx, r.db, usage)
+}
+
+// RecordTurnUsages appends several turns to the usage ledger in one
+// transaction, so either all of them are recorded or none is.
+func (r *Repository) RecordTurnUsages(ctx context.Context, usages []TurnUsage) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin record turn usages: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, usage := range usages {
+		if _, err := insertTurnUsage(ctx, tx, usage); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit record turn usages: %w", err)
+	}
+	return nil
+}
+
+// usageExecer is satisfied by both *sql.DB and *sql.Tx.
+type usageExecer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func insertTurnUsage(ctx context.Context, db usageExecer, usage TurnUsage) (TurnUsage, error) {
+	completedAt := usage.CompletedAt
+	if completedAt.IsZero() {
+		completedAt = time.Now()
+	}
+	startedAt := usage.StartedAt
+	if startedAt.IsZero() {
+		startedAt = completedAt
+	}
+	usage.StartedAt = startedAt.UTC()
+	usage.CompletedAt = completedAt.UTC()
+	res, err := db.ExecContext(ctx, `
+        INSERT INTO turn_usage (thread_id, project_id, agent_id, model, reasoning_level, status, input_tokens, cached_input_tokens, output_tokens, started_at, completed_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `, usage.ThreadID, usage.ProjectID, nullIfEmpty(usage.AgentID), nullIfEmpty(usage.Model), nullIfEmpty(usage.ReasoningLevel), nullIfEmpty(usage.Status),
+		usage.InputTokens, usage.CachedInputTokens, usage.OutputTokens, usage.StartedAt, usage.CompletedAt)
+	if err != nil {
+		return TurnUsage{}, fmt.Errorf("insert turn usage: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return TurnUsage{}, fmt.Errorf("turn usage last insert id: %w", err)
+	}
+	usage.ID = id
+	return usage, nil
+}
+
+func (f UsageFilter) where() (string, []any) {
+	var (
+		clauses []string
+		args    []any
+	)
+	if f.ThreadID != 0 {
+		clauses = append(clauses, "thread_id = ?")
+		args = append(args, f.ThreadID)
+	}
+	if f.ProjectID != 0 {
+		clauses = append(clauses, "project_id = ?")
+		args = append(args, f.ProjectID)
+	}
+	if f.Model != "" {
+		clauses = append(clauses, "model = ?")
+		args = append(args, f.Model)
+	}
+	if f.From != nil {
+		clauses = append(clauses, "completed_at >= ?")
+		args = append(args, f.From.UTC())
+	}
+	if f.To != nil {
+		clauses = append(clauses, "completed_at < ?")
+		args = append(args, f.To.UTC())
+	}
+	if len(clauses) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(clauses, " AND "), args
+}
+
+// SumTurnUsage totals the ledger rows matching filter, grouped by the given
+// keys (see the UsageGroup constants). Without keys a single total is returned.
+func (r *Repository) SumTurnUsage(ctx context.Context, filter UsageFilter, groupBy []string) ([]UsageTotal, error) {
+	columns := make([]string, 0, len(groupBy))
+	for _, key := range groupBy {
+		column, ok := usageGroupColumns[key]
+		if !ok {
+			return nil, fmt.Errorf("unknown usage grouping %q", key)
+		}
+		columns = append(columns, column)
+	}
+	where, args := filter.where()
+	selectKeys, groupClause := "", ""
+	if len(columns) > 0 {
+		selectKeys = strings.Join(columns, ", ") + ", "
+		groupClause = "GROUP BY " + strings.Join(columns, ", ") + " ORDER BY " + strings.Join(columns, ", ")
+	}
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT `+selectKeys+`COUNT(*), COALESCE(SUM(input_tokens), 0), COALESCE(SUM(cached_input_tokens), 0), COALESCE(SUM(output_tokens), 0)
+        FROM turn_usage
+        `+where+`
+        `+groupClause, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query usage totals: %w", err)
+	}
+	defer rows.Close()
+	var totals []UsageTotal
+	for rows.Next() {
+		total := UsageTotal{Keys: make([]string, len(columns))}
+		dest := make([]any, 0, len(columns)+4)
+		for i := range total.Keys {
+			dest = append(dest, &total.Keys[i])
+		}
+		dest = append(dest, &total.Turns, &total.InputTokens, &total.CachedInputTokens, &total.OutputTokens)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("scan usage total: %w", err)
+		}
+		totals = append(totals, total)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate usage totals: %w", err)
+	}
+	return totals, nil
+}
+
+// ListTurnUsage returns the ledger rows matching filter, oldest first.
+func (r *Repository) ListTurnUsage(ctx context.Context, filter UsageFilter) ([]TurnUsage, error) {
+	where, args := filter.where()
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT id, thread_id, project_id, COALESCE(agent_id, ''), COALESCE(model, ''), COALESCE(reasoning_level, ''), COALESCE(status, ''),
+               input_tokens, cached_input_tokens, output_tokens, started_at, completed_at
+        FROM turn_usage
+        `+where+`
+        ORDER BY completed_at, id
+    `, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query turn usage: %w", err)
+	}
+	defer rows.Close()
+	var list []TurnUsage
+	for rows.Next() {
+		var u TurnUsage
+		if err := rows.Scan(&u.ID, &u.ThreadID, &u.ProjectID, &u.AgentID, &u.Model, &u.ReasoningLevel, &u.Status,
+			&u.InputTokens, &u.CachedInputTokens, &u.OutputTokens, &u.StartedAt, &u.CompletedAt); err != nil {
+			return nil, fmt.Errorf("scan turn usage: %w", err)
+		}
+		list = append(list, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate turn usage: %w", err)
+	}
+	return list, nil
+}
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE threads ADD COLUMN parent_thread_id INTEGER REFERENCES threads(id) ON DELETE SET NULL;
+ALTER TABLE threads ADD COLUMN forked_from_entry_id INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_threads_parent ON threads(parent_thread_id);
+
+-- +goose Down
+-- No-op: keeping fork lineage columns if present. Recreate table without columns if needed.
+SELECT 1;
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE threads ADD COLUMN group_id TEXT;
+ALTER TABLE threads ADD COLUMN archived_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_threads_group ON threads(group_id);
+
+-- +goose Down
+-- No-op: keeping group/archive columns if present. Recreate table without columns if needed.
+SELECT 1;
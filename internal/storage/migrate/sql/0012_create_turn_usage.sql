@@ -0,0 +1,36 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS turn_usage (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    thread_id INTEGER NOT NULL,
+    project_id INTEGER NOT NULL,
+    agent_id TEXT,
+    model TEXT,
+    reasoning_level TEXT,
+    status TEXT,
+    input_tokens INTEGER NOT NULL DEFAULT 0,
+    cached_input_tokens INTEGER NOT NULL DEFAULT 0,
+    output_tokens INTEGER NOT NULL DEFAULT 0,
+    started_at TIMESTAMP NOT NULL,
+    completed_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_turn_usage_project_completed ON turn_usage(project_id, completed_at);
+CREATE INDEX IF NOT EXISTS idx_turn_usage_thread ON turn_usage(thread_id);
+CREATE INDEX IF NOT EXISTS idx_turn_usage_completed ON turn_usage(completed_at);
+
+-- Backfill from the usage system messages written before the ledger existed.
+INSERT INTO turn_usage (thread_id, project_id, model, reasoning_level, input_tokens, cached_input_tokens, output_tokens, started_at, completed_at)
+SELECT e.thread_id, t.project_id, t.model, t.reasoning_level,
+       COALESCE(json_extract(e.payload, '$.meta.inputTokens'), 0),
+       COALESCE(json_extract(e.payload, '$.meta.cachedInputTokens'), 0),
+       COALESCE(json_extract(e.payload, '$.meta.outputTokens'), 0),
+       e.created_at, e.created_at
+FROM thread_entries e
+JOIN threads t ON t.id = e.thread_id
+WHERE e.role = 'system'
+  AND json_valid(e.payload)
+  AND json_extract(e.payload, '$.meta.inputTokens') IS NOT NULL
+ORDER BY e.id;
+
+-- +goose Down
+DROP TABLE IF EXISTS turn_usage;
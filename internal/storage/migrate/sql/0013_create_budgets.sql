@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS budgets (
+    scope TEXT NOT NULL,
+    scope_id INTEGER NOT NULL,
+    max_turn_tokens INTEGER NOT NULL DEFAULT 0,
+    max_turn_seconds INTEGER NOT NULL DEFAULT 0,
+    max_thread_tokens INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (scope, scope_id)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS budgets;
@@ -0,0 +1,79 @@
+-- +goose Up
+-- Entries are indexed under their own id as rowid; thread titles under the
+-- negated thread id.
+CREATE VIRTUAL TABLE IF NOT EXISTS conversation_search USING fts5(
+    thread_id UNINDEXED,
+    text,
+    command,
+    tokenize = 'porter unicode61'
+);
+
+CREATE VIEW IF NOT EXISTS conversation_search_source AS
+SELECT id,
+       thread_id,
+       CASE WHEN json_valid(payload) THEN trim(
+           COALESCE(json_extract(payload, '$.text'), '') || ' ' ||
+           COALESCE(json_extract(payload, '$.reasoning'), '') || ' ' ||
+           COALESCE(json_extract(payload, '$.message'), '')
+       ) ELSE COALESCE(payload, '') END AS text,
+       CASE WHEN json_valid(payload) THEN trim(
+           COALESCE(json_extract(payload, '$.command.command'), '') || ' ' ||
+           COALESCE(json_extract(payload, '$.command.aggregatedOutput'), '')
+       ) ELSE '' END AS command
+FROM thread_entries;
+
+INSERT INTO conversation_search (rowid, thread_id, text, command)
+SELECT id, thread_id, text, command FROM conversation_search_source;
+
+INSERT INTO conversation_search (rowid, thread_id, text, command)
+SELECT -id, id, title, '' FROM threads;
+
+-- +goose StatementBegin
+CREATE TRIGGER IF NOT EXISTS conversation_search_entry_insert AFTER INSERT ON thread_entries BEGIN
+    INSERT INTO conversation_search (rowid, thread_id, text, command)
+    SELECT id, thread_id, text, command FROM conversation_search_source WHERE id = NEW.id;
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER IF NOT EXISTS conversation_search_entry_update AFTER UPDATE OF payload ON thread_entries BEGIN
+    DELETE FROM conversation_search WHERE rowid = OLD.id;
+    INSERT INTO conversation_search (rowid, thread_id, text, command)
+    SELECT id, thread_id, text, command FROM conversation_search_source WHERE id = NEW.id;
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER IF NOT EXISTS conversation_search_entry_delete AFTER DELETE ON thread_entries BEGIN
+    DELETE FROM conversation_search WHERE rowid = OLD.id;
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER IF NOT EXISTS conversation_search_thread_insert AFTER INSERT ON threads BEGIN
+    INSERT INTO conversation_search (rowid, thread_id, text, command) VALUES (-NEW.id, NEW.id, NEW.title, '');
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER IF NOT EXISTS conversation_search_thread_rename AFTER UPDATE OF title ON threads BEGIN
+    DELETE FROM conversation_search WHERE rowid = -OLD.id;
+    INSERT INTO conversation_search (rowid, thread_id, text, command) VALUES (-NEW.id, NEW.id, NEW.title, '');
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER IF NOT EXISTS conversation_search_thread_delete AFTER DELETE ON threads BEGIN
+    DELETE FROM conversation_search WHERE thread_id = OLD.id;
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TRIGGER IF EXISTS conversation_search_thread_delete;
+DROP TRIGGER IF EXISTS conversation_search_thread_rename;
+DROP TRIGGER IF EXISTS conversation_search_thread_insert;
+DROP TRIGGER IF EXISTS conversation_search_entry_delete;
+DROP TRIGGER IF EXISTS conversation_search_entry_update;
+DROP TRIGGER IF EXISTS conversation_search_entry_insert;
+DROP VIEW IF EXISTS conversation_search_source;
+DROP TABLE IF EXISTS conversation_search;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE threads ADD COLUMN read_only INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE threads ADD COLUMN imported_at TIMESTAMP;
+
+-- +goose Down
+-- No-op: keeping import columns if present.
+SELECT 1;
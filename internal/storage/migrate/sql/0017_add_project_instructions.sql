@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE project_settings ADD COLUMN instructions TEXT;
+
+-- +goose Down
+ALTER TABLE project_settings DROP COLUMN instructions;
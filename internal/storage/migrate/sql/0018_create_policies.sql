@@ -0,0 +1,42 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS policy_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    project_id INTEGER NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    action TEXT NOT NULL CHECK (action IN ('allow', 'deny', 'ask')),
+    target TEXT NOT NULL CHECK (target IN ('command', 'path')),
+    pattern TEXT NOT NULL,
+    description TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_policy_rules_project ON policy_rules(project_id, position, id);
+
+-- Decisions are an audit log: they keep the rule's action and pattern and
+-- outlive the rules and threads they refer to.
+CREATE TABLE IF NOT EXISTS policy_decisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    thread_id INTEGER NOT NULL,
+    project_id INTEGER NOT NULL,
+    rule_id INTEGER,
+    pattern TEXT,
+    action TEXT NOT NULL,
+    target TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    item_id TEXT,
+    reason TEXT,
+    approved_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_policy_decisions_thread ON policy_decisions(thread_id, id);
+CREATE INDEX IF NOT EXISTS idx_policy_decisions_project ON policy_decisions(project_id, id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_policy_decisions_project;
+DROP INDEX IF EXISTS idx_policy_decisions_thread;
+DROP TABLE IF EXISTS policy_decisions;
+DROP INDEX IF EXISTS idx_policy_rules_project;
+DROP TABLE IF EXISTS policy_rules;
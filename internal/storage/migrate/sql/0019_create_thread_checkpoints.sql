@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS thread_checkpoints (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    thread_id INTEGER NOT NULL,
+    entry_id INTEGER NOT NULL,
+    ref TEXT NOT NULL,
+    commit_sha TEXT NOT NULL,
+    head_sha TEXT,
+    restored_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (thread_id) REFERENCES threads(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_thread_checkpoints_thread ON thread_checkpoints(thread_id, id);
+
+ALTER TABLE thread_entries ADD COLUMN rolled_back_at TIMESTAMP;
+
+-- +goose Down
+-- Keeping the rolled_back_at column if present.
+DROP TABLE IF EXISTS thread_checkpoints;
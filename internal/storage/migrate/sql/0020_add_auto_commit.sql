@@ -0,0 +1,19 @@
+-- +goose Up
+ALTER TABLE project_settings ADD COLUMN auto_commit INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS thread_commits (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    thread_id INTEGER NOT NULL,
+    entry_id INTEGER NOT NULL,
+    agent_entry_id INTEGER,
+    commit_sha TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (thread_id) REFERENCES threads(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_thread_commits_thread ON thread_commits(thread_id, id);
+
+-- +goose Down
+-- Keeping the auto_commit column if present.
+DROP TABLE IF EXISTS thread_commits;
@@ -0,0 +1,8 @@
+-- +goose Up
+ALTER TABLE threads ADD COLUMN pinned_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_threads_project_archived ON threads(project_id, archived_at);
+
+-- +goose Down
+-- Keeping the pinned_at column if present.
+DROP INDEX IF EXISTS idx_threads_project_archived;
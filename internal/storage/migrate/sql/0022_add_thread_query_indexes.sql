@@ -0,0 +1,14 @@
+-- +goose Up
+-- Thread queries sort by these expressions, per project or across projects.
+CREATE INDEX IF NOT EXISTS idx_threads_project_activity ON threads(project_id, COALESCE(last_message_at, updated_at), id);
+CREATE INDEX IF NOT EXISTS idx_threads_project_created ON threads(project_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_threads_project_title ON threads(project_id, lower(title), id);
+CREATE INDEX IF NOT EXISTS idx_threads_activity ON threads(COALESCE(last_message_at, updated_at), id);
+CREATE INDEX IF NOT EXISTS idx_threads_status ON threads(status);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_threads_project_activity;
+DROP INDEX IF EXISTS idx_threads_project_created;
+DROP INDEX IF EXISTS idx_threads_project_title;
+DROP INDEX IF EXISTS idx_threads_activity;
+DROP INDEX IF EXISTS idx_threads_status;
@@ -0,0 +1,55 @@
+-- +goose Up
+-- Agent items are stored once per turn under item_key, "<user entry id>:<item id>",
+-- instead of once per started/updated/completed event.
+ALTER TABLE thread_entries ADD COLUMN item_key TEXT;
+ALTER TABLE thread_entries ADD COLUMN completed_at TIMESTAMP;
+
+-- A turn's items follow its user entry; items before the first user entry
+-- belong to turn 0.
+UPDATE thread_entries
+SET item_key = COALESCE((
+        SELECT MAX(u.id) FROM thread_entries u
+        WHERE u.thread_id = thread_entries.thread_id AND u.role = 'user' AND u.id < thread_entries.id
+    ), 0) || ':' || json_extract(payload, '$.id')
+WHERE role = 'agent' AND json_valid(payload) AND COALESCE(json_extract(payload, '$.id'), '') <> '';
+
+-- Each item keeps its first row, so its place in the timeline, and takes the
+-- type, payload and update time of its last row. Items of past turns are
+-- finished, so the last update is their completion time.
+CREATE TEMP TABLE folded_items AS
+SELECT thread_id, item_key, MIN(id) AS keep_id, MAX(id) AS latest_id
+FROM thread_entries
+WHERE item_key IS NOT NULL
+GROUP BY thread_id, item_key;
+
+CREATE TEMP TABLE folded_entries AS
+SELECT e.id, f.keep_id
+FROM thread_entries e
+JOIN folded_items f ON f.thread_id = e.thread_id AND f.item_key = e.item_key
+WHERE e.id <> f.keep_id;
+
+UPDATE thread_entries
+SET entry_type = (SELECT l.entry_type FROM folded_items f JOIN thread_entries l ON l.id = f.latest_id WHERE f.keep_id = thread_entries.id),
+    payload = (SELECT l.payload FROM folded_items f JOIN thread_entries l ON l.id = f.latest_id WHERE f.keep_id = thread_entries.id),
+    updated_at = (SELECT l.updated_at FROM folded_items f JOIN thread_entries l ON l.id = f.latest_id WHERE f.keep_id = thread_entries.id),
+    completed_at = (SELECT l.updated_at FROM folded_items f JOIN thread_entries l ON l.id = f.latest_id WHERE f.keep_id = thread_entries.id)
+WHERE id IN (SELECT keep_id FROM folded_items);
+
+UPDATE threads
+SET forked_from_entry_id = (SELECT keep_id FROM folded_entries WHERE folded_entries.id = threads.forked_from_entry_id)
+WHERE forked_from_entry_id IN (SELECT id FROM folded_entries);
+
+UPDATE thread_commits
+SET agent_entry_id = (SELECT keep_id FROM folded_entries WHERE folded_entries.id = thread_commits.agent_entry_id)
+WHERE agent_entry_id IN (SELECT id FROM folded_entries);
+
+DELETE FROM thread_entries WHERE id IN (SELECT id FROM folded_entries);
+
+DROP TABLE folded_entries;
+DROP TABLE folded_items;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_thread_entries_item ON thread_entries(thread_id, item_key) WHERE item_key IS NOT NULL;
+
+-- +goose Down
+-- No-op: folded entries cannot be split again; keeping the item columns.
+DROP INDEX IF EXISTS idx_thread_entries_item;
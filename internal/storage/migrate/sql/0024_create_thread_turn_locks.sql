@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS thread_turn_locks (
+    thread_id INTEGER PRIMARY KEY REFERENCES threads(id) ON DELETE CASCADE,
+    owner TEXT NOT NULL,
+    expires_at INTEGER NOT NULL
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS thread_turn_locks;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE scheduled_runs ADD COLUMN finished_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE scheduled_runs DROP COLUMN finished_at;
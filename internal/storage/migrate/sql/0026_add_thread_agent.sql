@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE threads ADD COLUMN agent_id TEXT;
+
+-- Earlier threads take the agent of their latest recorded turn.
+UPDATE threads
+SET agent_id = (
+    SELECT u.agent_id FROM turn_usage u
+    WHERE u.thread_id = threads.id AND u.agent_id IS NOT NULL
+    ORDER BY u.id DESC LIMIT 1
+);
+
+-- +goose Down
+ALTER TABLE threads DROP COLUMN agent_id;
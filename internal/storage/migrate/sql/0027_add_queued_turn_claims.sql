@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE thread_turn_queue ADD COLUMN claimed_until INTEGER;
+
+-- +goose Down
+ALTER TABLE thread_turn_queue DROP COLUMN claimed_until;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE thread_turn_locks ADD COLUMN cancel_requested INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE thread_turn_locks DROP COLUMN cancel_requested;
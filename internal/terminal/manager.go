@@ -15,6 +15,7 @@ import (
 	"syscall"
 
     "codex-ui/internal/agents"
+    "codex-ui/internal/events"
     "codex-ui/internal/logging"
 
 	"github.com/creack/pty"
@@ -33,6 +34,7 @@ type Manager struct {
     terms map[int64]*session
     shell string
     logger logging.Logger
+    hub   *events.Hub
 }
 
 type session struct {
@@ -53,6 +55,14 @@ func NewManager(agent *agents.Service, ctxProvider func() context.Context, shell
     return &Manager{agent: agent, ctxFn: ctxProvider, terms: map[int64]*session{}, shell: shellPath, logger: logger}
 }
 
+// SetEventHub routes terminal events through hub instead of emitting to the
+// Wails runtime directly.
+func (m *Manager) SetEventHub(hub *events.Hub) {
+	m.mu.Lock()
+	m.hub = hub
+	m.mu.Unlock()
+}
+
 func (m *Manager) Start(threadID int64) error {
 	if m.agent == nil {
 		return fmt.Errorf("agent service not initialised")
@@ -218,15 +228,22 @@ func (m *Manager) emitOutput(threadID int64, data []byte) {
     m.emitEvent(threadID, eventOutput, enc, "")
 }
 func (m *Manager) emitEvent(threadID int64, typ, data, status string) {
-	if m.ctxFn == nil {
-		return
-	}
 	payload := struct {
 		ThreadID int64  `json:"threadId"`
 		Type     string `json:"type"`
 		Data     string `json:"data,omitempty"`
 		Status   string `json:"status,omitempty"`
 	}{threadID, typ, data, status}
+	m.mu.Lock()
+	hub := m.hub
+	m.mu.Unlock()
+	if hub != nil {
+		hub.Emit(agents.TerminalTopic(threadID), payload)
+		return
+	}
+	if m.ctxFn == nil {
+		return
+	}
 	ctx := m.ctxFn()
 	if ctx == nil {
 		return
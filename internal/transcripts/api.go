@@ -0,0 +1,31 @@
+package transcripts
+
+import (
+	"context"
+	"fmt"
+
+	"codex-ui/internal/agents"
+)
+
+// API exposes transcript export and import to the frontend via Wails binding.
+type API struct {
+	s *Service
+}
+
+func NewAPI(s *Service) *API { return &API{s: s} }
+
+// ExportThread renders a thread as markdown, html or json.
+func (a *API) ExportThread(threadID int64, format string) (ExportDTO, error) {
+	if a.s == nil {
+		return ExportDTO{}, fmt.Errorf("transcripts not initialised")
+	}
+	return a.s.Export(context.Background(), threadID, format)
+}
+
+// ImportThread stores a JSON archive as a new thread of the project.
+func (a *API) ImportThread(projectID int64, archive string, readOnly bool) (agents.ThreadDTO, error) {
+	if a.s == nil {
+		return agents.ThreadDTO{}, fmt.Errorf("transcripts not initialised")
+	}
+	return a.s.Import(context.Background(), projectID, []byte(archive), readOnly)
+}
@@ -0,0 +1,63 @@
+package transcripts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"codex-ui/internal/agents"
+)
+
+// ArchiveKind identifies codex-ui thread archives; ArchiveVersion is the
+// newest archive layout this build reads and the one it writes.
+const (
+	ArchiveKind    = "codex-ui.thread"
+	ArchiveVersion = 1
+)
+
+// Archive is the JSON export of a thread and its conversation.
+type Archive struct {
+	Kind       string                        `json:"kind"`
+	Version    int                           `json:"version"`
+	ExportedAt string                        `json:"exportedAt"`
+	Thread     agents.ThreadDTO              `json:"thread"`
+	Entries    []agents.ConversationEntryDTO `json:"entries"`
+}
+
+func NewArchive(thread agents.ThreadDTO, entries []agents.ConversationEntryDTO, now time.Time) Archive {
+	if entries == nil {
+		entries = []agents.ConversationEntryDTO{}
+	}
+	return Archive{
+		Kind:       ArchiveKind,
+		Version:    ArchiveVersion,
+		ExportedAt: now.UTC().Format(time.RFC3339),
+		Thread:     thread,
+		Entries:    entries,
+	}
+}
+
+// Marshal encodes the archive as indented JSON.
+func (a Archive) Marshal() ([]byte, error) {
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encode archive: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// ParseArchive decodes an archive written by this or an older version.
+func ParseArchive(data []byte) (Archive, error) {
+	var archive Archive
+	if err := json.Unmarshal(data, &archive); err != nil {
+		return Archive{}, fmt.Errorf("decode archive: %w", err)
+	}
+	if archive.Kind != ArchiveKind {
+		return Archive{}, errors.New("not a codex-ui thread archive")
+	}
+	if archive.Version < 1 || archive.Version > ArchiveVersion {
+		return Archive{}, fmt.Errorf("unsupported archive version %d (this build reads up to %d)", archive.Version, ArchiveVersion)
+	}
+	return archive, nil
+}
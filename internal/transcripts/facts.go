@@ -0,0 +1,75 @@
+package transcripts
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"codex-ui/internal/agents"
+)
+
+type fact struct {
+	Label string
+	Value string
+}
+
+// threadFacts lists the thread details shown under the transcript title.
+func threadFacts(thread agents.ThreadDTO) []fact {
+	var facts []fact
+	add := func(label, value string) {
+		if value = strings.TrimSpace(value); value != "" {
+			facts = append(facts, fact{label, value})
+		}
+	}
+	add("Model", thread.Model)
+	add("Reasoning", thread.ReasoningLevel)
+	add("Sandbox", thread.SandboxMode)
+	add("Status", thread.Status)
+	add("Branch", thread.BranchName)
+	add("Pull request", thread.PRURL)
+	add("Created", thread.CreatedAt)
+	if thread.LastMessageAt != nil {
+		add("Last message", *thread.LastMessageAt)
+	}
+	return facts
+}
+
+// entryUsage reads the token counts of a usage system entry.
+func entryUsage(entry agents.ConversationEntryDTO) (agents.UsageDTO, bool) {
+	count := func(key string) (int, bool) {
+		switch v := entry.Meta[key].(type) {
+		case float64:
+			return int(v), true
+		case int:
+			return v, true
+		case int64:
+			return int(v), true
+		}
+		return 0, false
+	}
+	input, ok := count("inputTokens")
+	if !ok {
+		return agents.UsageDTO{}, false
+	}
+	cached, _ := count("cachedInputTokens")
+	output, _ := count("outputTokens")
+	return agents.UsageDTO{InputTokens: input, CachedInputTokens: cached, OutputTokens: output}, true
+}
+
+func formatUsage(usage agents.UsageDTO) string {
+	return fmt.Sprintf("in %d (cached %d) / out %d tokens", usage.InputTokens, usage.CachedInputTokens, usage.OutputTokens)
+}
+
+// prettyJSON indents a JSON value for display, or returns it unchanged when it
+// does not parse.
+func prettyJSON(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var b bytes.Buffer
+	if err := json.Indent(&b, raw, "", "  "); err != nil {
+		return string(raw)
+	}
+	return b.String()
+}
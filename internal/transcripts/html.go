@@ -0,0 +1,167 @@
+package transcripts
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+
+	"codex-ui/internal/agents"
+)
+
+// htmlBlock is one rendered timeline entry.
+type htmlBlock struct {
+	Kind   string // message, reasoning, command, list, note, usage
+	Class  string
+	Label  string
+	Time   string
+	Text   string
+	Code   string
+	Output string
+	Items  []htmlListItem
+}
+
+type htmlListItem struct {
+	Text string
+	Code string
+	Todo bool
+	Done bool
+}
+
+var htmlTemplate = template.Must(template.New("transcript").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font: 15px/1.5 -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; max-width: 52rem; margin: 2rem auto; padding: 0 1rem; color: #1f2328; }
+h1 { font-size: 1.6rem; margin-bottom: .5rem; }
+dl.facts { display: grid; grid-template-columns: max-content 1fr; gap: .1rem 1rem; color: #59636e; margin: 0 0 2rem; }
+dl.facts dt { font-weight: 600; }
+dl.facts dd { margin: 0; }
+section { margin: 1rem 0; padding: .75rem 1rem; border-radius: 8px; border: 1px solid #d1d9e0; }
+section.user { background: #f0f6ff; }
+section.reasoning { color: #59636e; font-style: italic; }
+section.note, section.usage { border-style: dashed; color: #59636e; font-size: .9rem; }
+section.error { border-color: #cf222e; color: #cf222e; }
+section.warning { border-color: #9a6700; color: #9a6700; }
+header { font-size: .8rem; font-weight: 600; color: #59636e; margin-bottom: .4rem; }
+header time { font-weight: normal; margin-left: .5rem; }
+.text { white-space: pre-wrap; }
+pre { background: #f6f8fa; padding: .6rem; border-radius: 6px; overflow-x: auto; white-space: pre-wrap; }
+ul { margin: 0; padding-left: 1.2rem; }
+ul.todos { list-style: none; padding-left: 0; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<dl class="facts">{{range .Facts}}<dt>{{.Label}}</dt><dd>{{.Value}}</dd>{{end}}</dl>
+{{range .Blocks}}<section class="{{.Kind}} {{.Class}}">
+<header>{{.Label}}{{if .Time}}<time>{{.Time}}</time>{{end}}</header>
+{{if .Text}}<div class="text">{{.Text}}</div>{{end}}
+{{if .Code}}<pre><code>{{.Code}}</code></pre>{{end}}
+{{if .Output}}<pre><samp>{{.Output}}</samp></pre>{{end}}
+{{if .Items}}<ul{{if (index .Items 0).Todo}} class="todos"{{end}}>{{range .Items}}<li>{{if .Todo}}{{if .Done}}☑{{else}}☐{{end}} {{end}}{{if .Code}}<code>{{.Code}}</code> {{end}}{{.Text}}</li>{{end}}</ul>{{end}}
+</section>
+{{end}}</body>
+</html>
+`))
+
+// RenderHTML renders a thread's timeline as a standalone HTML page with inline
+// styles and no external resources.
+func RenderHTML(thread agents.ThreadDTO, entries []agents.ConversationEntryDTO) (string, error) {
+	blocks := make([]htmlBlock, 0, len(entries))
+	for _, entry := range entries {
+		if block, ok := htmlEntryBlock(entry); ok {
+			blocks = append(blocks, block)
+		}
+	}
+	var b strings.Builder
+	err := htmlTemplate.Execute(&b, struct {
+		Title  string
+		Facts  []fact
+		Blocks []htmlBlock
+	}{strings.TrimSpace(thread.Title), threadFacts(thread), blocks})
+	if err != nil {
+		return "", fmt.Errorf("render html transcript: %w", err)
+	}
+	return b.String(), nil
+}
+
+func htmlEntryBlock(entry agents.ConversationEntryDTO) (htmlBlock, bool) {
+	switch entry.Role {
+	case "user":
+		block := htmlBlock{Kind: "message", Class: "user", Label: "User", Time: entry.CreatedAt, Text: strings.TrimSpace(entry.Text)}
+		for _, segment := range entry.Segments {
+			if segment.ImagePath != "" {
+				block.Items = append(block.Items, htmlListItem{Text: "attached image", Code: segment.ImagePath})
+			}
+		}
+		return block, true
+	case "system":
+		if usage, ok := entryUsage(entry); ok {
+			return htmlBlock{Kind: "usage", Label: "Usage", Text: formatUsage(usage)}, true
+		}
+		label := "Note"
+		switch entry.Tone {
+		case "error":
+			label = "Error"
+		case "warning":
+			label = "Warning"
+		}
+		return htmlBlock{Kind: "note", Class: entry.Tone, Label: label, Time: entry.CreatedAt, Text: strings.TrimSpace(entry.Message)}, true
+	case "agent":
+		return htmlItemBlock(entry)
+	}
+	return htmlBlock{}, false
+}
+
+func htmlItemBlock(entry agents.ConversationEntryDTO) (htmlBlock, bool) {
+	item := entry.Item
+	if item == nil {
+		return htmlBlock{}, false
+	}
+	block := htmlBlock{Time: entry.CreatedAt}
+	switch {
+	case item.Command != nil:
+		block.Kind, block.Label = "command", "Command · "+item.Command.Status
+		if item.Command.ExitCode != nil {
+			block.Label = fmt.Sprintf("Command · exit %d", *item.Command.ExitCode)
+		}
+		block.Code = item.Command.Command
+		block.Output = strings.TrimRight(item.Command.AggregatedOutput, "\n")
+	case len(item.FileDiffs) > 0:
+		block.Kind, block.Label = "list", "Files changed"
+		for _, change := range item.FileDiffs {
+			block.Items = append(block.Items, htmlListItem{Code: change.Path, Text: change.Kind})
+		}
+	case item.TodoList != nil:
+		block.Kind, block.Label = "list", "Plan"
+		for _, todo := range item.TodoList.Items {
+			block.Items = append(block.Items, htmlListItem{Text: todo.Text, Todo: true, Done: todo.Completed})
+		}
+	case item.ToolCall != nil:
+		block.Kind, block.Label = "note", "Tool call · "+item.ToolCall.Status
+		block.Code = item.ToolCall.Server + "/" + item.ToolCall.Tool
+	case item.WebSearch != nil:
+		block.Kind, block.Label, block.Text = "note", "Web search", item.WebSearch.Query
+	case item.Error != nil:
+		block.Kind, block.Class, block.Label, block.Text = "note", "error", "Error", item.Error.Message
+	case item.StructuredOutput != nil:
+		block.Kind, block.Label = "command", "Structured output · matches schema"
+		if !item.StructuredOutput.Valid {
+			block.Class, block.Label = "error", "Structured output · does not match schema"
+		}
+		block.Code = prettyJSON(item.StructuredOutput.Output)
+		for _, violation := range item.StructuredOutput.Violations {
+			block.Items = append(block.Items, htmlListItem{Text: violation})
+		}
+	case strings.TrimSpace(item.Reasoning) != "":
+		block.Kind, block.Label, block.Text = "reasoning", "Reasoning", strings.TrimSpace(item.Reasoning)
+	case strings.TrimSpace(item.Text) != "":
+		block.Kind, block.Class, block.Label, block.Text = "message", "assistant", "Assistant", strings.TrimSpace(item.Text)
+	default:
+		return htmlBlock{}, false
+	}
+	return block, true
+}
@@ -0,0 +1,131 @@
+package transcripts
+
+import (
+	"fmt"
+	"strings"
+
+	"codex-ui/internal/agents"
+)
+
+// RenderMarkdown renders a thread's timeline as a Markdown document.
+func RenderMarkdown(thread agents.ThreadDTO, entries []agents.ConversationEntryDTO) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", strings.TrimSpace(thread.Title))
+	for _, fact := range threadFacts(thread) {
+		fmt.Fprintf(&b, "- **%s:** %s\n", fact.Label, fact.Value)
+	}
+	for _, entry := range entries {
+		b.WriteString("\n")
+		writeMarkdownEntry(&b, entry)
+	}
+	return b.String()
+}
+
+func writeMarkdownEntry(b *strings.Builder, entry agents.ConversationEntryDTO) {
+	switch entry.Role {
+	case "user":
+		fmt.Fprintf(b, "## User · %s\n\n", entry.CreatedAt)
+		if text := strings.TrimSpace(entry.Text); text != "" {
+			b.WriteString(text + "\n")
+		}
+		for _, segment := range entry.Segments {
+			if segment.Type == "image" || segment.ImagePath != "" {
+				fmt.Fprintf(b, "\n_Attached image: `%s`_\n", segment.ImagePath)
+			}
+		}
+	case "agent":
+		writeMarkdownItem(b, entry)
+	case "system":
+		if usage, ok := entryUsage(entry); ok {
+			fmt.Fprintf(b, "_Usage: %s_\n", formatUsage(usage))
+			return
+		}
+		label := "Note"
+		switch entry.Tone {
+		case "error":
+			label = "Error"
+		case "warning":
+			label = "Warning"
+		}
+		fmt.Fprintf(b, "> **%s:** %s\n", label, strings.TrimSpace(entry.Message))
+	}
+}
+
+func writeMarkdownItem(b *strings.Builder, entry agents.ConversationEntryDTO) {
+	item := entry.Item
+	if item == nil {
+		return
+	}
+	switch {
+	case item.Command != nil:
+		status := item.Command.Status
+		if item.Command.ExitCode != nil {
+			status = fmt.Sprintf("exit %d", *item.Command.ExitCode)
+		}
+		fmt.Fprintf(b, "**Command** (%s)\n\n", status)
+		b.WriteString(fence("sh", item.Command.Command))
+		if output := strings.TrimRight(item.Command.AggregatedOutput, "\n"); output != "" {
+			b.WriteString("\n" + fence("text", output))
+		}
+	case len(item.FileDiffs) > 0:
+		b.WriteString("**Files changed**\n\n")
+		for _, change := range item.FileDiffs {
+			fmt.Fprintf(b, "- `%s` (%s)\n", change.Path, change.Kind)
+		}
+	case item.TodoList != nil:
+		b.WriteString("**Plan**\n\n")
+		for _, todo := range item.TodoList.Items {
+			mark := " "
+			if todo.Completed {
+				mark = "x"
+			}
+			fmt.Fprintf(b, "- [%s] %s\n", mark, todo.Text)
+		}
+	case item.ToolCall != nil:
+		fmt.Fprintf(b, "**Tool call** `%s/%s` (%s)\n", item.ToolCall.Server, item.ToolCall.Tool, item.ToolCall.Status)
+	case item.WebSearch != nil:
+		fmt.Fprintf(b, "**Web search:** %s\n", item.WebSearch.Query)
+	case item.Error != nil:
+		fmt.Fprintf(b, "> **Error:** %s\n", item.Error.Message)
+	case item.StructuredOutput != nil:
+		out := item.StructuredOutput
+		if out.Valid {
+			b.WriteString("**Structured output** (matches schema)\n")
+		} else {
+			b.WriteString("**Structured output** (does not match schema)\n")
+		}
+		if len(out.Output) > 0 {
+			b.WriteString("\n" + fence("json", prettyJSON(out.Output)))
+		}
+		for _, violation := range out.Violations {
+			fmt.Fprintf(b, "\n- %s", violation)
+		}
+		if len(out.Violations) > 0 {
+			b.WriteString("\n")
+		}
+	case strings.TrimSpace(item.Reasoning) != "":
+		b.WriteString("**Reasoning**\n\n")
+		for _, line := range strings.Split(strings.TrimSpace(item.Reasoning), "\n") {
+			b.WriteString(strings.TrimRight("> "+line, " ") + "\n")
+		}
+	case strings.TrimSpace(item.Text) != "":
+		fmt.Fprintf(b, "## Assistant · %s\n\n%s\n", entry.CreatedAt, strings.TrimSpace(item.Text))
+	}
+}
+
+// fence wraps content in a code fence longer than any backtick run inside it.
+func fence(lang, content string) string {
+	longest, run := 0, 0
+	for _, r := range content {
+		if r == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	marker := strings.Repeat("`", max(3, longest+1))
+	return marker + lang + "\n" + content + "\n" + marker + "\n"
+}
@@ -0,0 +1,84 @@
+// Package transcripts renders thread conversations as Markdown, self-contained
+// HTML or a versioned JSON archive, and imports archives back into a catalog.
+package transcripts
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"codex-ui/internal/agents"
+	"codex-ui/internal/git/worktrees"
+)
+
+// Export formats accepted by Export.
+const (
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+	FormatJSON     = "json"
+)
+
+// ExportDTO is a rendered transcript ready to be saved as FileName.
+type ExportDTO struct {
+	FileName string `json:"fileName"`
+	MimeType string `json:"mimeType"`
+	Content  string `json:"content"`
+}
+
+// Service exports and imports thread transcripts through the agent service.
+type Service struct {
+	agents *agents.Service
+	now    func() time.Time
+}
+
+func New(svc *agents.Service) *Service {
+	return &Service{agents: svc, now: time.Now}
+}
+
+// Export renders a thread in format (markdown, html or json).
+func (s *Service) Export(ctx context.Context, threadID int64, format string) (ExportDTO, error) {
+	thread, err := s.agents.GetThread(ctx, threadID)
+	if err != nil {
+		return ExportDTO{}, err
+	}
+	entries, err := s.agents.LoadThreadConversation(ctx, threadID)
+	if err != nil {
+		return ExportDTO{}, err
+	}
+	base := worktrees.DirSuffix(thread.Title, thread.ID)
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case FormatMarkdown, "md":
+		return ExportDTO{FileName: base + ".md", MimeType: "text/markdown", Content: RenderMarkdown(thread, entries)}, nil
+	case FormatHTML:
+		content, err := RenderHTML(thread, entries)
+		if err != nil {
+			return ExportDTO{}, err
+		}
+		return ExportDTO{FileName: base + ".html", MimeType: "text/html", Content: content}, nil
+	case FormatJSON:
+		content, err := NewArchive(thread, entries, s.now()).Marshal()
+		if err != nil {
+			return ExportDTO{}, err
+		}
+		return ExportDTO{FileName: base + ".json", MimeType: "application/json", Content: string(content)}, nil
+	default:
+		return ExportDTO{}, fmt.Errorf("unknown export format %q (use markdown, html or json)", format)
+	}
+}
+
+// Import stores a JSON archive as a new thread of the project. Read-only
+// threads refuse new turns; otherwise the next turn replays the transcript to
+// the agent.
+func (s *Service) Import(ctx context.Context, projectID int64, data []byte, readOnly bool) (agents.ThreadDTO, error) {
+	archive, err := ParseArchive(data)
+	if err != nil {
+		return agents.ThreadDTO{}, err
+	}
+	return s.agents.ImportThread(ctx, agents.ImportThreadRequest{
+		ProjectID: projectID,
+		Thread:    archive.Thread,
+		Entries:   archive.Entries,
+		ReadOnly:  readOnly,
+	})
+}
@@ -0,0 +1,215 @@
+package transcripts
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"codex-ui/internal/agents"
+	"codex-ui/internal/storage/discovery"
+	"codex-ui/internal/storage/migrate"
+
+	_ "modernc.org/sqlite"
+)
+
+// recordingAdapter completes every turn immediately and records the prompts.
+type recordingAdapter struct {
+	mu     sync.Mutex
+	inputs []string
+}
+
+func (a *recordingAdapter) Stream(ctx context.Context, req agents.MessageRequest) (*agents.StreamResult, error) {
+	a.mu.Lock()
+	a.inputs = append(a.inputs, req.Input)
+	a.mu.Unlock()
+	events := make(chan agents.StreamEvent)
+	done := make(chan error, 1)
+	close(events)
+	done <- nil
+	close(done)
+	return &agents.StreamResult{Events: events, Done: done}, nil
+}
+
+func newTestService(t *testing.T, adapter agents.Adapter) (*Service, *discovery.Repository, discovery.Project) {
+	t.Helper()
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", strings.ReplaceAll(t.Name(), "/", "_"))
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatalf("open in-memory database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	if err := migrate.Up(db); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	repo := discovery.NewRepository(db)
+	project, err := repo.UpsertProject(context.Background(), discovery.UpsertProjectParams{Path: "/tmp/" + t.Name()})
+	if err != nil {
+		t.Fatalf("upsert project: %v", err)
+	}
+	svc := agents.NewService("fake", repo)
+	if err := svc.Register("fake", adapter); err != nil {
+		t.Fatalf("register adapter: %v", err)
+	}
+	s := New(svc)
+	s.now = func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) }
+	return s, repo, project
+}
+
+// seedThread stores a thread covering every kind of timeline entry.
+func seedThread(t *testing.T, repo *discovery.Repository, projectID int64) discovery.Thread {
+	t.Helper()
+	ctx := context.Background()
+	thread, err := repo.CreateThread(ctx, discovery.CreateThreadParams{ProjectID: projectID, Title: "Fix OAuth refresh", Model: "gpt-5.1-codex", ReasoningLevel: "high"})
+	if err != nil {
+		t.Fatalf("create thread: %v", err)
+	}
+	base := time.Date(2024, 4, 30, 9, 0, 0, 0, time.UTC)
+	payloads := []struct{ role, entryType, payload string }{
+		{"user", "user_message", `{"text":"Why does <refresh> fail?"}`},
+		{"agent", "reasoning", `{"id":"r1","type":"reasoning","reasoning":"Check the token expiry."}`},
+		{"agent", "command_execution", "{\"id\":\"c1\",\"type\":\"command_execution\",\"command\":{\"command\":\"go test ./auth\",\"aggregatedOutput\":\"```\\nFAIL TestRefresh\\n\",\"exitCode\":1,\"status\":\"completed\"}}"},
+		{"agent", "file_change", `{"id":"f1","type":"file_change","fileDiffs":[{"path":"auth/refresh.go","kind":"update","status":"completed"}]}`},
+		{"agent", "todo_list", `{"id":"t1","type":"todo_list","todoList":{"items":[{"text":"Retry expired tokens","completed":true},{"text":"Add test","completed":false}]}}`},
+		{"agent", "agent_message", `{"id":"m1","type":"agent_message","text":"Fixed: expired tokens are now retried."}`},
+		{"system", "system_message", `{"tone":"info","message":"Usage · in 120 / out 30","meta":{"inputTokens":120,"cachedInputTokens":20,"outputTokens":30}}`},
+		{"system", "system_message", `{"tone":"warning","message":"Turn stopped: budget"}`},
+	}
+	for i, p := range payloads {
+		at := base.Add(time.Duration(i) * time.Minute)
+		if _, err := repo.CreateConversationEntry(ctx, discovery.CreateConversationEntryParams{
+			ThreadID: thread.ID, Role: p.role, EntryType: p.entryType, Payload: []byte(p.payload), CreatedAt: at,
+		}); err != nil {
+			t.Fatalf("create entry %d: %v", i, err)
+		}
+	}
+	return thread
+}
+
+func TestExportMarkdownAndHTML(t *testing.T) {
+	s, repo, project := newTestService(t, &recordingAdapter{})
+	thread := seedThread(t, repo, project.ID)
+	ctx := context.Background()
+
+	md, err := s.Export(ctx, thread.ID, "md")
+	if err != nil {
+		t.Fatalf("export markdown: %v", err)
+	}
+	if md.FileName != fmt.Sprintf("fix-oauth-refresh-%d.md", thread.ID) {
+		t.Fatalf("unexpected file name %q", md.FileName)
+	}
+	for _, want := range []string{
+		"# Fix OAuth refresh\n",
+		"- **Model:** gpt-5.1-codex\n",
+		"## User · 2024-04-30T09:00:00Z\n\nWhy does <refresh> fail?\n",
+		"> Check the token expiry.\n",
+		"**Command** (exit 1)\n\n```sh\ngo test ./auth\n```\n\n````text\n```\nFAIL TestRefresh\n````\n",
+		"- `auth/refresh.go` (update)\n",
+		"- [x] Retry expired tokens\n- [ ] Add test\n",
+		"## Assistant · 2024-04-30T09:05:00Z\n\nFixed: expired tokens are now retried.\n",
+		"_Usage: in 120 (cached 20) / out 30 tokens_\n",
+		"> **Warning:** Turn stopped: budget\n",
+	} {
+		if !strings.Contains(md.Content, want) {
+			t.Errorf("markdown missing %q\n%s", want, md.Content)
+		}
+	}
+
+	page, err := s.Export(ctx, thread.ID, FormatHTML)
+	if err != nil {
+		t.Fatalf("export html: %v", err)
+	}
+	for _, want := range []string{
+		"<title>Fix OAuth refresh</title>",
+		"Why does &lt;refresh&gt; fail?",
+		"<pre><code>go test ./auth</code></pre>",
+		"Command · exit 1",
+		"☑ Retry expired tokens",
+		"<code>auth/refresh.go</code> update",
+		`<section class="note warning">`,
+	} {
+		if !strings.Contains(page.Content, want) {
+			t.Errorf("html missing %q", want)
+		}
+	}
+	if strings.Contains(page.Content, "<refresh>") || strings.Contains(page.Content, "<script") || strings.Contains(page.Content, "href=") {
+		t.Error("html must escape content and not load external resources")
+	}
+
+	if _, err := s.Export(ctx, thread.ID, "pdf"); err == nil {
+		t.Fatal("expected unknown format error")
+	}
+}
+
+func TestExportJSONRoundTripsThroughImport(t *testing.T) {
+	adapter := &recordingAdapter{}
+	s, repo, project := newTestService(t, adapter)
+	source := seedThread(t, repo, project.ID)
+	ctx := context.Background()
+
+	archive, err := s.Export(ctx, source.ID, FormatJSON)
+	if err != nil {
+		t.Fatalf("export json: %v", err)
+	}
+	if !strings.Contains(archive.Content, `"kind": "codex-ui.thread"`) || !strings.Contains(archive.Content, `"version": 1`) {
+		t.Fatalf("unexpected archive header:\n%s", archive.Content[:200])
+	}
+
+	other, err := repo.UpsertProject(ctx, discovery.UpsertProjectParams{Path: "/tmp/other-install"})
+	if err != nil {
+		t.Fatalf("upsert project: %v", err)
+	}
+	readOnly, err := s.Import(ctx, other.ID, []byte(archive.Content), true)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if !readOnly.ReadOnly || readOnly.ImportedAt == nil || readOnly.ProjectID != other.ID || readOnly.Title != "Fix OAuth refresh" || readOnly.Model != "gpt-5.1-codex" {
+		t.Fatalf("unexpected imported thread %+v", readOnly)
+	}
+	original, _ := s.agents.LoadThreadConversation(ctx, source.ID)
+	imported, _ := s.agents.LoadThreadConversation(ctx, readOnly.ID)
+	if len(imported) != len(original) {
+		t.Fatalf("expected %d entries, got %d", len(original), len(imported))
+	}
+	for i := range original {
+		a, b := original[i], imported[i]
+		a.ID, b.ID = "", ""
+		if !reflect.DeepEqual(a, b) {
+			t.Fatalf("entry %d differs:\n%+v\n%+v", i, a, b)
+		}
+	}
+	if _, _, err := s.agents.Send(ctx, agents.MessageRequest{ThreadID: readOnly.ID, Input: "continue"}); !errors.Is(err, agents.ErrThreadReadOnly) {
+		t.Fatalf("expected read-only error, got %v", err)
+	}
+
+	resumable, err := s.Import(ctx, other.ID, []byte(archive.Content), false)
+	if err != nil {
+		t.Fatalf("import resumable: %v", err)
+	}
+	stream, _, err := s.agents.Send(ctx, agents.MessageRequest{ThreadID: resumable.ID, Input: "continue"})
+	if err != nil {
+		t.Fatalf("send to imported thread: %v", err)
+	}
+	for range stream.Events() {
+	}
+	_ = stream.Wait()
+	if len(adapter.inputs) != 1 || !strings.Contains(adapter.inputs[0], "imported from an earlier conversation") ||
+		!strings.Contains(adapter.inputs[0], "Assistant: Fixed: expired tokens are now retried.") {
+		t.Fatalf("expected the transcript to be replayed, got %q", adapter.inputs)
+	}
+}
+
+func TestParseArchiveRejectsUnknownVersions(t *testing.T) {
+	if _, err := ParseArchive([]byte(`{"kind":"codex-ui.thread","version":2}`)); err == nil || !strings.Contains(err.Error(), "version 2") {
+		t.Fatalf("expected version error, got %v", err)
+	}
+	if _, err := ParseArchive([]byte(`{"version":1}`)); err == nil {
+		t.Fatal("expected kind error")
+	}
+}
@@ -3,9 +3,12 @@ package main
 import (
     "context"
     "embed"
+    "io"
     "log"
+    "os"
     "path/filepath"
     "log/slog"
+    "time"
 
     "github.com/wailsapp/wails/v2"
     "github.com/wailsapp/wails/v2/pkg/options"
@@ -14,12 +17,18 @@ import (
 
 	"codex-ui/internal/agents"
 	"codex-ui/internal/attachments"
+	"codex-ui/internal/cli"
+	"codex-ui/internal/events"
 	"codex-ui/internal/projects"
+	"codex-ui/internal/scheduler"
+	"codex-ui/internal/server"
+	"codex-ui/internal/sessions"
 	"codex-ui/internal/storage"
 	"codex-ui/internal/storage/discovery"
 	"codex-ui/internal/storage/migrate"
 	"codex-ui/internal/storage/sqlite"
     term "codex-ui/internal/terminal"
+	"codex-ui/internal/transcripts"
     "codex-ui/internal/ui"
     "codex-ui/internal/watchers"
     "codex-ui/internal/logging"
@@ -50,25 +59,83 @@ func main() {
     repo := discovery.NewRepository(db)
     app.db = db
     app.repo = repo
-    // Logger (text slog by default)
-    logger := logging.NewText(nil, slog.LevelInfo)
-    app.projectService = projects.NewService(repo, logger)
-    agentService, err := agents.BootstrapService(dataDir, repo)
+    cliMode := len(os.Args) > 1 && os.Args[1] == "cli"
+    // Logger (text slog by default; stderr in CLI mode to keep stdout scriptable)
+    var logOut io.Writer
+    if cliMode {
+        logOut = os.Stderr
+    }
+    logger := logging.NewText(logOut, slog.LevelInfo)
+    // The CLI exits when its own turn ends, so it leaves queued turns to the app.
+    var agentOpts []agents.ServiceOption
+    if cliMode {
+        agentOpts = append(agentOpts, agents.WithoutQueueDispatch())
+    }
+    agentService, err := agents.BootstrapService(dataDir, repo, agentOpts...)
 	if err != nil {
 		log.Fatalf("init agent service: %v", err)
 	}
+    app.projectService = projects.NewService(repo, logger, projects.WithModelDefaults(agentService.StoreProjectModelDefaults))
 	app.agentService = agentService
 
+	// Headless mode: `codex-ui cli <command>` drives the same services from a shell.
+	if cliMode {
+		code := cli.Run(context.Background(), os.Args[2:], cli.Deps{
+			Projects: app.projectService,
+			Agents:   agentService,
+			Repo:     repo,
+			Stdin:    os.Stdin,
+			Stdout:   os.Stdout,
+			Stderr:   os.Stderr,
+		})
+		agentService.StopWorktreeCleanup()
+		_ = db.Close()
+		os.Exit(code)
+	}
+
     // Domain APIs
     projectsAPI := projects.NewAPI(app.projectService, logger)
     watcherSvc := watchers.New(nil)
     watcherSvc.SetLogger(logger)
-    agentsAPI := agents.NewAPI(app.agentService, repo, watcherSvc, app.Context, logger)
+    // Event hub fans runtime events out to the webview and local API clients.
+    hub := events.NewHub(app.Context)
+    agentsAPI := agents.NewAPI(app.agentService, repo, watcherSvc, app.Context, logger, agents.WithEventHub(hub))
     watcherSvc.SetEmitter(agentsAPI.EmitThreadDiffUpdate)
     termMgr := term.NewManager(app.agentService, app.Context, "", logger)
+    termMgr.SetEventHub(hub)
     termAPI := term.NewAPI(termMgr)
     attachAPI := attachments.NewAPI(logger)
     uiAPI := ui.NewAPI(app.Context, logger)
+    // Scheduled jobs fire as background turns; their streams reach the UI
+    // through the agents API's queued-stream handler.
+    jobScheduler := scheduler.New(repo, app.agentService, logger)
+    jobScheduler.Start(time.Minute)
+    schedulerAPI := scheduler.NewAPI(jobScheduler)
+    transcriptsAPI := transcripts.NewAPI(transcripts.New(app.agentService))
+    sessionsAPI := sessions.NewAPI(sessions.New(repo, app.agentService, logger))
+
+    // Optional local HTTP/WebSocket API (CODEX_UI_SERVER_ADDR)
+    var apiServer *server.Server
+    if serverCfg, err := server.ConfigFromEnv(dataDir); err != nil {
+        logger.Error("local api server config", "error", err)
+    } else if serverCfg.Addr != "" {
+        apiServer, err = server.New(serverCfg, hub, logger, map[string]any{
+            "projects":    projectsAPI,
+            "agents":      agentsAPI,
+            "terminal":    termAPI,
+            "attachments": attachAPI,
+            "scheduler":   schedulerAPI,
+            "transcripts": transcriptsAPI,
+            "sessions":    sessionsAPI,
+        })
+        if err == nil {
+            err = apiServer.Start()
+        }
+        if err != nil {
+            logger.Error("start local api server", "error", err)
+            apiServer = nil
+        }
+    }
 
 	// Create application with options
 	err = wails.Run(&options.App{
@@ -85,6 +152,10 @@ func main() {
 		OnStartup:        app.startup,
 		OnShutdown: func(ctx context.Context) {
 			// graceful shutdown of services
+			if apiServer != nil {
+				_ = apiServer.Shutdown(ctx)
+			}
+			jobScheduler.Stop()
 			if app.agentService != nil {
 				app.agentService.StopWorktreeCleanup()
 			}
@@ -98,7 +169,7 @@ func main() {
 				_ = app.db.Close()
 			}
 		},
-		Bind: []interface{}{projectsAPI, agentsAPI, termAPI, attachAPI, uiAPI},
+		Bind: []interface{}{projectsAPI, agentsAPI, termAPI, attachAPI, uiAPI, schedulerAPI, transcriptsAPI, sessionsAPI},
 	})
 
 	if err != nil {